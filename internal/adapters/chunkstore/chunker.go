@@ -0,0 +1,66 @@
+package chunkstore
+
+import (
+	"bufio"
+	"io"
+)
+
+// Content-defined chunking parameters. Chunk boundaries depend only on the
+// bytes around them, so an edit in one part of a file leaves the chunks of
+// the unchanged parts identical and they deduplicate against older versions.
+const (
+	minChunkSize = 16 * 1024  // 16KB - files smaller than this are a single chunk
+	maxChunkSize = 256 * 1024 // 256KB - hard cut even without a boundary
+	chunkMask    = 1<<16 - 1  // ~64KB average chunk size
+)
+
+// gearTable maps each byte to a pseudo-random 64-bit value for the gear hash.
+// It is generated from a fixed seed and must never change, otherwise chunks
+// written by older versions would stop deduplicating against new ones.
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	state := uint64(0x636f646562616b00) // "codebak\x00"
+	for i := range table {
+		// splitmix64
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// splitChunks reads r to EOF and calls emit for each content-defined chunk.
+// The slice passed to emit is reused after emit returns.
+func splitChunks(r io.Reader, emit func(chunk []byte) error) error {
+	br := bufio.NewReaderSize(r, maxChunkSize)
+	buf := make([]byte, 0, maxChunkSize)
+	var hash uint64
+
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		buf = append(buf, b)
+		hash = (hash << 1) + gearTable[b]
+
+		if len(buf) >= maxChunkSize || (len(buf) >= minChunkSize && hash&chunkMask == 0) {
+			if err := emit(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+			hash = 0
+		}
+	}
+
+	if len(buf) > 0 {
+		return emit(buf)
+	}
+	return nil
+}
@@ -0,0 +1,683 @@
+// Package chunkstore provides a deduplicating archiver adapter.
+//
+// Files are split into content-defined chunks which are stored once, keyed by
+// their SHA-256, under a chunks/ directory next to the version indexes. Each
+// backup version is a small JSON index listing the chunks of every file, so
+// unchanged files cost nothing beyond their index entry.
+package chunkstore
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jmcdonald/codebak/internal/atomicfile"
+	"github.com/jmcdonald/codebak/internal/ports"
+)
+
+// IndexExt is the file extension of a chunked backup version index.
+const IndexExt = ".idx"
+
+// ChunksDirName is the directory (next to the indexes) holding chunk data.
+const ChunksDirName = "chunks"
+
+// indexFormatVersion is bumped when the index layout changes incompatibly.
+const indexFormatVersion = 1
+
+// Index describes a single backup version in the chunk store.
+type Index struct {
+	Version int          `json:"version"`
+	Root    string       `json:"root"` // Project directory name, restored as the top-level folder
+	Files   []IndexEntry `json:"files"`
+}
+
+// IndexEntry describes one file of a backup version.
+type IndexEntry struct {
+	Path    string      `json:"path"` // Slash-separated path relative to Root
+	Size    int64       `json:"size"`
+	Mode    os.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mod_time"`
+	CRC32   uint32      `json:"crc32"`
+	SHA256  string      `json:"sha256,omitempty"` // Of the whole file; absent in older indexes
+	Chunks  []string    `json:"chunks"`           // SHA-256 of each chunk, in order
+}
+
+// ChunkStore implements ports.Archiver using a content-addressed chunk store.
+type ChunkStore struct{}
+
+// New creates a new ChunkStore adapter.
+func New() *ChunkStore {
+	return &ChunkStore{}
+}
+
+// IsIndex reports whether path names a chunk store version index.
+func IsIndex(path string) bool {
+	return strings.HasSuffix(path, IndexExt)
+}
+
+// chunksDir returns the chunk directory belonging to an index path.
+func chunksDir(indexPath string) string {
+	return filepath.Join(filepath.Dir(indexPath), ChunksDirName)
+}
+
+// chunkPath returns the path of a chunk, fanned out by the first two hex digits.
+func chunkPath(dir, hash string) string {
+	return filepath.Join(dir, hash[:2], hash)
+}
+
+// excluded reports whether path inside sourceDir should be skipped.
+func excluded(exclude ports.Excluder, sourceDir, path string, isDir bool) bool {
+	if exclude == nil {
+		return false
+	}
+	relPath, err := filepath.Rel(sourceDir, path)
+	if err != nil {
+		return false
+	}
+	return exclude.Excluded(relPath, isDir)
+}
+
+// Create stores sourceDir in the chunk store and writes the version index to destPath.
+// Returns the number of files archived and the files that could not be read.
+// exclude decides which files and directories to skip; nil archives everything.
+// compression sets the deflate level of newly stored chunks; chunks already
+// in the store are reused as they are.
+// progress, if non-nil, is called after each file is stored.
+// Cancelling ctx stops before the next file without writing the index; chunks
+// already stored are kept and reused by the next backup. Unreadable files are
+// left out of the index and reported in the result; errors writing chunks fail
+// the whole call. New chunks are not synced one by one: their directories are
+// synced once, before the index that references them is written.
+func (s *ChunkStore) Create(ctx context.Context, destPath, sourceDir string, exclude ports.Excluder, compression ports.Compression, progress ports.ProgressFunc) (ports.ArchiveResult, error) {
+	var result ports.ArchiveResult
+	dir := chunksDir(destPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return result, fmt.Errorf("creating chunk directory: %w", err)
+	}
+
+	index := Index{
+		Version: indexFormatVersion,
+		Root:    filepath.Base(sourceDir),
+	}
+	chunks := &chunkWriter{dir: dir, level: deflateLevel(compression), dirty: make(map[string]bool)}
+	var bytesDone int64
+
+	skip := func(path string, err error) {
+		relPath, relErr := filepath.Rel(sourceDir, path)
+		if relErr != nil {
+			relPath = path
+		}
+		result.Skipped = append(result.Skipped, ports.SkippedFile{Path: filepath.ToSlash(relPath), Reason: err.Error()})
+	}
+
+	walkErr := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			// Unreadable files and directories are recorded unless excluded anyway
+			if !excluded(exclude, sourceDir, path, info != nil && info.IsDir()) {
+				skip(path, err)
+			}
+			return nil
+		}
+
+		// Check exclusions
+		if excluded(exclude, sourceDir, path, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Only regular files carry content; directories are created implicitly
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return nil
+		}
+
+		entry, err := storeFile(chunks, path)
+		var readErr *readError
+		if errors.As(err, &readErr) {
+			skip(path, readErr.err)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("storing %s: %w", relPath, err)
+		}
+		entry.Path = filepath.ToSlash(relPath)
+		entry.Mode = info.Mode().Perm()
+		entry.ModTime = info.ModTime()
+
+		index.Files = append(index.Files, entry)
+		result.Files = append(result.Files, ports.ArchivedFile{
+			Path:    entry.Path,
+			Size:    entry.Size,
+			SHA256:  entry.SHA256,
+			Mode:    entry.Mode,
+			ModTime: entry.ModTime,
+		})
+		bytesDone += entry.Size
+		if progress != nil {
+			progress(ports.Progress{Files: len(index.Files), Bytes: bytesDone, Path: entry.Path})
+		}
+		return nil
+	})
+	if walkErr != nil {
+		return ports.ArchiveResult{}, walkErr
+	}
+	chunks.sync()
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return ports.ArchiveResult{}, fmt.Errorf("encoding index: %w", err)
+	}
+	if err := atomicfile.WriteFile(destPath, data, 0644); err != nil {
+		return ports.ArchiveResult{}, fmt.Errorf("writing index: %w", err)
+	}
+
+	result.FileCount = len(index.Files)
+	return result, nil
+}
+
+// readError marks a failure reading the source file, as opposed to writing
+// the chunk store.
+type readError struct {
+	err error
+}
+
+func (e *readError) Error() string { return e.err.Error() }
+
+func (e *readError) Unwrap() error { return e.err }
+
+// sourceReader records read errors so storeFile can tell them apart from
+// errors writing chunks.
+type sourceReader struct {
+	r   io.Reader
+	err error
+}
+
+func (s *sourceReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if err != nil && err != io.EOF {
+		s.err = err
+	}
+	return n, err
+}
+
+// storeFile splits a file into chunks, writes any chunks not yet stored and
+// returns an index entry with size, checksums and chunk list filled in.
+// Failures reading the file are returned as *readError.
+func storeFile(chunks *chunkWriter, path string) (IndexEntry, error) {
+	var entry IndexEntry
+
+	file, err := os.Open(path)
+	if err != nil {
+		return entry, &readError{err: err}
+	}
+	defer func() { _ = file.Close() }()
+
+	crc := crc32.NewIEEE()
+	whole := sha256.New()
+	src := &sourceReader{r: file}
+	err = splitChunks(src, func(chunk []byte) error {
+		sum := sha256.Sum256(chunk)
+		hash := hex.EncodeToString(sum[:])
+		if err := chunks.write(hash, chunk); err != nil {
+			return err
+		}
+		_, _ = crc.Write(chunk)
+		_, _ = whole.Write(chunk)
+		entry.Size += int64(len(chunk))
+		entry.Chunks = append(entry.Chunks, hash)
+		return nil
+	})
+	if src.err != nil {
+		return entry, &readError{err: src.err}
+	}
+	if err != nil {
+		return entry, err
+	}
+
+	entry.CRC32 = crc.Sum32()
+	entry.SHA256 = hex.EncodeToString(whole.Sum(nil))
+	return entry, nil
+}
+
+// deflateLevel maps a compression setting to the flate level used for chunks.
+// Chunks are always deflate streams, so CompressNone still needs no reader
+// changes.
+func deflateLevel(c ports.Compression) int {
+	switch c {
+	case ports.CompressNone:
+		return flate.NoCompression
+	case ports.CompressBest:
+		return flate.BestCompression
+	default:
+		return flate.BestSpeed
+	}
+}
+
+// chunkWriter stores the chunks of one Create call and remembers which
+// directories gained chunks, so they can be synced together at the end.
+type chunkWriter struct {
+	dir   string
+	level int
+	dirty map[string]bool // Directories with newly stored chunks
+}
+
+// write stores a chunk unless a chunk with the same hash exists.
+func (w *chunkWriter) write(hash string, data []byte) error {
+	stored, err := writeChunk(w.dir, hash, data, w.level)
+	if stored {
+		w.dirty[filepath.Dir(chunkPath(w.dir, hash))] = true
+	}
+	return err
+}
+
+// sync flushes the directories that gained chunks, and the chunk directory
+// itself for any new fan-out directories.
+func (w *chunkWriter) sync() {
+	if len(w.dirty) == 0 {
+		return
+	}
+	for dir := range w.dirty {
+		atomicfile.SyncDir(dir)
+	}
+	atomicfile.SyncDir(w.dir)
+}
+
+// writeChunk stores a chunk compressed at level unless a chunk with the same
+// hash exists. Reports whether it stored a new chunk. The chunk is not synced;
+// the caller syncs its directory once it is done writing chunks.
+func writeChunk(dir, hash string, data []byte, level int) (bool, error) {
+	path := chunkPath(dir, hash)
+	if _, err := os.Stat(path); err == nil {
+		return false, nil // Already stored
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, level)
+	if err != nil {
+		return false, err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return false, err
+	}
+	if err := fw.Close(); err != nil {
+		return false, err
+	}
+
+	// Chunks are skipped once present, so one must never be visible
+	// half-written: write a temp file and rename it into place
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+hash+".*"+atomicfile.PartialSuffix)
+	if err != nil {
+		return false, err
+	}
+	_, err = tmp.Write(buf.Bytes())
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return false, err
+	}
+	return true, nil
+}
+
+// readChunk loads a chunk and checks it against its content hash.
+func readChunk(dir, hash string) ([]byte, error) {
+	if len(hash) < 2 {
+		return nil, fmt.Errorf("invalid chunk hash: %q", hash)
+	}
+
+	compressed, err := os.ReadFile(chunkPath(dir, hash))
+	if err != nil {
+		return nil, fmt.Errorf("reading chunk %s: %w", hash, err)
+	}
+
+	fr := flate.NewReader(bytes.NewReader(compressed))
+	defer func() { _ = fr.Close() }()
+
+	// Chunks never exceed maxChunkSize; read one extra byte to detect corruption
+	data, err := io.ReadAll(io.LimitReader(fr, maxChunkSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing chunk %s: %w", hash, err)
+	}
+	if len(data) > maxChunkSize {
+		return nil, fmt.Errorf("chunk %s exceeds maximum chunk size", hash)
+	}
+
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != hash {
+		return nil, fmt.Errorf("chunk %s is corrupt (hash mismatch)", hash)
+	}
+	return data, nil
+}
+
+// ReadIndex loads a version index from disk.
+func ReadIndex(indexPath string) (*Index, error) {
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("parsing index %s: %w", filepath.Base(indexPath), err)
+	}
+	if index.Version > indexFormatVersion {
+		return nil, fmt.Errorf("index %s uses unsupported format version %d", filepath.Base(indexPath), index.Version)
+	}
+	return &index, nil
+}
+
+// writeEntry streams the chunks of entry into w.
+func writeEntry(w io.Writer, dir string, entry IndexEntry) error {
+	for _, hash := range entry.Chunks {
+		data, err := readChunk(dir, hash)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Extract restores a version index to destDir.
+// Cancelling ctx stops before the next file.
+func (s *ChunkStore) Extract(ctx context.Context, indexPath, destDir string) error {
+	index, err := ReadIndex(indexPath)
+	if err != nil {
+		return err
+	}
+	dir := chunksDir(indexPath)
+
+	// Get cleaned absolute path for destination
+	absDestDir, err := filepath.Abs(destDir)
+	if err != nil {
+		return fmt.Errorf("resolving destination path: %w", err)
+	}
+	absDestDir = filepath.Clean(absDestDir)
+
+	for _, entry := range index.Files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		fpath := filepath.Join(destDir, index.Root, filepath.FromSlash(entry.Path))
+
+		// SECURITY: Reject entries that would escape the destination
+		if !isWithinDir(absDestDir, fpath) {
+			return fmt.Errorf("invalid file path (path traversal detected): %s", entry.Path)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+			return fmt.Errorf("creating parent directory for %s: %w", fpath, err)
+		}
+
+		if err := extractEntry(dir, entry, fpath); err != nil {
+			return fmt.Errorf("extracting %s: %w", entry.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// ExtractFiles restores the named files of a version index to destDir/<path>.
+// Cancelling ctx stops before the next file.
+func (s *ChunkStore) ExtractFiles(ctx context.Context, indexPath, destDir string, paths []string) error {
+	index, err := ReadIndex(indexPath)
+	if err != nil {
+		return err
+	}
+	dir := chunksDir(indexPath)
+
+	absDestDir, err := filepath.Abs(destDir)
+	if err != nil {
+		return fmt.Errorf("resolving destination path: %w", err)
+	}
+	absDestDir = filepath.Clean(absDestDir)
+
+	wanted := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		wanted[p] = true
+	}
+
+	for _, entry := range index.Files {
+		if !wanted[entry.Path] {
+			continue
+		}
+		delete(wanted, entry.Path)
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		fpath := filepath.Join(destDir, filepath.FromSlash(entry.Path))
+
+		// SECURITY: Reject entries that would escape the destination
+		if !isWithinDir(absDestDir, fpath) {
+			return fmt.Errorf("invalid file path (path traversal detected): %s", entry.Path)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+			return fmt.Errorf("creating parent directory for %s: %w", fpath, err)
+		}
+
+		if err := extractEntry(dir, entry, fpath); err != nil {
+			return fmt.Errorf("extracting %s: %w", entry.Path, err)
+		}
+	}
+
+	for _, p := range paths {
+		if wanted[p] {
+			return fmt.Errorf("not in archive: %s", p)
+		}
+	}
+	return nil
+}
+
+// extractEntry writes a single file from the chunk store.
+func extractEntry(dir string, entry IndexEntry, destPath string) error {
+	mode := entry.Mode.Perm()
+	if mode == 0 {
+		mode = 0644
+	}
+
+	outFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = outFile.Close() }()
+
+	if err := writeEntry(outFile, dir, entry); err != nil {
+		return err
+	}
+
+	if !entry.ModTime.IsZero() {
+		_ = os.Chtimes(destPath, entry.ModTime, entry.ModTime)
+	}
+	return nil
+}
+
+// isWithinDir checks if the target path is within the base directory.
+func isWithinDir(absBaseDir, targetPath string) bool {
+	absTarget, err := filepath.Abs(targetPath)
+	if err != nil {
+		return false
+	}
+	absTarget = filepath.Clean(absTarget)
+
+	return strings.HasPrefix(absTarget, absBaseDir+string(filepath.Separator)) ||
+		absTarget == absBaseDir
+}
+
+// List returns a map of file paths to their info from the version index.
+// Paths are relative to the project directory.
+func (s *ChunkStore) List(indexPath string) (map[string]ports.FileInfo, error) {
+	index, err := ReadIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]ports.FileInfo, len(index.Files))
+	for _, entry := range index.Files {
+		files[entry.Path] = ports.FileInfo{
+			Size:    entry.Size,
+			CRC32:   entry.CRC32,
+			ModTime: entry.ModTime,
+		}
+	}
+	return files, nil
+}
+
+// ReadFile reads the contents of a file from a version index.
+// projectName is accepted for interface compatibility; the index records its own root.
+func (s *ChunkStore) ReadFile(indexPath, filePath, projectName string) (string, error) {
+	index, err := ReadIndex(indexPath)
+	if err != nil {
+		return "", err
+	}
+
+	for _, entry := range index.Files {
+		if entry.Path == filePath {
+			var buf bytes.Buffer
+			if err := writeEntry(&buf, chunksDir(indexPath), entry); err != nil {
+				return "", err
+			}
+			return buf.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("file not found in archive: %s", filePath)
+}
+
+// Check reads back every file of a version index. Each chunk is checked
+// against its content hash as it is read, and each file against the CRC32,
+// size and SHA-256 recorded in the index.
+// Cancelling ctx stops before the next file.
+func (s *ChunkStore) Check(ctx context.Context, indexPath string) ([]ports.CheckedFile, error) {
+	index, err := ReadIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	dir := chunksDir(indexPath)
+
+	checked := make([]ports.CheckedFile, 0, len(index.Files))
+	for _, entry := range index.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		checked = append(checked, checkEntry(dir, entry))
+	}
+	return checked, nil
+}
+
+// checkEntry reads a single file from the chunk store, hashing its content.
+func checkEntry(dir string, entry IndexEntry) ports.CheckedFile {
+	result := ports.CheckedFile{Path: entry.Path}
+	crc := crc32.NewIEEE()
+	h := sha256.New()
+	counter := &countingWriter{}
+	err := writeEntry(io.MultiWriter(crc, h, counter), dir, entry)
+	result.Size = counter.n
+	result.SHA256 = hex.EncodeToString(h.Sum(nil))
+	switch {
+	case err != nil:
+		result.Err = err
+	case result.Size != entry.Size:
+		result.Err = fmt.Errorf("size mismatch: expected %d bytes, read %d", entry.Size, result.Size)
+	case crc.Sum32() != entry.CRC32:
+		result.Err = fmt.Errorf("CRC32 mismatch")
+	case entry.SHA256 != "" && result.SHA256 != entry.SHA256:
+		result.Err = fmt.Errorf("SHA-256 mismatch")
+	}
+	return result
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// CollectGarbage removes chunks under projectDir that no version index references,
+// along with temp files left by interrupted chunk writes. Callers must ensure no
+// backup of the project is in progress, e.g. by holding the project lock.
+// It refuses to delete anything if any index cannot be read, since the chunks
+// it references would otherwise be lost. Returns the number of files removed.
+func CollectGarbage(projectDir string) (int, error) {
+	indexPaths, err := filepath.Glob(filepath.Join(projectDir, "*"+IndexExt))
+	if err != nil {
+		return 0, err
+	}
+
+	referenced := make(map[string]bool)
+	for _, indexPath := range indexPaths {
+		index, err := ReadIndex(indexPath)
+		if err != nil {
+			return 0, fmt.Errorf("reading index: %w", err)
+		}
+		for _, entry := range index.Files {
+			for _, hash := range entry.Chunks {
+				referenced[hash] = true
+			}
+		}
+	}
+
+	dir := filepath.Join(projectDir, ChunksDirName)
+	removed := 0
+	walkErr := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || referenced[info.Name()] {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil // Continue on error; the chunk is retried next time
+		}
+		removed++
+		return nil
+	})
+
+	return removed, walkErr
+}
+
+// Compile-time check that ChunkStore implements ports.Archiver.
+var _ ports.Archiver = (*ChunkStore)(nil)
@@ -0,0 +1,540 @@
+package chunkstore
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/jmcdonald/codebak/internal/atomicfile"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jmcdonald/codebak/internal/ignore"
+	"github.com/jmcdonald/codebak/internal/ports"
+)
+
+// writeTree creates files under root from a path -> content map.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		fullPath := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", path, err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+}
+
+// countChunks returns the number of chunk files stored under projectDir.
+func countChunks(t *testing.T, projectDir string) int {
+	t.Helper()
+	count := 0
+	_ = filepath.Walk(filepath.Join(projectDir, ChunksDirName), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			count++
+		}
+		return nil
+	})
+	return count
+}
+
+func TestCreateExtractRoundTrip(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "myproject")
+	files := map[string]string{
+		"main.go":             "package main",
+		"pkg/util/util.go":    "package util",
+		"node_modules/dep.js": "excluded",
+		"docs/readme.md":      strings.Repeat("documentation ", 10000),
+	}
+	writeTree(t, sourceDir, files)
+
+	projectDir := filepath.Join(tempDir, "backups", "myproject")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create backup dir: %v", err)
+	}
+
+	store := New()
+	indexPath := filepath.Join(projectDir, "20240101-120000"+IndexExt)
+	result, err := store.Create(context.Background(), indexPath, sourceDir, ignore.New(sourceDir, []string{"node_modules"}), ports.CompressDefault, nil)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if result.FileCount != 3 {
+		t.Errorf("fileCount = %d, expected 3", result.FileCount)
+	}
+
+	destDir := filepath.Join(tempDir, "restore")
+	if err := store.Extract(context.Background(), indexPath, destDir); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	for path, content := range files {
+		restored, err := os.ReadFile(filepath.Join(destDir, "myproject", path))
+		if path == "node_modules/dep.js" {
+			if err == nil {
+				t.Errorf("Excluded file %s was restored", path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Failed to read restored %s: %v", path, err)
+			continue
+		}
+		if string(restored) != content {
+			t.Errorf("Restored %s content mismatch", path)
+		}
+	}
+}
+
+func TestCreateReportsProgress(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "myproject")
+	writeTree(t, sourceDir, map[string]string{
+		"a.txt":     "aaaa",
+		"dir/b.txt": "bb",
+	})
+
+	var events []ports.Progress
+	result, err := New().Create(context.Background(), filepath.Join(tempDir, "v1"+IndexExt), sourceDir, nil, ports.CompressDefault, func(p ports.Progress) {
+		events = append(events, p)
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if len(events) != result.FileCount {
+		t.Fatalf("got %d progress events for %d files", len(events), result.FileCount)
+	}
+	last := events[len(events)-1]
+	if last.Files != 2 || last.Bytes != 6 {
+		t.Errorf("final progress = %d files %d bytes, expected 2 files 6 bytes", last.Files, last.Bytes)
+	}
+	if events[0].Path != "a.txt" || last.Path != "dir/b.txt" {
+		t.Errorf("paths = %q, %q, expected a.txt, dir/b.txt", events[0].Path, last.Path)
+	}
+}
+
+func TestCreateReportsFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "myproject")
+	writeTree(t, sourceDir, map[string]string{"a.txt": "aaaa"})
+
+	result, err := New().Create(context.Background(), filepath.Join(tempDir, "v1"+IndexExt), sourceDir, nil, ports.CompressDefault, nil)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	sum := sha256.Sum256([]byte("aaaa"))
+	if len(result.Files) != 1 || result.Files[0].Path != "a.txt" || result.Files[0].SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("Files = %+v, expected a.txt with its checksum", result.Files)
+	}
+
+	index, err := ReadIndex(filepath.Join(tempDir, "v1"+IndexExt))
+	if err != nil {
+		t.Fatalf("ReadIndex failed: %v", err)
+	}
+	if index.Files[0].SHA256 != result.Files[0].SHA256 {
+		t.Errorf("index checksum = %q, expected it stored with the entry", index.Files[0].SHA256)
+	}
+}
+
+func TestCreateReportsUnreadableFiles(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("file permissions are not enforced for root")
+	}
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "proj")
+	writeTree(t, sourceDir, map[string]string{
+		"a.txt":      "alpha",
+		"secret.key": "locked",
+	})
+	locked := filepath.Join(sourceDir, "secret.key")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+	defer func() { _ = os.Chmod(locked, 0644) }()
+
+	indexPath := filepath.Join(tempDir, "v1"+IndexExt)
+	result, err := New().Create(context.Background(), indexPath, sourceDir, nil, ports.CompressDefault, nil)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if result.FileCount != 1 {
+		t.Errorf("FileCount = %d, expected 1", result.FileCount)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Path != "secret.key" {
+		t.Fatalf("Skipped = %+v, expected secret.key", result.Skipped)
+	}
+
+	index, err := ReadIndex(indexPath)
+	if err != nil {
+		t.Fatalf("ReadIndex failed: %v", err)
+	}
+	if len(index.Files) != 1 || index.Files[0].Path != "a.txt" {
+		t.Errorf("index files = %+v, expected only a.txt", index.Files)
+	}
+}
+
+func TestCreateCancelled(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "proj")
+	writeTree(t, sourceDir, map[string]string{
+		"a.txt": "alpha",
+		"b.txt": "bravo",
+		"c.txt": "charlie",
+	})
+	indexPath := filepath.Join(tempDir, "v1"+IndexExt)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err := New().Create(ctx, indexPath, sourceDir, nil, ports.CompressDefault, func(ports.Progress) {
+		cancel()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, expected context.Canceled", err)
+	}
+	if _, err := os.Stat(indexPath); !os.IsNotExist(err) {
+		t.Error("cancelled Create should not write an index")
+	}
+}
+
+func TestListAndReadFile(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "proj")
+	writeTree(t, sourceDir, map[string]string{
+		"a.txt":     "alpha",
+		"sub/b.txt": "bravo",
+	})
+
+	store := New()
+	indexPath := filepath.Join(tempDir, "backups", "v1"+IndexExt)
+	if err := os.MkdirAll(filepath.Dir(indexPath), 0755); err != nil {
+		t.Fatalf("Failed to create backup dir: %v", err)
+	}
+	if _, err := store.Create(context.Background(), indexPath, sourceDir, nil, ports.CompressDefault, nil); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	listing, err := store.List(indexPath)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(listing) != 2 {
+		t.Fatalf("List returned %d files, expected 2", len(listing))
+	}
+	if listing["sub/b.txt"].Size != 5 {
+		t.Errorf("sub/b.txt size = %d, expected 5", listing["sub/b.txt"].Size)
+	}
+	if listing["a.txt"].CRC32 == 0 {
+		t.Error("a.txt CRC32 should be recorded")
+	}
+	if info, _ := os.Stat(filepath.Join(sourceDir, "a.txt")); !listing["a.txt"].ModTime.Equal(info.ModTime()) {
+		t.Errorf("a.txt ModTime = %v, expected %v", listing["a.txt"].ModTime, info.ModTime())
+	}
+
+	content, err := store.ReadFile(indexPath, "sub/b.txt", "proj")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if content != "bravo" {
+		t.Errorf("ReadFile = %q, expected %q", content, "bravo")
+	}
+
+	if _, err := store.ReadFile(indexPath, "missing.txt", "proj"); err == nil {
+		t.Error("ReadFile should fail for a missing file")
+	}
+}
+
+func TestDeduplicatesUnchangedContent(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "proj")
+	projectDir := filepath.Join(tempDir, "backups", "proj")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create backup dir: %v", err)
+	}
+
+	// A large random file spans many chunks
+	rng := rand.New(rand.NewSource(1))
+	big := make([]byte, 2*1024*1024)
+	rng.Read(big)
+	writeTree(t, sourceDir, map[string]string{"big.bin": string(big)})
+
+	store := New()
+	if _, err := store.Create(context.Background(), filepath.Join(projectDir, "v1"+IndexExt), sourceDir, nil, ports.CompressDefault, nil); err != nil {
+		t.Fatalf("Create v1 failed: %v", err)
+	}
+	afterFirst := countChunks(t, projectDir)
+	if afterFirst < 2 {
+		t.Fatalf("Expected a large file to span several chunks, got %d", afterFirst)
+	}
+
+	// Backing up identical content stores no new chunks
+	if _, err := store.Create(context.Background(), filepath.Join(projectDir, "v2"+IndexExt), sourceDir, nil, ports.CompressDefault, nil); err != nil {
+		t.Fatalf("Create v2 failed: %v", err)
+	}
+	if got := countChunks(t, projectDir); got != afterFirst {
+		t.Errorf("Identical backup added chunks: %d -> %d", afterFirst, got)
+	}
+
+	// A small edit in the middle only adds a few chunks
+	copy(big[1024*1024:], []byte("edited"))
+	writeTree(t, sourceDir, map[string]string{"big.bin": string(big)})
+	if _, err := store.Create(context.Background(), filepath.Join(projectDir, "v3"+IndexExt), sourceDir, nil, ports.CompressDefault, nil); err != nil {
+		t.Fatalf("Create v3 failed: %v", err)
+	}
+	added := countChunks(t, projectDir) - afterFirst
+	if added < 1 || added > 3 {
+		t.Errorf("Small edit added %d chunks, expected 1-3", added)
+	}
+
+	content, err := store.ReadFile(filepath.Join(projectDir, "v3"+IndexExt), "big.bin", "proj")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !bytes.Equal([]byte(content), big) {
+		t.Error("Edited file did not round-trip")
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "proj")
+	projectDir := filepath.Join(tempDir, "backups", "proj")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create backup dir: %v", err)
+	}
+
+	store := New()
+	writeTree(t, sourceDir, map[string]string{"a.txt": "version one"})
+	if _, err := store.Create(context.Background(), filepath.Join(projectDir, "v1"+IndexExt), sourceDir, nil, ports.CompressDefault, nil); err != nil {
+		t.Fatalf("Create v1 failed: %v", err)
+	}
+	writeTree(t, sourceDir, map[string]string{"a.txt": "version two"})
+	v2 := filepath.Join(projectDir, "v2"+IndexExt)
+	if _, err := store.Create(context.Background(), v2, sourceDir, nil, ports.CompressDefault, nil); err != nil {
+		t.Fatalf("Create v2 failed: %v", err)
+	}
+	if got := countChunks(t, projectDir); got != 2 {
+		t.Fatalf("chunk count = %d, expected 2", got)
+	}
+
+	// Nothing is collected while both versions exist
+	removed, err := CollectGarbage(projectDir)
+	if err != nil {
+		t.Fatalf("CollectGarbage failed: %v", err)
+	}
+	if removed != 0 {
+		t.Errorf("removed = %d, expected 0", removed)
+	}
+
+	// Dropping v1 frees its chunk; v2 still restores
+	if err := os.Remove(filepath.Join(projectDir, "v1"+IndexExt)); err != nil {
+		t.Fatalf("Failed to remove v1: %v", err)
+	}
+	removed, err = CollectGarbage(projectDir)
+	if err != nil {
+		t.Fatalf("CollectGarbage failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("removed = %d, expected 1", removed)
+	}
+
+	content, err := store.ReadFile(v2, "a.txt", "proj")
+	if err != nil {
+		t.Fatalf("ReadFile after GC failed: %v", err)
+	}
+	if content != "version two" {
+		t.Errorf("ReadFile = %q, expected %q", content, "version two")
+	}
+
+	// Temp files of interrupted chunk writes are collected too
+	stale := filepath.Join(projectDir, ChunksDirName, "ab", ".abcd.123"+atomicfile.PartialSuffix)
+	writeTree(t, filepath.Dir(stale), map[string]string{filepath.Base(stale): "half"})
+	if removed, err = CollectGarbage(projectDir); err != nil || removed != 1 {
+		t.Errorf("CollectGarbage = %d, %v, expected the temp file removed", removed, err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("temp file of an interrupted chunk write should be removed")
+	}
+}
+
+func TestCollectGarbageRefusesOnBadIndex(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "proj")
+	projectDir := filepath.Join(tempDir, "backups", "proj")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create backup dir: %v", err)
+	}
+	writeTree(t, sourceDir, map[string]string{"a.txt": "data"})
+	if _, err := New().Create(context.Background(), filepath.Join(projectDir, "v1"+IndexExt), sourceDir, nil, ports.CompressDefault, nil); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "v2"+IndexExt), []byte("not json"), 0644); err != nil {
+		t.Fatalf("Failed to write bad index: %v", err)
+	}
+
+	if _, err := CollectGarbage(projectDir); err == nil {
+		t.Error("CollectGarbage should fail when an index is unreadable")
+	}
+	if got := countChunks(t, projectDir); got != 1 {
+		t.Errorf("chunk count = %d, expected chunks to be kept", got)
+	}
+}
+
+func TestReadChunkDetectsCorruption(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "proj")
+	projectDir := filepath.Join(tempDir, "backups", "proj")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create backup dir: %v", err)
+	}
+	writeTree(t, sourceDir, map[string]string{"a.txt": "original"})
+	indexPath := filepath.Join(projectDir, "v1"+IndexExt)
+	store := New()
+	if _, err := store.Create(context.Background(), indexPath, sourceDir, nil, ports.CompressDefault, nil); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	index, err := ReadIndex(indexPath)
+	if err != nil {
+		t.Fatalf("ReadIndex failed: %v", err)
+	}
+	hash := index.Files[0].Chunks[0]
+
+	// Overwrite the chunk with different (validly compressed) data
+	other := filepath.Join(tempDir, "other")
+	if _, err := writeChunk(other, hash, []byte("tampered"), flate.BestSpeed); err != nil {
+		t.Fatalf("writeChunk failed: %v", err)
+	}
+	data, err := os.ReadFile(chunkPath(other, hash))
+	if err != nil {
+		t.Fatalf("Failed to read tampered chunk: %v", err)
+	}
+	if err := os.WriteFile(chunkPath(chunksDir(indexPath), hash), data, 0644); err != nil {
+		t.Fatalf("Failed to overwrite chunk: %v", err)
+	}
+
+	if _, err := store.ReadFile(indexPath, "a.txt", "proj"); err == nil {
+		t.Error("ReadFile should fail on a corrupt chunk")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "proj")
+	projectDir := filepath.Join(tempDir, "backups", "proj")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create backup dir: %v", err)
+	}
+	writeTree(t, sourceDir, map[string]string{"a.txt": "alpha", "b.txt": "bravo"})
+	indexPath := filepath.Join(projectDir, "v1"+IndexExt)
+	store := New()
+	if _, err := store.Create(context.Background(), indexPath, sourceDir, nil, ports.CompressDefault, nil); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	checked, err := store.Check(context.Background(), indexPath)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(checked) != 2 {
+		t.Fatalf("checked = %+v, expected both files", checked)
+	}
+	for _, f := range checked {
+		if f.Err != nil || f.Size != 5 || f.SHA256 == "" {
+			t.Errorf("%s = %+v, expected an intact file", f.Path, f)
+		}
+	}
+
+	// A recorded CRC32 that does not match the content is reported per file
+	index, _ := ReadIndex(indexPath)
+	index.Files[0].CRC32++
+	data, _ := json.Marshal(index)
+	if err := os.WriteFile(indexPath, data, 0644); err != nil {
+		t.Fatalf("Failed to rewrite index: %v", err)
+	}
+	checked, err = store.Check(context.Background(), indexPath)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if checked[0].Err == nil || checked[1].Err != nil {
+		t.Errorf("checked = %+v, expected only %s to fail", checked, index.Files[0].Path)
+	}
+}
+
+func TestExtractFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "proj")
+	projectDir := filepath.Join(tempDir, "backups", "proj")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create backup dir: %v", err)
+	}
+	writeTree(t, sourceDir, map[string]string{"a.txt": "alpha", "pkg/b.txt": "bravo", "pkg/c.txt": "charlie"})
+	indexPath := filepath.Join(projectDir, "v1"+IndexExt)
+	store := New()
+	if _, err := store.Create(context.Background(), indexPath, sourceDir, nil, ports.CompressDefault, nil); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	destDir := filepath.Join(tempDir, "restore")
+	writeTree(t, destDir, map[string]string{"pkg/b.txt": "clobbered"})
+	if err := store.ExtractFiles(context.Background(), indexPath, destDir, []string{"pkg/b.txt", "pkg/c.txt"}); err != nil {
+		t.Fatalf("ExtractFiles failed: %v", err)
+	}
+	for path, want := range map[string]string{"pkg/b.txt": "bravo", "pkg/c.txt": "charlie"} {
+		if got, err := os.ReadFile(filepath.Join(destDir, path)); err != nil || string(got) != want {
+			t.Errorf("%s = %q (%v), expected %q", path, got, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "a.txt")); err == nil {
+		t.Error("a file that was not named was extracted")
+	}
+
+	if err := store.ExtractFiles(context.Background(), indexPath, destDir, []string{"missing.txt"}); err == nil {
+		t.Error("ExtractFiles should fail for a file the version does not hold")
+	}
+}
+
+func TestSplitChunksBounds(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	data := make([]byte, 3*1024*1024)
+	rng.Read(data)
+
+	var total int
+	var sizes []int
+	err := splitChunks(bytes.NewReader(data), func(chunk []byte) error {
+		sizes = append(sizes, len(chunk))
+		total += len(chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("splitChunks failed: %v", err)
+	}
+	if total != len(data) {
+		t.Errorf("chunks cover %d bytes, expected %d", total, len(data))
+	}
+	for i, size := range sizes {
+		if size > maxChunkSize {
+			t.Errorf("chunk %d size %d exceeds max", i, size)
+		}
+		if size < minChunkSize && i != len(sizes)-1 {
+			t.Errorf("chunk %d size %d below min", i, size)
+		}
+	}
+}
+
+func TestIsIndex(t *testing.T) {
+	if !IsIndex("/b/proj/20240101-120000.idx") {
+		t.Error("IsIndex should match .idx files")
+	}
+	if IsIndex("/b/proj/20240101-120000.zip") {
+		t.Error("IsIndex should not match .zip files")
+	}
+}
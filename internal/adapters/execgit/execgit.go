@@ -2,9 +2,13 @@
 package execgit
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/jmcdonald/codebak/internal/ports"
@@ -20,14 +24,22 @@ func New() *ExecGitClient {
 
 // GetHead returns the current HEAD commit hash for the repository.
 // Returns empty string if not a git repo or on error.
-func (g *ExecGitClient) GetHead(repoPath string) string {
-	cmd := exec.Command("git", "rev-parse", "HEAD")
-	cmd.Dir = repoPath
-	out, err := cmd.Output()
+func (g *ExecGitClient) GetHead(ctx context.Context, repoPath string) string {
+	head, err := run(ctx, repoPath, "rev-parse", "HEAD")
 	if err != nil {
 		return ""
 	}
-	return strings.TrimSpace(string(out))
+	return head
+}
+
+// Branch returns the name of the checked-out branch.
+// Returns empty string for a detached HEAD, if not a git repo or on error.
+func (g *ExecGitClient) Branch(ctx context.Context, repoPath string) string {
+	branch, err := run(ctx, repoPath, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return branch
 }
 
 // IsRepo checks if the given path is a git repository.
@@ -40,5 +52,240 @@ func (g *ExecGitClient) IsRepo(path string) bool {
 	return info.IsDir()
 }
 
+// Status returns the uncommitted changes in the working tree.
+// Untracked files are listed individually; ignored files are omitted.
+func (g *ExecGitClient) Status(ctx context.Context, repoPath string) ([]ports.GitFileStatus, error) {
+	out, err := output(ctx, repoPath, "status", "--porcelain=v1", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+	return parseStatus(out), nil
+}
+
+// parseStatus parses `git status --porcelain=v1 -z` output.
+// Each record is "XY path\0"; renames and copies append "orig-path\0".
+func parseStatus(out []byte) []ports.GitFileStatus {
+	result := []ports.GitFileStatus{}
+	records := strings.Split(string(out), "\x00")
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if len(record) < 4 {
+			continue
+		}
+		code := record[:2]
+		result = append(result, ports.GitFileStatus{Path: record[3:], Code: code})
+		// Skip the original path that follows a rename or copy
+		if code[0] == 'R' || code[0] == 'C' {
+			i++
+		}
+	}
+	return result
+}
+
+// stashRefPrefix namespaces the refs that carry stash entries into a bundle.
+// Stashes live in the reflog of refs/stash, which bundles do not include, so
+// each entry is given its own ref in the scratch clone the bundle is made from.
+const stashRefPrefix = "refs/codebak/stash/"
+
+// headRefPrefix marks the checked-out branch in a bundle. Bundles only record
+// the commit HEAD points at, which is ambiguous when several branches share it.
+const headRefPrefix = "refs/codebak/head/"
+
+// bundleRefspecs are the refs fetched back out of a bundle. refs/stash is
+// rebuilt from the stash refs instead so every entry keeps its reflog slot.
+var bundleRefspecs = []string{
+	"refs/heads/*:refs/heads/*",
+	"refs/tags/*:refs/tags/*",
+	"refs/remotes/*:refs/remotes/*",
+	stashRefPrefix + "*:" + stashRefPrefix + "*",
+}
+
+// run executes git in dir and returns its trimmed stdout. Errors include
+// git's stderr output. Cancelling ctx kills git.
+func run(ctx context.Context, dir string, args ...string) (string, error) {
+	out, err := output(ctx, dir, args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// output is run without trimming, for output where whitespace is significant.
+func output(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// CreateBundle writes a bundle of all refs and stash entries to destPath.
+// The extra refs it needs are added to a scratch mirror clone that borrows
+// the repository's objects, so the repository itself is never written to.
+func (g *ExecGitClient) CreateBundle(ctx context.Context, repoPath, destPath string) error {
+	stashes, err := run(ctx, repoPath, "stash", "list", "--format=%H")
+	if err != nil {
+		return err
+	}
+	head, err := run(ctx, repoPath, "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	branch, _ := run(ctx, repoPath, "symbolic-ref", "-q", "HEAD")
+
+	scratch, err := os.MkdirTemp("", "codebak-bundle-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(scratch) }()
+	mirror := filepath.Join(scratch, "repo.git")
+	// --shared reads objects through alternates, so stash entries that are
+	// only reachable from the reflog are still available in the clone
+	if _, err := run(ctx, scratch, "clone", "-q", "--mirror", "--shared", repoPath, mirror); err != nil {
+		return err
+	}
+
+	// Drop refs an older codebak may have left in the repository
+	stale, err := run(ctx, mirror, "for-each-ref", "--format=%(refname)", "refs/codebak/")
+	if err != nil {
+		return err
+	}
+	for _, ref := range strings.Fields(stale) {
+		if _, err := run(ctx, mirror, "update-ref", "-d", ref); err != nil {
+			return err
+		}
+	}
+
+	// Point HEAD where the repository's does, and pin the checked-out branch
+	// and each stash entry to a ref
+	if strings.HasPrefix(branch, "refs/heads/") {
+		if _, err := run(ctx, mirror, "symbolic-ref", "HEAD", branch); err != nil {
+			return err
+		}
+		if _, err := run(ctx, mirror, "update-ref", headRefPrefix+strings.TrimPrefix(branch, "refs/heads/"), head); err != nil {
+			return err
+		}
+	} else if _, err := run(ctx, mirror, "update-ref", "--no-deref", "HEAD", head); err != nil {
+		return err
+	}
+	for i, hash := range strings.Fields(stashes) {
+		if _, err := run(ctx, mirror, "update-ref", fmt.Sprintf("%s%d", stashRefPrefix, i), hash); err != nil {
+			return err
+		}
+	}
+
+	absDest, err := filepath.Abs(destPath)
+	if err != nil {
+		return err
+	}
+	if _, err := run(ctx, mirror, "bundle", "create", "-q", absDest, "--all"); err != nil {
+		// git writes through a lock file that a killed process leaves behind
+		_ = os.Remove(absDest + ".lock")
+		_ = os.Remove(absDest)
+		return err
+	}
+	return nil
+}
+
+// RestoreBundle initializes a repository in repoPath from a bundle made by
+// CreateBundle and points HEAD at the bundled HEAD without touching the
+// working tree.
+func (g *ExecGitClient) RestoreBundle(ctx context.Context, bundlePath, repoPath string) error {
+	absBundle, err := filepath.Abs(bundlePath)
+	if err != nil {
+		return err
+	}
+	heads, err := run(ctx, repoPath, "bundle", "list-heads", absBundle)
+	if err != nil {
+		return err
+	}
+
+	if _, err := run(ctx, repoPath, "init", "-q"); err != nil {
+		return err
+	}
+	args := append([]string{"fetch", "-q", "--update-head-ok", absBundle}, bundleRefspecs...)
+	if _, err := run(ctx, repoPath, args...); err != nil {
+		return err
+	}
+
+	// Reattach HEAD to the branch it was on, or detach it at the same commit
+	headHash, headRef := parseBundleHead(heads)
+	if headHash != "" {
+		if headRef != "" {
+			_, err = run(ctx, repoPath, "symbolic-ref", "HEAD", headRef)
+		} else {
+			_, err = run(ctx, repoPath, "update-ref", "--no-deref", "HEAD", headHash)
+		}
+		if err != nil {
+			return err
+		}
+		// Mixed reset: the index follows HEAD, restored files stay as they are
+		if _, err := run(ctx, repoPath, "reset", "-q"); err != nil {
+			return err
+		}
+	}
+
+	return restoreStashes(ctx, repoPath)
+}
+
+// parseBundleHead returns the HEAD commit in `git bundle list-heads` output
+// and the branch that was checked out, or "" for a detached HEAD.
+func parseBundleHead(heads string) (hash, ref string) {
+	for _, line := range strings.Split(heads, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		switch {
+		case fields[1] == "HEAD":
+			hash = fields[0]
+		case strings.HasPrefix(fields[1], headRefPrefix):
+			ref = "refs/heads/" + strings.TrimPrefix(fields[1], headRefPrefix)
+		}
+	}
+	if hash == "" {
+		return "", ""
+	}
+	return hash, ref
+}
+
+// restoreStashes moves the temporary stash refs back onto the stash reflog,
+// oldest first so the newest ends up as stash@{0}.
+func restoreStashes(ctx context.Context, repoPath string) error {
+	out, err := run(ctx, repoPath, "for-each-ref", "--format=%(refname)", stashRefPrefix)
+	if err != nil {
+		return err
+	}
+	refs := strings.Fields(out)
+	sort.Slice(refs, func(i, j int) bool { return stashIndex(refs[i]) > stashIndex(refs[j]) })
+
+	for _, ref := range refs {
+		message, err := run(ctx, repoPath, "log", "-1", "--format=%s", ref)
+		if err != nil {
+			return err
+		}
+		if _, err := run(ctx, repoPath, "stash", "store", "-m", message, ref); err != nil {
+			return err
+		}
+		if _, err := run(ctx, repoPath, "update-ref", "-d", ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stashIndex returns the stash position encoded in a temporary stash ref.
+func stashIndex(ref string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(ref, stashRefPrefix))
+	return n
+}
+
 // Compile-time check that ExecGitClient implements ports.GitClient.
 var _ ports.GitClient = (*ExecGitClient)(nil)
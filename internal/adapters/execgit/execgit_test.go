@@ -0,0 +1,227 @@
+package execgit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jmcdonald/codebak/internal/ports"
+)
+
+func TestParseStatus(t *testing.T) {
+	out := []byte(" M main.go\x00A  new.go\x00R  renamed.go\x00old.go\x00?? notes.txt\x00")
+	got := parseStatus(out)
+	want := []ports.GitFileStatus{
+		{Path: "main.go", Code: " M"},
+		{Path: "new.go", Code: "A "},
+		{Path: "renamed.go", Code: "R "},
+		{Path: "notes.txt", Code: "??"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseStatus = %+v, expected %+v", got, want)
+	}
+}
+
+func TestParseStatusClean(t *testing.T) {
+	got := parseStatus(nil)
+	if got == nil || len(got) != 0 {
+		t.Errorf("parseStatus(nil) = %#v, expected empty slice", got)
+	}
+}
+
+// gitRepo creates a repository with a commit on main, a feature branch and
+// two stash entries, and returns its path.
+func gitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	repo := filepath.Join(t.TempDir(), "repo")
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	git := func(args ...string) {
+		if _, err := run(context.Background(), repo, args...); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatalf("Failed to create repo: %v", err)
+	}
+	git("init", "-q", "-b", "main")
+	write("main.go", "package main\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	git("branch", "feature")
+	write("main.go", "package main // first stash\n")
+	git("stash", "push", "-q", "-m", "first")
+	write("main.go", "package main // second stash\n")
+	git("stash", "push", "-q", "-m", "second")
+	write("main.go", "package main // uncommitted\n")
+	return repo
+}
+
+func TestCreateAndRestoreBundle(t *testing.T) {
+	repo := gitRepo(t)
+	client := New()
+	bundle := filepath.Join(t.TempDir(), "backup.bundle")
+
+	if err := client.CreateBundle(context.Background(), repo, bundle); err != nil {
+		t.Fatalf("CreateBundle failed: %v", err)
+	}
+	if out, _ := run(context.Background(), repo, "for-each-ref", "refs/codebak/"); out != "" {
+		t.Errorf("temporary refs left in source repo: %s", out)
+	}
+
+	// Restore into a directory holding only the working tree
+	restored := filepath.Join(t.TempDir(), "repo")
+	if err := os.MkdirAll(restored, 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(restored, "main.go"), []byte("package main // uncommitted\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := client.RestoreBundle(context.Background(), bundle, restored); err != nil {
+		t.Fatalf("RestoreBundle failed: %v", err)
+	}
+
+	if head := client.GetHead(context.Background(), restored); head != client.GetHead(context.Background(), repo) {
+		t.Errorf("HEAD = %s, expected %s", head, client.GetHead(context.Background(), repo))
+	}
+	branch, _ := run(context.Background(), restored, "symbolic-ref", "--short", "HEAD")
+	if branch != "main" {
+		t.Errorf("HEAD branch = %q, expected main", branch)
+	}
+	branches, _ := run(context.Background(), restored, "branch", "--format=%(refname:short)")
+	if !strings.Contains(branches, "feature") {
+		t.Errorf("branches = %q, expected feature", branches)
+	}
+	stashes, _ := run(context.Background(), restored, "stash", "list", "--format=%gs")
+	want, _ := run(context.Background(), repo, "stash", "list", "--format=%gs")
+	if stashes != want {
+		t.Errorf("stash list = %q, expected %q", stashes, want)
+	}
+
+	// The restored working tree keeps its uncommitted change
+	status, err := client.Status(context.Background(), restored)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(status) != 1 || status[0].Path != "main.go" {
+		t.Errorf("status = %+v, expected main.go modified", status)
+	}
+}
+
+func TestCreateBundleLeavesRepoAlone(t *testing.T) {
+	repo := gitRepo(t)
+	// A ref left behind by an older codebak must not end up in the bundle
+	if _, err := run(context.Background(), repo, "update-ref", stashRefPrefix+"7", "HEAD"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	refs := func() string {
+		out, err := run(context.Background(), repo, "for-each-ref", "--format=%(refname) %(objectname)")
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		return out
+	}
+	before := refs()
+
+	bundle := filepath.Join(t.TempDir(), "backup.bundle")
+	if err := New().CreateBundle(context.Background(), repo, bundle); err != nil {
+		t.Fatalf("CreateBundle failed: %v", err)
+	}
+	if after := refs(); after != before {
+		t.Errorf("refs changed by CreateBundle:\nbefore %s\nafter  %s", before, after)
+	}
+	heads, err := run(context.Background(), repo, "bundle", "list-heads", bundle)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if strings.Contains(heads, stashRefPrefix+"7") || !strings.Contains(heads, stashRefPrefix+"1") {
+		t.Errorf("bundle heads = %q, expected the two current stashes only", heads)
+	}
+}
+
+func TestCreateBundleCancelled(t *testing.T) {
+	repo := gitRepo(t)
+	bundle := filepath.Join(t.TempDir(), "backup.bundle")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := New().CreateBundle(ctx, repo, bundle); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, expected context.Canceled", err)
+	}
+	if _, err := os.Stat(bundle); !os.IsNotExist(err) {
+		t.Error("cancelled CreateBundle should not leave a bundle behind")
+	}
+	if out, _ := run(context.Background(), repo, "for-each-ref", "refs/codebak/"); out != "" {
+		t.Errorf("temporary refs left in source repo: %s", out)
+	}
+}
+
+func TestBranch(t *testing.T) {
+	repo := gitRepo(t)
+	client := New()
+	ctx := context.Background()
+
+	if branch := client.Branch(ctx, repo); branch != "main" {
+		t.Errorf("Branch = %q, expected main", branch)
+	}
+	if _, err := run(ctx, repo, "checkout", "-q", "feature"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if branch := client.Branch(ctx, repo); branch != "feature" {
+		t.Errorf("Branch = %q, expected feature after checkout", branch)
+	}
+	if _, err := run(ctx, repo, "checkout", "-q", "--detach"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if branch := client.Branch(ctx, repo); branch != "" {
+		t.Errorf("Branch = %q, expected empty for a detached HEAD", branch)
+	}
+	if branch := client.Branch(ctx, t.TempDir()); branch != "" {
+		t.Errorf("Branch = %q, expected empty outside a repository", branch)
+	}
+}
+
+func TestStatusCancelled(t *testing.T) {
+	repo := gitRepo(t)
+	client := New()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.Status(ctx, repo); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, expected context.Canceled", err)
+	}
+	if head := client.GetHead(ctx, repo); head != "" {
+		t.Errorf("GetHead = %q, expected empty after cancellation", head)
+	}
+}
+
+func TestParseBundleHead(t *testing.T) {
+	heads := "bbb refs/codebak/head/main\nbbb refs/heads/feature\nbbb refs/heads/main\nbbb HEAD\n"
+	hash, ref := parseBundleHead(heads)
+	if hash != "bbb" || ref != "refs/heads/main" {
+		t.Errorf("parseBundleHead = %q, %q; expected bbb, refs/heads/main", hash, ref)
+	}
+
+	hash, ref = parseBundleHead("ccc HEAD\naaa refs/heads/main\n")
+	if hash != "ccc" || ref != "" {
+		t.Errorf("detached parseBundleHead = %q, %q; expected ccc, \"\"", hash, ref)
+	}
+}
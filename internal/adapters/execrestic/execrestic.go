@@ -2,16 +2,22 @@
 package execrestic
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/jmcdonald/codebak/internal/ports"
 )
 
+// interruptGrace is how long restic gets to release its repository lock after
+// being interrupted before it is killed.
+const interruptGrace = 10 * time.Second
+
 // ExecResticClient implements ports.ResticClient using exec.Command.
 type ExecResticClient struct {
 	// resticPath is the path to the restic binary. Defaults to "restic".
@@ -40,8 +46,8 @@ func New(opts ...Option) *ExecResticClient {
 }
 
 // Init initializes a new restic repository at the given path.
-func (r *ExecResticClient) Init(repoPath, password string) error {
-	cmd := r.command("init", "--repo", repoPath)
+func (r *ExecResticClient) Init(ctx context.Context, repoPath, password string) error {
+	cmd := r.command(ctx, "init", "--repo", repoPath)
 	cmd.Env = append(os.Environ(), "RESTIC_PASSWORD="+password)
 
 	out, err := cmd.CombinedOutput()
@@ -51,13 +57,13 @@ func (r *ExecResticClient) Init(repoPath, password string) error {
 			strings.Contains(string(out), "config file already exists") {
 			return fmt.Errorf("repository already initialized at %s", repoPath)
 		}
-		return fmt.Errorf("restic init failed: %w: %s", err, string(out))
+		return commandError(ctx, "init", err, out)
 	}
 	return nil
 }
 
 // Backup creates a new backup of the given paths to the repository.
-func (r *ExecResticClient) Backup(repoPath, password string, paths []string, tags []string) (string, error) {
+func (r *ExecResticClient) Backup(ctx context.Context, repoPath, password string, paths []string, tags []string) (string, error) {
 	if len(paths) == 0 {
 		return "", fmt.Errorf("no paths specified for backup")
 	}
@@ -68,12 +74,12 @@ func (r *ExecResticClient) Backup(repoPath, password string, paths []string, tag
 	}
 	args = append(args, paths...)
 
-	cmd := r.command(args...)
+	cmd := r.command(ctx, args...)
 	cmd.Env = append(os.Environ(), "RESTIC_PASSWORD="+password)
 
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return "", fmt.Errorf("restic backup failed: %w: %s", err, string(out))
+		return "", commandError(ctx, "backup", err, out)
 	}
 
 	// Parse JSON output to get snapshot ID
@@ -100,18 +106,18 @@ func (r *ExecResticClient) Backup(repoPath, password string, paths []string, tag
 }
 
 // Snapshots returns all snapshots in the repository.
-func (r *ExecResticClient) Snapshots(repoPath, password string, tags []string) ([]ports.Snapshot, error) {
+func (r *ExecResticClient) Snapshots(ctx context.Context, repoPath, password string, tags []string) ([]ports.Snapshot, error) {
 	args := []string{"snapshots", "--repo", repoPath, "--json"}
 	for _, tag := range tags {
 		args = append(args, "--tag", tag)
 	}
 
-	cmd := r.command(args...)
+	cmd := r.command(ctx, args...)
 	cmd.Env = append(os.Environ(), "RESTIC_PASSWORD="+password)
 
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return nil, fmt.Errorf("restic snapshots failed: %w: %s", err, string(out))
+		return nil, commandError(ctx, "snapshots", err, out)
 	}
 
 	// Parse JSON output
@@ -128,34 +134,53 @@ func (r *ExecResticClient) Snapshots(repoPath, password string, tags []string) (
 }
 
 // Restore restores a snapshot to the given target directory.
-func (r *ExecResticClient) Restore(repoPath, password, snapshotID, targetDir string) error {
+func (r *ExecResticClient) Restore(ctx context.Context, repoPath, password, snapshotID, targetDir string) error {
+	return r.restore(ctx, repoPath, password, snapshotID, targetDir, nil)
+}
+
+// RestorePaths restores only the given paths of a snapshot to the target directory.
+func (r *ExecResticClient) RestorePaths(ctx context.Context, repoPath, password, snapshotID, targetDir string, paths []string) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("no paths specified for restore")
+	}
+	return r.restore(ctx, repoPath, password, snapshotID, targetDir, paths)
+}
+
+// restore runs restic restore, limited to include when it is not empty.
+func (r *ExecResticClient) restore(ctx context.Context, repoPath, password, snapshotID, targetDir string, include []string) error {
 	if snapshotID == "" {
 		snapshotID = "latest"
 	}
 
-	cmd := r.command("restore", "--repo", repoPath, "--target", targetDir, snapshotID)
+	args := []string{"restore", "--repo", repoPath, "--target", targetDir}
+	for _, p := range include {
+		args = append(args, "--include", p)
+	}
+	args = append(args, snapshotID)
+
+	cmd := r.command(ctx, args...)
 	cmd.Env = append(os.Environ(), "RESTIC_PASSWORD="+password)
 
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("restic restore failed: %w: %s", err, string(out))
+		return commandError(ctx, "restore", err, out)
 	}
 	return nil
 }
 
 // Forget removes old snapshots according to the retention policy.
-func (r *ExecResticClient) Forget(repoPath, password string, keepLast int, prune bool) error {
+func (r *ExecResticClient) Forget(ctx context.Context, repoPath, password string, keepLast int, prune bool) error {
 	args := []string{"forget", "--repo", repoPath, fmt.Sprintf("--keep-last=%d", keepLast)}
 	if prune {
 		args = append(args, "--prune")
 	}
 
-	cmd := r.command(args...)
+	cmd := r.command(ctx, args...)
 	cmd.Env = append(os.Environ(), "RESTIC_PASSWORD="+password)
 
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("restic forget failed: %w: %s", err, string(out))
+		return commandError(ctx, "forget", err, out)
 	}
 	return nil
 }
@@ -171,9 +196,25 @@ func (r *ExecResticClient) IsInitialized(repoPath string) bool {
 	return !info.IsDir()
 }
 
-// command creates an exec.Cmd for the restic binary.
-func (r *ExecResticClient) command(args ...string) *exec.Cmd {
-	return exec.Command(r.resticPath, args...)
+// command creates an exec.Cmd for the restic binary. Cancelling ctx sends
+// restic an interrupt so it can release its repository lock, and kills it if
+// it has not exited after interruptGrace.
+func (r *ExecResticClient) command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, r.resticPath, args...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = interruptGrace
+	return cmd
+}
+
+// commandError describes a failed restic run, reporting cancellation rather
+// than the exit status it caused.
+func commandError(ctx context.Context, op string, err error, out []byte) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("restic %s cancelled: %w", op, ctxErr)
+	}
+	return fmt.Errorf("restic %s failed: %w: %s", op, err, string(out))
 }
 
 // Compile-time check that ExecResticClient implements ports.ResticClient.
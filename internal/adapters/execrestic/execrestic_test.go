@@ -1,9 +1,12 @@
 package execrestic
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/jmcdonald/codebak/internal/ports"
 )
@@ -63,7 +66,7 @@ func TestIsInitialized(t *testing.T) {
 
 func TestBackupEmptyPaths(t *testing.T) {
 	client := New()
-	_, err := client.Backup("/repo", "password", []string{}, nil)
+	_, err := client.Backup(context.Background(), "/repo", "password", []string{}, nil)
 	if err == nil {
 		t.Error("expected error for empty paths")
 	}
@@ -72,6 +75,55 @@ func TestBackupEmptyPaths(t *testing.T) {
 	}
 }
 
+func TestBackupCancelled(t *testing.T) {
+	// A stand-in for restic that never finishes on its own
+	fake := filepath.Join(t.TempDir(), "restic")
+	if err := os.WriteFile(fake, []byte("#!/bin/sh\nexec sleep 30\n"), 0755); err != nil {
+		t.Fatalf("Failed to write fake restic: %v", err)
+	}
+	client := New(WithResticPath(fake))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.Backup(ctx, "/repo", "password", []string{"/data"}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, expected cancellation", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Backup took %v after cancellation, expected restic to be interrupted", elapsed)
+	}
+}
+
+func TestRestorePathsIncludes(t *testing.T) {
+	// A stand-in for restic that records its arguments
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	fake := filepath.Join(dir, "restic")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + argsFile + "\n"
+	if err := os.WriteFile(fake, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake restic: %v", err)
+	}
+	client := New(WithResticPath(fake))
+
+	if err := client.RestorePaths(context.Background(), "/repo", "password", "", "/target", []string{"/data/a", "/data/b c"}); err != nil {
+		t.Fatalf("RestorePaths failed: %v", err)
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("Failed to read recorded arguments: %v", err)
+	}
+	expected := "restore\n--repo\n/repo\n--target\n/target\n--include\n/data/a\n--include\n/data/b c\nlatest\n"
+	if string(args) != expected {
+		t.Errorf("restic arguments = %q, expected %q", args, expected)
+	}
+
+	if err := client.RestorePaths(context.Background(), "/repo", "password", "latest", "/target", nil); err == nil {
+		t.Error("expected error for empty paths")
+	}
+}
+
 func TestImplementsInterface(t *testing.T) {
 	// This test verifies at compile time that ExecResticClient implements the interface.
 	// The var _ declaration in the main file does this too, but this makes it explicit in tests.
@@ -88,7 +140,7 @@ func TestIntegrationInit(t *testing.T) {
 
 	// Check if restic is available
 	client := New()
-	cmd := client.command("version")
+	cmd := client.command(context.Background(), "version")
 	if err := cmd.Run(); err != nil {
 		t.Skip("restic not installed, skipping integration test")
 	}
@@ -98,7 +150,7 @@ func TestIntegrationInit(t *testing.T) {
 	password := "test-password"
 
 	// Init should succeed
-	err := client.Init(repoPath, password)
+	err := client.Init(context.Background(), repoPath, password)
 	if err != nil {
 		t.Fatalf("Init failed: %v", err)
 	}
@@ -109,7 +161,7 @@ func TestIntegrationInit(t *testing.T) {
 	}
 
 	// Init again should fail
-	err = client.Init(repoPath, password)
+	err = client.Init(context.Background(), repoPath, password)
 	if err == nil {
 		t.Error("expected error when re-initializing")
 	}
@@ -121,7 +173,7 @@ func TestIntegrationBackupAndSnapshots(t *testing.T) {
 	}
 
 	client := New()
-	cmd := client.command("version")
+	cmd := client.command(context.Background(), "version")
 	if err := cmd.Run(); err != nil {
 		t.Skip("restic not installed, skipping integration test")
 	}
@@ -141,12 +193,12 @@ func TestIntegrationBackupAndSnapshots(t *testing.T) {
 	}
 
 	// Init repo
-	if err := client.Init(repoPath, password); err != nil {
+	if err := client.Init(context.Background(), repoPath, password); err != nil {
 		t.Fatalf("Init failed: %v", err)
 	}
 
 	// Backup
-	snapshotID, err := client.Backup(repoPath, password, []string{dataDir}, []string{"test-tag"})
+	snapshotID, err := client.Backup(context.Background(), repoPath, password, []string{dataDir}, []string{"test-tag"})
 	if err != nil {
 		t.Fatalf("Backup failed: %v", err)
 	}
@@ -155,7 +207,7 @@ func TestIntegrationBackupAndSnapshots(t *testing.T) {
 	}
 
 	// List snapshots
-	snapshots, err := client.Snapshots(repoPath, password, nil)
+	snapshots, err := client.Snapshots(context.Background(), repoPath, password, nil)
 	if err != nil {
 		t.Fatalf("Snapshots failed: %v", err)
 	}
@@ -164,7 +216,7 @@ func TestIntegrationBackupAndSnapshots(t *testing.T) {
 	}
 
 	// Filter by tag
-	tagged, err := client.Snapshots(repoPath, password, []string{"test-tag"})
+	tagged, err := client.Snapshots(context.Background(), repoPath, password, []string{"test-tag"})
 	if err != nil {
 		t.Fatalf("Snapshots with tag failed: %v", err)
 	}
@@ -173,7 +225,7 @@ func TestIntegrationBackupAndSnapshots(t *testing.T) {
 	}
 
 	// Filter by non-existent tag
-	empty, err := client.Snapshots(repoPath, password, []string{"no-such-tag"})
+	empty, err := client.Snapshots(context.Background(), repoPath, password, []string{"no-such-tag"})
 	if err != nil {
 		t.Fatalf("Snapshots with missing tag failed: %v", err)
 	}
@@ -188,7 +240,7 @@ func TestIntegrationRestore(t *testing.T) {
 	}
 
 	client := New()
-	cmd := client.command("version")
+	cmd := client.command(context.Background(), "version")
 	if err := cmd.Run(); err != nil {
 		t.Skip("restic not installed, skipping integration test")
 	}
@@ -210,16 +262,16 @@ func TestIntegrationRestore(t *testing.T) {
 	}
 
 	// Init and backup
-	if err := client.Init(repoPath, password); err != nil {
+	if err := client.Init(context.Background(), repoPath, password); err != nil {
 		t.Fatalf("Init failed: %v", err)
 	}
-	_, err := client.Backup(repoPath, password, []string{dataDir}, nil)
+	_, err := client.Backup(context.Background(), repoPath, password, []string{dataDir}, nil)
 	if err != nil {
 		t.Fatalf("Backup failed: %v", err)
 	}
 
 	// Restore
-	if err := client.Restore(repoPath, password, "latest", restoreDir); err != nil {
+	if err := client.Restore(context.Background(), repoPath, password, "latest", restoreDir); err != nil {
 		t.Fatalf("Restore failed: %v", err)
 	}
 
@@ -241,7 +293,7 @@ func TestIntegrationForget(t *testing.T) {
 	}
 
 	client := New()
-	cmd := client.command("version")
+	cmd := client.command(context.Background(), "version")
 	if err := cmd.Run(); err != nil {
 		t.Skip("restic not installed, skipping integration test")
 	}
@@ -258,7 +310,7 @@ func TestIntegrationForget(t *testing.T) {
 	testFile := filepath.Join(dataDir, "test.txt")
 
 	// Init repo
-	if err := client.Init(repoPath, password); err != nil {
+	if err := client.Init(context.Background(), repoPath, password); err != nil {
 		t.Fatalf("Init failed: %v", err)
 	}
 
@@ -267,25 +319,25 @@ func TestIntegrationForget(t *testing.T) {
 		if err := os.WriteFile(testFile, []byte{byte(i)}, 0644); err != nil {
 			t.Fatal(err)
 		}
-		_, err := client.Backup(repoPath, password, []string{dataDir}, nil)
+		_, err := client.Backup(context.Background(), repoPath, password, []string{dataDir}, nil)
 		if err != nil {
 			t.Fatalf("Backup %d failed: %v", i, err)
 		}
 	}
 
 	// Verify we have 3 snapshots
-	snapshots, _ := client.Snapshots(repoPath, password, nil)
+	snapshots, _ := client.Snapshots(context.Background(), repoPath, password, nil)
 	if len(snapshots) != 3 {
 		t.Fatalf("expected 3 snapshots, got %d", len(snapshots))
 	}
 
 	// Forget all but last 1
-	if err := client.Forget(repoPath, password, 1, false); err != nil {
+	if err := client.Forget(context.Background(), repoPath, password, 1, false); err != nil {
 		t.Fatalf("Forget failed: %v", err)
 	}
 
 	// Verify only 1 remains
-	snapshots, _ = client.Snapshots(repoPath, password, nil)
+	snapshots, _ = client.Snapshots(context.Background(), repoPath, password, nil)
 	if len(snapshots) != 1 {
 		t.Errorf("expected 1 snapshot after forget, got %d", len(snapshots))
 	}
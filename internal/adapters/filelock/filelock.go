@@ -0,0 +1,287 @@
+// Package filelock provides a lock-file based implementation of ports.Locker.
+//
+// Locks are files created with O_EXCL that record the owning process. A lock
+// is considered stale, and is taken over, when its owner ran on this host and
+// that process no longer exists. Locks held from other hosts (e.g. a backup
+// directory on a shared volume) are never broken automatically.
+package filelock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jmcdonald/codebak/internal/ports"
+)
+
+const (
+	// BackupDirLockName is the lock file guarding the whole backup directory.
+	BackupDirLockName = ".codebak.lock"
+
+	// ProjectLocksDirName is the directory holding per-project lock files.
+	ProjectLocksDirName = ".locks"
+
+	// lockExt is the extension of per-project lock files.
+	lockExt = ".lock"
+)
+
+// unreadableStaleAfter is how old a lock file with unparseable contents must be
+// before it is treated as stale. A fresh lock may be observed between its
+// creation and the owner info being written.
+const unreadableStaleAfter = time.Minute
+
+// Owner identifies the process holding a lock.
+type Owner struct {
+	PID      int       `json:"pid"`
+	Hostname string    `json:"hostname"`
+	Acquired time.Time `json:"acquired"`
+}
+
+// LockedError reports a lock held by another process.
+type LockedError struct {
+	Path  string
+	Owner Owner
+}
+
+func (e *LockedError) Error() string {
+	if e.Owner.PID == 0 {
+		return fmt.Sprintf("%v (lock %s)", ports.ErrLocked, e.Path)
+	}
+	return fmt.Sprintf("%v (pid %d on %s since %s)",
+		ports.ErrLocked, e.Owner.PID, e.Owner.Hostname, e.Owner.Acquired.Format("2006-01-02 15:04:05"))
+}
+
+// Is reports whether target is ports.ErrLocked.
+func (e *LockedError) Is(target error) bool {
+	return target == ports.ErrLocked
+}
+
+// FileLocker implements ports.Locker using lock files under the backup directory.
+type FileLocker struct {
+	// pollInterval is how often a busy lock is retried while waiting.
+	pollInterval time.Duration
+	pid          int
+	hostname     string
+}
+
+// Option is a functional option for configuring FileLocker.
+type Option func(*FileLocker)
+
+// WithPollInterval sets how often a busy lock is retried while waiting.
+func WithPollInterval(d time.Duration) Option {
+	return func(l *FileLocker) {
+		l.pollInterval = d
+	}
+}
+
+// New creates a new FileLocker adapter.
+func New(opts ...Option) *FileLocker {
+	hostname, _ := os.Hostname()
+	l := &FileLocker{
+		pollInterval: 250 * time.Millisecond,
+		pid:          os.Getpid(),
+		hostname:     hostname,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// BackupDirLockPath returns the path of the lock file for a backup directory.
+func BackupDirLockPath(backupDir string) string {
+	return filepath.Join(backupDir, BackupDirLockName)
+}
+
+// ProjectLockPath returns the path of the lock file for a project.
+func ProjectLockPath(backupDir, project string) string {
+	return filepath.Join(backupDir, ProjectLocksDirName, project+lockExt)
+}
+
+// LockBackupDir acquires the lock for the whole backup directory. It waits for
+// any project locks held by other processes to be released.
+func (l *FileLocker) LockBackupDir(backupDir string, wait time.Duration) (ports.Lock, error) {
+	path := BackupDirLockPath(backupDir)
+	return l.acquire(wait, func() (*fileLock, error) {
+		held, err := l.tryAcquire(path)
+		if err != nil {
+			return nil, err
+		}
+		if busy := l.busyProjectLock(backupDir); busy != nil {
+			_ = held.Unlock()
+			return nil, busy
+		}
+		held.cleanupDir = filepath.Join(backupDir, ProjectLocksDirName)
+		return held, nil
+	})
+}
+
+// LockProject acquires the lock for one project. It fails while another
+// process holds the backup directory lock.
+func (l *FileLocker) LockProject(backupDir, project string, wait time.Duration) (ports.Lock, error) {
+	path := ProjectLockPath(backupDir, project)
+	dirPath := BackupDirLockPath(backupDir)
+	return l.acquire(wait, func() (*fileLock, error) {
+		held, err := l.tryAcquire(path)
+		if err != nil {
+			return nil, err
+		}
+		if owner, busy := l.heldByOther(dirPath); busy {
+			_ = held.Unlock()
+			return nil, &LockedError{Path: dirPath, Owner: owner}
+		}
+		return held, nil
+	})
+}
+
+// acquire calls try until it succeeds, fails with a non-lock error, or the
+// wait duration runs out.
+func (l *FileLocker) acquire(wait time.Duration, try func() (*fileLock, error)) (ports.Lock, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		held, err := try()
+		if err == nil {
+			return held, nil
+		}
+		if !errors.Is(err, ports.ErrLocked) {
+			return nil, err
+		}
+		if wait == 0 || (wait > 0 && time.Now().After(deadline)) {
+			return nil, err
+		}
+		time.Sleep(l.pollInterval)
+	}
+}
+
+// tryAcquire creates the lock file at path, taking over a stale lock.
+func (l *FileLocker) tryAcquire(path string) (*fileLock, error) {
+	owner := Owner{PID: l.pid, Hostname: l.hostname, Acquired: time.Now()}
+	data, err := json.Marshal(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	// A few attempts cover a stale lock being removed, or the lock directory
+	// being cleaned up by another process between MkdirAll and OpenFile
+	for attempt := 0; attempt < 3; attempt++ {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("creating lock directory: %w", err)
+		}
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, werr := f.Write(data)
+			cerr := f.Close()
+			if werr == nil {
+				werr = cerr
+			}
+			if werr != nil {
+				_ = os.Remove(path)
+				return nil, fmt.Errorf("writing lock file: %w", werr)
+			}
+			return &fileLock{path: path}, nil
+		}
+		if os.IsNotExist(err) {
+			continue
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("creating lock file: %w", err)
+		}
+
+		contents, owner, stale := l.inspect(path)
+		if !stale {
+			return nil, &LockedError{Path: path, Owner: owner}
+		}
+		// Only remove the stale lock if nobody replaced it in the meantime
+		if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, contents) {
+			_ = os.Remove(path)
+		}
+	}
+	return nil, &LockedError{Path: path}
+}
+
+// inspect reads the lock file at path and reports whether it is stale.
+func (l *FileLocker) inspect(path string) ([]byte, Owner, bool) {
+	var owner Owner
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// Vanished between the create attempt and now; retry
+		return nil, owner, os.IsNotExist(err)
+	}
+	if err := json.Unmarshal(data, &owner); err != nil || owner.PID <= 0 {
+		info, statErr := os.Stat(path)
+		return data, Owner{}, statErr == nil && time.Since(info.ModTime()) > unreadableStaleAfter
+	}
+	if owner.Hostname != l.hostname {
+		return data, owner, false
+	}
+	if owner.PID == l.pid {
+		return data, owner, false
+	}
+	return data, owner, !processAlive(owner.PID)
+}
+
+// heldByOther reports whether the lock at path is held by a live process
+// other than this one.
+func (l *FileLocker) heldByOther(path string) (Owner, bool) {
+	_, owner, stale := l.inspect(path)
+	if stale {
+		return owner, false
+	}
+	if owner.PID == l.pid && owner.Hostname == l.hostname {
+		return owner, false
+	}
+	return owner, true
+}
+
+// busyProjectLock returns an error for the first project lock held by another
+// process, or nil if there is none.
+func (l *FileLocker) busyProjectLock(backupDir string) error {
+	entries, err := os.ReadDir(filepath.Join(backupDir, ProjectLocksDirName))
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), lockExt) {
+			continue
+		}
+		path := filepath.Join(backupDir, ProjectLocksDirName, entry.Name())
+		if owner, busy := l.heldByOther(path); busy {
+			return &LockedError{Path: path, Owner: owner}
+		}
+	}
+	return nil
+}
+
+// processAlive reports whether a process with the given PID exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// fileLock is a held lock file.
+type fileLock struct {
+	path string
+	// cleanupDir is removed on unlock if it is empty.
+	cleanupDir string
+}
+
+// Unlock releases the lock by removing its file.
+func (f *fileLock) Unlock() error {
+	if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing lock file: %w", err)
+	}
+	if f.cleanupDir != "" {
+		_ = os.Remove(f.cleanupDir) // Fails harmlessly while project locks exist
+	}
+	return nil
+}
+
+// Compile-time check that FileLocker implements ports.Locker.
+var _ ports.Locker = (*FileLocker)(nil)
@@ -0,0 +1,244 @@
+package filelock
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmcdonald/codebak/internal/ports"
+)
+
+// writeOwner writes a lock file at path as if held by owner.
+func writeOwner(t *testing.T, path string, owner Owner) {
+	t.Helper()
+	data, err := json.Marshal(owner)
+	if err != nil {
+		t.Fatalf("Failed to marshal owner: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create lock dir: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write lock file: %v", err)
+	}
+}
+
+// deadPID returns the PID of a process that has already exited.
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("cannot run helper process: %v", err)
+	}
+	return cmd.Process.Pid
+}
+
+// otherLiveOwner returns an owner on this host that is a live process other than us.
+func otherLiveOwner(t *testing.T, l *FileLocker) Owner {
+	t.Helper()
+	return Owner{PID: os.Getppid(), Hostname: l.hostname, Acquired: time.Now()}
+}
+
+func TestLockProjectAndUnlock(t *testing.T) {
+	backupDir := t.TempDir()
+	locker := New()
+
+	held, err := locker.LockProject(backupDir, "proj", 0)
+	if err != nil {
+		t.Fatalf("LockProject failed: %v", err)
+	}
+	path := ProjectLockPath(backupDir, "proj")
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("lock file not created: %v", err)
+	}
+
+	// A second holder in the same process is refused
+	if _, err := locker.LockProject(backupDir, "proj", 0); !errors.Is(err, ports.ErrLocked) {
+		t.Errorf("second LockProject err = %v, expected ErrLocked", err)
+	}
+
+	// Other projects are independent
+	other, err := locker.LockProject(backupDir, "other", 0)
+	if err != nil {
+		t.Fatalf("LockProject(other) failed: %v", err)
+	}
+	_ = other.Unlock()
+
+	if err := held.Unlock(); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("lock file should be removed on unlock")
+	}
+}
+
+func TestLockedErrorReportsOwner(t *testing.T) {
+	backupDir := t.TempDir()
+	locker := New()
+	owner := otherLiveOwner(t, locker)
+	writeOwner(t, ProjectLockPath(backupDir, "proj"), owner)
+
+	_, err := locker.LockProject(backupDir, "proj", 0)
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("err = %v, expected *LockedError", err)
+	}
+	if locked.Owner.PID != owner.PID {
+		t.Errorf("Owner.PID = %d, expected %d", locked.Owner.PID, owner.PID)
+	}
+	if !errors.Is(err, ports.ErrLocked) {
+		t.Error("LockedError should match ports.ErrLocked")
+	}
+}
+
+func TestStaleLockIsTakenOver(t *testing.T) {
+	backupDir := t.TempDir()
+	locker := New()
+	path := ProjectLockPath(backupDir, "proj")
+	writeOwner(t, path, Owner{PID: deadPID(t), Hostname: locker.hostname, Acquired: time.Now().Add(-time.Hour)})
+
+	held, err := locker.LockProject(backupDir, "proj", 0)
+	if err != nil {
+		t.Fatalf("LockProject should take over a stale lock: %v", err)
+	}
+	defer func() { _ = held.Unlock() }()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read lock file: %v", err)
+	}
+	var owner Owner
+	if err := json.Unmarshal(data, &owner); err != nil {
+		t.Fatalf("Failed to parse lock file: %v", err)
+	}
+	if owner.PID != os.Getpid() {
+		t.Errorf("lock owner PID = %d, expected %d", owner.PID, os.Getpid())
+	}
+}
+
+func TestLockFromOtherHostIsNeverStale(t *testing.T) {
+	backupDir := t.TempDir()
+	locker := New()
+	writeOwner(t, ProjectLockPath(backupDir, "proj"), Owner{PID: deadPID(t), Hostname: "elsewhere", Acquired: time.Now()})
+
+	if _, err := locker.LockProject(backupDir, "proj", 0); !errors.Is(err, ports.ErrLocked) {
+		t.Errorf("err = %v, expected a lock from another host to be respected", err)
+	}
+}
+
+func TestUnreadableLockStaleAfterGracePeriod(t *testing.T) {
+	backupDir := t.TempDir()
+	locker := New()
+	path := ProjectLockPath(backupDir, "proj")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create lock dir: %v", err)
+	}
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatalf("Failed to write lock file: %v", err)
+	}
+
+	// A fresh empty lock may still be being written by its owner
+	if _, err := locker.LockProject(backupDir, "proj", 0); !errors.Is(err, ports.ErrLocked) {
+		t.Errorf("err = %v, expected fresh unreadable lock to be respected", err)
+	}
+
+	old := time.Now().Add(-2 * unreadableStaleAfter)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	held, err := locker.LockProject(backupDir, "proj", 0)
+	if err != nil {
+		t.Fatalf("LockProject should take over an old unreadable lock: %v", err)
+	}
+	_ = held.Unlock()
+}
+
+func TestWaitForRelease(t *testing.T) {
+	backupDir := t.TempDir()
+	locker := New(WithPollInterval(10 * time.Millisecond))
+
+	held, err := locker.LockProject(backupDir, "proj", 0)
+	if err != nil {
+		t.Fatalf("LockProject failed: %v", err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = held.Unlock()
+	}()
+
+	second, err := locker.LockProject(backupDir, "proj", ports.WaitForever)
+	if err != nil {
+		t.Fatalf("waiting LockProject failed: %v", err)
+	}
+	_ = second.Unlock()
+}
+
+func TestWaitTimesOut(t *testing.T) {
+	backupDir := t.TempDir()
+	locker := New(WithPollInterval(10 * time.Millisecond))
+	writeOwner(t, ProjectLockPath(backupDir, "proj"), otherLiveOwner(t, locker))
+
+	start := time.Now()
+	_, err := locker.LockProject(backupDir, "proj", 50*time.Millisecond)
+	if !errors.Is(err, ports.ErrLocked) {
+		t.Fatalf("err = %v, expected ErrLocked after timeout", err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("LockProject returned before the wait elapsed")
+	}
+}
+
+func TestBackupDirLockExcludesProjects(t *testing.T) {
+	backupDir := t.TempDir()
+	locker := New()
+
+	// Another process running over the whole directory blocks project locks
+	writeOwner(t, BackupDirLockPath(backupDir), otherLiveOwner(t, locker))
+	if _, err := locker.LockProject(backupDir, "proj", 0); !errors.Is(err, ports.ErrLocked) {
+		t.Errorf("LockProject err = %v, expected ErrLocked while directory is locked", err)
+	}
+	if _, err := os.Stat(ProjectLockPath(backupDir, "proj")); !os.IsNotExist(err) {
+		t.Error("refused project lock should not be left behind")
+	}
+	if err := os.Remove(BackupDirLockPath(backupDir)); err != nil {
+		t.Fatalf("Failed to remove dir lock: %v", err)
+	}
+
+	// Another process holding a project lock blocks the directory lock
+	writeOwner(t, ProjectLockPath(backupDir, "proj"), otherLiveOwner(t, locker))
+	if _, err := locker.LockBackupDir(backupDir, 0); !errors.Is(err, ports.ErrLocked) {
+		t.Errorf("LockBackupDir err = %v, expected ErrLocked while a project is locked", err)
+	}
+}
+
+func TestOwnBackupDirLockAllowsProjects(t *testing.T) {
+	backupDir := t.TempDir()
+	locker := New()
+
+	dirLock, err := locker.LockBackupDir(backupDir, 0)
+	if err != nil {
+		t.Fatalf("LockBackupDir failed: %v", err)
+	}
+
+	// A full run locks the directory and then each project it backs up
+	held, err := locker.LockProject(backupDir, "proj", 0)
+	if err != nil {
+		t.Fatalf("LockProject under own directory lock failed: %v", err)
+	}
+	_ = held.Unlock()
+
+	if err := dirLock.Unlock(); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("backup dir should be left clean, found %d entries", len(entries))
+	}
+}
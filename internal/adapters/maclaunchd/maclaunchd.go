@@ -21,6 +21,7 @@ const plistTemplate = `<?xml version="1.0" encoding="UTF-8"?>
     <array>
         <string>{{.BinaryPath}}</string>
         <string>run</string>
+        <string>--scheduled</string>
     </array>
     <key>StartCalendarInterval</key>
     <dict>
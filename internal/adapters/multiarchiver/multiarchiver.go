@@ -0,0 +1,72 @@
+// Package multiarchiver provides an archiver adapter that dispatches to the
+// zip or chunk store engine based on the archive path.
+package multiarchiver
+
+import (
+	"context"
+
+	"github.com/jmcdonald/codebak/internal/adapters/chunkstore"
+	"github.com/jmcdonald/codebak/internal/adapters/ziparchiver"
+	"github.com/jmcdonald/codebak/internal/ports"
+)
+
+// MultiArchiver implements ports.Archiver by routing each call to the engine
+// that owns the archive: chunk store indexes (.idx) or zip archives (anything else).
+type MultiArchiver struct {
+	zip     ports.Archiver
+	chunked ports.Archiver
+}
+
+// New creates a new MultiArchiver adapter with the production engines.
+func New() *MultiArchiver {
+	return NewWith(ziparchiver.New(), chunkstore.New())
+}
+
+// NewWith creates a MultiArchiver with the given engines.
+func NewWith(zip, chunked ports.Archiver) *MultiArchiver {
+	return &MultiArchiver{
+		zip:     zip,
+		chunked: chunked,
+	}
+}
+
+// engine returns the archiver responsible for path.
+func (a *MultiArchiver) engine(path string) ports.Archiver {
+	if chunkstore.IsIndex(path) {
+		return a.chunked
+	}
+	return a.zip
+}
+
+// Create creates an archive of sourceDir at destPath.
+func (a *MultiArchiver) Create(ctx context.Context, destPath, sourceDir string, exclude ports.Excluder, compression ports.Compression, progress ports.ProgressFunc) (ports.ArchiveResult, error) {
+	return a.engine(destPath).Create(ctx, destPath, sourceDir, exclude, compression, progress)
+}
+
+// Extract extracts an archive to destDir.
+func (a *MultiArchiver) Extract(ctx context.Context, archivePath, destDir string) error {
+	return a.engine(archivePath).Extract(ctx, archivePath, destDir)
+}
+
+// ExtractFiles extracts the named files of an archive to destDir.
+func (a *MultiArchiver) ExtractFiles(ctx context.Context, archivePath, destDir string, paths []string) error {
+	return a.engine(archivePath).ExtractFiles(ctx, archivePath, destDir, paths)
+}
+
+// List returns a map of file paths to their info from the archive.
+func (a *MultiArchiver) List(archivePath string) (map[string]ports.FileInfo, error) {
+	return a.engine(archivePath).List(archivePath)
+}
+
+// ReadFile reads the contents of a file from inside an archive.
+func (a *MultiArchiver) ReadFile(archivePath, filePath, projectName string) (string, error) {
+	return a.engine(archivePath).ReadFile(archivePath, filePath, projectName)
+}
+
+// Check reads back every file in an archive, verifying its checksum.
+func (a *MultiArchiver) Check(ctx context.Context, archivePath string) ([]ports.CheckedFile, error) {
+	return a.engine(archivePath).Check(ctx, archivePath)
+}
+
+// Compile-time check that MultiArchiver implements ports.Archiver.
+var _ ports.Archiver = (*MultiArchiver)(nil)
@@ -67,6 +67,12 @@ func (f *OSFileSystem) Create(name string) (*os.File, error) {
 	return os.Create(name)
 }
 
+// MkdirTemp creates a new directory in dir whose name begins with pattern
+// and returns its path.
+func (f *OSFileSystem) MkdirTemp(dir, pattern string) (string, error) {
+	return os.MkdirTemp(dir, pattern)
+}
+
 // Walk walks the file tree rooted at root, calling fn for each file or directory.
 func (f *OSFileSystem) Walk(root string, fn ports.WalkFunc) error {
 	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
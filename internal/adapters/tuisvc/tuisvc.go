@@ -2,6 +2,8 @@
 package tuisvc
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
 
@@ -10,14 +12,17 @@ import (
 	"github.com/jmcdonald/codebak/internal/config"
 	"github.com/jmcdonald/codebak/internal/manifest"
 	"github.com/jmcdonald/codebak/internal/ports"
+	"github.com/jmcdonald/codebak/internal/recovery"
 )
 
 // Service implements ports.TUIService using real filesystem operations.
-type Service struct{}
+type Service struct {
+	version string // codebak version recorded with backups
+}
 
-// New creates a new TUI service.
-func New() *Service {
-	return &Service{}
+// New creates a new TUI service for the given codebak version.
+func New(version string) *Service {
+	return &Service{version: version}
 }
 
 // LoadConfig loads the application configuration.
@@ -164,6 +169,12 @@ func (s *Service) ListVersions(cfg *config.Config, project string) ([]ports.TUIV
 			FileCount: b.FileCount,
 			GitHead:   b.GitHead,
 			CreatedAt: b.CreatedAt,
+			Skipped:   skippedFiles(b.Skipped),
+			Pinned:    b.Pinned,
+			Labels:    b.Labels,
+			Note:      b.Note,
+
+			Provenance: provenance(b.Provenance),
 		})
 	}
 
@@ -176,39 +187,110 @@ func (s *Service) ListVersions(cfg *config.Config, project string) ([]ports.TUIV
 }
 
 // RunBackup performs a backup of the specified project.
-func (s *Service) RunBackup(cfg *config.Config, project string) ports.TUIBackupResult {
-	result := backup.BackupProject(cfg, project)
+func (s *Service) RunBackup(ctx context.Context, cfg *config.Config, project string, progress ports.ProgressFunc) ports.TUIBackupResult {
+	var onProgress backup.ProgressFunc
+	if progress != nil {
+		onProgress = func(_ string, p ports.Progress) { progress(p) }
+	}
+	ctx = backup.WithRunInfo(ctx, backup.RunInfo{Version: s.version, Trigger: backup.TriggerTUI})
+	result := backup.BackupProjectProgress(ctx, cfg, project, onProgress)
 	return ports.TUIBackupResult{
-		Size:    result.Size,
-		Error:   result.Error,
-		Skipped: result.Skipped,
-		Reason:  result.Reason,
+		Size:         result.Size,
+		Error:        result.Error,
+		Skipped:      result.Skipped,
+		Reason:       result.Reason,
+		SkippedFiles: skippedFiles(result.SkippedFiles),
 	}
 }
 
-// VerifyBackup verifies the latest backup of a project.
-func (s *Service) VerifyBackup(cfg *config.Config, project string) error {
-	backupDir, err := config.ExpandPath(cfg.BackupDir)
-	if err != nil {
-		return err
+// provenance converts a manifest provenance record for display.
+func provenance(p *manifest.Provenance) *ports.TUIProvenance {
+	if p == nil {
+		return nil
 	}
-	mf, err := manifest.Load(backupDir, project)
-	if err != nil || len(mf.Backups) == 0 {
-		return fmt.Errorf("no backups to verify")
+	return &ports.TUIProvenance{
+		Hostname:       p.Hostname,
+		User:           p.User,
+		CodebakVersion: p.CodebakVersion,
+		Branch:         p.Branch,
+		Dirty:          p.Dirty,
+		Duration:       p.Duration,
+		Trigger:        p.Trigger,
+		ConfigHash:     p.ConfigHash,
+	}
+}
+
+// skippedFiles converts manifest records of unreadable files for display.
+func skippedFiles(files []manifest.SkippedFile) []ports.SkippedFile {
+	var skipped []ports.SkippedFile
+	for _, f := range files {
+		skipped = append(skipped, ports.SkippedFile{Path: f.Path, Reason: f.Reason})
 	}
+	return skipped
+}
+
+// VerifyBackup verifies the latest backup of a project.
+func (s *Service) VerifyBackup(cfg *config.Config, project string) error {
+	return recovery.Verify(cfg, project, "")
+}
 
-	latest := mf.LatestBackup()
-	zipPath := filepath.Join(backupDir, project, latest.File)
-	actualChecksum, err := manifest.ComputeSHA256(zipPath)
+// AnnotateVersion pins, labels or notes a backup version of a project.
+func (s *Service) AnnotateVersion(cfg *config.Config, project, version string, a ports.TUIAnnotation) error {
+	_, err := backup.Annotate(cfg, project, version, manifest.Annotation{
+		Pinned:       a.Pinned,
+		AddLabels:    a.AddLabels,
+		RemoveLabels: a.RemoveLabels,
+		Note:         a.Note,
+	})
+	return err
+}
+
+// RestorePath restores one file or directory of a backup version.
+func (s *Service) RestorePath(ctx context.Context, cfg *config.Config, project, version, path string, overwrite bool) (ports.TUIRestoreResult, error) {
+	result, err := recovery.Restore(ctx, cfg, recovery.RestoreOptions{
+		Project:   project,
+		Path:      path,
+		Version:   version,
+		Overwrite: overwrite,
+	})
+	if errors.Is(err, recovery.ErrWouldOverwrite) {
+		return ports.TUIRestoreResult{Dir: result.Dir, Conflicts: result.Overwritten}, nil
+	}
 	if err != nil {
-		return fmt.Errorf("verify failed: %w", err)
+		return ports.TUIRestoreResult{}, err
 	}
+	return ports.TUIRestoreResult{
+		Dir:         result.Dir,
+		Restored:    len(result.Restored),
+		Overwritten: len(result.Overwritten),
+	}, nil
+}
 
-	if actualChecksum != latest.SHA256 {
-		return fmt.Errorf("checksum mismatch")
+// PreviewRecover compares a backup version with the directory recovering it would write to.
+func (s *Service) PreviewRecover(cfg *config.Config, project, version, target string) (ports.TUIRecoverPreview, error) {
+	preview, err := recovery.Preview(cfg, recovery.RecoverOptions{Project: project, Version: version, Target: target})
+	if err != nil {
+		return ports.TUIRecoverPreview{}, err
 	}
+	return ports.TUIRecoverPreview{
+		Dir:         preview.Dir,
+		Added:       len(preview.Added),
+		Overwritten: len(preview.Overwritten),
+		Removed:     len(preview.Removed),
+		Unchanged:   len(preview.Unchanged),
+		Newer:       len(preview.Newer),
+	}, nil
+}
 
-	return nil
+// RecoverVersion restores a whole backup version of a project.
+func (s *Service) RecoverVersion(ctx context.Context, cfg *config.Config, project, version string, opts ports.TUIRecoverOptions) error {
+	return recovery.Recover(ctx, cfg, recovery.RecoverOptions{
+		Project: project,
+		Version: version,
+		Wipe:    opts.Wipe,
+		Archive: opts.Archive,
+		Target:  opts.Target,
+	})
 }
 
 // ListSnapshots returns all restic snapshots for sensitive sources.
@@ -235,7 +317,7 @@ func (s *Service) ListSnapshots(cfg *config.Config, tag string) ([]ports.TUISnap
 		tags = []string{tag}
 	}
 
-	snapshots, err := restic.Snapshots(repoPath, password, tags)
+	snapshots, err := restic.Snapshots(context.Background(), repoPath, password, tags)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list snapshots: %w", err)
 	}
@@ -3,6 +3,12 @@ package ziparchiver
 
 import (
 	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -10,6 +16,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/jmcdonald/codebak/internal/atomicfile"
 	"github.com/jmcdonald/codebak/internal/ports"
 )
 
@@ -21,42 +28,151 @@ func New() *ZipArchiver {
 	return &ZipArchiver{}
 }
 
-// shouldExclude checks if a path should be excluded based on patterns.
-func shouldExclude(path string, excludePatterns []string) bool {
-	base := filepath.Base(path)
-	for _, pattern := range excludePatterns {
-		// Check exact match
-		if base == pattern {
-			return true
-		}
-		// Check glob pattern
-		if matched, _ := filepath.Match(pattern, base); matched {
-			return true
+// excluded reports whether path inside sourceDir should be skipped.
+func excluded(exclude ports.Excluder, sourceDir, path string, isDir bool) bool {
+	if exclude == nil {
+		return false
+	}
+	relPath, err := filepath.Rel(sourceDir, path)
+	if err != nil {
+		return false
+	}
+	return exclude.Excluded(relPath, isDir)
+}
+
+// sourceReader records read errors so they can be told apart from errors
+// writing the archive.
+type sourceReader struct {
+	r   io.Reader
+	err error
+}
+
+func (s *sourceReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if err != nil && err != io.EOF {
+		s.err = err
+	}
+	return n, err
+}
+
+// openSource opens a file to archive. Tests replace it to simulate read errors.
+var openSource = func(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
+
+// spoolLimit is the largest file held in memory while it is read; larger
+// files are spooled to a temporary file next to the archive.
+const spoolLimit = 8 << 20
+
+// spool holds each file's content until it has been read in full, so a file
+// that fails partway through never gets an entry in the archive.
+type spool struct {
+	dir  string // Directory for the temporary file
+	name string // Base name of the archive, used to name the temporary file
+	buf  bytes.Buffer
+	file *os.File // Reused for every file larger than spoolLimit
+}
+
+// fill reads all of src, which is expected to hold size bytes. It returns a
+// reader over the content, its length and its SHA-256. Read errors come from
+// src; any other error is from the spool itself.
+func (s *spool) fill(src io.Reader, size int64) (io.Reader, int64, string, error) {
+	h := sha256.New()
+	if size <= spoolLimit {
+		s.buf.Reset()
+		n, err := io.Copy(io.MultiWriter(&s.buf, h), src)
+		return &s.buf, n, hex.EncodeToString(h.Sum(nil)), err
+	}
+
+	if s.file == nil {
+		// The partial suffix lets cleanup remove it if a crash leaves it behind
+		f, err := os.CreateTemp(s.dir, "."+s.name+".spool.*"+atomicfile.PartialSuffix)
+		if err != nil {
+			return nil, 0, "", err
 		}
+		s.file = f
+	} else if err := s.file.Truncate(0); err != nil {
+		return nil, 0, "", err
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, "", err
+	}
+	n, err := io.Copy(io.MultiWriter(s.file, h), src)
+	if err != nil {
+		return nil, n, "", err
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, n, "", err
+	}
+	return io.LimitReader(s.file, n), n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// close removes the temporary file, if one was created.
+func (s *spool) close() {
+	if s.file != nil {
+		_ = s.file.Close()
+		_ = os.Remove(s.file.Name())
 	}
-	return false
 }
 
 // Create creates a zip archive of sourceDir at destPath.
-// Returns the number of files archived.
-// exclude is a list of patterns to skip (e.g., "node_modules", "*.pyc").
-func (a *ZipArchiver) Create(destPath, sourceDir string, exclude []string) (int, error) {
-	zipFile, err := os.Create(destPath)
+// Returns the number of files archived and the files that could not be read.
+// exclude decides which files and directories to skip; nil archives everything.
+// compression selects the deflate level; CompressNone stores entries as is.
+// progress, if non-nil, is called after each file is written.
+// The archive is written to a temporary file and only renamed to destPath once
+// complete, so a crash or cancellation never leaves a truncated zip under the
+// final name. Unreadable files are left out and reported in the result. Each
+// file is read in full before its entry is added, so one that fails partway
+// through is left out too. Errors writing the archive itself fail the whole call.
+func (a *ZipArchiver) Create(ctx context.Context, destPath, sourceDir string, exclude ports.Excluder, compression ports.Compression, progress ports.ProgressFunc) (ports.ArchiveResult, error) {
+	var result ports.ArchiveResult
+	zipFile, err := atomicfile.Create(destPath, 0644)
 	if err != nil {
-		return 0, err
+		return result, err
 	}
 
 	w := zip.NewWriter(zipFile)
-	fileCount := 0
+	method := zip.Deflate
+	switch compression {
+	case ports.CompressNone:
+		method = zip.Store
+	case ports.CompressFast, ports.CompressBest:
+		level := flate.BestSpeed
+		if compression == ports.CompressBest {
+			level = flate.BestCompression
+		}
+		w.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, level)
+		})
+	}
+	var bytesDone int64
 	baseName := filepath.Base(sourceDir)
+	sp := &spool{dir: filepath.Dir(destPath), name: filepath.Base(destPath)}
+	defer sp.close()
+
+	skip := func(path string, err error) {
+		relPath, relErr := filepath.Rel(sourceDir, path)
+		if relErr != nil {
+			relPath = path
+		}
+		result.Skipped = append(result.Skipped, ports.SkippedFile{Path: filepath.ToSlash(relPath), Reason: err.Error()})
+	}
 
 	walkErr := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		if err != nil {
-			return nil // Skip files with errors
+			// Unreadable files and directories are recorded unless excluded anyway
+			if !excluded(exclude, sourceDir, path, info != nil && info.IsDir()) {
+				skip(path, err)
+			}
+			return nil
 		}
 
 		// Check exclusions
-		if shouldExclude(path, exclude) {
+		if excluded(exclude, sourceDir, path, info.IsDir()) {
 			if info.IsDir() {
 				return filepath.SkipDir
 			}
@@ -76,52 +192,79 @@ func (a *ZipArchiver) Create(destPath, sourceDir string, exclude []string) (int,
 			return nil // Directories are created implicitly
 		}
 
-		// Create file header
-		header, err := zip.FileInfoHeader(info)
+		// Read the whole file before adding an entry so unreadable files,
+		// and files that fail partway through, leave no trace
+		file, err := openSource(path)
 		if err != nil {
+			skip(path, err)
 			return nil
 		}
-		header.Name = archivePath
-		header.Method = zip.Deflate
+		defer func() { _ = file.Close() }()
 
-		writer, err := w.CreateHeader(header)
-		if err != nil {
+		// Spool file content, hashing it for the file index
+		src := &sourceReader{r: file}
+		content, n, sum, spoolErr := sp.fill(src, info.Size())
+		if src.err != nil {
+			skip(path, src.err)
 			return nil
 		}
+		if spoolErr != nil {
+			return fmt.Errorf("reading %s: %w", relPath, spoolErr)
+		}
 
-		// Copy file content
-		file, err := os.Open(path)
+		// Create file header
+		header, err := zip.FileInfoHeader(info)
 		if err != nil {
+			skip(path, err)
 			return nil
 		}
+		header.Name = archivePath
+		header.Method = method
 
-		_, copyErr := io.Copy(writer, file)
-		_ = file.Close() // Explicitly ignore close error - data already copied
-
-		if copyErr != nil {
-			return nil
+		writer, err := w.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("adding %s: %w", relPath, err)
+		}
+		if _, err := io.Copy(writer, content); err != nil {
+			return fmt.Errorf("writing %s: %w", relPath, err)
 		}
 
-		fileCount++
+		result.FileCount++
+		result.Files = append(result.Files, ports.ArchivedFile{
+			Path:    filepath.ToSlash(relPath),
+			Size:    n,
+			SHA256:  sum,
+			Mode:    info.Mode().Perm(),
+			ModTime: info.ModTime(),
+		})
+		bytesDone += n
+		if progress != nil {
+			progress(ports.Progress{Files: result.FileCount, Bytes: bytesDone, Path: filepath.ToSlash(relPath)})
+		}
 		return nil
 	})
 
 	// Close zip writer first to flush data
 	if closeErr := w.Close(); closeErr != nil {
-		_ = zipFile.Close() // Best effort cleanup on error path
-		return 0, fmt.Errorf("closing zip writer: %w", closeErr)
+		zipFile.Abort()
+		return ports.ArchiveResult{}, fmt.Errorf("closing zip writer: %w", closeErr)
+	}
+	if walkErr != nil {
+		zipFile.Abort()
+		return ports.ArchiveResult{}, walkErr
 	}
 
-	// Then close the file
-	if closeErr := zipFile.Close(); closeErr != nil {
-		return 0, fmt.Errorf("closing zip file: %w", closeErr)
+	// Then sync and move the file into place
+	if commitErr := zipFile.Commit(); commitErr != nil {
+		return ports.ArchiveResult{}, fmt.Errorf("closing zip file: %w", commitErr)
 	}
 
-	return fileCount, walkErr
+	return result, nil
 }
 
 // Extract extracts a zip archive to destDir.
-func (a *ZipArchiver) Extract(zipPath, destDir string) error {
+// Cancelling ctx stops before the next file.
+func (a *ZipArchiver) Extract(ctx context.Context, zipPath, destDir string) error {
 	r, err := zip.OpenReader(zipPath)
 	if err != nil {
 		return err
@@ -136,6 +279,10 @@ func (a *ZipArchiver) Extract(zipPath, destDir string) error {
 	absDestDir = filepath.Clean(absDestDir)
 
 	for _, f := range r.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		// SECURITY: Block symlinks to prevent symlink attacks
 		if f.Mode()&os.ModeSymlink != 0 {
 			return fmt.Errorf("symlinks not supported in backups: %s", f.Name)
@@ -169,6 +316,72 @@ func (a *ZipArchiver) Extract(zipPath, destDir string) error {
 	return nil
 }
 
+// ExtractFiles extracts the named files of a zip archive to destDir/<path>.
+// Cancelling ctx stops before the next file.
+func (a *ZipArchiver) ExtractFiles(ctx context.Context, zipPath, destDir string, paths []string) error {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+
+	absDestDir, err := filepath.Abs(destDir)
+	if err != nil {
+		return fmt.Errorf("resolving destination path: %w", err)
+	}
+	absDestDir = filepath.Clean(absDestDir)
+
+	wanted := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		wanted[p] = true
+	}
+
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		// Strip project prefix (first path component), as List does
+		name := f.Name
+		if idx := strings.Index(name, "/"); idx != -1 {
+			name = name[idx+1:]
+		}
+		if !wanted[name] {
+			continue
+		}
+		delete(wanted, name)
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// SECURITY: Block symlinks to prevent symlink attacks
+		if f.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("symlinks not supported in backups: %s", f.Name)
+		}
+
+		fpath := filepath.Join(destDir, filepath.FromSlash(name))
+
+		// SECURITY: Check for ZipSlip vulnerability
+		if !isWithinDir(absDestDir, fpath) {
+			return fmt.Errorf("invalid file path (path traversal detected): %s", f.Name)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+			return fmt.Errorf("creating parent directory for %s: %w", fpath, err)
+		}
+		if err := extractFile(f, fpath); err != nil {
+			return fmt.Errorf("extracting %s: %w", f.Name, err)
+		}
+	}
+
+	for _, p := range paths {
+		if wanted[p] {
+			return fmt.Errorf("not in archive: %s", p)
+		}
+	}
+	return nil
+}
+
 // MaxDecompressSize is the maximum allowed uncompressed file size (10GB).
 // This prevents decompression bomb attacks (G110).
 const MaxDecompressSize = 10 * 1024 * 1024 * 1024 // 10GB
@@ -248,8 +461,9 @@ func (a *ZipArchiver) List(zipPath string) (map[string]ports.FileInfo, error) {
 			size = int64(f.UncompressedSize64) // #nosec G115 -- bounds checked above
 		}
 		files[name] = ports.FileInfo{
-			Size:  size,
-			CRC32: f.CRC32,
+			Size:    size,
+			CRC32:   f.CRC32,
+			ModTime: f.Modified,
 		}
 	}
 
@@ -286,5 +500,63 @@ func (a *ZipArchiver) ReadFile(zipPath, filePath, projectName string) (string, e
 	return "", fmt.Errorf("file not found in archive: %s", filePath)
 }
 
+// Check reads back every file in a zip archive. archive/zip verifies each
+// entry's CRC32 once it has been read to the end.
+// Cancelling ctx stops before the next file.
+func (a *ZipArchiver) Check(ctx context.Context, zipPath string) ([]ports.CheckedFile, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	var checked []ports.CheckedFile
+	for _, f := range r.File {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		// Strip project prefix (first path component)
+		name := f.Name
+		if idx := strings.Index(name, "/"); idx != -1 {
+			name = name[idx+1:]
+		}
+		checked = append(checked, checkFile(f, name))
+	}
+	return checked, nil
+}
+
+// checkFile reads a single file from the zip, hashing its content.
+func checkFile(f *zip.File, name string) ports.CheckedFile {
+	result := ports.CheckedFile{Path: name}
+	if f.UncompressedSize64 > MaxDecompressSize {
+		result.Err = fmt.Errorf("file too large: %d bytes exceeds limit of %d bytes", f.UncompressedSize64, MaxDecompressSize)
+		return result
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer func() { _ = rc.Close() }()
+
+	h := sha256.New()
+	result.Size, err = io.Copy(h, io.LimitReader(rc, int64(f.UncompressedSize64)+1))
+	result.SHA256 = hex.EncodeToString(h.Sum(nil))
+	switch {
+	case errors.Is(err, zip.ErrChecksum):
+		result.Err = fmt.Errorf("CRC32 mismatch")
+	case err != nil:
+		result.Err = err
+	case uint64(result.Size) != f.UncompressedSize64:
+		result.Err = fmt.Errorf("size mismatch: expected %d bytes, read %d", f.UncompressedSize64, result.Size)
+	}
+	return result
+}
+
 // Compile-time check that ZipArchiver implements ports.Archiver.
 var _ ports.Archiver = (*ZipArchiver)(nil)
@@ -0,0 +1,104 @@
+package ziparchiver
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmcdonald/codebak/internal/ports"
+)
+
+// failingReader returns its data and then err.
+type failingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, f.err
+	}
+	return n, err
+}
+
+func (f *failingReader) Close() error { return nil }
+
+func TestCreateLeavesOutFilesFailingMidStream(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "myproject")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	large := bytes.Repeat([]byte("x"), spoolLimit+1)
+	files := map[string][]byte{
+		"main.go":   []byte("package main"),
+		"broken.go": []byte("package broken"),
+		"large.bin": large,
+		"huge.bin":  large,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(sourceDir, name), content, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	// broken.go and huge.bin fail after half their content has been read
+	readErr := errors.New("input/output error")
+	openSource = func(path string) (io.ReadCloser, error) {
+		content := files[filepath.Base(path)]
+		switch filepath.Base(path) {
+		case "broken.go", "huge.bin":
+			return &failingReader{r: bytes.NewReader(content[:len(content)/2]), err: readErr}, nil
+		}
+		return os.Open(path)
+	}
+	t.Cleanup(func() {
+		openSource = func(path string) (io.ReadCloser, error) { return os.Open(path) }
+	})
+
+	destPath := filepath.Join(tempDir, "backup.zip")
+	result, err := New().Create(context.Background(), destPath, sourceDir, nil, ports.CompressDefault, nil)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if result.FileCount != 2 || len(result.Skipped) != 2 {
+		t.Errorf("result = %d files, skipped %+v; expected 2 archived and 2 skipped", result.FileCount, result.Skipped)
+	}
+
+	r, err := zip.OpenReader(destPath)
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	defer func() { _ = r.Close() }()
+	entries := make(map[string]*zip.File)
+	for _, f := range r.File {
+		entries[f.Name] = f
+	}
+	for _, name := range []string{"broken.go", "huge.bin"} {
+		if _, ok := entries["myproject/"+name]; ok {
+			t.Errorf("archive has an entry for %s, which failed partway through", name)
+		}
+	}
+	f, ok := entries["myproject/large.bin"]
+	if !ok {
+		t.Fatal("archive is missing large.bin")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		t.Fatalf("Failed to open large.bin: %v", err)
+	}
+	defer func() { _ = rc.Close() }()
+	if data, err := io.ReadAll(rc); err != nil || !bytes.Equal(data, large) {
+		t.Errorf("large.bin was not archived intact (err %v)", err)
+	}
+
+	if leftovers, _ := filepath.Glob(filepath.Join(tempDir, ".*.partial")); len(leftovers) != 0 {
+		t.Errorf("temporary files were left behind: %v", leftovers)
+	}
+}
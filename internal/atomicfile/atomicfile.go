@@ -0,0 +1,138 @@
+// Package atomicfile writes files crash-safely. Content goes to a temporary
+// file next to the destination, is fsynced, and is then renamed into place,
+// so readers only ever see the previous file or the complete new one.
+package atomicfile
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// PartialSuffix marks temporary files that have not been committed yet.
+// Files with this suffix left behind by a crash are safe to delete.
+const PartialSuffix = ".partial"
+
+// File is a temporary file that replaces its destination on Commit.
+type File struct {
+	*os.File
+	path string
+	done bool
+}
+
+// Create opens a temporary file in the directory of path. Write to it, then
+// call Commit to move it into place, or Abort to discard it.
+func Create(path string, perm os.FileMode) (*File, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, "."+base+".*"+PartialSuffix)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return nil, err
+	}
+	return &File{File: tmp, path: path}, nil
+}
+
+// Commit flushes the file to disk and atomically renames it to its destination.
+// On failure the temporary file is removed and the destination is untouched.
+func (f *File) Commit() error {
+	if f.done {
+		return fmt.Errorf("atomicfile: %s already committed or aborted", f.path)
+	}
+	f.done = true
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("syncing %s: %w", f.path, err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("closing %s: %w", f.path, err)
+	}
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("renaming %s: %w", f.path, err)
+	}
+	SyncDir(filepath.Dir(f.path))
+	return nil
+}
+
+// Abort discards the temporary file. It is a no-op after Commit.
+func (f *File) Abort() {
+	if f.done {
+		return
+	}
+	f.done = true
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+}
+
+// WriteFile atomically replaces path with data.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	f, err := Create(path, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
+
+// IsPartial reports whether name is an uncommitted temporary file.
+func IsPartial(name string) bool {
+	return strings.HasSuffix(name, PartialSuffix)
+}
+
+// CleanupPartial removes temporary files left under dir by interrupted writes.
+// Subdirectories of dir named in skipDirs are left out of the scan.
+// Callers must ensure no write is in progress, e.g. by holding the project lock.
+// Returns the removed paths. A missing dir is not an error.
+func CleanupPartial(dir string, skipDirs ...string) ([]string, error) {
+	var removed []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			if filepath.Dir(path) == filepath.Clean(dir) && slices.Contains(skipDirs, d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !IsPartial(d.Name()) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		removed = append(removed, path)
+		return nil
+	})
+	return removed, err
+}
+
+// SyncDir flushes a directory so the renames in it survive a crash. Not every
+// filesystem supports syncing directories, so errors are ignored.
+func SyncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
@@ -0,0 +1,134 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileReplaces(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if err := WriteFile(path, []byte("new"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(data) != "new" {
+		t.Errorf("content = %q, expected %q", data, "new")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, expected 0600", info.Mode().Perm())
+	}
+	assertNoPartial(t, dir)
+}
+
+func TestAbortLeavesDestinationUntouched(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "backup.zip")
+
+	f, err := Create(path, 0644)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := f.Write([]byte("half written")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Until committed, only the temp file exists
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("destination should not exist before Commit")
+	}
+	if !IsPartial(f.Name()) {
+		t.Errorf("temp file %s should be recognised as partial", f.Name())
+	}
+
+	f.Abort()
+	f.Abort() // Safe to call twice
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("destination should not exist after Abort")
+	}
+	assertNoPartial(t, dir)
+	if err := f.Commit(); err == nil {
+		t.Error("Commit after Abort should fail")
+	}
+}
+
+func TestCleanupPartial(t *testing.T) {
+	dir := t.TempDir()
+	keep := filepath.Join(dir, "20240101-120000.zip")
+	stale := filepath.Join(dir, ".20240102-120000.zip.123"+PartialSuffix)
+	nested := filepath.Join(dir, "chunks", "ab", ".abcd.456"+PartialSuffix)
+	for _, p := range []string{keep, stale, nested} {
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	removed, err := CleanupPartial(dir)
+	if err != nil {
+		t.Fatalf("CleanupPartial failed: %v", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("removed %v, expected 2 files", removed)
+	}
+	if _, err := os.Stat(keep); err != nil {
+		t.Error("committed files must be kept")
+	}
+	assertNoPartial(t, dir)
+
+	if _, err := CleanupPartial(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("CleanupPartial on a missing dir should succeed, got %v", err)
+	}
+}
+
+func TestCleanupPartialSkipsDirs(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "files", ".20240102-120000.json.123"+PartialSuffix)
+	skipped := filepath.Join(dir, "chunks", "ab", ".abcd.456"+PartialSuffix)
+	for _, p := range []string{stale, skipped} {
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	removed, err := CleanupPartial(dir, "chunks")
+	if err != nil {
+		t.Fatalf("CleanupPartial failed: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != stale {
+		t.Errorf("removed %v, expected only %s", removed, stale)
+	}
+	if _, err := os.Stat(skipped); err != nil {
+		t.Error("temp files in skipped directories must be kept")
+	}
+}
+
+// assertNoPartial fails if any temp file remains under dir.
+func assertNoPartial(t *testing.T, dir string) {
+	t.Helper()
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && IsPartial(info.Name()) {
+			t.Errorf("leftover temp file %s", path)
+		}
+		return nil
+	})
+}
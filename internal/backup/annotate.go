@@ -0,0 +1,46 @@
+package backup
+
+import (
+	"fmt"
+
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/manifest"
+)
+
+// Annotate pins, labels or notes a backup version of project and saves the
+// manifest, holding the project lock so a concurrent backup cannot lose the
+// change. Returns the updated entry.
+func (s *Service) Annotate(cfg *config.Config, project, version string, a manifest.Annotation) (manifest.BackupEntry, error) {
+	backupDir, err := config.ExpandPath(cfg.BackupDir)
+	if err != nil {
+		return manifest.BackupEntry{}, err
+	}
+	if _, err := s.fs.Stat(manifest.ManifestPath(backupDir, project)); err != nil {
+		return manifest.BackupEntry{}, fmt.Errorf("no backups found for %s", project)
+	}
+
+	unlock, err := s.lockProject(cfg, backupDir, project)
+	if err != nil {
+		return manifest.BackupEntry{}, err
+	}
+	defer unlock()
+
+	m, err := manifest.Load(backupDir, project)
+	if err != nil {
+		return manifest.BackupEntry{}, fmt.Errorf("loading manifest: %w", err)
+	}
+	entry, err := m.Annotate(version, a)
+	if err != nil {
+		return manifest.BackupEntry{}, err
+	}
+	if err := m.Save(backupDir); err != nil {
+		return manifest.BackupEntry{}, fmt.Errorf("saving manifest: %w", err)
+	}
+	return *entry, nil
+}
+
+// Annotate pins, labels or notes a backup version of project.
+// Uses the default production dependencies.
+func Annotate(cfg *config.Config, project, version string, a manifest.Annotation) (manifest.BackupEntry, error) {
+	return defaultService.Annotate(cfg, project, version, a)
+}
@@ -0,0 +1,54 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/manifest"
+)
+
+func TestAnnotateSavesManifest(t *testing.T) {
+	backupDir := t.TempDir()
+	files := writeVersions(t, backupDir, "proj", 3)
+	cfg := &config.Config{BackupDir: backupDir, Retention: config.RetentionConfig{KeepLast: 1}}
+	svc := newPruneService()
+
+	pinned := true
+	entry, err := svc.Annotate(cfg, "proj", manifest.VersionName(files[0]), manifest.Annotation{Pinned: &pinned, AddLabels: []string{"pre-refactor"}})
+	if err != nil {
+		t.Fatalf("Annotate failed: %v", err)
+	}
+	if !entry.Pinned || !entry.HasLabel("pre-refactor") {
+		t.Errorf("entry = %+v, expected pinned and labelled", entry)
+	}
+
+	// The pin survives a prune that would otherwise remove the version
+	results, err := svc.Prune(cfg, "proj", false)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if len(results[0].Removed) != 1 {
+		t.Errorf("Removed = %v, expected only the unpinned middle version", results[0].Removed)
+	}
+	m, err := manifest.Load(backupDir, "proj")
+	if err != nil {
+		t.Fatalf("manifest.Load failed: %v", err)
+	}
+	if len(m.Backups) != 2 || m.Backups[0].File != files[0] || !m.Backups[0].Pinned {
+		t.Errorf("manifest backups = %+v, expected the pinned version to remain", m.Backups)
+	}
+}
+
+func TestAnnotateUnknownVersion(t *testing.T) {
+	backupDir := t.TempDir()
+	writeVersions(t, backupDir, "proj", 1)
+	cfg := &config.Config{BackupDir: backupDir}
+
+	note := "hello"
+	if _, err := newPruneService().Annotate(cfg, "proj", "19990101-000000", manifest.Annotation{Note: &note}); err == nil {
+		t.Error("Annotate should fail for an unknown version")
+	}
+	if _, err := newPruneService().Annotate(cfg, "missing", "19990101-000000", manifest.Annotation{Note: &note}); err == nil {
+		t.Error("Annotate should fail for a project without backups")
+	}
+}
@@ -1,17 +1,27 @@
 package backup
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/jmcdonald/codebak/internal/adapters/chunkstore"
 	"github.com/jmcdonald/codebak/internal/adapters/execgit"
 	"github.com/jmcdonald/codebak/internal/adapters/execrestic"
+	"github.com/jmcdonald/codebak/internal/adapters/filelock"
+	"github.com/jmcdonald/codebak/internal/adapters/multiarchiver"
 	"github.com/jmcdonald/codebak/internal/adapters/osfs"
-	"github.com/jmcdonald/codebak/internal/adapters/ziparchiver"
+	"github.com/jmcdonald/codebak/internal/atomicfile"
 	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/fileindex"
+	"github.com/jmcdonald/codebak/internal/ignore"
 	"github.com/jmcdonald/codebak/internal/manifest"
 	"github.com/jmcdonald/codebak/internal/ports"
 )
@@ -28,12 +38,8 @@ type BackupResult struct {
 	Reason     string
 	Error      error
 	SourceType config.SourceType // git or sensitive
-}
-
-// ZipResult contains results from createZip including any skipped files.
-type ZipResult struct {
-	FileCount int
-	Skipped   []string
+	// SkippedFiles lists files that could not be read and were left out
+	SkippedFiles []manifest.SkippedFile
 }
 
 // Service provides backup operations with injected dependencies.
@@ -42,16 +48,31 @@ type Service struct {
 	git      ports.GitClient
 	archiver ports.Archiver
 	restic   ports.ResticClient
+	locker   ports.Locker // nil disables cross-process locking
+}
+
+// Option is a functional option for configuring Service.
+type Option func(*Service)
+
+// WithLocker sets the locker used to serialize backups with other codebak processes.
+func WithLocker(locker ports.Locker) Option {
+	return func(s *Service) {
+		s.locker = locker
+	}
 }
 
 // NewService creates a new backup service with the given dependencies.
-func NewService(fs ports.FileSystem, git ports.GitClient, archiver ports.Archiver, restic ports.ResticClient) *Service {
-	return &Service{
+func NewService(fs ports.FileSystem, git ports.GitClient, archiver ports.Archiver, restic ports.ResticClient, opts ...Option) *Service {
+	s := &Service{
 		fs:       fs,
 		git:      git,
 		archiver: archiver,
 		restic:   restic,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // NewDefaultService creates a backup service with real production dependencies.
@@ -59,11 +80,42 @@ func NewDefaultService() *Service {
 	return NewService(
 		osfs.New(),
 		execgit.New(),
-		ziparchiver.New(),
+		multiarchiver.New(),
 		execrestic.New(),
+		WithLocker(filelock.New()),
 	)
 }
 
+// lockProject takes the cross-process lock for a project's backups and
+// returns the function that releases it.
+func (s *Service) lockProject(cfg *config.Config, backupDir, project string) (func(), error) {
+	if s.locker == nil {
+		return func() {}, nil
+	}
+	l, err := s.locker.LockProject(backupDir, project, cfg.LockWait)
+	if err != nil {
+		return nil, err
+	}
+	return func() { _ = l.Unlock() }, nil
+}
+
+// lockBackupDir takes the cross-process lock for the whole backup directory
+// and returns the function that releases it.
+func (s *Service) lockBackupDir(cfg *config.Config) (func(), error) {
+	if s.locker == nil {
+		return func() {}, nil
+	}
+	backupDir, err := config.ExpandPath(cfg.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+	l, err := s.locker.LockBackupDir(backupDir, cfg.LockWait)
+	if err != nil {
+		return nil, err
+	}
+	return func() { _ = l.Unlock() }, nil
+}
+
 // ListProjects returns all directories in the source directory.
 func (s *Service) ListProjects(sourceDir string) ([]string, error) {
 	entries, err := s.fs.ReadDir(sourceDir)
@@ -81,8 +133,8 @@ func (s *Service) ListProjects(sourceDir string) ([]string, error) {
 }
 
 // GetGitHead returns the current HEAD commit hash for a git repo.
-func (s *Service) GetGitHead(projectPath string) string {
-	return s.git.GetHead(projectPath)
+func (s *Service) GetGitHead(ctx context.Context, projectPath string) string {
+	return s.git.GetHead(ctx, projectPath)
 }
 
 // shortHash returns the first 7 characters of a hash, or the full hash if shorter.
@@ -93,8 +145,43 @@ func shortHash(hash string) string {
 	return hash
 }
 
+// DirtyFingerprint returns a fingerprint of the uncommitted changes in a git
+// working tree: modified, staged and untracked (non-ignored) files. Returns an
+// empty string for a clean tree. The fingerprint covers each changed file's
+// status, size and mtime, so further edits to an already-dirty file change it.
+// Files skipped by exclude (which may be nil) are left out, since the backup
+// would not contain them either.
+func (s *Service) DirtyFingerprint(ctx context.Context, projectPath string, exclude ports.Excluder) (string, error) {
+	all, err := s.git.Status(ctx, projectPath)
+	if err != nil {
+		return "", err
+	}
+	var status []ports.GitFileStatus
+	for _, st := range all {
+		if exclude == nil || !exclude.Excluded(st.Path, false) {
+			status = append(status, st)
+		}
+	}
+	if len(status) == 0 {
+		return "", nil
+	}
+
+	sort.Slice(status, func(i, j int) bool { return status[i].Path < status[j].Path })
+
+	h := sha256.New()
+	for _, st := range status {
+		fmt.Fprintf(h, "%s %s", st.Code, st.Path)
+		if info, err := s.fs.Stat(filepath.Join(projectPath, st.Path)); err == nil {
+			fmt.Fprintf(h, " %d %d", info.Size(), info.ModTime().UnixNano())
+		}
+		_, _ = h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 // HasChanges checks if project has changed since last backup.
-func (s *Service) HasChanges(projectPath string, lastBackup *manifest.BackupEntry) (bool, string) {
+// Files skipped by exclude (which may be nil) are not considered.
+func (s *Service) HasChanges(ctx context.Context, projectPath string, lastBackup *manifest.BackupEntry, exclude ports.Excluder) (bool, string) {
 	// If no previous backup, definitely has changes
 	if lastBackup == nil {
 		return true, "no previous backup"
@@ -103,12 +190,23 @@ func (s *Service) HasChanges(projectPath string, lastBackup *manifest.BackupEntr
 	// Check if it's a git repo
 	if s.git.IsRepo(projectPath) {
 		// It's a git repo - compare HEAD
-		currentHead := s.git.GetHead(projectPath)
+		currentHead := s.git.GetHead(ctx, projectPath)
 		if currentHead != "" && currentHead != lastBackup.GitHead {
 			return true, fmt.Sprintf("git HEAD changed: %s -> %s", shortHash(lastBackup.GitHead), shortHash(currentHead))
 		}
 		if currentHead == lastBackup.GitHead {
-			return false, "git HEAD unchanged"
+			// Same commit - uncommitted work still needs protecting
+			dirty, err := s.DirtyFingerprint(ctx, projectPath, exclude)
+			if err == nil {
+				if dirty != lastBackup.DirtyHash {
+					if dirty == "" {
+						return true, "uncommitted changes discarded"
+					}
+					return true, "uncommitted changes"
+				}
+				return false, "git HEAD unchanged"
+			}
+			// git status failed - fall back to mtime check below
 		}
 	}
 
@@ -118,6 +216,14 @@ func (s *Service) HasChanges(projectPath string, lastBackup *manifest.BackupEntr
 		if err != nil {
 			return nil
 		}
+		if exclude != nil {
+			if relPath, err := filepath.Rel(projectPath, path); err == nil && exclude.Excluded(relPath, info.IsDir()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
 		if info.ModTime().After(lastBackup.CreatedAt) {
 			hasNewer = true
 			return filepath.SkipAll
@@ -131,34 +237,76 @@ func (s *Service) HasChanges(projectPath string, lastBackup *manifest.BackupEntr
 	return false, "no changes detected"
 }
 
-// shouldExclude checks if a path should be excluded.
-func shouldExclude(path string, excludePatterns []string) bool {
-	base := filepath.Base(path)
-	for _, pattern := range excludePatterns {
-		// Check exact match
-		if base == pattern {
-			return true
+// trackProgress wraps onProgress so each archiver update carries the totals
+// for the project and an ETA. It returns nil when onProgress is nil, which
+// also skips measuring the project.
+func (s *Service) trackProgress(project, projectPath string, exclude ports.Excluder, onProgress ProgressFunc) ports.ProgressFunc {
+	if onProgress == nil {
+		return nil
+	}
+	filesTotal, bytesTotal := s.measure(projectPath, exclude)
+	start := time.Now()
+	return func(p ports.Progress) {
+		p.FilesTotal, p.BytesTotal = filesTotal, bytesTotal
+		p.ETA = estimateRemaining(time.Since(start), p.Fraction())
+		onProgress(project, p)
+	}
+}
+
+// measure counts the regular files and bytes an archive of projectPath will hold.
+func (s *Service) measure(projectPath string, exclude ports.Excluder) (files int, bytes int64) {
+	_ = s.fs.Walk(projectPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
 		}
-		// Check glob pattern
-		if matched, _ := filepath.Match(pattern, base); matched {
-			return true
+		if exclude != nil {
+			if relPath, err := filepath.Rel(projectPath, path); err == nil && exclude.Excluded(relPath, info.IsDir()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if info.Mode().IsRegular() {
+			files++
+			bytes += info.Size()
 		}
+		return nil
+	})
+	return files, bytes
+}
+
+// estimateRemaining extrapolates the time left from the time spent so far.
+func estimateRemaining(elapsed time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 || fraction >= 1 {
+		return 0
 	}
-	return false
+	return time.Duration(float64(elapsed) * (1 - fraction) / fraction)
 }
 
-// BackupProject creates a zip backup of a single project.
-func (s *Service) BackupProject(cfg *config.Config, project string) BackupResult {
-	result := BackupResult{Project: project}
+// excluder builds the exclusion rules for a project from its effective
+// patterns and the project's ignore files.
+func excluder(cfg *config.Config, eff config.Effective, projectPath string) ports.Excluder {
+	return ignore.New(projectPath, eff.Exclude, ignore.WithGitignore(cfg.RespectGitignore))
+}
 
-	backupDir, err := config.ExpandPath(cfg.BackupDir)
-	if err != nil {
-		result.Error = err
-		return result
+// compression maps a configured compression setting for the archiver.
+func compression(c config.Compression) ports.Compression {
+	switch c {
+	case config.CompressionNone:
+		return ports.CompressNone
+	case config.CompressionFast:
+		return ports.CompressFast
+	case config.CompressionBest:
+		return ports.CompressBest
+	default:
+		return ports.CompressDefault
 	}
+}
 
-	// Search for project in all sources
-	var projectPath string
+// locateProject returns the directory of project and the source containing
+// it, searching sources in order. The path is "" when no source has it.
+func (s *Service) locateProject(cfg *config.Config, project string) (string, *config.Source) {
 	for _, source := range cfg.GetSources() {
 		sourceDir, err := config.ExpandPath(source.Path)
 		if err != nil {
@@ -166,17 +314,80 @@ func (s *Service) BackupProject(cfg *config.Config, project string) BackupResult
 		}
 		candidatePath := filepath.Join(sourceDir, project)
 		if _, err := s.fs.Stat(candidatePath); err == nil {
-			projectPath = candidatePath
-			break
+			return candidatePath, &source
 		}
 	}
+	return "", nil
+}
+
+// Effective resolves the settings used to back up project: the global
+// config with the overrides of its source and of the project applied.
+func (s *Service) Effective(cfg *config.Config, project string) config.Effective {
+	_, source := s.locateProject(cfg, project)
+	return cfg.Effective(project, source)
+}
 
-	// Check if project was found
+// ProgressFunc receives archive progress for the named project.
+type ProgressFunc func(project string, p ports.Progress)
+
+// BackupProject creates a zip backup of a single project.
+func (s *Service) BackupProject(ctx context.Context, cfg *config.Config, project string) BackupResult {
+	return s.BackupProjectProgress(ctx, cfg, project, nil)
+}
+
+// BackupProjectProgress creates a zip backup of a single project, calling
+// onProgress (if non-nil) as files are archived. Cancelling ctx stops the
+// backup and removes anything it has written.
+func (s *Service) BackupProjectProgress(ctx context.Context, cfg *config.Config, project string, onProgress ProgressFunc) BackupResult {
+	start := time.Now()
+	result := BackupResult{Project: project}
+	if err := ctx.Err(); err != nil {
+		result.Error = err
+		return result
+	}
+
+	backupDir, err := config.ExpandPath(cfg.BackupDir)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+
+	// Search for project in all sources
+	projectPath, source := s.locateProject(cfg, project)
 	if projectPath == "" {
 		result.Error = fmt.Errorf("project not found: %s", project)
 		return result
 	}
 
+	eff := cfg.Effective(project, source)
+	if !eff.Enabled {
+		result.Skipped = true
+		result.Reason = "disabled in config"
+		return result
+	}
+	// Configs that did not come from Load have not been validated
+	if err := config.CheckStorage(cfg.Storage); err != nil {
+		result.Error = err
+		return result
+	}
+	if err := config.CheckCompression(eff.Compression); err != nil {
+		result.Error = err
+		return result
+	}
+
+	// Hold the project lock until the manifest is saved so concurrent
+	// runs cannot lose each other's entries
+	unlock, err := s.lockProject(cfg, backupDir, project)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	defer unlock()
+
+	// Clear out temp files left behind by an interrupted run. The chunk store
+	// can be large, so its temp files are left to chunk garbage collection.
+	_, _ = atomicfile.CleanupPartial(filepath.Join(backupDir, project), chunkstore.ChunksDirName)
+
 	// Load manifest
 	m, err := manifest.Load(backupDir, project)
 	if err != nil {
@@ -186,13 +397,21 @@ func (s *Service) BackupProject(cfg *config.Config, project string) BackupResult
 	m.Source = projectPath
 
 	// Check for changes
-	hasChanges, reason := s.HasChanges(projectPath, m.LatestBackup())
+	exclude := excluder(cfg, eff, projectPath)
+	hasChanges, reason := s.HasChanges(ctx, projectPath, m.LatestBackup(), exclude)
 	if !hasChanges {
 		result.Skipped = true
 		result.Reason = reason
 		return result
 	}
 
+	// Fingerprint uncommitted work before archiving so edits made during the
+	// backup are picked up by the next run
+	var dirtyHash string
+	if s.git.IsRepo(projectPath) {
+		dirtyHash, _ = s.DirtyFingerprint(ctx, projectPath, exclude)
+	}
+
 	// Create backup directory
 	projectBackupDir := filepath.Join(backupDir, project)
 	if err := s.fs.MkdirAll(projectBackupDir, 0755); err != nil {
@@ -200,21 +419,67 @@ func (s *Service) BackupProject(cfg *config.Config, project string) BackupResult
 		return result
 	}
 
-	// Generate zip filename
+	// Generate archive filename; chunked storage writes a version index instead of a zip
 	timestamp := time.Now().Format("20060102-150405")
-	zipName := fmt.Sprintf("%s.zip", timestamp)
+	ext, format := ".zip", ""
+	if cfg.GetStorage() == config.StorageChunked {
+		ext, format = chunkstore.IndexExt, manifest.FormatChunked
+	}
+	zipName := timestamp + ext
 	zipPath := filepath.Join(projectBackupDir, zipName)
 
-	// Create zip file using archiver
-	fileCount, err := s.archiver.Create(zipPath, projectPath, cfg.Exclude)
+	var bundleName, bundleChecksum string
+	// discard removes the files of a version that failed before it was
+	// recorded, including a bundle left half written and the chunks only the
+	// dropped index referenced
+	discard := func() {
+		_ = s.fs.Remove(zipPath)
+		if bundleName != "" {
+			bundlePath := filepath.Join(projectBackupDir, bundleName)
+			_ = s.fs.Remove(bundlePath)
+			_ = s.fs.Remove(bundlePath + atomicfile.PartialSuffix)
+		}
+		if format == manifest.FormatChunked {
+			_ = collectChunks(projectBackupDir)
+		}
+	}
+
+	// Create archive using archiver
+	progress := s.trackProgress(project, projectPath, exclude, onProgress)
+	archived, err := s.archiver.Create(ctx, zipPath, projectPath, exclude, compression(eff.Compression), progress)
 	if err != nil {
+		discard()
 		result.Error = fmt.Errorf("creating zip: %w", err)
 		return result
 	}
+	result.SkippedFiles = skippedFiles(archived.Skipped)
+	if cfg.FailOnSkip && len(archived.Skipped) > 0 {
+		discard()
+		result.Error = fmt.Errorf("%d file(s) could not be read (fail_on_skip is set)", len(archived.Skipped))
+		return result
+	}
+
+	// Capture history, branches and stashes that the working tree copy misses
+	if cfg.GitBundle && s.git.IsRepo(projectPath) && s.git.GetHead(ctx, projectPath) != "" {
+		bundleName = timestamp + manifest.BundleExt
+		bundlePath := filepath.Join(projectBackupDir, bundleName)
+		if err := s.git.CreateBundle(ctx, projectPath, bundlePath); err != nil {
+			discard()
+			result.Error = fmt.Errorf("creating git bundle: %w", err)
+			return result
+		}
+		bundleChecksum, err = manifest.ComputeSHA256(bundlePath)
+		if err != nil {
+			discard()
+			result.Error = fmt.Errorf("computing bundle checksum: %w", err)
+			return result
+		}
+	}
 
 	// Get zip file info
 	zipInfo, err := s.fs.Stat(zipPath)
 	if err != nil {
+		discard()
 		result.Error = fmt.Errorf("stat zip: %w", err)
 		return result
 	}
@@ -222,45 +487,90 @@ func (s *Service) BackupProject(cfg *config.Config, project string) BackupResult
 	// Compute checksum
 	checksum, err := manifest.ComputeSHA256(zipPath)
 	if err != nil {
+		discard()
 		result.Error = fmt.Errorf("computing checksum: %w", err)
 		return result
 	}
 
+	// Last chance to back out before the version is recorded
+	if err := ctx.Err(); err != nil {
+		discard()
+		result.Error = err
+		return result
+	}
+
+	// Record every file's checksum so diffs and restore checks need not open the archive
+	if err := fileindex.Write(backupDir, project, timestamp, indexedFiles(archived.Files)); err != nil {
+		discard()
+		result.Error = fmt.Errorf("writing file index: %w", err)
+		return result
+	}
+
 	// Create manifest entry
 	entry := manifest.BackupEntry{
-		File:      zipName,
-		SHA256:    checksum,
-		SizeBytes: zipInfo.Size(),
-		CreatedAt: time.Now(),
-		GitHead:   s.git.GetHead(projectPath),
-		FileCount: fileCount,
-		Excluded:  cfg.Exclude,
+		File:         zipName,
+		SHA256:       checksum,
+		SizeBytes:    zipInfo.Size(),
+		CreatedAt:    time.Now(),
+		GitHead:      s.git.GetHead(ctx, projectPath),
+		DirtyHash:    dirtyHash,
+		FileCount:    archived.FileCount,
+		Excluded:     eff.Exclude,
+		Format:       format,
+		Bundle:       bundleName,
+		BundleSHA256: bundleChecksum,
+		Skipped:      result.SkippedFiles,
+		Provenance:   s.provenance(ctx, cfg, eff, projectPath, dirtyHash, start),
 	}
 
 	m.AddBackup(entry)
 
 	// Prune old backups if retention is configured
-	if cfg.Retention.KeepLast > 0 {
-		_, _ = m.Prune(backupDir, cfg.Retention.KeepLast)
+	if policy := eff.Retention.Policy(); !policy.Empty() {
+		_, _ = m.ApplyRetention(backupDir, policy, collectChunks)
 	}
 
 	// Save manifest
 	if err := m.Save(backupDir); err != nil {
+		discard()
+		_ = fileindex.Remove(backupDir, project, timestamp)
 		result.Error = fmt.Errorf("saving manifest: %w", err)
 		return result
 	}
 
 	result.ZipPath = zipPath
 	result.Size = zipInfo.Size()
-	result.FileCount = fileCount
+	result.FileCount = archived.FileCount
 	result.GitHead = entry.GitHead
 	result.Reason = reason
 
 	return result
 }
 
+// skippedFiles converts the archiver's unreadable files for the manifest.
+func skippedFiles(files []ports.SkippedFile) []manifest.SkippedFile {
+	if len(files) == 0 {
+		return nil
+	}
+	skipped := make([]manifest.SkippedFile, len(files))
+	for i, f := range files {
+		skipped[i] = manifest.SkippedFile{Path: f.Path, Reason: f.Reason}
+	}
+	return skipped
+}
+
+// indexedFiles converts the archiver's file list for the file index.
+func indexedFiles(files []ports.ArchivedFile) []fileindex.File {
+	indexed := make([]fileindex.File, len(files))
+	for i, f := range files {
+		indexed[i] = fileindex.File{Path: f.Path, Size: f.Size, SHA256: f.SHA256, Mode: f.Mode, ModTime: f.ModTime}
+	}
+	return indexed
+}
+
 // BackupSensitiveSource backs up a sensitive source using restic.
-func (s *Service) BackupSensitiveSource(cfg *config.Config, source config.Source) BackupResult {
+// Cancelling ctx interrupts restic.
+func (s *Service) BackupSensitiveSource(ctx context.Context, cfg *config.Config, source config.Source) BackupResult {
 	result := BackupResult{
 		Project:    source.Label,
 		SourceType: config.SourceTypeSensitive,
@@ -271,6 +581,13 @@ func (s *Service) BackupSensitiveSource(cfg *config.Config, source config.Source
 		result.Project = filepath.Base(source.Path)
 	}
 
+	eff := cfg.Effective(result.Project, &source)
+	if !eff.Enabled {
+		result.Skipped = true
+		result.Reason = "disabled in config"
+		return result
+	}
+
 	// Get restic repo path
 	repoPath, err := cfg.GetResticRepoPath()
 	if err != nil {
@@ -310,7 +627,7 @@ func (s *Service) BackupSensitiveSource(cfg *config.Config, source config.Source
 			result.Error = fmt.Errorf("creating restic repo directory: %w", err)
 			return result
 		}
-		if err := s.restic.Init(repoPath, password); err != nil {
+		if err := s.restic.Init(ctx, repoPath, password); err != nil {
 			result.Error = fmt.Errorf("initializing restic repo: %w", err)
 			return result
 		}
@@ -318,7 +635,7 @@ func (s *Service) BackupSensitiveSource(cfg *config.Config, source config.Source
 
 	// Create backup with source path as tag for identification
 	tag := filepath.Base(sourcePath)
-	snapshotID, err := s.restic.Backup(repoPath, password, []string{sourcePath}, []string{tag})
+	snapshotID, err := s.restic.Backup(ctx, repoPath, password, []string{sourcePath}, []string{tag})
 	if err != nil {
 		result.Error = fmt.Errorf("restic backup failed: %w", err)
 		return result
@@ -327,19 +644,136 @@ func (s *Service) BackupSensitiveSource(cfg *config.Config, source config.Source
 	result.SnapshotID = snapshotID
 	result.Reason = "restic backup created"
 
-	// Apply retention policy
-	if cfg.Retention.KeepLast > 0 {
-		_ = s.restic.Forget(repoPath, password, cfg.Retention.KeepLast, false)
+	// Apply the source's retention policy
+	if eff.Retention.KeepLast > 0 {
+		_ = s.restic.Forget(ctx, repoPath, password, eff.Retention.KeepLast, false)
 	}
 
 	return result
 }
 
 // RunBackup backs up all changed projects from all configured sources.
-func (s *Service) RunBackup(cfg *config.Config) ([]BackupResult, error) {
-	var results []BackupResult
+func (s *Service) RunBackup(ctx context.Context, cfg *config.Config) ([]BackupResult, error) {
+	return s.RunBackupStream(ctx, cfg, nil, nil)
+}
+
+// cleanupPartials removes temp files left by interrupted writes from every
+// project directory in backupDir, including projects that are no longer
+// backed up, leaving chunk stores out. The caller must hold the backup
+// directory lock.
+func (s *Service) cleanupPartials(backupDir string) {
+	entries, err := s.fs.ReadDir(backupDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		// Skip the lock directory and other codebak internals
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			_, _ = atomicfile.CleanupPartial(filepath.Join(backupDir, entry.Name()), chunkstore.ChunksDirName)
+		}
+	}
+}
+
+// backupTask is one project or sensitive source to back up during a full run.
+type backupTask struct {
+	sensitive bool
+	run       func(ctx context.Context, onProgress ProgressFunc) BackupResult
+}
+
+// RunBackupStream backs up all changed projects using up to cfg.GetJobs()
+// concurrent workers. onResult, if non-nil, is called as each backup finishes
+// (never concurrently). onProgress, if non-nil, receives archive progress for
+// git projects and is likewise never called concurrently. The returned results
+// are in source order regardless of completion order.
+//
+// Cancelling ctx stops backups in progress and starts no new ones; the
+// results of the backups that ran are returned along with ctx's error.
+func (s *Service) RunBackupStream(ctx context.Context, cfg *config.Config, onResult func(BackupResult), onProgress ProgressFunc) ([]BackupResult, error) {
+	// A full run owns the whole backup directory
+	unlock, err := s.lockBackupDir(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if backupDir, err := config.ExpandPath(cfg.BackupDir); err == nil {
+		s.cleanupPartials(backupDir)
+	}
+
+	tasks := s.planBackup(cfg)
+	results := make([]BackupResult, len(tasks))
+
+	jobs := cfg.GetJobs()
+	if jobs > len(tasks) {
+		jobs = len(tasks)
+	}
+
+	// Sensitive sources share one restic repository, so they run one at a time
+	var resticMu, resultMu sync.Mutex
+	var progress ProgressFunc
+	if onProgress != nil {
+		progress = func(project string, p ports.Progress) {
+			resultMu.Lock()
+			defer resultMu.Unlock()
+			onProgress(project, p)
+		}
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				task := tasks[i]
+				if task.sensitive {
+					resticMu.Lock()
+				}
+				result := task.run(ctx, progress)
+				if task.sensitive {
+					resticMu.Unlock()
+				}
+
+				resultMu.Lock()
+				results[i] = result
+				if onResult != nil {
+					onResult(result)
+				}
+				resultMu.Unlock()
+			}
+		}()
+	}
+	started := 0
+dispatch:
+	for started < len(tasks) {
+		select {
+		case next <- started:
+			started++
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return results[:started], err
+	}
+	return results, nil
+}
+
+// planBackup lists everything a full run backs up, in source order.
+func (s *Service) planBackup(cfg *config.Config) []backupTask {
+	var tasks []backupTask
 	seen := make(map[string]bool) // Track project names to avoid duplicates
 
+	gitTask := func(project string) backupTask {
+		return backupTask{run: func(ctx context.Context, onProgress ProgressFunc) BackupResult {
+			result := s.BackupProjectProgress(ctx, cfg, project, onProgress)
+			result.SourceType = config.SourceTypeGit
+			return result
+		}}
+	}
+
 	// Iterate over all sources
 	for _, source := range cfg.GetSources() {
 		// Branch on source type
@@ -353,8 +787,9 @@ func (s *Service) RunBackup(cfg *config.Config) ([]BackupResult, error) {
 				continue
 			}
 			seen[sourcePath] = true
-			result := s.BackupSensitiveSource(cfg, source)
-			results = append(results, result)
+			tasks = append(tasks, backupTask{sensitive: true, run: func(ctx context.Context, _ ProgressFunc) BackupResult {
+				return s.BackupSensitiveSource(ctx, cfg, source)
+			}})
 			continue
 		}
 
@@ -374,9 +809,7 @@ func (s *Service) RunBackup(cfg *config.Config) ([]BackupResult, error) {
 				continue
 			}
 			seen[project] = true
-			result := s.BackupProject(cfg, project)
-			result.SourceType = config.SourceTypeGit
-			results = append(results, result)
+			tasks = append(tasks, gitTask(project))
 		}
 	}
 
@@ -391,12 +824,10 @@ func (s *Service) RunBackup(cfg *config.Config) ([]BackupResult, error) {
 			continue
 		}
 		seen[name] = true
-		result := s.BackupProject(cfg, name)
-		result.SourceType = config.SourceTypeGit
-		results = append(results, result)
+		tasks = append(tasks, gitTask(name))
 	}
 
-	return results, nil
+	return tasks
 }
 
 // FormatSize formats bytes as human-readable.
@@ -413,6 +844,21 @@ func FormatSize(bytes int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
+// FormatProgress renders archive progress as a bar of the given width followed
+// by the percentage, file and byte counts and the ETA when known.
+func FormatProgress(p ports.Progress, width int) string {
+	fraction := p.Fraction()
+	filled := int(fraction * float64(width))
+	bar := strings.Repeat("#", filled) + strings.Repeat("-", width-filled)
+
+	out := fmt.Sprintf("[%s] %3d%%  %d/%d files  %s/%s", bar, int(fraction*100),
+		p.Files, p.FilesTotal, FormatSize(p.Bytes), FormatSize(p.BytesTotal))
+	if eta := p.ETA.Round(time.Second); eta > 0 {
+		out += "  ETA " + eta.String()
+	}
+	return out
+}
+
 // ============================================================================
 // Backward-compatible package-level functions using default service
 // ============================================================================
@@ -427,24 +873,42 @@ func ListProjects(sourceDir string) ([]string, error) {
 
 // GetGitHead returns the current HEAD commit hash for a git repo.
 // Uses the default production dependencies.
-func GetGitHead(projectPath string) string {
-	return defaultService.GetGitHead(projectPath)
+func GetGitHead(ctx context.Context, projectPath string) string {
+	return defaultService.GetGitHead(ctx, projectPath)
 }
 
 // HasChanges checks if project has changed since last backup.
 // Uses the default production dependencies.
-func HasChanges(projectPath string, lastBackup *manifest.BackupEntry) (bool, string) {
-	return defaultService.HasChanges(projectPath, lastBackup)
+func HasChanges(ctx context.Context, projectPath string, lastBackup *manifest.BackupEntry, exclude ports.Excluder) (bool, string) {
+	return defaultService.HasChanges(ctx, projectPath, lastBackup, exclude)
 }
 
 // BackupProject creates a zip backup of a single project.
 // Uses the default production dependencies.
-func BackupProject(cfg *config.Config, project string) BackupResult {
-	return defaultService.BackupProject(cfg, project)
+func BackupProject(ctx context.Context, cfg *config.Config, project string) BackupResult {
+	return defaultService.BackupProject(ctx, cfg, project)
+}
+
+// BackupProjectProgress creates a zip backup of a single project, reporting progress.
+// Uses the default production dependencies.
+func BackupProjectProgress(ctx context.Context, cfg *config.Config, project string, onProgress ProgressFunc) BackupResult {
+	return defaultService.BackupProjectProgress(ctx, cfg, project, onProgress)
 }
 
 // RunBackup backs up all changed projects.
 // Uses the default production dependencies.
-func RunBackup(cfg *config.Config) ([]BackupResult, error) {
-	return defaultService.RunBackup(cfg)
+func RunBackup(ctx context.Context, cfg *config.Config) ([]BackupResult, error) {
+	return defaultService.RunBackup(ctx, cfg)
+}
+
+// RunBackupStream backs up all changed projects, reporting each result as it finishes.
+// Uses the default production dependencies.
+func RunBackupStream(ctx context.Context, cfg *config.Config, onResult func(BackupResult), onProgress ProgressFunc) ([]BackupResult, error) {
+	return defaultService.RunBackupStream(ctx, cfg, onResult, onProgress)
+}
+
+// Effective resolves the settings used to back up project.
+// Uses the default production dependencies.
+func Effective(cfg *config.Config, project string) config.Effective {
+	return defaultService.Effective(cfg, project)
 }
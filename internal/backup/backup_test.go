@@ -2,17 +2,33 @@ package backup
 
 import (
 	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/jmcdonald/codebak/internal/adapters/chunkstore"
+	"github.com/jmcdonald/codebak/internal/adapters/multiarchiver"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/jmcdonald/codebak/internal/adapters/execgit"
 	"github.com/jmcdonald/codebak/internal/adapters/osfs"
 	"github.com/jmcdonald/codebak/internal/adapters/ziparchiver"
+	"github.com/jmcdonald/codebak/internal/atomicfile"
 	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/fileindex"
+	"github.com/jmcdonald/codebak/internal/ignore"
 	"github.com/jmcdonald/codebak/internal/manifest"
 	"github.com/jmcdonald/codebak/internal/mocks"
+	"github.com/jmcdonald/codebak/internal/ports"
 )
 
 func TestCreateZipRoundTrip(t *testing.T) {
@@ -49,13 +65,13 @@ func TestCreateZipRoundTrip(t *testing.T) {
 	// Create zip using archiver adapter
 	archiver := ziparchiver.New()
 	zipPath := filepath.Join(tempDir, "backup.zip")
-	fileCount, err := archiver.Create(zipPath, sourceDir, nil)
+	result, err := archiver.Create(context.Background(), zipPath, sourceDir, nil, ports.CompressDefault, nil)
 	if err != nil {
 		t.Fatalf("archiver.Create failed: %v", err)
 	}
 
-	if fileCount != len(testFiles) {
-		t.Errorf("fileCount = %d, expected %d", fileCount, len(testFiles))
+	if result.FileCount != len(testFiles) {
+		t.Errorf("fileCount = %d, expected %d", result.FileCount, len(testFiles))
 	}
 
 	// Verify zip contents
@@ -114,14 +130,14 @@ func TestCreateZipExclusions(t *testing.T) {
 	archiver := ziparchiver.New()
 	zipPath := filepath.Join(tempDir, "backup.zip")
 	exclude := []string{"node_modules", ".venv", "build", ".DS_Store"}
-	fileCount, err := archiver.Create(zipPath, sourceDir, exclude)
+	result, err := archiver.Create(context.Background(), zipPath, sourceDir, ignore.New(sourceDir, exclude), ports.CompressDefault, nil)
 	if err != nil {
 		t.Fatalf("archiver.Create failed: %v", err)
 	}
 
 	// Only main.go should be included
-	if fileCount != 1 {
-		t.Errorf("fileCount = %d, expected 1 (only main.go)", fileCount)
+	if result.FileCount != 1 {
+		t.Errorf("fileCount = %d, expected 1 (only main.go)", result.FileCount)
 	}
 
 	// Verify excluded files are NOT in zip
@@ -140,28 +156,90 @@ func TestCreateZipExclusions(t *testing.T) {
 	}
 }
 
-func TestShouldExclude(t *testing.T) {
+func TestCreateZipReportsUnreadableFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+	if err := os.MkdirAll(filepath.Join(sourceDir, "node_modules"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	// Dangling symlinks cannot be opened, even by root
+	if err := os.Symlink(filepath.Join(tempDir, "missing"), filepath.Join(sourceDir, "broken.txt")); err != nil {
+		t.Skipf("cannot create symlink: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(tempDir, "missing"), filepath.Join(sourceDir, "node_modules", "dep.js")); err != nil {
+		t.Skipf("cannot create symlink: %v", err)
+	}
+
+	zipPath := filepath.Join(tempDir, "backup.zip")
+	result, err := ziparchiver.New().Create(context.Background(), zipPath, sourceDir, ignore.New(sourceDir, []string{"node_modules"}), ports.CompressDefault, nil)
+	if err != nil {
+		t.Fatalf("archiver.Create failed: %v", err)
+	}
+
+	if result.FileCount != 1 {
+		t.Errorf("FileCount = %d, expected 1", result.FileCount)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Path != "broken.txt" || result.Skipped[0].Reason == "" {
+		t.Fatalf("Skipped = %+v, expected broken.txt with a reason", result.Skipped)
+	}
+
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatalf("Failed to open zip: %v", err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if strings.HasSuffix(f.Name, "broken.txt") {
+			t.Errorf("unreadable file should not have an entry in the zip")
+		}
+	}
+}
+
+func TestCreateZipCompression(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "main.go"), []byte(strings.Repeat("package main\n", 100)), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
 	tests := []struct {
-		path     string
-		patterns []string
-		expected bool
+		compression ports.Compression
+		method      uint16
 	}{
-		{"node_modules", []string{"node_modules"}, true},
-		{"src/node_modules", []string{"node_modules"}, true},
-		{"file.pyc", []string{"*.pyc"}, true},
-		{"dir/file.pyc", []string{"*.pyc"}, true},
-		{"main.go", []string{"*.pyc", "node_modules"}, false},
-		{".DS_Store", []string{".DS_Store"}, true},
-		{"readme.md", []string{}, false},
+		{ports.CompressDefault, zip.Deflate},
+		{ports.CompressNone, zip.Store},
+		{ports.CompressFast, zip.Deflate},
+		{ports.CompressBest, zip.Deflate},
 	}
-
 	for _, tt := range tests {
-		t.Run(tt.path, func(t *testing.T) {
-			result := shouldExclude(tt.path, tt.patterns)
-			if result != tt.expected {
-				t.Errorf("shouldExclude(%q, %v) = %v, expected %v", tt.path, tt.patterns, result, tt.expected)
-			}
-		})
+		zipPath := filepath.Join(tempDir, fmt.Sprintf("backup-%d.zip", tt.compression))
+		if _, err := ziparchiver.New().Create(context.Background(), zipPath, sourceDir, nil, tt.compression, nil); err != nil {
+			t.Fatalf("archiver.Create failed: %v", err)
+		}
+		r, err := zip.OpenReader(zipPath)
+		if err != nil {
+			t.Fatalf("Failed to open zip: %v", err)
+		}
+		if len(r.File) != 1 || r.File[0].Method != tt.method {
+			t.Errorf("compression %d: entries %+v, expected method %d", tt.compression, r.File, tt.method)
+		}
+		// Every level must read back intact
+		rc, err := r.File[0].Open()
+		if err != nil {
+			t.Fatalf("Failed to open entry: %v", err)
+		}
+		data, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil || len(data) != 1300 {
+			t.Errorf("compression %d: read %d bytes, err %v", tt.compression, len(data), err)
+		}
+		_ = r.Close()
 	}
 }
 
@@ -172,7 +250,7 @@ func TestHasChangesNoBackup(t *testing.T) {
 	}
 	defer os.RemoveAll(tempDir)
 
-	hasChanges, reason := HasChanges(tempDir, nil)
+	hasChanges, reason := HasChanges(context.Background(), tempDir, nil, nil)
 	if !hasChanges {
 		t.Error("HasChanges should return true when no previous backup exists")
 	}
@@ -199,7 +277,7 @@ func TestHasChangesModifiedFiles(t *testing.T) {
 		CreatedAt: time.Now().Add(-24 * time.Hour),
 	}
 
-	hasChanges, reason := HasChanges(tempDir, lastBackup)
+	hasChanges, reason := HasChanges(context.Background(), tempDir, lastBackup, nil)
 	if !hasChanges {
 		t.Error("HasChanges should return true when files are modified after last backup")
 	}
@@ -226,7 +304,7 @@ func TestHasChangesNoChanges(t *testing.T) {
 		CreatedAt: time.Now().Add(24 * time.Hour),
 	}
 
-	hasChanges, _ := HasChanges(tempDir, lastBackup)
+	hasChanges, _ := HasChanges(context.Background(), tempDir, lastBackup, nil)
 	if hasChanges {
 		t.Error("HasChanges should return false when no files are modified after last backup")
 	}
@@ -277,6 +355,41 @@ func TestFormatSize(t *testing.T) {
 	}
 }
 
+func TestFormatProgress(t *testing.T) {
+	tests := []struct {
+		name     string
+		progress ports.Progress
+		expected string
+	}{
+		{"empty", ports.Progress{}, "[----------]   0%  0/0 files  0 B/0 B"},
+		{"halfway", ports.Progress{Files: 1, FilesTotal: 4, Bytes: 1024, BytesTotal: 2048, ETA: 3 * time.Second},
+			"[#####-----]  50%  1/4 files  1.0 KB/2.0 KB  ETA 3s"},
+		{"by files", ports.Progress{Files: 3, FilesTotal: 4}, "[#######---]  75%  3/4 files  0 B/0 B"},
+		{"done", ports.Progress{Files: 4, FilesTotal: 4, Bytes: 2048, BytesTotal: 2048, ETA: 100 * time.Millisecond},
+			"[##########] 100%  4/4 files  2.0 KB/2.0 KB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := FormatProgress(tt.progress, 10); result != tt.expected {
+				t.Errorf("FormatProgress() = %q, expected %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEstimateRemaining(t *testing.T) {
+	if eta := estimateRemaining(10*time.Second, 0.25); eta != 30*time.Second {
+		t.Errorf("estimateRemaining(10s, 0.25) = %v, expected 30s", eta)
+	}
+	if eta := estimateRemaining(10*time.Second, 0); eta != 0 {
+		t.Errorf("estimateRemaining with no progress = %v, expected 0", eta)
+	}
+	if eta := estimateRemaining(10*time.Second, 1); eta != 0 {
+		t.Errorf("estimateRemaining when done = %v, expected 0", eta)
+	}
+}
+
 func TestListProjects(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "codebak-test-*")
 	if err != nil {
@@ -359,7 +472,7 @@ func TestGetGitHeadNonGitRepo(t *testing.T) {
 	}
 	defer os.RemoveAll(tempDir)
 
-	result := GetGitHead(tempDir)
+	result := GetGitHead(context.Background(), tempDir)
 	if result != "" {
 		t.Errorf("GetGitHead for non-git repo should return empty string, got %q", result)
 	}
@@ -377,12 +490,63 @@ func TestBackupProjectNotFound(t *testing.T) {
 		BackupDir: filepath.Join(tempDir, "backups"),
 	}
 
-	result := BackupProject(cfg, "nonexistent-project")
+	result := BackupProject(context.Background(), cfg, "nonexistent-project")
 	if result.Error == nil {
 		t.Error("BackupProject should fail for non-existent project")
 	}
 }
 
+func TestBackupProjectCleansUpPartialFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+	backupDir := filepath.Join(tempDir, "backups")
+	projectDir := filepath.Join(sourceDir, "test-project")
+	projectBackupDir := filepath.Join(backupDir, "test-project")
+
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// A zip left half-written by a crashed run
+	if err := os.MkdirAll(projectBackupDir, 0755); err != nil {
+		t.Fatalf("Failed to create backup dir: %v", err)
+	}
+	partial := filepath.Join(projectBackupDir, ".20240101-120000.zip.123"+atomicfile.PartialSuffix)
+	if err := os.WriteFile(partial, []byte("truncated"), 0644); err != nil {
+		t.Fatalf("Failed to create partial file: %v", err)
+	}
+	// The chunk store is left to chunk garbage collection rather than scanned
+	chunkPartial := filepath.Join(projectBackupDir, chunkstore.ChunksDirName, "ab", ".abcd.123"+atomicfile.PartialSuffix)
+	if err := os.MkdirAll(filepath.Dir(chunkPartial), 0755); err != nil {
+		t.Fatalf("Failed to create chunk dir: %v", err)
+	}
+	if err := os.WriteFile(chunkPartial, []byte("half"), 0644); err != nil {
+		t.Fatalf("Failed to create chunk partial file: %v", err)
+	}
+
+	cfg := &config.Config{
+		SourceDir: sourceDir,
+		BackupDir: backupDir,
+	}
+
+	result := BackupProject(context.Background(), cfg, "test-project")
+	if result.Error != nil {
+		t.Fatalf("BackupProject failed: %v", result.Error)
+	}
+	if _, err := os.Stat(partial); !os.IsNotExist(err) {
+		t.Error("leftover partial file should be removed")
+	}
+	if _, err := os.Stat(chunkPartial); err != nil {
+		t.Error("the chunk directory should not be scanned for partial files")
+	}
+	if _, err := os.Stat(result.ZipPath); err != nil {
+		t.Errorf("backup zip missing: %v", err)
+	}
+}
+
 func TestBackupProjectSuccess(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "codebak-test-*")
 	if err != nil {
@@ -408,7 +572,7 @@ func TestBackupProjectSuccess(t *testing.T) {
 		Exclude:   []string{"node_modules"},
 	}
 
-	result := BackupProject(cfg, "test-project")
+	result := BackupProject(context.Background(), cfg, "test-project")
 	if result.Error != nil {
 		t.Fatalf("BackupProject failed: %v", result.Error)
 	}
@@ -431,6 +595,49 @@ func TestBackupProjectSuccess(t *testing.T) {
 	}
 }
 
+func TestBackupProjectChunkedStorage(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+	backupDir := filepath.Join(tempDir, "backups")
+	projectDir := filepath.Join(sourceDir, "test-project")
+
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	cfg := &config.Config{
+		SourceDir: sourceDir,
+		BackupDir: backupDir,
+		Storage:   config.StorageChunked,
+	}
+
+	result := BackupProject(context.Background(), cfg, "test-project")
+	if result.Error != nil {
+		t.Fatalf("BackupProject failed: %v", result.Error)
+	}
+	if filepath.Ext(result.ZipPath) != ".idx" {
+		t.Errorf("ZipPath = %q, expected a chunk store index", result.ZipPath)
+	}
+	if result.FileCount != 1 {
+		t.Errorf("FileCount = %d, expected 1", result.FileCount)
+	}
+
+	m, err := manifest.Load(backupDir, "test-project")
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+	latest := m.LatestBackup()
+	if latest == nil || latest.Format != manifest.FormatChunked {
+		t.Fatalf("Latest backup = %+v, expected chunked format", latest)
+	}
+	if _, err := os.Stat(filepath.Join(backupDir, "test-project", "chunks")); err != nil {
+		t.Errorf("Chunk directory was not created: %v", err)
+	}
+}
+
 func TestBackupProjectSkipsUnchanged(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "codebak-test-*")
 	if err != nil {
@@ -456,13 +663,13 @@ func TestBackupProjectSkipsUnchanged(t *testing.T) {
 	}
 
 	// First backup
-	result1 := BackupProject(cfg, "test-project")
+	result1 := BackupProject(context.Background(), cfg, "test-project")
 	if result1.Error != nil {
 		t.Fatalf("First backup failed: %v", result1.Error)
 	}
 
 	// Second backup (should be skipped since no changes)
-	result2 := BackupProject(cfg, "test-project")
+	result2 := BackupProject(context.Background(), cfg, "test-project")
 	if result2.Error != nil {
 		t.Fatalf("Second backup failed: %v", result2.Error)
 	}
@@ -498,7 +705,7 @@ func TestRunBackup(t *testing.T) {
 		BackupDir: backupDir,
 	}
 
-	results, err := RunBackup(cfg)
+	results, err := RunBackup(context.Background(), cfg)
 	if err != nil {
 		t.Fatalf("RunBackup failed: %v", err)
 	}
@@ -537,7 +744,7 @@ func TestHasChangesGitHeadChanged(t *testing.T) {
 		CreatedAt: time.Now().Add(-24 * time.Hour),
 	}
 
-	hasChanges, reason := svc.HasChanges(projectPath, lastBackup)
+	hasChanges, reason := svc.HasChanges(context.Background(), projectPath, lastBackup, nil)
 	if !hasChanges {
 		t.Error("HasChanges should return true when git HEAD changed")
 	}
@@ -565,7 +772,7 @@ func TestHasChangesGitHeadUnchanged(t *testing.T) {
 		CreatedAt: time.Now().Add(-24 * time.Hour),
 	}
 
-	hasChanges, reason := svc.HasChanges(projectPath, lastBackup)
+	hasChanges, reason := svc.HasChanges(context.Background(), projectPath, lastBackup, nil)
 	if hasChanges {
 		t.Error("HasChanges should return false when git HEAD unchanged")
 	}
@@ -574,6 +781,84 @@ func TestHasChangesGitHeadUnchanged(t *testing.T) {
 	}
 }
 
+func TestHasChangesUncommittedChanges(t *testing.T) {
+	mockFS := mocks.NewMockFileSystem()
+	mockGit := mocks.NewMockGitClient()
+	mockArchiver := mocks.NewMockArchiver()
+	mockRestic := mocks.NewMockResticClient()
+
+	projectPath := "/test/project"
+	headCommit := "samehead1234567890"
+	mockGit.Repos[projectPath] = true
+	mockGit.Heads[projectPath] = headCommit
+	mockGit.Statuses[projectPath] = []ports.GitFileStatus{
+		{Path: "main.go", Code: " M"},
+		{Path: "notes.txt", Code: "??"},
+	}
+	mockFS.Files[projectPath+"/main.go"] = []byte("package main // edited")
+
+	svc := NewService(mockFS, mockGit, mockArchiver, mockRestic)
+
+	// Last backup was of a clean tree at the same HEAD
+	lastBackup := &manifest.BackupEntry{GitHead: headCommit}
+	hasChanges, reason := svc.HasChanges(context.Background(), projectPath, lastBackup, nil)
+	if !hasChanges {
+		t.Error("HasChanges should return true for uncommitted changes at the same HEAD")
+	}
+	if reason != "uncommitted changes" {
+		t.Errorf("reason = %q, expected %q", reason, "uncommitted changes")
+	}
+
+	// Same dirty state as the last backup is not a change
+	dirty, err := svc.DirtyFingerprint(context.Background(), projectPath, nil)
+	if err != nil {
+		t.Fatalf("DirtyFingerprint failed: %v", err)
+	}
+	lastBackup.DirtyHash = dirty
+	if hasChanges, _ := svc.HasChanges(context.Background(), projectPath, lastBackup, nil); hasChanges {
+		t.Error("HasChanges should return false when the dirty state is unchanged")
+	}
+
+	// Editing an already-modified file changes the fingerprint
+	mockFS.Files[projectPath+"/main.go"] = []byte("package main // edited again")
+	if hasChanges, _ := svc.HasChanges(context.Background(), projectPath, lastBackup, nil); !hasChanges {
+		t.Error("HasChanges should return true after further edits to a dirty file")
+	}
+
+	// Committing or discarding the changes also differs from the dirty backup
+	mockGit.Statuses[projectPath] = nil
+	hasChanges, reason = svc.HasChanges(context.Background(), projectPath, lastBackup, nil)
+	if !hasChanges || reason != "uncommitted changes discarded" {
+		t.Errorf("HasChanges = %v, %q; expected discarded changes to trigger a backup", hasChanges, reason)
+	}
+}
+
+func TestHasChangesStatusErrorFallsBackToMtime(t *testing.T) {
+	mockFS := mocks.NewMockFileSystem()
+	mockGit := mocks.NewMockGitClient()
+	mockArchiver := mocks.NewMockArchiver()
+	mockRestic := mocks.NewMockResticClient()
+
+	projectPath := "/test/project"
+	mockGit.Repos[projectPath] = true
+	mockGit.Heads[projectPath] = "samehead1234567890"
+	mockGit.StatusErrors[projectPath] = errors.New("git status failed")
+	mockFS.WalkEntries = []mocks.WalkEntry{
+		{Path: projectPath + "/file.txt", Info: &mockFileInfo{modTime: time.Now()}},
+	}
+
+	svc := NewService(mockFS, mockGit, mockArchiver, mockRestic)
+
+	lastBackup := &manifest.BackupEntry{
+		GitHead:   "samehead1234567890",
+		CreatedAt: time.Now().Add(-time.Hour),
+	}
+	hasChanges, reason := svc.HasChanges(context.Background(), projectPath, lastBackup, nil)
+	if !hasChanges || reason != "files modified since last backup" {
+		t.Errorf("HasChanges = %v, %q; expected mtime fallback", hasChanges, reason)
+	}
+}
+
 func TestHasChangesNonGitRepoWithNewerFiles(t *testing.T) {
 	// Test non-git repo with newer files (mtime fallback)
 	mockFS := mocks.NewMockFileSystem()
@@ -599,7 +884,7 @@ func TestHasChangesNonGitRepoWithNewerFiles(t *testing.T) {
 		CreatedAt: time.Now().Add(-24 * time.Hour), // Backup was yesterday
 	}
 
-	hasChanges, reason := svc.HasChanges(projectPath, lastBackup)
+	hasChanges, reason := svc.HasChanges(context.Background(), projectPath, lastBackup, nil)
 	if !hasChanges {
 		t.Error("HasChanges should return true when files modified after last backup")
 	}
@@ -632,7 +917,7 @@ func TestHasChangesNonGitRepoNoChanges(t *testing.T) {
 		CreatedAt: time.Now().Add(-24 * time.Hour), // Backup was yesterday
 	}
 
-	hasChanges, reason := svc.HasChanges(projectPath, lastBackup)
+	hasChanges, reason := svc.HasChanges(context.Background(), projectPath, lastBackup, nil)
 	if hasChanges {
 		t.Error("HasChanges should return false when no files modified after last backup")
 	}
@@ -641,7 +926,434 @@ func TestHasChangesNonGitRepoNoChanges(t *testing.T) {
 	}
 }
 
-func TestBackupProjectMkdirAllError(t *testing.T) {
+func TestHasChangesSkipsExcludedFiles(t *testing.T) {
+	projectPath := t.TempDir()
+	for path, content := range map[string]string{
+		"main.go":             "package main",
+		".gitignore":          "*.log\n",
+		"node_modules/dep.js": "dep",
+		"debug.log":           "log",
+	} {
+		fullPath := filepath.Join(projectPath, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	// Only excluded paths are newer than the last backup
+	old := time.Now().Add(-48 * time.Hour)
+	for _, path := range []string{"main.go", ".gitignore", "."} {
+		if err := os.Chtimes(filepath.Join(projectPath, path), old, old); err != nil {
+			t.Fatalf("Chtimes failed: %v", err)
+		}
+	}
+
+	mockGit := mocks.NewMockGitClient() // not a repo
+	svc := NewService(osfs.New(), mockGit, mocks.NewMockArchiver(), mocks.NewMockResticClient())
+	lastBackup := &manifest.BackupEntry{CreatedAt: time.Now().Add(-24 * time.Hour)}
+
+	exclude := ignore.New(projectPath, []string{"node_modules"}, ignore.WithGitignore(true))
+	if hasChanges, reason := svc.HasChanges(context.Background(), projectPath, lastBackup, exclude); hasChanges {
+		t.Errorf("HasChanges = true (%s), expected excluded files to be ignored", reason)
+	}
+	if hasChanges, _ := svc.HasChanges(context.Background(), projectPath, lastBackup, nil); !hasChanges {
+		t.Error("HasChanges without exclusions should see the newer files")
+	}
+}
+
+func TestDirtyFingerprintSkipsExcludedFiles(t *testing.T) {
+	mockFS := mocks.NewMockFileSystem()
+	mockGit := mocks.NewMockGitClient()
+	projectPath := "/test/project"
+	mockGit.Repos[projectPath] = true
+	mockGit.Heads[projectPath] = "samehead1234567890"
+	mockGit.Statuses[projectPath] = []ports.GitFileStatus{{Path: "main.go", Code: " M"}}
+	mockFS.Files[projectPath+"/main.go"] = []byte("package main // edited")
+	svc := NewService(mockFS, mockGit, mocks.NewMockArchiver(), mocks.NewMockResticClient())
+	exclude := ignore.New(projectPath, []string{".env.local", "dist/"})
+
+	clean, err := svc.DirtyFingerprint(context.Background(), projectPath, exclude)
+	if err != nil {
+		t.Fatalf("DirtyFingerprint failed: %v", err)
+	}
+	lastBackup := &manifest.BackupEntry{GitHead: "samehead1234567890", DirtyHash: clean}
+
+	// Untracked files that are excluded but not gitignored leave it alone
+	mockGit.Statuses[projectPath] = append(mockGit.Statuses[projectPath],
+		ports.GitFileStatus{Path: ".env.local", Code: "??"},
+		ports.GitFileStatus{Path: "dist/app.js", Code: "??"},
+	)
+	if dirty, _ := svc.DirtyFingerprint(context.Background(), projectPath, exclude); dirty != clean {
+		t.Error("excluded files should not change the fingerprint")
+	}
+	if hasChanges, reason := svc.HasChanges(context.Background(), projectPath, lastBackup, exclude); hasChanges {
+		t.Errorf("HasChanges = true (%s), expected excluded files to be ignored", reason)
+	}
+	if hasChanges, _ := svc.HasChanges(context.Background(), projectPath, lastBackup, nil); !hasChanges {
+		t.Error("HasChanges without exclusions should see the untracked files")
+	}
+}
+
+func TestBackupProjectPassesExclusionsToArchiver(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+	projectDir := filepath.Join(sourceDir, "test-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, ignore.CodebakignoreName), []byte("/scratch/\n"), 0644); err != nil {
+		t.Fatalf("Failed to write .codebakignore: %v", err)
+	}
+
+	mockArchiver := mocks.NewMockArchiver()
+	svc := NewService(osfs.New(), mocks.NewMockGitClient(), mockArchiver, mocks.NewMockResticClient())
+	cfg := &config.Config{
+		Sources:   []config.Source{{Path: sourceDir}},
+		BackupDir: filepath.Join(tempDir, "backups"),
+		Exclude:   []string{"docs/build"},
+	}
+
+	// The mock archiver writes nothing, so the backup itself fails after Create
+	_ = svc.BackupProject(context.Background(), cfg, "test-project")
+
+	if len(mockArchiver.CreateCalls) != 1 {
+		t.Fatalf("CreateCalls = %d, expected 1", len(mockArchiver.CreateCalls))
+	}
+	exclude := mockArchiver.CreateCalls[0].Exclude
+	if exclude == nil {
+		t.Fatal("archiver should receive an excluder")
+	}
+	if !exclude.Excluded("docs/build", true) || exclude.Excluded("build", true) {
+		t.Error("config patterns should be path-anchored")
+	}
+	if !exclude.Excluded("scratch", true) {
+		t.Error(".codebakignore patterns should be applied")
+	}
+}
+
+func TestBackupProjectAppliesOverrides(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+	for _, project := range []string{"monorepo", "scratch"} {
+		if err := os.MkdirAll(filepath.Join(sourceDir, project), 0755); err != nil {
+			t.Fatalf("Failed to create project dir: %v", err)
+		}
+	}
+
+	mockArchiver := mocks.NewMockArchiver()
+	svc := NewService(osfs.New(), mocks.NewMockGitClient(), mockArchiver, mocks.NewMockResticClient())
+	disabled := false
+	cfg := &config.Config{
+		Sources: []config.Source{{Path: sourceDir, Override: config.Override{
+			Exclude:     []string{"vendor"},
+			Compression: config.CompressionFast,
+		}}},
+		BackupDir: filepath.Join(tempDir, "backups"),
+		Exclude:   []string{"node_modules"},
+		Overrides: map[string]config.Override{
+			"monorepo": {Compression: config.CompressionBest},
+			"scratch":  {Enabled: &disabled},
+		},
+	}
+
+	// The mock archiver writes nothing, so the backup itself fails after Create
+	_ = svc.BackupProject(context.Background(), cfg, "monorepo")
+
+	if len(mockArchiver.CreateCalls) != 1 {
+		t.Fatalf("CreateCalls = %d, expected 1", len(mockArchiver.CreateCalls))
+	}
+	call := mockArchiver.CreateCalls[0]
+	if call.Compression != ports.CompressBest {
+		t.Errorf("Compression = %d, expected the project override", call.Compression)
+	}
+	if !call.Exclude.Excluded("node_modules", true) || !call.Exclude.Excluded("vendor", true) {
+		t.Error("global and source exclude patterns should both apply")
+	}
+
+	result := svc.BackupProject(context.Background(), cfg, "scratch")
+	if result.Error != nil || !result.Skipped || result.Reason != "disabled in config" {
+		t.Errorf("result = %+v, expected disabled project to be skipped", result)
+	}
+	if len(mockArchiver.CreateCalls) != 1 {
+		t.Error("disabled project should not be archived")
+	}
+
+	eff := svc.Effective(cfg, "monorepo")
+	if eff.Source != sourceDir || eff.Compression != config.CompressionBest {
+		t.Errorf("Effective = %+v, expected source and project compression", eff)
+	}
+
+	// An unknown compression fails rather than falling back to the default
+	cfg.Overrides["monorepo"] = config.Override{Compression: "zstd"}
+	result = svc.BackupProject(context.Background(), cfg, "monorepo")
+	if result.Error == nil || !strings.Contains(result.Error.Error(), `invalid compression "zstd"`) {
+		t.Errorf("err = %v, expected the bad compression to be reported", result.Error)
+	}
+	if len(mockArchiver.CreateCalls) != 1 {
+		t.Error("a project with a bad compression should not be archived")
+	}
+}
+
+func TestBackupProjectProgress(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+	projectDir := filepath.Join(sourceDir, "test-project")
+	for path, content := range map[string]string{
+		"main.go":             "package main",
+		"docs/README.md":      "# Test",
+		"node_modules/dep.js": "excluded from totals",
+	} {
+		fullPath := filepath.Join(projectDir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	cfg := &config.Config{
+		SourceDir: sourceDir,
+		BackupDir: filepath.Join(tempDir, "backups"),
+		Exclude:   []string{"node_modules"},
+	}
+
+	var events []ports.Progress
+	result := BackupProjectProgress(context.Background(), cfg, "test-project", func(project string, p ports.Progress) {
+		if project != "test-project" {
+			t.Errorf("progress project = %q, expected test-project", project)
+		}
+		events = append(events, p)
+	})
+	if result.Error != nil {
+		t.Fatalf("BackupProjectProgress failed: %v", result.Error)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("got %d progress events, expected one per file", len(events))
+	}
+	last := events[len(events)-1]
+	if last.Files != 2 || last.FilesTotal != 2 {
+		t.Errorf("files = %d/%d, expected 2/2", last.Files, last.FilesTotal)
+	}
+	if want := int64(len("package main") + len("# Test")); last.Bytes != want || last.BytesTotal != want {
+		t.Errorf("bytes = %d/%d, expected %d/%d", last.Bytes, last.BytesTotal, want, want)
+	}
+	if last.Fraction() != 1 || last.ETA != 0 {
+		t.Errorf("final event should be complete, got fraction %v ETA %v", last.Fraction(), last.ETA)
+	}
+	for _, p := range events {
+		if p.Path != "main.go" && p.Path != "docs/README.md" {
+			t.Errorf("unexpected progress path %q", p.Path)
+		}
+	}
+}
+
+func TestBackupProjectCancelledRemovesPartialFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+	projectDir := filepath.Join(sourceDir, "test-project")
+	for i := 0; i < 5; i++ {
+		path := filepath.Join(projectDir, fmt.Sprintf("file%d.go", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("package main"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	cfg := &config.Config{
+		SourceDir: sourceDir,
+		BackupDir: filepath.Join(tempDir, "backups"),
+	}
+
+	// Cancel after the first file has been archived
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := BackupProjectProgress(ctx, cfg, "test-project", func(string, ports.Progress) {
+		cancel()
+	})
+	if !errors.Is(result.Error, context.Canceled) {
+		t.Fatalf("Error = %v, expected context.Canceled", result.Error)
+	}
+
+	projectBackupDir := filepath.Join(cfg.BackupDir, "test-project")
+	entries, err := os.ReadDir(projectBackupDir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".zip") || atomicfile.IsPartial(e.Name()) {
+			t.Errorf("cancelled backup left %s behind", e.Name())
+		}
+	}
+	m, err := manifest.Load(cfg.BackupDir, "test-project")
+	if err != nil {
+		t.Fatalf("manifest.Load failed: %v", err)
+	}
+	if len(m.Backups) != 0 {
+		t.Errorf("manifest has %d backups, expected none", len(m.Backups))
+	}
+}
+
+func TestBackupProjectMkdirAllError(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "codebak-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mockFS := mocks.NewMockFileSystem()
+	mockGit := mocks.NewMockGitClient()
+	mockArchiver := mocks.NewMockArchiver()
+	mockRestic := mocks.NewMockResticClient()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	projectDir := filepath.Join(sourceDir, "test-project")
+	backupDir := filepath.Join(tempDir, "backups")
+	projectBackupDir := filepath.Join(backupDir, "test-project")
+
+	// Create actual project dir (so Stat succeeds via real FS)
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// Mock Stat to succeed, but MkdirAll to fail
+	mockFS.Stats[projectDir] = &mockFileInfo{name: "test-project", isDir: true}
+	mockFS.Errors[projectBackupDir] = os.ErrPermission
+
+	svc := NewService(mockFS, mockGit, mockArchiver, mockRestic)
+
+	cfg := &config.Config{
+		SourceDir: sourceDir,
+		BackupDir: backupDir,
+	}
+
+	result := svc.BackupProject(context.Background(), cfg, "test-project")
+	if result.Error == nil {
+		t.Error("BackupProject should fail when MkdirAll fails")
+	}
+}
+
+func TestBackupProjectLocked(t *testing.T) {
+	mockFS := mocks.NewMockFileSystem()
+	mockGit := mocks.NewMockGitClient()
+	mockArchiver := mocks.NewMockArchiver()
+	mockRestic := mocks.NewMockResticClient()
+	locker := mocks.NewMockLocker()
+
+	projectDir := "/test/source/test-project"
+	backupDir := "/test/backups"
+	mockFS.Stats[projectDir] = &mockFileInfo{name: "test-project", isDir: true}
+	locker.Held["project:"+backupDir+"/test-project"] = true
+
+	svc := NewService(mockFS, mockGit, mockArchiver, mockRestic, WithLocker(locker))
+
+	cfg := &config.Config{
+		SourceDir: "/test/source",
+		BackupDir: backupDir,
+	}
+
+	result := svc.BackupProject(context.Background(), cfg, "test-project")
+	if !errors.Is(result.Error, ports.ErrLocked) {
+		t.Errorf("Error = %v, expected ErrLocked", result.Error)
+	}
+	if len(mockArchiver.CreateCalls) != 0 {
+		t.Error("BackupProject should not archive while the project is locked")
+	}
+}
+
+func TestRunBackupLocksBackupDir(t *testing.T) {
+	tempDir := t.TempDir()
+
+	mockGit := mocks.NewMockGitClient()
+	mockArchiver := mocks.NewMockArchiver()
+	mockRestic := mocks.NewMockResticClient()
+	locker := mocks.NewMockLocker()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	backupDir := filepath.Join(tempDir, "backups")
+	projectDir := filepath.Join(sourceDir, "test-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	mockArchiver.Errors["Create"] = os.ErrPermission // stop after locking
+
+	svc := NewService(osfs.New(), mockGit, mockArchiver, mockRestic, WithLocker(locker))
+
+	cfg := &config.Config{
+		SourceDir: sourceDir,
+		BackupDir: backupDir,
+	}
+
+	// Another process owns the backup directory
+	locker.Held["dir:"+backupDir] = true
+	if _, err := svc.RunBackup(context.Background(), cfg); !errors.Is(err, ports.ErrLocked) {
+		t.Errorf("RunBackup err = %v, expected ErrLocked", err)
+	}
+
+	// Once free, the run holds the directory and each project lock in turn
+	delete(locker.Held, "dir:"+backupDir)
+	if _, err := svc.RunBackup(context.Background(), cfg); err != nil {
+		t.Fatalf("RunBackup failed: %v", err)
+	}
+	expected := []string{"dir:" + backupDir, "dir:" + backupDir, "project:" + backupDir + "/test-project"}
+	if strings.Join(locker.Calls, ",") != strings.Join(expected, ",") {
+		t.Errorf("lock calls = %v, expected %v", locker.Calls, expected)
+	}
+	if len(locker.Held) != 0 {
+		t.Errorf("locks still held after run: %v", locker.Held)
+	}
+}
+
+func TestRunBackupCleansUpPartialsInEveryProject(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+	backupDir := filepath.Join(tempDir, "backups")
+	if err := os.MkdirAll(filepath.Join(sourceDir, "test-project"), 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+
+	// A project that is no longer backed up, and an internal directory
+	oldDir := filepath.Join(backupDir, "old-project", "files")
+	internalDir := filepath.Join(backupDir, ".codebak")
+	for _, dir := range []string{oldDir, internalDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	stale := filepath.Join(oldDir, "20260101-120000.json.partial")
+	internal := filepath.Join(internalDir, "lock.partial")
+	for _, path := range []string{stale, internal} {
+		if err := os.WriteFile(path, []byte("half"), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", path, err)
+		}
+	}
+
+	mockArchiver := mocks.NewMockArchiver()
+	mockArchiver.Errors["Create"] = os.ErrPermission
+	svc := NewService(osfs.New(), mocks.NewMockGitClient(), mockArchiver, mocks.NewMockResticClient(), WithLocker(mocks.NewMockLocker()))
+	cfg := &config.Config{SourceDir: sourceDir, BackupDir: backupDir}
+
+	if _, err := svc.RunBackup(context.Background(), cfg); err != nil {
+		t.Fatalf("RunBackup failed: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("temp file of a project outside the run should be removed")
+	}
+	if _, err := os.Stat(internal); err != nil {
+		t.Errorf("files under dot directories should be left alone: %v", err)
+	}
+}
+
+func TestBackupProjectArchiverCreateError(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "codebak-test-*")
 	if err != nil {
 		t.Fatalf("Failed to create temp dir: %v", err)
@@ -656,9 +1368,8 @@ func TestBackupProjectMkdirAllError(t *testing.T) {
 	sourceDir := filepath.Join(tempDir, "source")
 	projectDir := filepath.Join(sourceDir, "test-project")
 	backupDir := filepath.Join(tempDir, "backups")
-	projectBackupDir := filepath.Join(backupDir, "test-project")
 
-	// Create actual project dir (so Stat succeeds via real FS)
+	// Create actual project dir
 	if err := os.MkdirAll(projectDir, 0755); err != nil {
 		t.Fatalf("Failed to create project dir: %v", err)
 	}
@@ -666,9 +1377,11 @@ func TestBackupProjectMkdirAllError(t *testing.T) {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	// Mock Stat to succeed, but MkdirAll to fail
+	// Mock Stat to succeed
 	mockFS.Stats[projectDir] = &mockFileInfo{name: "test-project", isDir: true}
-	mockFS.Errors[projectBackupDir] = os.ErrPermission
+
+	// Mock archiver to fail
+	mockArchiver.Errors["Create"] = os.ErrPermission
 
 	svc := NewService(mockFS, mockGit, mockArchiver, mockRestic)
 
@@ -677,29 +1390,28 @@ func TestBackupProjectMkdirAllError(t *testing.T) {
 		BackupDir: backupDir,
 	}
 
-	result := svc.BackupProject(cfg, "test-project")
+	result := svc.BackupProject(context.Background(), cfg, "test-project")
 	if result.Error == nil {
-		t.Error("BackupProject should fail when MkdirAll fails")
+		t.Error("BackupProject should fail when archiver.Create fails")
 	}
 }
 
-func TestBackupProjectArchiverCreateError(t *testing.T) {
-	tempDir, err := os.MkdirTemp("", "codebak-test-*")
-	if err != nil {
-		t.Fatalf("Failed to create temp dir: %v", err)
-	}
-	defer os.RemoveAll(tempDir)
+// skippingArchiver archives with ZipArchiver and reports extra unreadable files.
+type skippingArchiver struct {
+	*ziparchiver.ZipArchiver
+	skipped []ports.SkippedFile
+}
 
-	mockFS := mocks.NewMockFileSystem()
-	mockGit := mocks.NewMockGitClient()
-	mockArchiver := mocks.NewMockArchiver()
-	mockRestic := mocks.NewMockResticClient()
+func (a *skippingArchiver) Create(ctx context.Context, destPath, sourceDir string, exclude ports.Excluder, compression ports.Compression, progress ports.ProgressFunc) (ports.ArchiveResult, error) {
+	result, err := a.ZipArchiver.Create(ctx, destPath, sourceDir, exclude, compression, progress)
+	result.Skipped = append(result.Skipped, a.skipped...)
+	return result, err
+}
 
+func TestBackupProjectRecordsSkippedFiles(t *testing.T) {
+	tempDir := t.TempDir()
 	sourceDir := filepath.Join(tempDir, "source")
 	projectDir := filepath.Join(sourceDir, "test-project")
-	backupDir := filepath.Join(tempDir, "backups")
-
-	// Create actual project dir
 	if err := os.MkdirAll(projectDir, 0755); err != nil {
 		t.Fatalf("Failed to create project dir: %v", err)
 	}
@@ -707,22 +1419,70 @@ func TestBackupProjectArchiverCreateError(t *testing.T) {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	// Mock Stat to succeed
-	mockFS.Stats[projectDir] = &mockFileInfo{name: "test-project", isDir: true}
+	archiver := &skippingArchiver{
+		ZipArchiver: ziparchiver.New(),
+		skipped:     []ports.SkippedFile{{Path: "secret.key", Reason: "permission denied"}},
+	}
+	svc := NewService(osfs.New(), mocks.NewMockGitClient(), archiver, mocks.NewMockResticClient())
+	cfg := &config.Config{
+		SourceDir: sourceDir,
+		BackupDir: filepath.Join(tempDir, "backups"),
+	}
 
-	// Mock archiver to fail
-	mockArchiver.Errors["Create"] = os.ErrPermission
+	result := svc.BackupProject(context.Background(), cfg, "test-project")
+	if result.Error != nil {
+		t.Fatalf("BackupProject failed: %v", result.Error)
+	}
+	want := []manifest.SkippedFile{{Path: "secret.key", Reason: "permission denied"}}
+	if !reflect.DeepEqual(result.SkippedFiles, want) {
+		t.Errorf("SkippedFiles = %+v, expected %+v", result.SkippedFiles, want)
+	}
+	m, err := manifest.Load(cfg.BackupDir, "test-project")
+	if err != nil {
+		t.Fatalf("manifest.Load failed: %v", err)
+	}
+	if len(m.Backups) != 1 || !reflect.DeepEqual(m.Backups[0].Skipped, want) {
+		t.Errorf("manifest entry = %+v, expected skipped files to be recorded", m.Backups)
+	}
+}
 
-	svc := NewService(mockFS, mockGit, mockArchiver, mockRestic)
+func TestBackupProjectFailOnSkip(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+	projectDir := filepath.Join(sourceDir, "test-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
 
+	archiver := &skippingArchiver{
+		ZipArchiver: ziparchiver.New(),
+		skipped:     []ports.SkippedFile{{Path: "secret.key", Reason: "permission denied"}},
+	}
+	svc := NewService(osfs.New(), mocks.NewMockGitClient(), archiver, mocks.NewMockResticClient())
 	cfg := &config.Config{
-		SourceDir: sourceDir,
-		BackupDir: backupDir,
+		SourceDir:  sourceDir,
+		BackupDir:  filepath.Join(tempDir, "backups"),
+		FailOnSkip: true,
 	}
 
-	result := svc.BackupProject(cfg, "test-project")
-	if result.Error == nil {
-		t.Error("BackupProject should fail when archiver.Create fails")
+	result := svc.BackupProject(context.Background(), cfg, "test-project")
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "could not be read") {
+		t.Fatalf("Error = %v, expected unreadable files to fail the backup", result.Error)
+	}
+	if len(result.SkippedFiles) != 1 {
+		t.Errorf("SkippedFiles = %+v, expected the unreadable file to be reported", result.SkippedFiles)
+	}
+	entries, err := os.ReadDir(filepath.Join(cfg.BackupDir, "test-project"))
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".zip") {
+			t.Errorf("failed backup left %s behind", e.Name())
+		}
 	}
 }
 
@@ -745,7 +1505,7 @@ func TestRunBackupListProjectsError(t *testing.T) {
 
 	// With multi-source support, failed sources are skipped gracefully
 	// (returns empty results instead of error)
-	results, err := svc.RunBackup(cfg)
+	results, err := svc.RunBackup(context.Background(), cfg)
 	if err != nil {
 		t.Errorf("RunBackup should not fail with multi-source; got error: %v", err)
 	}
@@ -772,6 +1532,9 @@ func TestNewServiceAndNewDefaultService(t *testing.T) {
 	if svc.restic == nil {
 		t.Error("NewDefaultService should set restic client")
 	}
+	if svc.locker == nil {
+		t.Error("NewDefaultService should set locker")
+	}
 
 	// Test NewService with mocks
 	mockFS := mocks.NewMockFileSystem()
@@ -834,7 +1597,7 @@ func TestBackupProjectManifestLoadError(t *testing.T) {
 		BackupDir: backupDir,
 	}
 
-	result := BackupProject(cfg, "test-project")
+	result := BackupProject(context.Background(), cfg, "test-project")
 	if result.Error == nil {
 		t.Error("BackupProject should fail when manifest Load fails")
 	}
@@ -867,7 +1630,7 @@ func TestHasChangesWalkError(t *testing.T) {
 	}
 
 	// Should handle error gracefully and continue (return false/no changes)
-	hasChanges, _ := svc.HasChanges(projectPath, lastBackup)
+	hasChanges, _ := svc.HasChanges(context.Background(), projectPath, lastBackup, nil)
 	// Error handling in walk continues, so it should return no changes
 	if hasChanges {
 		t.Error("HasChanges should return false when walk encounters error and finds no newer files")
@@ -897,12 +1660,10 @@ func TestBackupProjectWithRetention(t *testing.T) {
 		SourceDir: sourceDir,
 		BackupDir: backupDir,
 		Exclude:   []string{"node_modules"},
-		Retention: struct {
-			KeepLast int `yaml:"keep_last"`
-		}{KeepLast: 5}, // Enable retention
+		Retention: config.RetentionConfig{KeepLast: 5}, // Enable retention
 	}
 
-	result := BackupProject(cfg, "test-project")
+	result := BackupProject(context.Background(), cfg, "test-project")
 	if result.Error != nil {
 		t.Fatalf("BackupProject failed: %v", result.Error)
 	}
@@ -962,7 +1723,7 @@ func TestBackupSensitiveSourceSuccess(t *testing.T) {
 		},
 	}
 
-	result := svc.BackupSensitiveSource(cfg, source)
+	result := svc.BackupSensitiveSource(context.Background(), cfg, source)
 	if result.Error != nil {
 		t.Fatalf("BackupSensitiveSource failed: %v", result.Error)
 	}
@@ -985,6 +1746,39 @@ func TestBackupSensitiveSourceSuccess(t *testing.T) {
 	}
 }
 
+func TestBackupSensitiveSourceUsesSourceRetention(t *testing.T) {
+	tempDir := t.TempDir()
+	sshDir := filepath.Join(tempDir, ".ssh")
+	repoPath := filepath.Join(tempDir, "restic-repo")
+
+	mockFS := mocks.NewMockFileSystem()
+	mockFS.Stats[sshDir] = &mockFileInfo{name: ".ssh", isDir: true}
+	mockRestic := mocks.NewMockResticClient()
+	svc := NewService(mockFS, mocks.NewMockGitClient(), mocks.NewMockArchiver(), mockRestic)
+
+	t.Setenv("CODEBAK_RESTIC_PASSWORD", "test-password")
+
+	source := config.Source{
+		Path:     sshDir,
+		Label:    "SSH Keys",
+		Type:     config.SourceTypeSensitive,
+		Override: config.Override{Retention: &config.RetentionConfig{KeepLast: 3}},
+	}
+	cfg := &config.Config{
+		BackupDir: filepath.Join(tempDir, "backups"),
+		Retention: config.RetentionConfig{KeepLast: 30},
+		Restic:    config.ResticConfig{RepoPath: repoPath},
+	}
+
+	result := svc.BackupSensitiveSource(context.Background(), cfg, source)
+	if result.Error != nil {
+		t.Fatalf("BackupSensitiveSource failed: %v", result.Error)
+	}
+	if got := mockRestic.ForgetCalls[repoPath]; got != 3 {
+		t.Errorf("forget keepLast = %d, expected the source's 3", got)
+	}
+}
+
 func TestBackupSensitiveSourceNonExistentPath(t *testing.T) {
 	mockFS := mocks.NewMockFileSystem()
 	mockGit := mocks.NewMockGitClient()
@@ -1012,7 +1806,7 @@ func TestBackupSensitiveSourceNonExistentPath(t *testing.T) {
 		},
 	}
 
-	result := svc.BackupSensitiveSource(cfg, source)
+	result := svc.BackupSensitiveSource(context.Background(), cfg, source)
 	if !result.Skipped {
 		t.Error("Should be skipped when source path doesn't exist")
 	}
@@ -1044,7 +1838,7 @@ func TestBackupSensitiveSourceNoPassword(t *testing.T) {
 		},
 	}
 
-	result := svc.BackupSensitiveSource(cfg, source)
+	result := svc.BackupSensitiveSource(context.Background(), cfg, source)
 	if result.Error == nil {
 		t.Error("Should fail when password env var is not set")
 	}
@@ -1090,7 +1884,7 @@ func TestBackupSensitiveSourceResticBackupError(t *testing.T) {
 		},
 	}
 
-	result := svc.BackupSensitiveSource(cfg, source)
+	result := svc.BackupSensitiveSource(context.Background(), cfg, source)
 	if result.Error == nil {
 		t.Error("Should fail when restic backup fails")
 	}
@@ -1148,7 +1942,7 @@ func TestRunBackupMixedSources(t *testing.T) {
 		},
 	}
 
-	results, err := svc.RunBackup(cfg)
+	results, err := svc.RunBackup(context.Background(), cfg)
 	if err != nil {
 		t.Fatalf("RunBackup failed: %v", err)
 	}
@@ -1230,7 +2024,7 @@ func TestRunBackupSensitiveSourcesOnly(t *testing.T) {
 		},
 	}
 
-	results, err := svc.RunBackup(cfg)
+	results, err := svc.RunBackup(context.Background(), cfg)
 	if err != nil {
 		t.Fatalf("RunBackup failed: %v", err)
 	}
@@ -1248,3 +2042,379 @@ func TestRunBackupSensitiveSourcesOnly(t *testing.T) {
 		}
 	}
 }
+
+func TestRunBackupParallelKeepsOrder(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+
+	var names []string
+	for i := 0; i < 12; i++ {
+		name := fmt.Sprintf("project-%02d", i)
+		names = append(names, name)
+		projectDir := filepath.Join(sourceDir, name)
+		if err := os.MkdirAll(projectDir, 0755); err != nil {
+			t.Fatalf("Failed to create project dir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(projectDir, "main.go"), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	svc := NewService(osfs.New(), mocks.NewMockGitClient(), ziparchiver.New(), mocks.NewMockResticClient())
+	cfg := &config.Config{
+		SourceDir: sourceDir,
+		BackupDir: filepath.Join(tempDir, "backups"),
+		Jobs:      4,
+	}
+
+	var streamed []string
+	results, err := svc.RunBackupStream(context.Background(), cfg, func(r BackupResult) {
+		streamed = append(streamed, r.Project)
+	}, nil)
+	if err != nil {
+		t.Fatalf("RunBackupStream failed: %v", err)
+	}
+
+	if len(results) != len(names) {
+		t.Fatalf("got %d results, expected %d", len(results), len(names))
+	}
+	for i, r := range results {
+		if r.Project != names[i] {
+			t.Errorf("results[%d] = %s, expected %s", i, r.Project, names[i])
+		}
+		if r.Error != nil {
+			t.Errorf("%s failed: %v", r.Project, r.Error)
+		}
+	}
+	if len(streamed) != len(names) {
+		t.Errorf("onResult called %d times, expected %d", len(streamed), len(names))
+	}
+}
+
+func TestRunBackupStreamStopsOnCancel(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+	for i := 0; i < 4; i++ {
+		projectDir := filepath.Join(sourceDir, fmt.Sprintf("project-%d", i))
+		if err := os.MkdirAll(projectDir, 0755); err != nil {
+			t.Fatalf("Failed to create project dir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(projectDir, "main.go"), []byte("package main"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	svc := NewService(osfs.New(), mocks.NewMockGitClient(), ziparchiver.New(), mocks.NewMockResticClient())
+	cfg := &config.Config{
+		SourceDir: sourceDir,
+		BackupDir: filepath.Join(tempDir, "backups"),
+		Jobs:      1,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results, err := svc.RunBackupStream(ctx, cfg, func(BackupResult) {
+		cancel()
+	}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, expected context.Canceled", err)
+	}
+	if len(results) == 0 || len(results) == 4 {
+		t.Fatalf("got %d results, expected the run to stop early", len(results))
+	}
+	if results[0].Error != nil {
+		t.Errorf("first project failed: %v", results[0].Error)
+	}
+	for _, r := range results[1:] {
+		if !errors.Is(r.Error, context.Canceled) {
+			t.Errorf("%s Error = %v, expected context.Canceled", r.Project, r.Error)
+		}
+	}
+}
+
+// concurrencyRestic wraps MockResticClient and records the peak number of
+// concurrent Backup calls.
+type concurrencyRestic struct {
+	*mocks.MockResticClient
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func (r *concurrencyRestic) Backup(ctx context.Context, repoPath, password string, paths []string, tags []string) (string, error) {
+	r.mu.Lock()
+	r.running++
+	if r.running > r.peak {
+		r.peak = r.running
+	}
+	r.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running--
+	return r.MockResticClient.Backup(ctx, repoPath, password, paths, tags)
+}
+
+func TestRunBackupSerializesSensitiveSources(t *testing.T) {
+	t.Setenv("CODEBAK_RESTIC_PASSWORD", "test-password")
+	tempDir := t.TempDir()
+
+	var sources []config.Source
+	for i := 0; i < 4; i++ {
+		path := filepath.Join(tempDir, fmt.Sprintf("secrets-%d", i))
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatalf("Failed to create source dir: %v", err)
+		}
+		sources = append(sources, config.Source{Path: path, Type: config.SourceTypeSensitive})
+	}
+
+	restic := &concurrencyRestic{MockResticClient: mocks.NewMockResticClient()}
+	restic.MockResticClient.InitializedRepos[filepath.Join(tempDir, "repo")] = true
+	svc := NewService(osfs.New(), mocks.NewMockGitClient(), mocks.NewMockArchiver(), restic)
+	cfg := &config.Config{
+		Sources:   sources,
+		BackupDir: filepath.Join(tempDir, "backups"),
+		Jobs:      4,
+	}
+	cfg.Restic.RepoPath = filepath.Join(tempDir, "repo")
+
+	results, err := svc.RunBackup(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("RunBackup failed: %v", err)
+	}
+	for _, r := range results {
+		if r.Error != nil {
+			t.Errorf("%s failed: %v", r.Project, r.Error)
+		}
+	}
+	if restic.peak != 1 {
+		t.Errorf("peak concurrent restic backups = %d, expected 1", restic.peak)
+	}
+}
+
+func TestBackupProjectGitBundle(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+	projectDir := filepath.Join(sourceDir, "repo")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"add", "."}, {"commit", "-q", "-m", "initial"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = projectDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+
+	backupDir := filepath.Join(tempDir, "backups")
+	cfg := &config.Config{
+		SourceDir: sourceDir,
+		BackupDir: backupDir,
+		Exclude:   []string{".git"},
+		GitBundle: true,
+	}
+	svc := NewService(osfs.New(), execgit.New(), ziparchiver.New(), mocks.NewMockResticClient())
+
+	result := svc.BackupProject(context.Background(), cfg, "repo")
+	if result.Error != nil {
+		t.Fatalf("BackupProject failed: %v", result.Error)
+	}
+
+	m, err := manifest.Load(backupDir, "repo")
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+	entry := m.LatestBackup()
+	if entry.Bundle != entry.Version()+manifest.BundleExt {
+		t.Fatalf("Bundle = %q, expected %q", entry.Bundle, entry.Version()+manifest.BundleExt)
+	}
+	checksum, err := manifest.ComputeSHA256(filepath.Join(backupDir, "repo", entry.Bundle))
+	if err != nil {
+		t.Fatalf("bundle not written: %v", err)
+	}
+	if checksum != entry.BundleSHA256 {
+		t.Errorf("BundleSHA256 = %s, expected %s", entry.BundleSHA256, checksum)
+	}
+}
+
+func TestBackupProjectGitBundleError(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+	projectDir := filepath.Join(sourceDir, "repo")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+
+	mockGit := mocks.NewMockGitClient()
+	mockGit.Repos[projectDir] = true
+	mockGit.Heads[projectDir] = "abc123"
+	mockGit.BundleErrors[projectDir] = errors.New("bundle failed")
+	svc := NewService(osfs.New(), mockGit, ziparchiver.New(), mocks.NewMockResticClient())
+
+	cfg := &config.Config{SourceDir: sourceDir, BackupDir: filepath.Join(tempDir, "backups"), GitBundle: true}
+	result := svc.BackupProject(context.Background(), cfg, "repo")
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "creating git bundle") {
+		t.Fatalf("Error = %v, expected bundle error", result.Error)
+	}
+
+	// The archive of a failed version is not left behind
+	entries, _ := os.ReadDir(filepath.Join(tempDir, "backups", "repo"))
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".zip") {
+			t.Errorf("archive %s should be removed after a bundle failure", e.Name())
+		}
+	}
+}
+
+// bundleGit is a mock git client whose bundles are written to disk.
+type bundleGit struct {
+	*mocks.MockGitClient
+}
+
+func (g *bundleGit) CreateBundle(ctx context.Context, repoPath, destPath string) error {
+	if err := g.MockGitClient.CreateBundle(ctx, repoPath, destPath); err != nil {
+		return err
+	}
+	return os.WriteFile(destPath, []byte("bundle"), 0644)
+}
+
+func TestBackupProjectRemovesBundleOnFailure(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+	projectDir := filepath.Join(sourceDir, "repo")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// A directory in the way of manifest.json.bak makes the manifest save fail
+	backupDir := filepath.Join(tempDir, "backups")
+	if err := (&manifest.Manifest{Project: "repo"}).Save(backupDir); err != nil {
+		t.Fatalf("Failed to save manifest: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(manifest.BackupPath(backupDir, "repo"), "blocker"), 0755); err != nil {
+		t.Fatalf("Failed to create blocker: %v", err)
+	}
+
+	mockGit := &bundleGit{mocks.NewMockGitClient()}
+	mockGit.Repos[projectDir] = true
+	mockGit.Heads[projectDir] = "abc123"
+	svc := NewService(osfs.New(), mockGit, ziparchiver.New(), mocks.NewMockResticClient())
+
+	cfg := &config.Config{SourceDir: sourceDir, BackupDir: backupDir, GitBundle: true}
+	result := svc.BackupProject(context.Background(), cfg, "repo")
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "saving manifest") {
+		t.Fatalf("Error = %v, expected a manifest save error", result.Error)
+	}
+	if len(mockGit.Bundles) != 1 {
+		t.Fatalf("expected a bundle to be created, got %v", mockGit.Bundles)
+	}
+
+	// Neither the archive nor the bundle of the unrecorded version is left behind
+	entries, _ := os.ReadDir(filepath.Join(backupDir, "repo"))
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".zip") || strings.HasSuffix(e.Name(), manifest.BundleExt) {
+			t.Errorf("%s should be removed after the manifest save failed", e.Name())
+		}
+	}
+}
+
+// failingBundleGit is a mock git client that writes part of a bundle, and
+// the temp file it was writing, before failing.
+type failingBundleGit struct {
+	*mocks.MockGitClient
+}
+
+func (g *failingBundleGit) CreateBundle(ctx context.Context, repoPath, destPath string) error {
+	_ = os.WriteFile(destPath, []byte("half a bun"), 0644)
+	_ = os.WriteFile(destPath+atomicfile.PartialSuffix, []byte("half"), 0644)
+	return errors.New("bundle: write error")
+}
+
+func TestBackupProjectDiscardsVersionWhenBundleFails(t *testing.T) {
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+	projectDir := filepath.Join(sourceDir, "repo")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	mockGit := &failingBundleGit{mocks.NewMockGitClient()}
+	mockGit.Repos[projectDir] = true
+	mockGit.Heads[projectDir] = "abc123"
+	svc := NewService(osfs.New(), mockGit, multiarchiver.New(), mocks.NewMockResticClient())
+
+	backupDir := filepath.Join(tempDir, "backups")
+	cfg := &config.Config{SourceDir: sourceDir, BackupDir: backupDir, GitBundle: true, Storage: config.StorageChunked}
+	result := svc.BackupProject(context.Background(), cfg, "repo")
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "creating git bundle") {
+		t.Fatalf("Error = %v, expected a bundle error", result.Error)
+	}
+
+	// Nothing of the failed version is left: no index, bundle, temp file or chunk
+	var left []string
+	_ = filepath.Walk(filepath.Join(backupDir, "repo"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			left = append(left, path)
+		}
+		return nil
+	})
+	if len(left) != 0 {
+		t.Errorf("files left behind by the failed version: %v", left)
+	}
+}
+
+func TestBackupProjectWritesFileIndex(t *testing.T) {
+	tempDir := t.TempDir()
+	projectDir := filepath.Join(tempDir, "source", "indexed")
+	if err := os.MkdirAll(filepath.Join(projectDir, "pkg"), 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	for path, content := range map[string]string{"main.go": "package main", "pkg/util.go": "package pkg"} {
+		if err := os.WriteFile(filepath.Join(projectDir, path), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	svc := NewService(osfs.New(), mocks.NewMockGitClient(), ziparchiver.New(), mocks.NewMockResticClient())
+	cfg := &config.Config{SourceDir: filepath.Dir(projectDir), BackupDir: filepath.Join(tempDir, "backups")}
+
+	result := svc.BackupProject(context.Background(), cfg, "indexed")
+	if result.Error != nil {
+		t.Fatalf("BackupProject failed: %v", result.Error)
+	}
+
+	version := manifest.VersionName(filepath.Base(result.ZipPath))
+	idx, err := fileindex.Load(cfg.BackupDir, "indexed", version)
+	if err != nil {
+		t.Fatalf("fileindex.Load failed: %v", err)
+	}
+	if len(idx.Files) != 2 || idx.Version != version {
+		t.Fatalf("index = %+v, expected both files", idx)
+	}
+	f, ok := idx.Lookup("pkg/util.go")
+	sum := sha256.Sum256([]byte("package pkg"))
+	if !ok || f.Size != 11 || f.SHA256 != hex.EncodeToString(sum[:]) || f.Mode != 0644 {
+		t.Errorf("pkg/util.go = %+v, expected size, checksum and mode recorded", f)
+	}
+}
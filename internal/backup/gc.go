@@ -0,0 +1,389 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmcdonald/codebak/internal/adapters/chunkstore"
+	"github.com/jmcdonald/codebak/internal/atomicfile"
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/fileindex"
+	"github.com/jmcdonald/codebak/internal/manifest"
+)
+
+// IssueKind classifies a mismatch between a manifest and the files on disk.
+type IssueKind string
+
+const (
+	// IssueOrphan is an archive or bundle that no manifest entry references
+	IssueOrphan IssueKind = "orphan"
+	// IssueMissing is a manifest entry whose archive or bundle is gone
+	IssueMissing IssueKind = "missing"
+	// IssueChecksum is a file whose SHA-256 no longer matches the manifest
+	IssueChecksum IssueKind = "checksum"
+	// IssueTemp is a temp file left behind by an interrupted write
+	IssueTemp IssueKind = "temp"
+)
+
+// Issue is one problem found by GC.
+type Issue struct {
+	Kind   IssueKind
+	File   string // Relative to the project's backup directory
+	Detail string
+	Fixed  bool // Set when --fix repaired the issue
+}
+
+// GCResult describes a GC run over one project.
+type GCResult struct {
+	Project string
+	Issues  []Issue
+	Error   error
+}
+
+// Unfixed returns how many issues remain.
+func (r GCResult) Unfixed() int {
+	n := 0
+	for _, issue := range r.Issues {
+		if !issue.Fixed {
+			n++
+		}
+	}
+	return n
+}
+
+// GC reconciles project's manifest with the files in its backup directory,
+// or every project's when project is "". It reports archives and bundles the
+// manifest does not know about, entries whose files are missing, checksum
+// drift and leftover temp files. With fix, orphaned archives are adopted into
+// the manifest, entries with missing archives are dropped and temp files and
+// stray file indexes are removed. Checksum drift is only reported: the file is the only copy.
+func (s *Service) GC(cfg *config.Config, project string, fix bool) ([]GCResult, error) {
+	backupDir, err := config.ExpandPath(cfg.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+
+	projects := []string{project}
+	if project == "" {
+		projects, err = s.storedProjects(backupDir)
+		if err != nil {
+			return nil, err
+		}
+	} else if info, err := s.fs.Stat(filepath.Join(backupDir, project)); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("no backups found for %s", project)
+	}
+
+	var results []GCResult
+	for _, p := range projects {
+		results = append(results, s.gcProject(cfg, backupDir, p, fix))
+	}
+	return results, nil
+}
+
+// storedProjects returns the directories in backupDir that hold a manifest,
+// backup archives or temp files, sorted by name. Unlike manifest.Projects it
+// includes projects whose manifest has been lost, and directories holding
+// nothing but the leftovers of an interrupted write.
+func (s *Service) storedProjects(backupDir string) ([]string, error) {
+	entries, err := s.fs.ReadDir(backupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var projects []string
+	for _, entry := range entries {
+		// Skip the lock directory and other codebak internals
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		files, err := s.fs.ReadDir(filepath.Join(backupDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		stored := false
+		for _, f := range files {
+			if f.Name() == "manifest.json" || isArchive(f.Name()) {
+				stored = true
+				break
+			}
+		}
+		if stored || len(s.partialFiles(backupDir, entry.Name())) > 0 {
+			projects = append(projects, entry.Name())
+		}
+	}
+	sort.Strings(projects)
+	return projects, nil
+}
+
+// isArchive reports whether name is a backup archive or chunk store index.
+func isArchive(name string) bool {
+	return filepath.Ext(name) == ".zip" || chunkstore.IsIndex(name)
+}
+
+// gcProject checks one project and, if fix is set, repairs what it can.
+func (s *Service) gcProject(cfg *config.Config, backupDir, project string, fix bool) GCResult {
+	result := GCResult{Project: project}
+
+	if fix {
+		unlock, err := s.lockProject(cfg, backupDir, project)
+		if err != nil {
+			result.Error = err
+			return result
+		}
+		defer unlock()
+	}
+
+	m, err := manifest.Load(backupDir, project)
+	if err != nil {
+		result.Error = fmt.Errorf("loading manifest: %w", err)
+		return result
+	}
+
+	projectDir := filepath.Join(backupDir, project)
+	entries, err := s.fs.ReadDir(projectDir)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	onDisk := make(map[string]bool)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			onDisk[entry.Name()] = true
+		}
+	}
+
+	changed := false
+	droppedChunked := false
+	referenced := make(map[string]bool)
+	var kept []manifest.BackupEntry
+	for _, entry := range m.Backups {
+		if !onDisk[entry.File] {
+			referenced[entry.Bundle] = true
+			issue := Issue{Kind: IssueMissing, File: entry.File, Detail: "archive listed in manifest but not on disk"}
+			if fix {
+				if entry.Bundle != "" && onDisk[entry.Bundle] {
+					_ = s.fs.Remove(filepath.Join(projectDir, entry.Bundle))
+				}
+				_ = fileindex.Remove(backupDir, project, entry.Version())
+				droppedChunked = droppedChunked || entry.Format == manifest.FormatChunked
+				issue.Fixed = true
+				changed = true
+			} else {
+				kept = append(kept, entry)
+			}
+			result.Issues = append(result.Issues, issue)
+			continue
+		}
+		referenced[entry.File] = true
+		result.Issues = append(result.Issues, checkChecksum(projectDir, entry.File, entry.SHA256)...)
+
+		if entry.Bundle != "" {
+			if onDisk[entry.Bundle] {
+				referenced[entry.Bundle] = true
+				result.Issues = append(result.Issues, checkChecksum(projectDir, entry.Bundle, entry.BundleSHA256)...)
+			} else {
+				issue := Issue{Kind: IssueMissing, File: entry.Bundle, Detail: "git bundle listed in manifest but not on disk"}
+				if fix {
+					entry.Bundle, entry.BundleSHA256 = "", ""
+					issue.Fixed = true
+					changed = true
+				}
+				result.Issues = append(result.Issues, issue)
+			}
+		}
+		kept = append(kept, entry)
+	}
+
+	// Archives first, so bundles can attach to versions adopted in this run
+	names := make([]string, 0, len(onDisk))
+	for name := range onDisk {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if referenced[name] || !isArchive(name) {
+			continue
+		}
+		issue := Issue{Kind: IssueOrphan, File: name, Detail: "archive not in manifest"}
+		if fix {
+			entry, err := s.adopt(projectDir, name)
+			if err != nil {
+				issue.Detail = fmt.Sprintf("archive not in manifest, cannot adopt: %v", err)
+			} else {
+				kept = append(kept, entry)
+				referenced[name] = true
+				issue.Fixed = true
+				changed = true
+			}
+		}
+		result.Issues = append(result.Issues, issue)
+	}
+	for _, name := range names {
+		if referenced[name] || filepath.Ext(name) != manifest.BundleExt {
+			continue
+		}
+		issue := Issue{Kind: IssueOrphan, File: name, Detail: "git bundle not in manifest"}
+		if fix {
+			if owner := bundleOwner(kept, name); owner != nil {
+				if sum, err := manifest.ComputeSHA256(filepath.Join(projectDir, name)); err == nil {
+					owner.Bundle, owner.BundleSHA256 = name, sum
+					issue.Fixed = true
+					changed = true
+				}
+			}
+		}
+		result.Issues = append(result.Issues, issue)
+	}
+
+	result.Issues = append(result.Issues, s.orphanIndexes(backupDir, project, kept, fix)...)
+
+	for _, name := range s.partialFiles(projectDir, "") {
+		issue := Issue{Kind: IssueTemp, File: name, Detail: "leftover from an interrupted write"}
+		if fix && s.fs.Remove(filepath.Join(projectDir, name)) == nil {
+			issue.Fixed = true
+		}
+		result.Issues = append(result.Issues, issue)
+	}
+
+	if !changed {
+		return result
+	}
+
+	if kept == nil {
+		kept = []manifest.BackupEntry{}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].CreatedAt.Before(kept[j].CreatedAt) })
+	m.Backups = kept
+	if err := m.Save(backupDir); err != nil {
+		result.Error = fmt.Errorf("saving manifest: %w", err)
+		return result
+	}
+	if droppedChunked {
+		if _, err := chunkstore.CollectGarbage(projectDir); err != nil {
+			result.Error = fmt.Errorf("collecting unused chunks: %w", err)
+		}
+	}
+	return result
+}
+
+// checkChecksum compares a file's SHA-256 with the one recorded in the
+// manifest. Entries without a recorded checksum are not checked.
+func checkChecksum(projectDir, name, want string) []Issue {
+	if want == "" {
+		return nil
+	}
+	got, err := manifest.ComputeSHA256(filepath.Join(projectDir, name))
+	if err != nil {
+		return []Issue{{Kind: IssueChecksum, File: name, Detail: fmt.Sprintf("cannot read: %v", err)}}
+	}
+	if got != want {
+		return []Issue{{Kind: IssueChecksum, File: name, Detail: fmt.Sprintf("expected %s, got %s", shortHash(want), shortHash(got))}}
+	}
+	return nil
+}
+
+// adopt builds a manifest entry for an archive found on disk. The creation
+// time comes from the version name, falling back to the file's mtime.
+func (s *Service) adopt(projectDir, name string) (manifest.BackupEntry, error) {
+	path := filepath.Join(projectDir, name)
+	info, err := s.fs.Stat(path)
+	if err != nil {
+		return manifest.BackupEntry{}, err
+	}
+	// Listing proves the archive is readable before it is trusted
+	files, err := s.archiver.List(path)
+	if err != nil {
+		return manifest.BackupEntry{}, err
+	}
+	checksum, err := manifest.ComputeSHA256(path)
+	if err != nil {
+		return manifest.BackupEntry{}, err
+	}
+
+	created, err := time.ParseInLocation("20060102-150405", manifest.VersionName(name), time.Local)
+	if err != nil {
+		created = info.ModTime()
+	}
+	entry := manifest.BackupEntry{
+		File:      name,
+		SHA256:    checksum,
+		SizeBytes: info.Size(),
+		CreatedAt: created,
+		FileCount: len(files),
+	}
+	if chunkstore.IsIndex(name) {
+		entry.Format = manifest.FormatChunked
+	}
+	return entry, nil
+}
+
+// bundleOwner returns the version a bundle belongs to, matched by version
+// name, or nil if there is none or it already has a bundle.
+func bundleOwner(entries []manifest.BackupEntry, bundle string) *manifest.BackupEntry {
+	version := manifest.VersionName(bundle)
+	for i := range entries {
+		if entries[i].Version() == version && entries[i].Bundle == "" {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+// orphanIndexes reports file indexes for versions not in entries and, with
+// fix, removes them.
+func (s *Service) orphanIndexes(backupDir, project string, entries []manifest.BackupEntry, fix bool) []Issue {
+	files, err := s.fs.ReadDir(filepath.Join(backupDir, project, fileindex.DirName))
+	if err != nil {
+		return nil
+	}
+	versions := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		versions[entry.Version()] = true
+	}
+
+	var issues []Issue
+	for _, f := range files {
+		version := manifest.VersionName(f.Name())
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" || versions[version] {
+			continue
+		}
+		issue := Issue{Kind: IssueOrphan, File: filepath.Join(fileindex.DirName, f.Name()), Detail: "file index not in manifest"}
+		if fix && fileindex.Remove(backupDir, project, version) == nil {
+			issue.Fixed = true
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// partialFiles returns the temp files under dir/rel, relative to dir.
+func (s *Service) partialFiles(dir, rel string) []string {
+	entries, err := s.fs.ReadDir(filepath.Join(dir, rel))
+	if err != nil {
+		return nil
+	}
+	var found []string
+	for _, entry := range entries {
+		name := filepath.Join(rel, entry.Name())
+		if entry.IsDir() {
+			found = append(found, s.partialFiles(dir, name)...)
+		} else if atomicfile.IsPartial(entry.Name()) {
+			found = append(found, name)
+		}
+	}
+	return found
+}
+
+// GC reconciles manifests with the files on disk for project, or for every
+// project when project is "".
+// Uses the default production dependencies.
+func GC(cfg *config.Config, project string, fix bool) ([]GCResult, error) {
+	return defaultService.GC(cfg, project, fix)
+}
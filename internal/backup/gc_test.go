@@ -0,0 +1,182 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmcdonald/codebak/internal/adapters/ziparchiver"
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/fileindex"
+	"github.com/jmcdonald/codebak/internal/manifest"
+	"github.com/jmcdonald/codebak/internal/ports"
+)
+
+// setupGC creates a project with three versions, then deletes the first
+// archive, drops in a real zip and a file index the manifest does not know
+// about and leaves a temp file behind. Returns the backup dir and the version
+// file names.
+func setupGC(t *testing.T) (string, []string) {
+	t.Helper()
+	backupDir := t.TempDir()
+	files := writeVersions(t, backupDir, "proj", 3)
+	projectDir := filepath.Join(backupDir, "proj")
+
+	if err := os.Remove(filepath.Join(projectDir, files[0])); err != nil {
+		t.Fatalf("Failed to remove backup: %v", err)
+	}
+
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+	orphan := filepath.Join(projectDir, "20260102-180000.zip")
+	if _, err := ziparchiver.New().Create(context.Background(), orphan, sourceDir, nil, ports.CompressDefault, nil); err != nil {
+		t.Fatalf("Failed to create orphan zip: %v", err)
+	}
+
+	if err := fileindex.Write(backupDir, "proj", "20251231-120000", nil); err != nil {
+		t.Fatalf("Failed to create file index: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(projectDir, "20260104-120000.zip.partial"), []byte("half"), 0644); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	return backupDir, files
+}
+
+// issueKinds counts a result's issues by kind.
+func issueKinds(r GCResult) map[IssueKind]int {
+	kinds := make(map[IssueKind]int)
+	for _, issue := range r.Issues {
+		kinds[issue.Kind]++
+	}
+	return kinds
+}
+
+func TestGCReportsIssues(t *testing.T) {
+	backupDir, _ := setupGC(t)
+	cfg := &config.Config{BackupDir: backupDir}
+
+	results, err := newPruneService().GC(cfg, "", false)
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Error != nil {
+		t.Fatalf("results = %+v, expected one successful result", results)
+	}
+	kinds := issueKinds(results[0])
+	if kinds[IssueMissing] != 1 || kinds[IssueOrphan] != 2 || kinds[IssueTemp] != 1 {
+		t.Errorf("issues = %+v, expected one missing, two orphans and one temp", results[0].Issues)
+	}
+	if results[0].Unfixed() != 4 {
+		t.Errorf("Unfixed() = %d, expected 4 without --fix", results[0].Unfixed())
+	}
+
+	m, _ := manifest.Load(backupDir, "proj")
+	if len(m.Backups) != 3 {
+		t.Errorf("manifest has %d backups, expected it untouched", len(m.Backups))
+	}
+}
+
+func TestGCFix(t *testing.T) {
+	backupDir, files := setupGC(t)
+	cfg := &config.Config{BackupDir: backupDir}
+
+	results, err := newPruneService().GC(cfg, "proj", true)
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if results[0].Error != nil || results[0].Unfixed() != 0 {
+		t.Fatalf("result = %+v, expected every issue fixed", results[0])
+	}
+
+	m, err := manifest.Load(backupDir, "proj")
+	if err != nil {
+		t.Fatalf("manifest.Load failed: %v", err)
+	}
+	if len(m.Backups) != 3 {
+		t.Fatalf("manifest backups = %+v, expected the orphan adopted and the missing entry dropped", m.Backups)
+	}
+	// The adopted version is placed by the time in its name
+	adopted := m.Backups[1]
+	if adopted.File != "20260102-180000.zip" || m.Backups[0].File != files[1] {
+		t.Errorf("backups = %+v, expected the orphan between the remaining versions", m.Backups)
+	}
+	want := time.Date(2026, time.January, 2, 18, 0, 0, 0, time.Local)
+	if !adopted.CreatedAt.Equal(want) || adopted.FileCount != 1 || adopted.SHA256 == "" {
+		t.Errorf("adopted = %+v, expected time, file count and checksum filled in", adopted)
+	}
+	if _, err := os.Stat(filepath.Join(backupDir, "proj", "20260104-120000.zip.partial")); !os.IsNotExist(err) {
+		t.Error("temp file should be removed")
+	}
+	if _, err := fileindex.Load(backupDir, "proj", "20251231-120000"); !os.IsNotExist(err) {
+		t.Error("stray file index should be removed")
+	}
+
+	// A second run finds nothing
+	results, _ = newPruneService().GC(cfg, "proj", false)
+	if len(results[0].Issues) != 0 {
+		t.Errorf("issues = %+v, expected a clean project", results[0].Issues)
+	}
+}
+
+func TestGCCleansUpPartialOnlyProjects(t *testing.T) {
+	backupDir, _ := setupGC(t)
+	// A project whose only write was interrupted before its manifest was saved
+	stale := filepath.Join(backupDir, "abandoned", "20260101-120000.zip.partial")
+	if err := os.MkdirAll(filepath.Dir(stale), 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	if err := os.WriteFile(stale, []byte("half"), 0644); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	cfg := &config.Config{BackupDir: backupDir}
+
+	results, err := newPruneService().GC(cfg, "", false)
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Project != "abandoned" {
+		t.Fatalf("results = %+v, expected the abandoned project to be checked", results)
+	}
+	if kinds := issueKinds(results[0]); kinds[IssueTemp] != 1 || len(results[0].Issues) != 1 {
+		t.Errorf("issues = %+v, expected one temp file", results[0].Issues)
+	}
+
+	if _, err := newPruneService().GC(cfg, "", true); err != nil {
+		t.Fatalf("GC --fix failed: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("temp file should be removed")
+	}
+}
+
+func TestGCChecksumDrift(t *testing.T) {
+	backupDir := t.TempDir()
+	files := writeVersions(t, backupDir, "proj", 1)
+	m, _ := manifest.Load(backupDir, "proj")
+	m.Backups[0].SHA256 = "0000000000000000"
+	if err := m.Save(backupDir); err != nil {
+		t.Fatalf("Failed to save manifest: %v", err)
+	}
+	cfg := &config.Config{BackupDir: backupDir}
+
+	results, err := newPruneService().GC(cfg, "proj", true)
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	issues := results[0].Issues
+	if len(issues) != 1 || issues[0].Kind != IssueChecksum || issues[0].File != files[0] || issues[0].Fixed {
+		t.Errorf("issues = %+v, expected unfixed checksum drift", issues)
+	}
+}
+
+func TestGCUnknownProject(t *testing.T) {
+	cfg := &config.Config{BackupDir: t.TempDir()}
+	if _, err := newPruneService().GC(cfg, "missing", false); err == nil {
+		t.Error("GC should fail for a project without a backup directory")
+	}
+}
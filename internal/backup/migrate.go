@@ -0,0 +1,52 @@
+package backup
+
+import (
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/manifest"
+)
+
+// MigrateResult describes the manifest upgrade of one project.
+type MigrateResult struct {
+	Project string
+	From    int    // Schema version found on disk
+	Backup  string // Copy of the original manifest; "" when it was already current
+	Error   error
+}
+
+// Migrate upgrades every project's manifest on disk to the current schema
+// version, keeping a copy of each original next to it. Manifests already at
+// the current version are left alone, and those written by a newer codebak
+// are reported as errors. Holds the backup directory lock throughout.
+func (s *Service) Migrate(cfg *config.Config) ([]MigrateResult, error) {
+	backupDir, err := config.ExpandPath(cfg.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := s.lockBackupDir(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	projects, err := manifest.Projects(backupDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []MigrateResult
+	for _, project := range projects {
+		result := MigrateResult{Project: project}
+		result.From, result.Error = manifest.Migrate(backupDir, project)
+		if result.Error == nil && result.From < manifest.SchemaVersion {
+			result.Backup = manifest.MigrationBackupPath(backupDir, project, result.From)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Migrate upgrades every project's manifest to the current schema version.
+// Uses the default production dependencies.
+func Migrate(cfg *config.Config) ([]MigrateResult, error) {
+	return defaultService.Migrate(cfg)
+}
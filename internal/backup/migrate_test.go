@@ -0,0 +1,37 @@
+package backup
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/manifest"
+)
+
+func TestMigrateUpgradesLegacyManifests(t *testing.T) {
+	backupDir := t.TempDir()
+	writeVersions(t, backupDir, "current", 1)
+	writeVersions(t, backupDir, "legacy", 1)
+	legacy := `{"project": "legacy", "source": "", "backups": []}`
+	if err := os.WriteFile(manifest.ManifestPath(backupDir, "legacy"), []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+	cfg := &config.Config{BackupDir: backupDir}
+
+	results, err := newPruneService().Migrate(cfg)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Project != "current" || results[1].Project != "legacy" {
+		t.Fatalf("results = %+v, expected current and legacy", results)
+	}
+	if results[0].Error != nil || results[0].Backup != "" || results[0].From != manifest.SchemaVersion {
+		t.Errorf("current = %+v, expected it left alone", results[0])
+	}
+	if results[1].Error != nil || results[1].From != 0 || results[1].Backup != manifest.MigrationBackupPath(backupDir, "legacy", 0) {
+		t.Errorf("legacy = %+v, expected an upgrade from schema 0", results[1])
+	}
+	if _, err := os.Stat(results[1].Backup); err != nil {
+		t.Errorf("original manifest should be kept: %v", err)
+	}
+}
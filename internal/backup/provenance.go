@@ -0,0 +1,91 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/manifest"
+)
+
+// Trigger records what started a backup run.
+type Trigger string
+
+const (
+	// TriggerManual is a run started from the command line (default)
+	TriggerManual Trigger = "manual"
+	// TriggerSchedule is a run started by the launchd schedule
+	TriggerSchedule Trigger = "schedule"
+	// TriggerTUI is a backup started from the interactive TUI
+	TriggerTUI Trigger = "tui"
+)
+
+// RunInfo describes the codebak process making a backup. It is recorded in
+// the provenance of every version the run creates.
+type RunInfo struct {
+	Version string // codebak version
+	Trigger Trigger
+}
+
+type runInfoKey struct{}
+
+// WithRunInfo returns a context whose backups record info in their provenance.
+func WithRunInfo(ctx context.Context, info RunInfo) context.Context {
+	return context.WithValue(ctx, runInfoKey{}, info)
+}
+
+// runInfo returns the RunInfo attached to ctx. Runs without one are manual.
+func runInfo(ctx context.Context) RunInfo {
+	info, _ := ctx.Value(runInfoKey{}).(RunInfo)
+	if info.Trigger == "" {
+		info.Trigger = TriggerManual
+	}
+	return info
+}
+
+// provenance describes the backup of projectPath that started at start.
+func (s *Service) provenance(ctx context.Context, cfg *config.Config, eff config.Effective, projectPath, dirtyHash string, start time.Time) *manifest.Provenance {
+	info := runInfo(ctx)
+	p := &manifest.Provenance{
+		User:           username(),
+		CodebakVersion: info.Version,
+		Dirty:          dirtyHash != "",
+		Duration:       time.Since(start),
+		Trigger:        string(info.Trigger),
+		ConfigHash:     configHash(cfg, eff),
+	}
+	p.Hostname, _ = os.Hostname()
+	if s.git.IsRepo(projectPath) {
+		p.Branch = s.git.Branch(ctx, projectPath)
+	}
+	return p
+}
+
+// username returns the name of the user running codebak, or "" if unknown.
+func username() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// configHash returns the SHA-256 of the settings that shape a project's
+// backups, so versions made with different settings can be told apart.
+// Settings that do not change what is stored, such as the schedule or the
+// lock wait, are left out.
+func configHash(cfg *config.Config, eff config.Effective) string {
+	data, _ := json.Marshal(struct {
+		Effective        config.Effective
+		Storage          config.StorageFormat
+		GitBundle        bool
+		RespectGitignore bool
+		FailOnSkip       bool
+	}{eff, cfg.GetStorage(), cfg.GitBundle, cfg.RespectGitignore, cfg.FailOnSkip})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
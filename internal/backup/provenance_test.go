@@ -0,0 +1,91 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmcdonald/codebak/internal/adapters/osfs"
+	"github.com/jmcdonald/codebak/internal/adapters/ziparchiver"
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/manifest"
+	"github.com/jmcdonald/codebak/internal/mocks"
+	"github.com/jmcdonald/codebak/internal/ports"
+)
+
+func TestBackupProjectRecordsProvenance(t *testing.T) {
+	tempDir := t.TempDir()
+	projectDir := filepath.Join(tempDir, "source", "traced")
+	if err := os.MkdirAll(filepath.Join(projectDir, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	git := mocks.NewMockGitClient()
+	git.Repos[projectDir] = true
+	git.Heads[projectDir] = "abc123"
+	git.Branches[projectDir] = "feature/login"
+	git.Statuses[projectDir] = []ports.GitFileStatus{{Path: "main.go", Code: " M"}}
+	svc := NewService(osfs.New(), git, ziparchiver.New(), mocks.NewMockResticClient())
+	cfg := &config.Config{SourceDir: filepath.Dir(projectDir), BackupDir: filepath.Join(tempDir, "backups")}
+
+	ctx := WithRunInfo(context.Background(), RunInfo{Version: "1.2.3", Trigger: TriggerSchedule})
+	if result := svc.BackupProject(ctx, cfg, "traced"); result.Error != nil {
+		t.Fatalf("BackupProject failed: %v", result.Error)
+	}
+
+	m, err := manifest.Load(cfg.BackupDir, "traced")
+	if err != nil {
+		t.Fatalf("manifest.Load failed: %v", err)
+	}
+	p := m.Backups[0].Provenance
+	if p == nil {
+		t.Fatal("backup entry has no provenance")
+	}
+	hostname, _ := os.Hostname()
+	if p.Hostname != hostname || p.User == "" {
+		t.Errorf("host/user = %q/%q, expected %q and the current user", p.Hostname, p.User, hostname)
+	}
+	if p.CodebakVersion != "1.2.3" || p.Trigger != "schedule" {
+		t.Errorf("version/trigger = %q/%q, expected 1.2.3/schedule", p.CodebakVersion, p.Trigger)
+	}
+	if p.Branch != "feature/login" || !p.Dirty {
+		t.Errorf("branch = %q, dirty = %v, expected feature/login with uncommitted changes", p.Branch, p.Dirty)
+	}
+	if p.Duration <= 0 {
+		t.Errorf("duration = %v, expected the run time", p.Duration)
+	}
+	if p.ConfigHash != configHash(cfg, svc.Effective(cfg, "traced")) {
+		t.Errorf("config hash = %q, expected the hash of the effective config", p.ConfigHash)
+	}
+}
+
+func TestRunInfoDefaultsToManual(t *testing.T) {
+	if info := runInfo(context.Background()); info.Trigger != TriggerManual {
+		t.Errorf("trigger = %q, expected manual without run info", info.Trigger)
+	}
+}
+
+func TestConfigHash(t *testing.T) {
+	cfg := &config.Config{Exclude: []string{"node_modules"}}
+	base := configHash(cfg, cfg.Effective("proj", nil))
+	if base != configHash(cfg, cfg.Effective("proj", nil)) {
+		t.Error("config hash should be stable")
+	}
+
+	// Settings that change what is stored change the hash
+	cfg.Storage = config.StorageChunked
+	if configHash(cfg, cfg.Effective("proj", nil)) == base {
+		t.Error("config hash should change with the storage format")
+	}
+
+	// Settings that do not, do not
+	cfg.Storage = ""
+	cfg.Schedule = "hourly"
+	if configHash(cfg, cfg.Effective("proj", nil)) != base {
+		t.Error("config hash should not change with the schedule")
+	}
+}
@@ -0,0 +1,147 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jmcdonald/codebak/internal/adapters/chunkstore"
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/manifest"
+)
+
+// ErrNoRetention is returned by Prune when no retention rule is configured.
+var ErrNoRetention = errors.New("no retention rules configured")
+
+// PruneVersion is the retention decision for one backup version.
+type PruneVersion struct {
+	Entry   manifest.BackupEntry
+	Keep    bool
+	Reasons []string // Rules that keep the version, e.g. "last", "daily"
+}
+
+// PruneResult describes a retention run over one project.
+type PruneResult struct {
+	Project   string
+	Retention config.RetentionConfig // Effective rules for the project
+	Versions  []PruneVersion         // Newest first
+	Removed   []string               // Backup files deleted; empty for a dry run
+	Error     error
+}
+
+// Kept returns how many versions the policy keeps.
+func (r PruneResult) Kept() int {
+	n := 0
+	for _, v := range r.Versions {
+		if v.Keep {
+			n++
+		}
+	}
+	return n
+}
+
+// Prune applies the configured retention policy to project, or to every
+// project in the backup directory when project is "". Each project uses its
+// effective rules, so source and project overrides apply. With dryRun nothing
+// is removed and the result only reports which versions each rule keeps.
+func (s *Service) Prune(cfg *config.Config, project string, dryRun bool) ([]PruneResult, error) {
+	if !hasRetention(cfg) {
+		return nil, ErrNoRetention
+	}
+	backupDir, err := config.ExpandPath(cfg.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+
+	projects := []string{project}
+	if project == "" {
+		projects, err = manifest.Projects(backupDir)
+		if err != nil {
+			return nil, err
+		}
+	} else if _, err := s.fs.Stat(manifest.ManifestPath(backupDir, project)); err != nil {
+		return nil, fmt.Errorf("no backups found for %s", project)
+	}
+
+	var results []PruneResult
+	for _, p := range projects {
+		results = append(results, s.pruneProject(cfg, backupDir, p, dryRun))
+	}
+	return results, nil
+}
+
+// pruneProject plans retention for one project and, unless dryRun, applies it.
+func (s *Service) pruneProject(cfg *config.Config, backupDir, project string, dryRun bool) PruneResult {
+	result := PruneResult{Project: project, Retention: s.Effective(cfg, project).Retention}
+
+	if !dryRun {
+		unlock, err := s.lockProject(cfg, backupDir, project)
+		if err != nil {
+			result.Error = err
+			return result
+		}
+		defer unlock()
+	}
+
+	m, err := manifest.Load(backupDir, project)
+	if err != nil {
+		result.Error = fmt.Errorf("loading manifest: %w", err)
+		return result
+	}
+
+	policy := result.Retention.Policy()
+	decisions := m.PlanRetention(policy)
+	for i := len(m.Backups) - 1; i >= 0; i-- {
+		result.Versions = append(result.Versions, PruneVersion{
+			Entry:   m.Backups[i],
+			Keep:    decisions[i].Keep,
+			Reasons: decisions[i].Reasons,
+		})
+	}
+	if dryRun || result.Kept() == len(result.Versions) {
+		return result
+	}
+
+	removed, pruneErr := m.ApplyRetention(backupDir, policy, collectChunks)
+	result.Removed = removed
+	if err := m.Save(backupDir); err != nil {
+		result.Error = fmt.Errorf("saving manifest: %w", err)
+		return result
+	}
+	if pruneErr != nil {
+		result.Error = pruneErr
+	}
+	return result
+}
+
+// collectChunks removes the chunks of projectDir that no remaining version
+// references.
+func collectChunks(projectDir string) error {
+	_, err := chunkstore.CollectGarbage(projectDir)
+	return err
+}
+
+// hasRetention reports whether any retention rule is configured, globally or
+// in a source or project override.
+func hasRetention(cfg *config.Config) bool {
+	if !cfg.Retention.Policy().Empty() {
+		return true
+	}
+	for _, source := range cfg.GetSources() {
+		if source.Retention != nil && !source.Retention.Policy().Empty() {
+			return true
+		}
+	}
+	for _, o := range cfg.Overrides {
+		if o.Retention != nil && !o.Retention.Policy().Empty() {
+			return true
+		}
+	}
+	return false
+}
+
+// Prune applies the configured retention policy to project, or to every
+// project when project is "".
+// Uses the default production dependencies.
+func Prune(cfg *config.Config, project string, dryRun bool) ([]PruneResult, error) {
+	return defaultService.Prune(cfg, project, dryRun)
+}
@@ -0,0 +1,193 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmcdonald/codebak/internal/adapters/osfs"
+	"github.com/jmcdonald/codebak/internal/adapters/ziparchiver"
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/manifest"
+	"github.com/jmcdonald/codebak/internal/mocks"
+)
+
+// writeVersions creates a project's manifest with one dummy backup per day,
+// oldest first, and returns the backup file names.
+func writeVersions(t *testing.T, backupDir, project string, days int) []string {
+	t.Helper()
+	projectDir := filepath.Join(backupDir, project)
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create backup dir: %v", err)
+	}
+
+	m := &manifest.Manifest{Project: project}
+	var files []string
+	for day := 1; day <= days; day++ {
+		created := time.Date(2026, time.January, day, 12, 0, 0, 0, time.Local)
+		file := created.Format("20060102-150405") + ".zip"
+		if err := os.WriteFile(filepath.Join(projectDir, file), []byte("dummy"), 0644); err != nil {
+			t.Fatalf("Failed to create backup file: %v", err)
+		}
+		m.Backups = append(m.Backups, manifest.BackupEntry{File: file, CreatedAt: created})
+		files = append(files, file)
+	}
+	if err := m.Save(backupDir); err != nil {
+		t.Fatalf("Failed to save manifest: %v", err)
+	}
+	return files
+}
+
+func newPruneService() *Service {
+	return NewService(osfs.New(), mocks.NewMockGitClient(), ziparchiver.New(), mocks.NewMockResticClient())
+}
+
+func TestPruneDryRun(t *testing.T) {
+	backupDir := t.TempDir()
+	files := writeVersions(t, backupDir, "proj", 4)
+	cfg := &config.Config{BackupDir: backupDir, Retention: config.RetentionConfig{KeepLast: 1, KeepWeekly: 2}}
+
+	results, err := newPruneService().Prune(cfg, "proj", true)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Error != nil {
+		t.Fatalf("results = %+v, expected one successful result", results)
+	}
+	r := results[0]
+	if len(r.Versions) != 4 || r.Versions[0].Entry.File != files[3] {
+		t.Fatalf("versions = %+v, expected all four newest first", r.Versions)
+	}
+	// Jan 1-4 2026 all fall in ISO week 1, so the weekly rule also keeps only the newest
+	if r.Kept() != 1 || len(r.Versions[0].Reasons) != 2 {
+		t.Errorf("kept %d, reasons %v, expected only the newest kept by last and weekly", r.Kept(), r.Versions[0].Reasons)
+	}
+	if len(r.Removed) != 0 {
+		t.Errorf("Removed = %v, expected nothing removed on a dry run", r.Removed)
+	}
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(backupDir, "proj", f)); err != nil {
+			t.Errorf("dry run removed %s", f)
+		}
+	}
+}
+
+func TestPruneAllProjects(t *testing.T) {
+	backupDir := t.TempDir()
+	writeVersions(t, backupDir, "b-proj", 3)
+	aFiles := writeVersions(t, backupDir, "a-proj", 5)
+	if err := os.MkdirAll(filepath.Join(backupDir, "not-a-project"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	cfg := &config.Config{BackupDir: backupDir, Retention: config.RetentionConfig{KeepLast: 2}}
+
+	results, err := newPruneService().Prune(cfg, "", false)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Project != "a-proj" || results[1].Project != "b-proj" {
+		t.Fatalf("results = %+v, expected a-proj and b-proj", results)
+	}
+	if len(results[0].Removed) != 3 || len(results[1].Removed) != 1 {
+		t.Errorf("removed %v and %v, expected 3 and 1", results[0].Removed, results[1].Removed)
+	}
+
+	m, err := manifest.Load(backupDir, "a-proj")
+	if err != nil {
+		t.Fatalf("manifest.Load failed: %v", err)
+	}
+	if len(m.Backups) != 2 || m.Backups[0].File != aFiles[3] {
+		t.Errorf("manifest backups = %+v, expected the two newest", m.Backups)
+	}
+	if _, err := os.Stat(filepath.Join(backupDir, "a-proj", aFiles[0])); !os.IsNotExist(err) {
+		t.Error("pruned backup file should be removed")
+	}
+}
+
+func TestPruneWithoutRules(t *testing.T) {
+	cfg := &config.Config{BackupDir: t.TempDir()}
+	if _, err := newPruneService().Prune(cfg, "", true); !errors.Is(err, ErrNoRetention) {
+		t.Errorf("err = %v, expected ErrNoRetention", err)
+	}
+}
+
+func TestPruneUnknownProject(t *testing.T) {
+	cfg := &config.Config{BackupDir: t.TempDir(), Retention: config.RetentionConfig{KeepLast: 1}}
+	if _, err := newPruneService().Prune(cfg, "missing", true); err == nil {
+		t.Error("Prune should fail for a project without backups")
+	}
+}
+
+func TestPruneUsesOverrides(t *testing.T) {
+	backupDir := t.TempDir()
+	writeVersions(t, backupDir, "big", 4)
+	writeVersions(t, backupDir, "small", 4)
+	cfg := &config.Config{
+		BackupDir: backupDir,
+		Overrides: map[string]config.Override{
+			"big": {Retention: &config.RetentionConfig{KeepLast: 1}},
+		},
+	}
+
+	results, err := newPruneService().Prune(cfg, "", false)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Project != "big" {
+		t.Fatalf("results = %+v, expected big and small", results)
+	}
+	if results[0].Retention.KeepLast != 1 || len(results[0].Removed) != 3 {
+		t.Errorf("big: retention %+v removed %v, expected keep_last 1 to remove 3", results[0].Retention, results[0].Removed)
+	}
+	// No global rules, so projects without an override keep everything
+	if len(results[1].Removed) != 0 || results[1].Kept() != 4 {
+		t.Errorf("small: removed %v kept %d, expected everything kept", results[1].Removed, results[1].Kept())
+	}
+}
+
+func TestPruneCollectsChunks(t *testing.T) {
+	backupDir := t.TempDir()
+	projectDir := filepath.Join(backupDir, "proj")
+	chunkDir := filepath.Join(projectDir, "chunks", "ab")
+	if err := os.MkdirAll(chunkDir, 0755); err != nil {
+		t.Fatalf("Failed to create chunk dir: %v", err)
+	}
+
+	// The older version references an orphan-to-be chunk, both reference a shared one
+	shared := "ab" + strings.Repeat("1", 62)
+	onlyOld := "ab" + strings.Repeat("2", 62)
+	for _, hash := range []string{shared, onlyOld} {
+		if err := os.WriteFile(filepath.Join(chunkDir, hash), []byte("chunk"), 0644); err != nil {
+			t.Fatalf("Failed to write chunk: %v", err)
+		}
+	}
+	m := &manifest.Manifest{Project: "proj"}
+	for day, chunks := range [][]string{{shared, onlyOld}, {shared}} {
+		created := time.Date(2026, time.January, day+1, 12, 0, 0, 0, time.Local)
+		file := created.Format("20060102-150405") + ".idx"
+		data := fmt.Sprintf(`{"version":1,"root":"proj","files":[{"path":"a","chunks":["%s"]}]}`, strings.Join(chunks, `","`))
+		if err := os.WriteFile(filepath.Join(projectDir, file), []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write index: %v", err)
+		}
+		m.Backups = append(m.Backups, manifest.BackupEntry{File: file, CreatedAt: created, Format: manifest.FormatChunked})
+	}
+	if err := m.Save(backupDir); err != nil {
+		t.Fatalf("Failed to save manifest: %v", err)
+	}
+	cfg := &config.Config{BackupDir: backupDir, Retention: config.RetentionConfig{KeepLast: 1}}
+
+	results, err := newPruneService().Prune(cfg, "proj", false)
+	if err != nil || len(results) != 1 || results[0].Error != nil {
+		t.Fatalf("Prune = %+v, %v; expected one successful result", results, err)
+	}
+	if _, err := os.Stat(filepath.Join(chunkDir, onlyOld)); !os.IsNotExist(err) {
+		t.Error("Chunk referenced only by pruned version should be removed")
+	}
+	if _, err := os.Stat(filepath.Join(chunkDir, shared)); err != nil {
+		t.Error("Chunk referenced by remaining version should be kept")
+	}
+}
@@ -2,17 +2,26 @@
 package cli
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/jmcdonald/codebak/internal/adapters/filelock"
 	"github.com/jmcdonald/codebak/internal/backup"
 	"github.com/jmcdonald/codebak/internal/config"
 	"github.com/jmcdonald/codebak/internal/launchd"
 	"github.com/jmcdonald/codebak/internal/manifest"
+	"github.com/jmcdonald/codebak/internal/ports"
 	"github.com/jmcdonald/codebak/internal/recovery"
 )
 
@@ -26,15 +35,28 @@ type ConfigService interface {
 
 // BackupService provides backup operations for the CLI.
 type BackupService interface {
-	BackupProject(cfg *config.Config, project string) backup.BackupResult
-	RunBackup(cfg *config.Config) ([]backup.BackupResult, error)
+	BackupProject(ctx context.Context, cfg *config.Config, project string, onProgress backup.ProgressFunc) backup.BackupResult
+	RunBackup(ctx context.Context, cfg *config.Config, onResult func(backup.BackupResult), onProgress backup.ProgressFunc) ([]backup.BackupResult, error)
+	Prune(cfg *config.Config, project string, dryRun bool) ([]backup.PruneResult, error)
+	Effective(cfg *config.Config, project string) config.Effective
+	Annotate(cfg *config.Config, project, version string, a manifest.Annotation) (manifest.BackupEntry, error)
+	GC(cfg *config.Config, project string, fix bool) ([]backup.GCResult, error)
+	Migrate(cfg *config.Config) ([]backup.MigrateResult, error)
 }
 
 // RecoveryService provides recovery operations for the CLI.
 type RecoveryService interface {
 	Verify(cfg *config.Config, project, version string) error
-	Recover(cfg *config.Config, opts recovery.RecoverOptions) error
+	VerifyDeep(ctx context.Context, cfg *config.Config, project, version string) (recovery.DeepResult, error)
+	VerifyAll(ctx context.Context, cfg *config.Config, opts recovery.VerifyAllOptions) ([]recovery.VersionCheck, error)
+	RestoreTest(ctx context.Context, cfg *config.Config, project, version string) (recovery.RestoreTestResult, error)
+	RestoreTests(cfg *config.Config) ([]recovery.RestoreTestStatus, error)
+	Recover(ctx context.Context, cfg *config.Config, opts recovery.RecoverOptions) error
+	Preview(cfg *config.Config, opts recovery.RecoverOptions) (recovery.RecoverPreview, error)
+	RecoverSensitive(ctx context.Context, cfg *config.Config, opts recovery.SensitiveOptions) (recovery.SensitiveResult, error)
+	Restore(ctx context.Context, cfg *config.Config, opts recovery.RestoreOptions) (recovery.RestoreResult, error)
 	ListVersions(cfg *config.Config, project string) ([]manifest.BackupEntry, error)
+	FileHistory(cfg *config.Config, project, path string) ([]recovery.FileVersion, error)
 }
 
 // LaunchdService provides launchd operations for the CLI.
@@ -54,6 +76,10 @@ type CLI struct {
 	Version string    // Application version
 	Args    []string  // Command arguments (like os.Args)
 
+	// ShowProgress draws a live progress line during backups.
+	// New enables it when stdout is a terminal.
+	ShowProgress bool
+
 	// Exit function for testability (defaults to os.Exit)
 	Exit func(code int)
 
@@ -62,6 +88,7 @@ type CLI struct {
 	BackupSvc   BackupService
 	RecoverySvc RecoveryService
 	LaunchdSvc  LaunchdService
+	Locker      ports.Locker
 
 	// Color functions (can be disabled for testing)
 	green  func(a ...interface{}) string
@@ -84,6 +111,8 @@ func New(version string) *CLI {
 		cyan:    color.New(color.FgCyan).SprintFunc(),
 		gray:    color.New(color.FgHiBlack).SprintFunc(),
 		red:     color.New(color.FgRed).SprintFunc(),
+
+		ShowProgress: isTerminal(os.Stdout),
 	}
 }
 
@@ -116,11 +145,26 @@ func (d *defaultConfigService) DefaultConfig() (*config.Config, error) { return
 // defaultBackupService wraps the backup package functions.
 type defaultBackupService struct{}
 
-func (d *defaultBackupService) BackupProject(cfg *config.Config, project string) backup.BackupResult {
-	return backup.BackupProject(cfg, project)
+func (d *defaultBackupService) BackupProject(ctx context.Context, cfg *config.Config, project string, onProgress backup.ProgressFunc) backup.BackupResult {
+	return backup.BackupProjectProgress(ctx, cfg, project, onProgress)
+}
+func (d *defaultBackupService) RunBackup(ctx context.Context, cfg *config.Config, onResult func(backup.BackupResult), onProgress backup.ProgressFunc) ([]backup.BackupResult, error) {
+	return backup.RunBackupStream(ctx, cfg, onResult, onProgress)
+}
+func (d *defaultBackupService) Prune(cfg *config.Config, project string, dryRun bool) ([]backup.PruneResult, error) {
+	return backup.Prune(cfg, project, dryRun)
 }
-func (d *defaultBackupService) RunBackup(cfg *config.Config) ([]backup.BackupResult, error) {
-	return backup.RunBackup(cfg)
+func (d *defaultBackupService) Effective(cfg *config.Config, project string) config.Effective {
+	return backup.Effective(cfg, project)
+}
+func (d *defaultBackupService) GC(cfg *config.Config, project string, fix bool) ([]backup.GCResult, error) {
+	return backup.GC(cfg, project, fix)
+}
+func (d *defaultBackupService) Migrate(cfg *config.Config) ([]backup.MigrateResult, error) {
+	return backup.Migrate(cfg)
+}
+func (d *defaultBackupService) Annotate(cfg *config.Config, project, version string, a manifest.Annotation) (manifest.BackupEntry, error) {
+	return backup.Annotate(cfg, project, version, a)
 }
 
 // defaultRecoveryService wraps the recovery package functions.
@@ -129,12 +173,36 @@ type defaultRecoveryService struct{}
 func (d *defaultRecoveryService) Verify(cfg *config.Config, project, version string) error {
 	return recovery.Verify(cfg, project, version)
 }
-func (d *defaultRecoveryService) Recover(cfg *config.Config, opts recovery.RecoverOptions) error {
-	return recovery.Recover(cfg, opts)
+func (d *defaultRecoveryService) VerifyDeep(ctx context.Context, cfg *config.Config, project, version string) (recovery.DeepResult, error) {
+	return recovery.VerifyDeep(ctx, cfg, project, version)
+}
+func (d *defaultRecoveryService) VerifyAll(ctx context.Context, cfg *config.Config, opts recovery.VerifyAllOptions) ([]recovery.VersionCheck, error) {
+	return recovery.VerifyAll(ctx, cfg, opts)
+}
+func (d *defaultRecoveryService) RestoreTest(ctx context.Context, cfg *config.Config, project, version string) (recovery.RestoreTestResult, error) {
+	return recovery.RestoreTest(ctx, cfg, project, version)
+}
+func (d *defaultRecoveryService) RestoreTests(cfg *config.Config) ([]recovery.RestoreTestStatus, error) {
+	return recovery.RestoreTests(cfg)
+}
+func (d *defaultRecoveryService) Recover(ctx context.Context, cfg *config.Config, opts recovery.RecoverOptions) error {
+	return recovery.Recover(ctx, cfg, opts)
+}
+func (d *defaultRecoveryService) Preview(cfg *config.Config, opts recovery.RecoverOptions) (recovery.RecoverPreview, error) {
+	return recovery.Preview(cfg, opts)
+}
+func (d *defaultRecoveryService) RecoverSensitive(ctx context.Context, cfg *config.Config, opts recovery.SensitiveOptions) (recovery.SensitiveResult, error) {
+	return recovery.RecoverSensitive(ctx, cfg, opts)
+}
+func (d *defaultRecoveryService) Restore(ctx context.Context, cfg *config.Config, opts recovery.RestoreOptions) (recovery.RestoreResult, error) {
+	return recovery.Restore(ctx, cfg, opts)
 }
 func (d *defaultRecoveryService) ListVersions(cfg *config.Config, project string) ([]manifest.BackupEntry, error) {
 	return recovery.ListVersions(cfg, project)
 }
+func (d *defaultRecoveryService) FileHistory(cfg *config.Config, project, path string) ([]recovery.FileVersion, error) {
+	return recovery.FileHistory(cfg, project, path)
+}
 
 // defaultLaunchdService wraps the launchd package functions.
 type defaultLaunchdService struct{}
@@ -175,6 +243,101 @@ func (c *CLI) launchdSvc() LaunchdService {
 	return &defaultLaunchdService{}
 }
 
+func (c *CLI) locker() ports.Locker {
+	if c.Locker != nil {
+		return c.Locker
+	}
+	return filelock.New()
+}
+
+// splitWaitFlag separates --wait[=DURATION] from the other arguments.
+// A bare --wait waits indefinitely for another codebak process to finish.
+// Returns nil wait when the flag is absent.
+func splitWaitFlag(args []string) ([]string, *time.Duration, error) {
+	var rest []string
+	var wait *time.Duration
+	for _, arg := range args {
+		switch {
+		case arg == "--wait":
+			d := ports.WaitForever
+			wait = &d
+		case strings.HasPrefix(arg, "--wait="):
+			d, err := time.ParseDuration(strings.TrimPrefix(arg, "--wait="))
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid --wait duration: %w", err)
+			}
+			wait = &d
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest, wait, nil
+}
+
+// splitJobsFlag separates --jobs N (or --jobs=N) from the other arguments.
+// Returns 0 when the flag is absent.
+func splitJobsFlag(args []string) ([]string, int, error) {
+	var rest []string
+	jobs := 0
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		var value string
+		switch {
+		case arg == "--jobs":
+			if i+1 >= len(args) {
+				return nil, 0, fmt.Errorf("--jobs requires a number")
+			}
+			i++
+			value = args[i]
+		case strings.HasPrefix(arg, "--jobs="):
+			value = strings.TrimPrefix(arg, "--jobs=")
+		default:
+			rest = append(rest, arg)
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return nil, 0, fmt.Errorf("invalid --jobs value %q: must be a positive number", value)
+		}
+		jobs = n
+	}
+	return rest, jobs, nil
+}
+
+// applyWait overrides the configured lock wait with the --wait flag, if given.
+func applyWait(cfg *config.Config, wait *time.Duration) {
+	if wait != nil {
+		cfg.LockWait = *wait
+	}
+}
+
+// printLockHint suggests --wait when err is caused by another codebak process.
+func (c *CLI) printLockHint(err error) {
+	if errors.Is(err, ports.ErrLocked) {
+		fmt.Fprintln(c.Err, "Use --wait to wait for it to finish.")
+	}
+}
+
+// exitInterrupted is the conventional exit status after Ctrl+C.
+const exitInterrupted = 130
+
+// interruptContext returns a context that is cancelled on Ctrl+C or SIGTERM,
+// so long-running commands can stop and clean up after themselves.
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// reportInterrupted reports a command stopped by interruptContext and exits
+// with exitInterrupted. It returns false, doing nothing, for other errors.
+func (c *CLI) reportInterrupted(err error, msg string) bool {
+	if !errors.Is(err, context.Canceled) {
+		return false
+	}
+	fmt.Fprintf(c.Err, "Interrupted: %s\n", msg)
+	c.Exit(exitInterrupted)
+	return true
+}
+
 // Run executes the CLI with the configured arguments.
 func (c *CLI) Run() {
 	if len(c.Args) < 2 {
@@ -198,10 +361,24 @@ func (c *CLI) Run() {
 		c.RunVerify()
 	case "recover":
 		c.RunRecover()
+	case "restore":
+		c.RunRestore()
 	case "list":
 		c.ListBackups()
+	case "find":
+		c.FindFile()
+	case "prune":
+		c.RunPrune()
+	case "pin", "unpin", "tag", "note":
+		c.RunAnnotate()
+	case "gc", "fsck":
+		c.RunGC()
+	case "migrate":
+		c.RunMigrate()
 	case "move":
 		c.MoveBackups()
+	case "config":
+		c.RunConfig()
 	case "version", "-v", "--version":
 		fmt.Fprintf(c.Out, "codebak v%s\n", c.Version)
 	case "help", "-h", "--help":
@@ -220,19 +397,49 @@ func (c *CLI) PrintUsage() {
 Usage:
   codebak                                  Launch interactive TUI
   codebak ui                               Launch interactive TUI
-  codebak run [project]                    Backup all changed projects (or specific project)
-  codebak list <project>                   List all backup versions for a project
-  codebak verify <project> [version]       Verify backup integrity
-  codebak recover <project> [--wipe|--archive] [--version=YYYYMMDD-HHMMSS]
-                                           Recover project from backup
+  codebak run [project] [--jobs N] [--wait]
+                                           Backup all changed projects (or specific project)
+  codebak list <project> [--long]          List all backup versions for a project
+  codebak find <project> <path>            List the versions that contain a file
+  codebak prune [project] [--dry-run] [--wait]
+                                           Remove versions the retention rules do not keep
+  codebak pin|unpin <project> <version>    Protect a version from retention, or stop protecting it
+  codebak tag <project> <version> <label>... [--remove]
+                                           Add (or remove) labels on a version
+  codebak note <project> <version> [text]  Set a version's note; no text clears it
+  codebak gc|fsck [project] [--fix] [--wait]
+                                           Check manifests against the files on disk
+  codebak migrate [--wait]                 Upgrade manifests written by older codebak releases
+  codebak verify <project> [version] [--deep | --restore-test] [--wait]
+                                           Verify backup integrity; --deep reads back every file,
+                                           --restore-test extracts it into a scratch directory
+  codebak verify --all [--versions=latest|all] [--deep] [--jobs N] [--json] [--wait]
+                                           Verify every project; exits non-zero on any failure
+  codebak recover <project> [--wipe|--archive] [--version=YYYYMMDD-HHMMSS] [--to=DIR [--force]] [--dry-run] [--wait]
+                                           Recover project from backup; --to restores into another
+                                           directory, --force allows a non-empty one, --dry-run lists
+                                           the files that would be added, overwritten or removed
+  codebak recover --sensitive <label> [--snapshot=ID|latest] [--include=PATH]... [--wipe|--archive] [--to=DIR [--force]] [--dry-run] [--wait]
+                                           Recover a sensitive source from restic; --include
+                                           restores only the given files or directories, --dry-run
+                                           still restores the snapshot into a scratch directory
+                                           next to the destination to compare it
+  codebak restore <project> <path> [--version=YYYYMMDD-HHMMSS] [--to=DIR] [--force] [--dry-run] [--wait]
+                                           Restore one file or directory; --force overwrites local changes
   codebak install                          Install daily launchd schedule (3am)
   codebak uninstall                        Remove launchd schedule
-  codebak status                           Show launchd status
+  codebak status                           Show launchd status and the last restore tests
   codebak move <path>                      Move all backups to new location
   codebak init                             Create default config file
+  codebak config show <project>            Show the effective settings for a project
   codebak --version, -v                    Show version
   codebak --help, -h                       Show this help
 
+  --wait[=DURATION] waits for another running codebak (e.g. the scheduled run)
+  instead of failing; without a duration it waits indefinitely.
+  --jobs N backs up or verifies N projects in parallel (overrides "jobs" in the config).
+  <version> is a YYYYMMDD-HHMMSS version from "codebak list", or "latest".
+
 Config: ~/.codebak/config.yaml`)
 }
 
@@ -261,6 +468,29 @@ func (c *CLI) InitConfig() {
 
 // RunBackup runs the backup command.
 func (c *CLI) RunBackup() {
+	args, wait, err := splitWaitFlag(c.Args[2:])
+	var jobs int
+	if err == nil {
+		args, jobs, err = splitJobsFlag(args)
+	}
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error: %v\n", err)
+		c.Exit(1)
+		return
+	}
+
+	// The launchd schedule passes --scheduled so its versions record the trigger
+	trigger := backup.TriggerManual
+	var rest []string
+	for _, arg := range args {
+		if arg == "--scheduled" {
+			trigger = backup.TriggerSchedule
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	args = rest
+
 	cfgSvc := c.configSvc()
 	backupSvc := c.backupSvc()
 
@@ -270,6 +500,10 @@ func (c *CLI) RunBackup() {
 		c.Exit(1)
 		return
 	}
+	applyWait(cfg, wait)
+	if jobs > 0 {
+		cfg.Jobs = jobs
+	}
 
 	sources := cfg.GetSources()
 	if len(sources) == 1 {
@@ -278,40 +512,64 @@ func (c *CLI) RunBackup() {
 		fmt.Fprintf(c.Out, "%s Scanning %d source directories...\n", c.cyan("=>"), len(sources))
 	}
 
+	ctx, stop := interruptContext()
+	defer stop()
+	ctx = backup.WithRunInfo(ctx, backup.RunInfo{Version: c.Version, Trigger: trigger})
+
+	var onProgress backup.ProgressFunc
+	progress := &progressLine{out: c.Out}
+	if c.ShowProgress {
+		onProgress = progress.update
+	}
+
 	var results []backup.BackupResult
-	if len(c.Args) > 2 {
-		project := c.Args[2]
-		result := backupSvc.BackupProject(cfg, project)
+	if len(args) > 0 {
+		project := args[0]
+		result := backupSvc.BackupProject(ctx, cfg, project, onProgress)
+		progress.clear()
+		fmt.Fprintln(c.Out)
+		c.printBackupResult(result)
+		if c.reportInterrupted(result.Error, "backup stopped; partial files were removed") {
+			return
+		}
 		results = []backup.BackupResult{result}
 	} else {
-		results, err = backupSvc.RunBackup(cfg)
+		// Print each project as it finishes rather than after the whole run
+		first := true
+		results, err = backupSvc.RunBackup(ctx, cfg, func(r backup.BackupResult) {
+			progress.clear()
+			if first {
+				fmt.Fprintln(c.Out)
+				first = false
+			}
+			c.printBackupResult(r)
+		}, onProgress)
+		progress.clear()
+		if c.reportInterrupted(err, "backup stopped; partial files were removed") {
+			return
+		}
 		if err != nil {
 			fmt.Fprintf(c.Err, "Error: %v\n", err)
+			c.printLockHint(err)
 			c.Exit(1)
 			return
 		}
+		if first {
+			fmt.Fprintln(c.Out)
+		}
 	}
 
 	backedUp := 0
 	skipped := 0
 	errors := 0
-
-	fmt.Fprintln(c.Out)
+	unreadable := 0
 	for _, r := range results {
+		unreadable += len(r.SkippedFiles)
 		if r.Error != nil {
-			fmt.Fprintf(c.Out, "  %s %s: %v\n", c.red("x"), r.Project, r.Error)
 			errors++
 		} else if r.Skipped {
-			fmt.Fprintf(c.Out, "  %s %s %s\n", c.gray("-"), c.gray(r.Project), c.gray("("+r.Reason+")"))
 			skipped++
 		} else {
-			sizeStr := backup.FormatSize(r.Size)
-			fmt.Fprintf(c.Out, "  %s %s %s %s %d files\n",
-				c.green("*"),
-				r.Project,
-				c.yellow(sizeStr),
-				c.gray(r.Reason),
-				r.FileCount)
 			backedUp++
 		}
 	}
@@ -323,9 +581,84 @@ func (c *CLI) RunBackup() {
 	if errors > 0 {
 		fmt.Fprintf(c.Out, ", %s errors", c.red(fmt.Sprintf("%d", errors)))
 	}
+	if unreadable > 0 {
+		fmt.Fprintf(c.Out, ", %s unreadable files", c.yellow(fmt.Sprintf("%d", unreadable)))
+	}
 	fmt.Fprintln(c.Out)
 }
 
+// progressInterval limits how often the progress line is redrawn.
+const progressInterval = 100 * time.Millisecond
+
+// progressLine draws a single self-overwriting progress line on a terminal.
+type progressLine struct {
+	out   io.Writer
+	drawn time.Time
+	shown bool
+}
+
+// update redraws the line for project, at most every progressInterval.
+func (l *progressLine) update(project string, p ports.Progress) {
+	if time.Since(l.drawn) < progressInterval {
+		return
+	}
+	l.drawn = time.Now()
+	l.shown = true
+	fmt.Fprintf(l.out, "\r\033[K  ~ %s %s  %s", project, backup.FormatProgress(p, 20), p.Path)
+}
+
+// clear erases the progress line so regular output starts on a clean line.
+func (l *progressLine) clear() {
+	if !l.shown {
+		return
+	}
+	l.shown = false
+	l.drawn = time.Time{}
+	fmt.Fprint(l.out, "\r\033[K")
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// printBackupResult prints one line of backup output.
+func (c *CLI) printBackupResult(r backup.BackupResult) {
+	if r.Error != nil {
+		fmt.Fprintf(c.Out, "  %s %s: %v\n", c.red("x"), r.Project, r.Error)
+	} else if r.Skipped {
+		fmt.Fprintf(c.Out, "  %s %s %s\n", c.gray("-"), c.gray(r.Project), c.gray("("+r.Reason+")"))
+	} else {
+		sizeStr := backup.FormatSize(r.Size)
+		fmt.Fprintf(c.Out, "  %s %s %s %s %d files\n",
+			c.green("*"),
+			r.Project,
+			c.yellow(sizeStr),
+			c.gray(r.Reason),
+			r.FileCount)
+	}
+	c.printSkippedFiles(r.SkippedFiles)
+}
+
+// maxSkippedShown limits how many unreadable files are listed per project.
+const maxSkippedShown = 10
+
+// printSkippedFiles lists files that could not be read under a result line.
+func (c *CLI) printSkippedFiles(files []manifest.SkippedFile) {
+	if len(files) == 0 {
+		return
+	}
+	fmt.Fprintf(c.Out, "    %s %d file(s) could not be read:\n", c.yellow("!"), len(files))
+	for i, f := range files {
+		if i == maxSkippedShown {
+			fmt.Fprintf(c.Out, "      %s\n", c.gray(fmt.Sprintf("... and %d more", len(files)-maxSkippedShown)))
+			break
+		}
+		fmt.Fprintf(c.Out, "      %s %s\n", f.Path, c.gray("("+f.Reason+")"))
+	}
+}
+
 // InstallLaunchd installs the launchd schedule.
 func (c *CLI) InstallLaunchd() {
 	svc := c.launchdSvc()
@@ -408,12 +741,77 @@ func (c *CLI) ShowStatus() {
 	} else {
 		fmt.Fprintf(c.Out, "  launchd: %s\n", c.gray("not installed"))
 	}
+
+	c.printRestoreTests(cfg)
+}
+
+// printRestoreTests lists when each project last passed a restore test.
+func (c *CLI) printRestoreTests(cfg *config.Config) {
+	statuses, err := c.recoverySvc().RestoreTests(cfg)
+	if err != nil {
+		fmt.Fprintf(c.Out, "  Restore tests: %s\n", c.gray(err.Error()))
+		return
+	}
+	if len(statuses) == 0 {
+		return
+	}
+	fmt.Fprintln(c.Out, "  Restore tests:")
+	for _, st := range statuses {
+		if st.TestedAt.IsZero() {
+			fmt.Fprintf(c.Out, "           %-20s %s\n", st.Project, c.yellow("never"))
+			continue
+		}
+		fmt.Fprintf(c.Out, "           %-20s %s %s\n", st.Project, st.TestedAt.Local().Format("2006-01-02 15:04"), c.gray("("+st.Version+")"))
+	}
 }
 
 // RunVerify verifies a backup.
 func (c *CLI) RunVerify() {
-	if len(c.Args) < 3 {
-		fmt.Fprintln(c.Out, "Usage: codebak verify <project> [version]")
+	const usage = "Usage: codebak verify <project> [version] [--deep | --restore-test] [--wait]\n" +
+		"       codebak verify --all [--versions=latest|all] [--deep] [--jobs N] [--json] [--wait]"
+	args, wait, err := splitWaitFlag(c.Args[2:])
+	var jobs int
+	if err == nil {
+		args, jobs, err = splitJobsFlag(args)
+	}
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error: %v\n", err)
+		c.Exit(1)
+		return
+	}
+	var opts recovery.VerifyAllOptions
+	all, asJSON, scoped, restoreTest := false, false, false, false
+	var rest []string
+	for _, arg := range args {
+		switch {
+		case arg == "--deep":
+			opts.Deep = true
+		case arg == "--restore-test":
+			restoreTest = true
+		case arg == "--all":
+			all = true
+		case arg == "--json":
+			asJSON = true
+		case strings.HasPrefix(arg, "--versions="):
+			opts.Versions = recovery.VersionScope(strings.TrimPrefix(arg, "--versions="))
+			scoped = true
+			if opts.Versions != recovery.VersionsLatest && opts.Versions != recovery.VersionsAll {
+				fmt.Fprintf(c.Err, "Error: invalid --versions value %q: must be latest or all\n", opts.Versions)
+				c.Exit(1)
+				return
+			}
+		case strings.HasPrefix(arg, "-"):
+			fmt.Fprintf(c.Err, "Unknown flag: %s\n", arg)
+			fmt.Fprintln(c.Out, usage)
+			c.Exit(1)
+			return
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	args = rest
+	if all != (len(args) == 0) || (!all && (asJSON || scoped || jobs > 0)) || (restoreTest && (all || opts.Deep)) {
+		fmt.Fprintln(c.Out, usage)
 		c.Exit(1)
 		return
 	}
@@ -428,14 +826,34 @@ func (c *CLI) RunVerify() {
 		return
 	}
 
-	project := c.Args[2]
+	applyWait(cfg, wait)
+
+	if all {
+		if jobs > 0 {
+			cfg.Jobs = jobs
+		}
+		c.verifyAll(cfg, opts, asJSON)
+		return
+	}
+
+	project := args[0]
 	version := ""
-	if len(c.Args) > 3 {
-		version = c.Args[3]
+	if len(args) > 1 {
+		version = args[1]
+	}
+
+	if opts.Deep {
+		c.verifyDeep(cfg, project, version)
+		return
+	}
+	if restoreTest {
+		c.verifyRestoreTest(cfg, project, version)
+		return
 	}
 
 	if err := recoverySvc.Verify(cfg, project, version); err != nil {
 		fmt.Fprintf(c.Err, "Verification failed: %v\n", err)
+		c.printLockHint(err)
 		c.Exit(1)
 		return
 	}
@@ -443,125 +861,197 @@ func (c *CLI) RunVerify() {
 	fmt.Fprintf(c.Out, "%s Checksum verified for %s\n", c.green("*"), project)
 }
 
-// RunRecover recovers a project from backup.
-func (c *CLI) RunRecover() {
-	if len(c.Args) < 3 {
-		fmt.Fprintln(c.Out, "Usage: codebak recover <project> [--wipe|--archive] [--version=YYYYMMDD-HHMMSS]")
-		c.Exit(1)
+// verifyDeep reads back every file of a backup and lists the ones that fail.
+func (c *CLI) verifyDeep(cfg *config.Config, project, version string) {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	result, err := c.recoverySvc().VerifyDeep(ctx, cfg, project, version)
+	if c.reportInterrupted(err, "verification stopped") {
 		return
 	}
-
-	cfgSvc := c.configSvc()
-	recoverySvc := c.recoverySvc()
-
-	cfg, err := cfgSvc.Load()
 	if err != nil {
-		fmt.Fprintf(c.Err, "Error loading config: %v\n", err)
+		fmt.Fprintf(c.Err, "Verification failed: %v\n", err)
+		c.printLockHint(err)
 		c.Exit(1)
 		return
 	}
 
-	opts := recovery.RecoverOptions{
-		Project: c.Args[2],
+	if result.OK() {
+		fmt.Fprintf(c.Out, "%s Verified %d files of %s %s\n", c.green("*"), result.Files, project, result.Version)
+		return
 	}
 
-	// Parse flags
-	for _, arg := range c.Args[3:] {
-		switch {
-		case arg == "--wipe":
-			opts.Wipe = true
-		case arg == "--archive":
-			opts.Archive = true
-		case strings.HasPrefix(arg, "--version="):
-			opts.Version = strings.TrimPrefix(arg, "--version=")
+	fmt.Fprintf(c.Out, "%s %s %s: %d problem(s) in %d files\n", c.red("x"), project, result.Version, len(result.Failures), result.Files)
+	for _, f := range result.Failures {
+		path := f.Path
+		if path == "" {
+			path = "(archive)"
 		}
+		fmt.Fprintf(c.Out, "    %s  %s\n", path, f.Reason)
 	}
+	c.Exit(1)
+}
 
-	if opts.Wipe && opts.Archive {
-		fmt.Fprintln(c.Out, "Cannot use both --wipe and --archive")
+// verifyRestoreTest extracts a backup into a scratch directory and lists
+// the problems the restored tree shows.
+func (c *CLI) verifyRestoreTest(cfg *config.Config, project, version string) {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	result, err := c.recoverySvc().RestoreTest(ctx, cfg, project, version)
+	if c.reportInterrupted(err, "restore test stopped") {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(c.Err, "Restore test failed: %v\n", err)
+		c.printLockHint(err)
 		c.Exit(1)
 		return
 	}
 
-	if opts.Wipe {
-		fmt.Fprintf(c.Out, "%s Recovering %s (wiping current)...\n", c.yellow("!"), opts.Project)
-	} else if opts.Archive {
-		fmt.Fprintf(c.Out, "%s Recovering %s (archiving current)...\n", c.yellow("!"), opts.Project)
-	} else {
-		fmt.Fprintf(c.Out, "Recovering %s...\n", opts.Project)
+	if result.OK() {
+		fmt.Fprintf(c.Out, "%s Restored %d files of %s %s cleanly\n", c.green("*"), result.Files, project, result.Version)
+		return
 	}
 
-	if err := recoverySvc.Recover(cfg, opts); err != nil {
-		fmt.Fprintf(c.Err, "Recovery failed: %v\n", err)
-		c.Exit(1)
-		return
+	fmt.Fprintf(c.Out, "%s %s %s: restore test found %d problem(s)\n", c.red("x"), project, result.Version, len(result.Failures))
+	for _, f := range result.Failures {
+		path := f.Path
+		if path == "" {
+			path = "(archive)"
+		}
+		fmt.Fprintf(c.Out, "    %s  %s\n", path, f.Reason)
 	}
+	c.Exit(1)
+}
 
-	fmt.Fprintf(c.Out, "%s Successfully recovered %s\n", c.green("*"), opts.Project)
+// verifyReport is the JSON output of verify --all.
+type verifyReport struct {
+	OK      bool                `json:"ok"`
+	Checked int                 `json:"checked"`
+	Failed  int                 `json:"failed"`
+	Results []verifyReportEntry `json:"results"`
 }
 
-// ListBackups lists all backups for a project.
-func (c *CLI) ListBackups() {
-	if len(c.Args) < 3 {
-		fmt.Fprintln(c.Out, "Usage: codebak list <project>")
-		c.Exit(1)
-		return
-	}
+// verifyReportEntry is one version in a verifyReport.
+type verifyReportEntry struct {
+	Project  string                  `json:"project"`
+	Version  string                  `json:"version,omitempty"`
+	OK       bool                    `json:"ok"`
+	Files    int                     `json:"files,omitempty"`
+	Error    string                  `json:"error,omitempty"`
+	Failures []recovery.EntryFailure `json:"failures,omitempty"`
+}
 
-	cfgSvc := c.configSvc()
-	recoverySvc := c.recoverySvc()
+// verifyAll verifies every project and prints a summary table, or a JSON
+// report with asJSON. Exits non-zero when any version fails.
+func (c *CLI) verifyAll(cfg *config.Config, opts recovery.VerifyAllOptions, asJSON bool) {
+	ctx, stop := interruptContext()
+	defer stop()
 
-	cfg, err := cfgSvc.Load()
+	checks, err := c.recoverySvc().VerifyAll(ctx, cfg, opts)
+	if c.reportInterrupted(err, "verification stopped") {
+		return
+	}
 	if err != nil {
-		fmt.Fprintf(c.Err, "Error loading config: %v\n", err)
+		fmt.Fprintf(c.Err, "Verification failed: %v\n", err)
 		c.Exit(1)
 		return
 	}
 
-	project := c.Args[2]
+	report := verifyReport{Results: []verifyReportEntry{}}
+	for _, check := range checks {
+		entry := verifyReportEntry{
+			Project:  check.Project,
+			Version:  check.Version,
+			OK:       check.OK(),
+			Files:    check.Files,
+			Failures: check.Failures,
+		}
+		if check.Err != nil {
+			entry.Error = check.Err.Error()
+		}
+		if !entry.OK {
+			report.Failed++
+		}
+		report.Results = append(report.Results, entry)
+	}
+	report.Checked = len(checks)
+	report.OK = report.Failed == 0
 
-	backups, err := recoverySvc.ListVersions(cfg, project)
-	if err != nil {
-		fmt.Fprintf(c.Err, "Error: %v\n", err)
+	if asJSON {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Fprintln(c.Out, string(data))
+	} else {
+		c.printVerifyReport(report)
+	}
+	if !report.OK {
 		c.Exit(1)
-		return
 	}
+}
 
-	if len(backups) == 0 {
-		fmt.Fprintf(c.Out, "No backups found for %s\n", project)
+// printVerifyReport prints the verify --all summary table.
+func (c *CLI) printVerifyReport(report verifyReport) {
+	if report.Checked == 0 {
+		fmt.Fprintf(c.Out, "%s No backups to verify\n", c.gray("-"))
 		return
 	}
 
-	fmt.Fprintf(c.Out, "Backups for %s:\n\n", c.cyan(project))
-	fmt.Fprintf(c.Out, "  %-20s %10s %8s %s\n", "VERSION", "SIZE", "FILES", "GIT HEAD")
-	fmt.Fprintf(c.Out, "  %-20s %10s %8s %s\n", "-------", "----", "-----", "--------")
-
-	for _, b := range backups {
-		gitHead := b.GitHead
-		if len(gitHead) > 7 {
-			gitHead = gitHead[:7]
+	fmt.Fprintf(c.Out, "  %-20s %-18s %s\n", "PROJECT", "VERSION", "RESULT")
+	fmt.Fprintf(c.Out, "  %-20s %-18s %s\n", "-------", "-------", "------")
+	for _, r := range report.Results {
+		version := r.Version
+		if version == "" {
+			version = "-"
 		}
-		if gitHead == "" {
-			gitHead = c.gray("-")
+		switch {
+		case r.OK:
+			fmt.Fprintf(c.Out, "  %-20s %-18s %s\n", r.Project, version, c.green("ok"))
+		case r.Error != "":
+			fmt.Fprintf(c.Out, "  %-20s %-18s %s %s\n", r.Project, version, c.red("FAILED"), r.Error)
+		default:
+			fmt.Fprintf(c.Out, "  %-20s %-18s %s %d problem(s)\n", r.Project, version, c.red("FAILED"), len(r.Failures))
 		}
-		fmt.Fprintf(c.Out, "  %-20s %10s %8d %s\n",
-			strings.TrimSuffix(b.File, ".zip"),
-			backup.FormatSize(b.SizeBytes),
-			b.FileCount,
-			gitHead)
+		for _, f := range r.Failures {
+			path := f.Path
+			if path == "" {
+				path = "(archive)"
+			}
+			fmt.Fprintf(c.Out, "      %s  %s\n", path, f.Reason)
+		}
+	}
+
+	fmt.Fprintln(c.Out)
+	if report.OK {
+		fmt.Fprintf(c.Out, "%s %d version(s) verified\n", c.green("*"), report.Checked)
+	} else {
+		fmt.Fprintf(c.Out, "%s %d of %d version(s) failed verification\n", c.red("x"), report.Failed, report.Checked)
 	}
 }
 
-// MoveBackups moves all backups to a new location and updates the config.
-func (c *CLI) MoveBackups() {
-	if len(c.Args) < 3 {
-		fmt.Fprintln(c.Out, "Usage: codebak move <new-path>")
-		fmt.Fprintln(c.Out, "Example: codebak move /Volumes/External/backups")
+// RunRecover recovers a project from backup.
+func (c *CLI) RunRecover() {
+	args, wait, err := splitWaitFlag(c.Args[2:])
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error: %v\n", err)
+		c.Exit(1)
+		return
+	}
+	for _, arg := range args {
+		if arg == "--sensitive" {
+			c.runRecoverSensitive(args, wait)
+			return
+		}
+	}
+	if len(args) < 1 {
+		fmt.Fprintln(c.Out, "Usage: codebak recover <project> [--wipe|--archive] [--version=YYYYMMDD-HHMMSS] [--to=DIR [--force]] [--dry-run] [--wait]")
 		c.Exit(1)
 		return
 	}
 
 	cfgSvc := c.configSvc()
+	recoverySvc := c.recoverySvc()
 
 	cfg, err := cfgSvc.Load()
 	if err != nil {
@@ -570,30 +1060,935 @@ func (c *CLI) MoveBackups() {
 		return
 	}
 
-	newPath := c.Args[2]
+	applyWait(cfg, wait)
 
-	// Expand ~ if present
-	if len(newPath) > 0 && newPath[0] == '~' {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Fprintf(c.Err, "Error: cannot expand home directory: %v\n", err)
-			c.Exit(1)
-			return
+	opts := recovery.RecoverOptions{
+		Project: args[0],
+	}
+	dryRun := false
+
+	// Parse flags
+	for _, arg := range args[1:] {
+		switch {
+		case arg == "--dry-run":
+			dryRun = true
+		case arg == "--wipe":
+			opts.Wipe = true
+		case arg == "--archive":
+			opts.Archive = true
+		case strings.HasPrefix(arg, "--version="):
+			opts.Version = strings.TrimPrefix(arg, "--version=")
+		case strings.HasPrefix(arg, "--to="):
+			opts.Target = strings.TrimPrefix(arg, "--to=")
+		case arg == "--force":
+			opts.Force = true
 		}
-		newPath = filepath.Join(home, newPath[1:])
 	}
 
-	// Make absolute
-	newPath, err = filepath.Abs(newPath)
-	if err != nil {
-		fmt.Fprintf(c.Err, "Error: invalid path: %v\n", err)
+	if opts.Wipe && opts.Archive {
+		fmt.Fprintln(c.Out, "Cannot use both --wipe and --archive")
+		c.Exit(1)
+		return
+	}
+	if opts.Force && opts.Target == "" {
+		fmt.Fprintln(c.Out, "--force requires --to=DIR")
 		c.Exit(1)
 		return
 	}
 
-	oldPath := cfg.BackupDir
-	if oldPath == newPath {
-		fmt.Fprintln(c.Out, "New path is the same as current path. Nothing to do.")
+	if dryRun {
+		preview, err := recoverySvc.Preview(cfg, opts)
+		if err != nil {
+			fmt.Fprintf(c.Err, "Recovery preview failed: %v\n", err)
+			c.printLockHint(err)
+			c.Exit(1)
+			return
+		}
+		c.printRecoverPreview(preview, opts.Project+" "+preview.Version)
+		return
+	}
+
+	if opts.Target != "" {
+		fmt.Fprintf(c.Out, "Recovering %s to %s...\n", opts.Project, opts.Target)
+	} else if opts.Wipe {
+		fmt.Fprintf(c.Out, "%s Recovering %s (wiping current)...\n", c.yellow("!"), opts.Project)
+	} else if opts.Archive {
+		fmt.Fprintf(c.Out, "%s Recovering %s (archiving current)...\n", c.yellow("!"), opts.Project)
+	} else {
+		fmt.Fprintf(c.Out, "Recovering %s...\n", opts.Project)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if err := recoverySvc.Recover(ctx, cfg, opts); err != nil {
+		if c.reportInterrupted(err, "recovery stopped; any partially restored files were removed") {
+			return
+		}
+		fmt.Fprintf(c.Err, "Recovery failed: %v\n", err)
+		c.printLockHint(err)
+		c.Exit(1)
+		return
+	}
+
+	if opts.Target != "" {
+		fmt.Fprintf(c.Out, "%s Successfully recovered %s to %s\n", c.green("*"), opts.Project, opts.Target)
+		return
+	}
+	fmt.Fprintf(c.Out, "%s Successfully recovered %s\n", c.green("*"), opts.Project)
+}
+
+// printRecoverPreview lists what recovering from (a version or snapshot)
+// would change, flagging local copies newer than the backup.
+func (c *CLI) printRecoverPreview(p recovery.RecoverPreview, from string) {
+	if p.Changes() == 0 {
+		fmt.Fprintf(c.Out, "%s %s already matches %s\n", c.gray("-"), p.Dir, from)
+		return
+	}
+	fmt.Fprintf(c.Out, "Recovering %s would change %d file(s) in %s:\n", from, p.Changes(), p.Dir)
+	newer := pathSet(p.Newer)
+	for _, f := range p.Added {
+		fmt.Fprintf(c.Out, "    %s %s\n", c.green("+"), f)
+	}
+	for _, f := range p.Overwritten {
+		if newer[f] {
+			fmt.Fprintf(c.Out, "    %s %s %s\n", c.yellow("~"), f, c.yellow("(newer locally)"))
+			continue
+		}
+		fmt.Fprintf(c.Out, "    %s %s\n", c.yellow("~"), f)
+	}
+	for _, f := range p.Removed {
+		fmt.Fprintf(c.Out, "    %s %s\n", c.red("-"), f)
+	}
+	if len(p.Unchanged) > 0 {
+		fmt.Fprintf(c.Out, "    %s\n", c.gray(fmt.Sprintf("%d file(s) already match the backup", len(p.Unchanged))))
+	}
+	if len(p.Removed) > 0 {
+		fmt.Fprintln(c.Out, c.gray("  Files marked - are not in the backup: --wipe deletes them and --archive moves them aside with the rest."))
+	}
+	if len(p.Newer) > 0 {
+		fmt.Fprintf(c.Out, "%s %d file(s) were modified locally after the backup and would be rolled back\n", c.yellow("!"), len(p.Newer))
+	}
+}
+
+// runRecoverSensitive restores a sensitive source from a restic snapshot.
+func (c *CLI) runRecoverSensitive(args []string, wait *time.Duration) {
+	const usage = "Usage: codebak recover --sensitive <label> [--snapshot=ID|latest] [--include=PATH]... [--wipe|--archive] [--to=DIR [--force]] [--dry-run] [--wait]"
+	var opts recovery.SensitiveOptions
+	var positional []string
+	for _, arg := range args {
+		switch {
+		case arg == "--sensitive":
+		case arg == "--dry-run":
+			opts.DryRun = true
+		case arg == "--wipe":
+			opts.Wipe = true
+		case arg == "--archive":
+			opts.Archive = true
+		case arg == "--force":
+			opts.Force = true
+		case strings.HasPrefix(arg, "--snapshot="):
+			opts.Snapshot = strings.TrimPrefix(arg, "--snapshot=")
+		case strings.HasPrefix(arg, "--to="):
+			opts.Target = strings.TrimPrefix(arg, "--to=")
+		case strings.HasPrefix(arg, "--include="):
+			opts.Include = append(opts.Include, strings.TrimPrefix(arg, "--include="))
+		case strings.HasPrefix(arg, "-"):
+			fmt.Fprintf(c.Err, "Unknown flag: %s\n", arg)
+			fmt.Fprintln(c.Out, usage)
+			c.Exit(1)
+			return
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(c.Out, usage)
+		c.Exit(1)
+		return
+	}
+	opts.Label = positional[0]
+
+	if opts.Wipe && opts.Archive {
+		fmt.Fprintln(c.Out, "Cannot use both --wipe and --archive")
+		c.Exit(1)
+		return
+	}
+	if opts.Force && opts.Target == "" {
+		fmt.Fprintln(c.Out, "--force requires --to=DIR")
+		c.Exit(1)
+		return
+	}
+
+	cfg, err := c.configSvc().Load()
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error loading config: %v\n", err)
+		c.Exit(1)
+		return
+	}
+
+	applyWait(cfg, wait)
+
+	if opts.DryRun {
+		fmt.Fprintf(c.Out, "Comparing %s with its snapshot...\n", opts.Label)
+	} else if opts.Target != "" {
+		fmt.Fprintf(c.Out, "Recovering %s to %s...\n", opts.Label, opts.Target)
+	} else if opts.Wipe {
+		fmt.Fprintf(c.Out, "%s Recovering %s (wiping current)...\n", c.yellow("!"), opts.Label)
+	} else if opts.Archive {
+		fmt.Fprintf(c.Out, "%s Recovering %s (archiving current)...\n", c.yellow("!"), opts.Label)
+	} else {
+		fmt.Fprintf(c.Out, "Recovering %s...\n", opts.Label)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	result, err := c.recoverySvc().RecoverSensitive(ctx, cfg, opts)
+	if err != nil {
+		if c.reportInterrupted(err, "recovery stopped; the current copy was left in place") {
+			return
+		}
+		fmt.Fprintf(c.Err, "Recovery failed: %v\n", err)
+		c.printLockHint(err)
+		c.Exit(1)
+		return
+	}
+
+	snapshot := fmt.Sprintf("%s (%s)", shortID(result.Snapshot.ID), result.Snapshot.Time.Local().Format("2006-01-02 15:04"))
+	if result.Preview != nil {
+		c.printRecoverPreview(*result.Preview, "snapshot "+snapshot)
+		return
+	}
+	fmt.Fprintf(c.Out, "%s Recovered %s from snapshot %s to %s\n", c.green("*"), opts.Label, snapshot, result.Dir)
+	if len(opts.Include) > 0 {
+		for _, p := range result.Restored {
+			fmt.Fprintf(c.Out, "    %s\n", p)
+		}
+	}
+}
+
+// shortID returns the short form restic shows for a snapshot ID.
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// RunRestore restores one file or directory of a project from backup.
+func (c *CLI) RunRestore() {
+	const usage = "Usage: codebak restore <project> <path> [--version=YYYYMMDD-HHMMSS] [--to=DIR] [--force] [--dry-run] [--wait]"
+	args, wait, err := splitWaitFlag(c.Args[2:])
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error: %v\n", err)
+		c.Exit(1)
+		return
+	}
+	var opts recovery.RestoreOptions
+	var positional []string
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "--version="):
+			opts.Version = strings.TrimPrefix(arg, "--version=")
+		case strings.HasPrefix(arg, "--to="):
+			opts.Target = strings.TrimPrefix(arg, "--to=")
+		case arg == "--force":
+			opts.Overwrite = true
+		case arg == "--dry-run":
+			opts.DryRun = true
+		case strings.HasPrefix(arg, "-"):
+			fmt.Fprintf(c.Err, "Unknown flag: %s\n", arg)
+			fmt.Fprintln(c.Out, usage)
+			c.Exit(1)
+			return
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) != 2 {
+		fmt.Fprintln(c.Out, usage)
+		c.Exit(1)
+		return
+	}
+	opts.Project, opts.Path = positional[0], positional[1]
+
+	cfg, err := c.configSvc().Load()
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error loading config: %v\n", err)
+		c.Exit(1)
+		return
+	}
+
+	applyWait(cfg, wait)
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	result, err := c.recoverySvc().Restore(ctx, cfg, opts)
+	if errors.Is(err, recovery.ErrWouldOverwrite) {
+		fmt.Fprintf(c.Out, "%s %d local file(s) differ from %s %s:\n", c.red("x"), len(result.Overwritten), opts.Project, result.Version)
+		newer := pathSet(result.Newer)
+		for _, p := range result.Overwritten {
+			if newer[p] {
+				fmt.Fprintf(c.Out, "    %s %s\n", p, c.yellow("(newer locally)"))
+				continue
+			}
+			fmt.Fprintf(c.Out, "    %s\n", p)
+		}
+		fmt.Fprintln(c.Out, c.gray("  Re-run with --force to overwrite them, or --to=DIR to restore elsewhere."))
+		c.Exit(1)
+		return
+	}
+	if c.reportInterrupted(err, "restore stopped; files already restored were left in place") {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(c.Err, "Restore failed: %v\n", err)
+		c.printLockHint(err)
+		c.Exit(1)
+		return
+	}
+
+	if len(result.Restored) == 0 {
+		fmt.Fprintf(c.Out, "%s %s already matches %s\n", c.gray("-"), opts.Path, result.Version)
+		return
+	}
+	if opts.DryRun {
+		c.printRestorePlan(opts.Project, result)
+		return
+	}
+	fmt.Fprintf(c.Out, "%s Restored %d file(s) from %s %s to %s\n", c.green("*"), len(result.Restored), opts.Project, result.Version, result.Dir)
+	for _, p := range result.Overwritten {
+		fmt.Fprintf(c.Out, "    %s %s %s\n", c.yellow("!"), p, c.gray("(local changes overwritten)"))
+	}
+	if len(result.Unchanged) > 0 {
+		fmt.Fprintf(c.Out, "    %s\n", c.gray(fmt.Sprintf("%d file(s) already matched the backup", len(result.Unchanged))))
+	}
+}
+
+// printRestorePlan lists what a restore dry run found: files that would be
+// added or overwritten, flagging local copies newer than the backup.
+func (c *CLI) printRestorePlan(project string, r recovery.RestoreResult) {
+	fmt.Fprintf(c.Out, "Restoring %s %s would write %d file(s) in %s:\n", project, r.Version, len(r.Restored), r.Dir)
+	overwritten := pathSet(r.Overwritten)
+	newer := pathSet(r.Newer)
+	for _, p := range r.Restored {
+		switch {
+		case newer[p]:
+			fmt.Fprintf(c.Out, "    %s %s %s\n", c.yellow("~"), p, c.yellow("(newer locally)"))
+		case overwritten[p]:
+			fmt.Fprintf(c.Out, "    %s %s\n", c.yellow("~"), p)
+		default:
+			fmt.Fprintf(c.Out, "    %s %s\n", c.green("+"), p)
+		}
+	}
+	if len(r.Unchanged) > 0 {
+		fmt.Fprintf(c.Out, "    %s\n", c.gray(fmt.Sprintf("%d file(s) already match the backup", len(r.Unchanged))))
+	}
+	if len(r.Overwritten) > 0 {
+		fmt.Fprintln(c.Out, c.gray("  Files marked ~ differ locally; restoring them needs --force."))
+	}
+}
+
+// pathSet returns paths as a set for lookups.
+func pathSet(paths []string) map[string]bool {
+	set := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		set[p] = true
+	}
+	return set
+}
+
+// ListBackups lists all backups for a project.
+func (c *CLI) ListBackups() {
+	var project string
+	long := false
+	for _, arg := range c.Args[2:] {
+		switch {
+		case arg == "--long" || arg == "-l":
+			long = true
+		case strings.HasPrefix(arg, "-"):
+			fmt.Fprintf(c.Err, "Unknown flag: %s\n", arg)
+			fmt.Fprintln(c.Out, "Usage: codebak list <project> [--long]")
+			c.Exit(1)
+			return
+		default:
+			project = arg
+		}
+	}
+	if project == "" {
+		fmt.Fprintln(c.Out, "Usage: codebak list <project> [--long]")
+		c.Exit(1)
+		return
+	}
+
+	cfgSvc := c.configSvc()
+	recoverySvc := c.recoverySvc()
+
+	cfg, err := cfgSvc.Load()
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error loading config: %v\n", err)
+		c.Exit(1)
+		return
+	}
+
+	backups, err := recoverySvc.ListVersions(cfg, project)
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error: %v\n", err)
+		c.Exit(1)
+		return
+	}
+
+	if len(backups) == 0 {
+		fmt.Fprintf(c.Out, "No backups found for %s\n", project)
+		return
+	}
+
+	fmt.Fprintf(c.Out, "Backups for %s:\n\n", c.cyan(project))
+	fmt.Fprintf(c.Out, "  %-20s %10s %8s %s\n", "VERSION", "SIZE", "FILES", "GIT HEAD")
+	fmt.Fprintf(c.Out, "  %-20s %10s %8s %s\n", "-------", "----", "-----", "--------")
+
+	for _, b := range backups {
+		gitHead := b.GitHead
+		if len(gitHead) > 7 {
+			gitHead = gitHead[:7]
+		}
+		if gitHead == "" {
+			gitHead = c.gray("-")
+		}
+		fmt.Fprintf(c.Out, "  %-20s %10s %8d %s%s\n",
+			b.Version(),
+			backup.FormatSize(b.SizeBytes),
+			b.FileCount,
+			gitHead,
+			c.describeMarks(b))
+		if b.Note != "" {
+			fmt.Fprintf(c.Out, "  %s\n", c.gray("  "+b.Note))
+		}
+		if long {
+			fmt.Fprintf(c.Out, "    %s\n", c.gray(describeProvenance(b.Provenance)))
+		}
+	}
+}
+
+// describeProvenance summarises how a version was made for list --long.
+func describeProvenance(p *manifest.Provenance) string {
+	if p == nil {
+		return "no provenance recorded"
+	}
+	var parts []string
+	add := func(key, value string) {
+		if value != "" {
+			parts = append(parts, key+" "+value)
+		}
+	}
+	add("host", p.Hostname)
+	add("user", p.User)
+	add("codebak", p.CodebakVersion)
+	branch := p.Branch
+	if p.Dirty {
+		branch += " (dirty)"
+	}
+	add("branch", strings.TrimSpace(branch))
+	add("trigger", p.Trigger)
+	add("took", p.Duration.Round(time.Millisecond).String())
+	add("config", shortConfigHash(p.ConfigHash))
+	return strings.Join(parts, "  ")
+}
+
+// shortConfigHash returns the first 12 characters of a config hash.
+func shortConfigHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// describeMarks returns the pin and labels of a version for listing, or "".
+func (c *CLI) describeMarks(b manifest.BackupEntry) string {
+	var marks string
+	if b.Pinned {
+		marks += " " + c.yellow("pinned")
+	}
+	if len(b.Labels) > 0 {
+		marks += " " + c.cyan("["+strings.Join(b.Labels, ", ")+"]")
+	}
+	return marks
+}
+
+// annotateUsage holds the usage line of each version annotation command.
+var annotateUsage = map[string]string{
+	"pin":   "Usage: codebak pin <project> <version> [--wait]",
+	"unpin": "Usage: codebak unpin <project> <version> [--wait]",
+	"tag":   "Usage: codebak tag <project> <version> <label>... [--remove] [--wait]",
+	"note":  "Usage: codebak note <project> <version> [text] [--wait]",
+}
+
+// FindFile lists the backup versions of a project that contain a file.
+func (c *CLI) FindFile() {
+	if len(c.Args) < 4 {
+		fmt.Fprintln(c.Out, "Usage: codebak find <project> <path>")
+		c.Exit(1)
+		return
+	}
+
+	cfg, err := c.configSvc().Load()
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error loading config: %v\n", err)
+		c.Exit(1)
+		return
+	}
+
+	project, path := c.Args[2], c.Args[3]
+	versions, err := c.recoverySvc().FileHistory(cfg, project, path)
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error: %v\n", err)
+		c.Exit(1)
+		return
+	}
+	if len(versions) == 0 {
+		fmt.Fprintf(c.Out, "%s is not in any backup of %s\n", path, project)
+		c.Exit(1)
+		return
+	}
+
+	fmt.Fprintf(c.Out, "%s in %s (%d versions):\n\n", c.cyan(path), project, len(versions))
+	fmt.Fprintf(c.Out, "  %-20s %10s %-12s\n", "VERSION", "SIZE", "SHA256")
+	fmt.Fprintf(c.Out, "  %-20s %10s %-12s\n", "-------", "----", "------")
+	for _, v := range versions {
+		sum := c.gray("-")
+		if v.SHA256 != "" {
+			sum = v.SHA256[:12]
+		}
+		line := fmt.Sprintf("  %-20s %10s %s", v.Version, backup.FormatSize(v.Size), sum)
+		if v.Changed {
+			line += " " + c.yellow("changed")
+		}
+		fmt.Fprintln(c.Out, line)
+	}
+}
+
+// RunAnnotate pins, unpins, labels or notes a backup version.
+func (c *CLI) RunAnnotate() {
+	command := c.Args[1]
+	args, wait, err := splitWaitFlag(c.Args[2:])
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error: %v\n", err)
+		c.Exit(1)
+		return
+	}
+	remove := false
+	var positional []string
+	for _, arg := range args {
+		switch {
+		case arg == "--remove" && command == "tag":
+			remove = true
+		case strings.HasPrefix(arg, "-") && command != "note":
+			fmt.Fprintf(c.Err, "Unknown flag: %s\n", arg)
+			fmt.Fprintln(c.Out, annotateUsage[command])
+			c.Exit(1)
+			return
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) < 2 || (command == "tag" && len(positional) < 3) {
+		fmt.Fprintln(c.Out, annotateUsage[command])
+		c.Exit(1)
+		return
+	}
+	project, version, rest := positional[0], positional[1], positional[2:]
+
+	var a manifest.Annotation
+	switch command {
+	case "pin", "unpin":
+		pinned := command == "pin"
+		a.Pinned = &pinned
+	case "tag":
+		if remove {
+			a.RemoveLabels = rest
+		} else {
+			a.AddLabels = rest
+		}
+	case "note":
+		note := strings.Join(rest, " ")
+		a.Note = &note
+	}
+
+	cfg, err := c.configSvc().Load()
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error loading config: %v\n", err)
+		c.Exit(1)
+		return
+	}
+	applyWait(cfg, wait)
+
+	entry, err := c.backupSvc().Annotate(cfg, project, version, a)
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error: %v\n", err)
+		c.printLockHint(err)
+		c.Exit(1)
+		return
+	}
+
+	name := fmt.Sprintf("%s %s", project, entry.Version())
+	switch {
+	case command == "pin":
+		fmt.Fprintf(c.Out, "%s Pinned %s; retention will keep it\n", c.green("*"), name)
+	case command == "unpin":
+		fmt.Fprintf(c.Out, "%s Unpinned %s\n", c.green("*"), name)
+	case command == "tag" && len(entry.Labels) == 0:
+		fmt.Fprintf(c.Out, "%s %s has no labels\n", c.green("*"), name)
+	case command == "tag":
+		fmt.Fprintf(c.Out, "%s Labels on %s: %s\n", c.green("*"), name, strings.Join(entry.Labels, ", "))
+	case entry.Note == "":
+		fmt.Fprintf(c.Out, "%s Cleared the note on %s\n", c.green("*"), name)
+	default:
+		fmt.Fprintf(c.Out, "%s Note on %s: %s\n", c.green("*"), name, entry.Note)
+	}
+}
+
+// RunPrune applies the retention rules, or with --dry-run shows what they keep.
+func (c *CLI) RunPrune() {
+	args, wait, err := splitWaitFlag(c.Args[2:])
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error: %v\n", err)
+		c.Exit(1)
+		return
+	}
+	dryRun := false
+	var project string
+	for _, arg := range args {
+		switch {
+		case arg == "--dry-run":
+			dryRun = true
+		case strings.HasPrefix(arg, "-"):
+			fmt.Fprintf(c.Err, "Unknown flag: %s\n", arg)
+			fmt.Fprintln(c.Out, "Usage: codebak prune [project] [--dry-run] [--wait]")
+			c.Exit(1)
+			return
+		default:
+			project = arg
+		}
+	}
+
+	cfg, err := c.configSvc().Load()
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error loading config: %v\n", err)
+		c.Exit(1)
+		return
+	}
+	applyWait(cfg, wait)
+
+	results, err := c.backupSvc().Prune(cfg, project, dryRun)
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error: %v\n", err)
+		if errors.Is(err, backup.ErrNoRetention) {
+			fmt.Fprintln(c.Err, "Set keep_last, keep_daily, etc. under retention in ~/.codebak/config.yaml.")
+		}
+		c.Exit(1)
+		return
+	}
+
+	fmt.Fprintf(c.Out, "Retention: %s\n", describeRetention(cfg.Retention))
+	if dryRun {
+		fmt.Fprintln(c.Out, c.gray("Dry run: nothing will be removed"))
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(c.Out, "No backups found")
+		return
+	}
+
+	failed := false
+	for _, r := range results {
+		fmt.Fprintln(c.Out)
+		if r.Error != nil {
+			fmt.Fprintf(c.Out, "  %s %s: %v\n", c.red("x"), r.Project, r.Error)
+			c.printLockHint(r.Error)
+			failed = true
+			continue
+		}
+		if r.Retention != cfg.Retention {
+			fmt.Fprintf(c.Out, "  %s\n", c.gray(fmt.Sprintf("%s overrides retention: %s", r.Project, describeRetention(r.Retention))))
+		}
+		if dryRun {
+			c.printPrunePlan(r)
+			continue
+		}
+		if removed := len(r.Removed); removed > 0 {
+			fmt.Fprintf(c.Out, "  %s %s: removed %d, kept %d\n", c.green("*"), r.Project, removed, r.Kept())
+		} else {
+			fmt.Fprintf(c.Out, "  %s %s %s\n", c.gray("-"), c.gray(r.Project), c.gray(fmt.Sprintf("(nothing to remove, %d kept)", r.Kept())))
+		}
+	}
+	if failed {
+		c.Exit(1)
+	}
+}
+
+// printPrunePlan lists each version of a project with the rules that keep it.
+func (c *CLI) printPrunePlan(r backup.PruneResult) {
+	fmt.Fprintf(c.Out, "%s: keep %d of %d versions\n", c.cyan(r.Project), r.Kept(), len(r.Versions))
+	for _, v := range r.Versions {
+		if v.Keep {
+			fmt.Fprintf(c.Out, "  %-18s %s    %s\n", v.Entry.Version(), c.green("keep"), strings.Join(v.Reasons, ", "))
+		} else {
+			fmt.Fprintf(c.Out, "  %-18s %s\n", v.Entry.Version(), c.red("remove"))
+		}
+	}
+}
+
+// describeRetention summarizes the enabled retention rules.
+func describeRetention(r config.RetentionConfig) string {
+	var rules []string
+	for _, rule := range []struct {
+		name string
+		n    int
+	}{
+		{"keep_last", r.KeepLast},
+		{"keep_hourly", r.KeepHourly},
+		{"keep_daily", r.KeepDaily},
+		{"keep_weekly", r.KeepWeekly},
+		{"keep_monthly", r.KeepMonthly},
+		{"keep_yearly", r.KeepYearly},
+	} {
+		if rule.n > 0 {
+			rules = append(rules, fmt.Sprintf("%s %d", rule.name, rule.n))
+		}
+	}
+	if r.KeepWithin > 0 {
+		rules = append(rules, "keep_within "+r.KeepWithin.String())
+	}
+	if len(rules) == 0 {
+		return "none"
+	}
+	return strings.Join(rules, ", ")
+}
+
+// RunGC checks every project's manifest against its backup directory and,
+// with --fix, repairs what it can. Exits non-zero while issues remain.
+func (c *CLI) RunGC() {
+	args, wait, err := splitWaitFlag(c.Args[2:])
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error: %v\n", err)
+		c.Exit(1)
+		return
+	}
+	fix := false
+	var project string
+	for _, arg := range args {
+		switch {
+		case arg == "--fix":
+			fix = true
+		case strings.HasPrefix(arg, "-"):
+			fmt.Fprintf(c.Err, "Unknown flag: %s\n", arg)
+			fmt.Fprintf(c.Out, "Usage: codebak %s [project] [--fix] [--wait]\n", c.Args[1])
+			c.Exit(1)
+			return
+		default:
+			project = arg
+		}
+	}
+
+	cfg, err := c.configSvc().Load()
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error loading config: %v\n", err)
+		c.Exit(1)
+		return
+	}
+	applyWait(cfg, wait)
+
+	results, err := c.backupSvc().GC(cfg, project, fix)
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error: %v\n", err)
+		c.Exit(1)
+		return
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(c.Out, "No backups found")
+		return
+	}
+
+	failed := false
+	for _, r := range results {
+		if r.Error != nil {
+			fmt.Fprintf(c.Out, "  %s %s: %v\n", c.red("x"), r.Project, r.Error)
+			c.printLockHint(r.Error)
+			failed = true
+			continue
+		}
+		if len(r.Issues) == 0 {
+			fmt.Fprintf(c.Out, "  %s %s %s\n", c.green("*"), r.Project, c.gray("(ok)"))
+			continue
+		}
+		fmt.Fprintf(c.Out, "  %s %s: %d issue(s)\n", c.yellow("!"), r.Project, len(r.Issues))
+		for _, issue := range r.Issues {
+			line := fmt.Sprintf("    %-9s %s  %s", issue.Kind, issue.File, c.gray(issue.Detail))
+			if issue.Fixed {
+				line += " " + c.green("(fixed)")
+			} else if fix {
+				line += " " + c.red("(not fixed)")
+			}
+			fmt.Fprintln(c.Out, line)
+		}
+		if r.Unfixed() > 0 {
+			failed = true
+		}
+	}
+	if failed {
+		if !fix {
+			fmt.Fprintf(c.Out, "\nRun 'codebak %s --fix' to adopt orphans, drop missing entries and remove temp files.\n", c.Args[1])
+		}
+		c.Exit(1)
+	}
+}
+
+// RunMigrate upgrades every manifest to the current schema version.
+func (c *CLI) RunMigrate() {
+	args, wait, err := splitWaitFlag(c.Args[2:])
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error: %v\n", err)
+		c.Exit(1)
+		return
+	}
+	if len(args) > 0 {
+		fmt.Fprintln(c.Out, "Usage: codebak migrate [--wait]")
+		c.Exit(1)
+		return
+	}
+
+	cfg, err := c.configSvc().Load()
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error loading config: %v\n", err)
+		c.Exit(1)
+		return
+	}
+	applyWait(cfg, wait)
+
+	results, err := c.backupSvc().Migrate(cfg)
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error: %v\n", err)
+		c.printLockHint(err)
+		c.Exit(1)
+		return
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(c.Out, "No backups found")
+		return
+	}
+
+	failed := false
+	for _, r := range results {
+		switch {
+		case r.Error != nil:
+			fmt.Fprintf(c.Out, "  %s %s: %v\n", c.red("x"), r.Project, r.Error)
+			failed = true
+		case r.Backup != "":
+			fmt.Fprintf(c.Out, "  %s %s: schema %d -> %d %s\n", c.green("*"), r.Project, r.From, manifest.SchemaVersion,
+				c.gray(fmt.Sprintf("(original kept as %s)", filepath.Base(r.Backup))))
+		default:
+			fmt.Fprintf(c.Out, "  %s %s %s\n", c.gray("-"), c.gray(r.Project), c.gray("(up to date)"))
+		}
+	}
+	if failed {
+		c.Exit(1)
+	}
+}
+
+// RunConfig handles the config subcommands.
+func (c *CLI) RunConfig() {
+	if len(c.Args) < 4 || c.Args[2] != "show" {
+		fmt.Fprintln(c.Out, "Usage: codebak config show <project>")
+		c.Exit(1)
+		return
+	}
+	project := c.Args[3]
+
+	cfg, err := c.configSvc().Load()
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error loading config: %v\n", err)
+		c.Exit(1)
+		return
+	}
+
+	eff := c.backupSvc().Effective(cfg, project)
+
+	source := eff.Source
+	if source == "" {
+		source = c.gray("not found in any source")
+	}
+	enabled := c.green("yes")
+	if !eff.Enabled {
+		enabled = c.yellow("no")
+	}
+	exclude := strings.Join(eff.Exclude, ", ")
+	if exclude == "" {
+		exclude = "none"
+	}
+
+	fmt.Fprintf(c.Out, "Effective config for %s:\n", c.cyan(project))
+	fmt.Fprintf(c.Out, "  Source:      %s\n", source)
+	fmt.Fprintf(c.Out, "  Enabled:     %s\n", enabled)
+	fmt.Fprintf(c.Out, "  Retention:   %s\n", describeRetention(eff.Retention))
+	fmt.Fprintf(c.Out, "  Compression: %s\n", eff.Compression)
+	fmt.Fprintf(c.Out, "  Exclude:     %s\n", exclude)
+}
+
+// MoveBackups moves all backups to a new location and updates the config.
+func (c *CLI) MoveBackups() {
+	args, wait, err := splitWaitFlag(c.Args[2:])
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error: %v\n", err)
+		c.Exit(1)
+		return
+	}
+	if len(args) < 1 {
+		fmt.Fprintln(c.Out, "Usage: codebak move <new-path> [--wait]")
+		fmt.Fprintln(c.Out, "Example: codebak move /Volumes/External/backups")
+		c.Exit(1)
+		return
+	}
+
+	cfgSvc := c.configSvc()
+
+	cfg, err := cfgSvc.Load()
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error loading config: %v\n", err)
+		c.Exit(1)
+		return
+	}
+
+	// The config is saved below, so --wait goes to the lock only
+	lockWait := cfg.LockWait
+	if wait != nil {
+		lockWait = *wait
+	}
+
+	newPath := args[0]
+
+	// Expand ~ if present
+	if len(newPath) > 0 && newPath[0] == '~' {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			fmt.Fprintf(c.Err, "Error: cannot expand home directory: %v\n", err)
+			c.Exit(1)
+			return
+		}
+		newPath = filepath.Join(home, newPath[1:])
+	}
+
+	// Make absolute
+	newPath, err = filepath.Abs(newPath)
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error: invalid path: %v\n", err)
+		c.Exit(1)
+		return
+	}
+
+	oldPath := cfg.BackupDir
+	if oldPath == newPath {
+		fmt.Fprintln(c.Out, "New path is the same as current path. Nothing to do.")
 		return
 	}
 
@@ -611,8 +2006,18 @@ func (c *CLI) MoveBackups() {
 		return
 	}
 
+	// Keep every other codebak process out of the backups while they move
+	dirLock, err := c.locker().LockBackupDir(oldPath, lockWait)
+	if err != nil {
+		fmt.Fprintf(c.Err, "Error: %v\n", err)
+		c.printLockHint(err)
+		c.Exit(1)
+		return
+	}
+
 	// Create new directory if it doesn't exist
 	if err := os.MkdirAll(newPath, 0755); err != nil {
+		_ = dirLock.Unlock()
 		fmt.Fprintf(c.Err, "Error creating destination: %v\n", err)
 		c.Exit(1)
 		return
@@ -626,6 +2031,7 @@ func (c *CLI) MoveBackups() {
 	// Read all entries in old path
 	entries, err := os.ReadDir(oldPath)
 	if err != nil {
+		_ = dirLock.Unlock()
 		fmt.Fprintf(c.Err, "Error reading source: %v\n", err)
 		c.Exit(1)
 		return
@@ -633,8 +2039,8 @@ func (c *CLI) MoveBackups() {
 
 	moved := 0
 	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue // Skip non-directories (we only move project folders)
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue // Skip non-directories and hidden dirs like locks (we only move project folders)
 		}
 
 		oldProjectPath := filepath.Join(oldPath, entry.Name())
@@ -654,7 +2060,9 @@ func (c *CLI) MoveBackups() {
 
 	// Update config
 	cfg.BackupDir = newPath
-	if err := cfgSvc.Save(cfg); err != nil {
+	err = cfgSvc.Save(cfg)
+	_ = dirLock.Unlock()
+	if err != nil {
 		fmt.Fprintf(c.Err, "Error saving config: %v\n", err)
 		c.Exit(1)
 		return
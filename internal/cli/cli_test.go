@@ -2,7 +2,13 @@ package cli
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -10,7 +16,10 @@ import (
 	"github.com/jmcdonald/codebak/internal/backup"
 	"github.com/jmcdonald/codebak/internal/config"
 	"github.com/jmcdonald/codebak/internal/manifest"
+	"github.com/jmcdonald/codebak/internal/mocks"
+	"github.com/jmcdonald/codebak/internal/ports"
 	"github.com/jmcdonald/codebak/internal/recovery"
+	"gopkg.in/yaml.v3"
 )
 
 // ============================================================================
@@ -25,6 +34,7 @@ type mockConfigService struct {
 	configPath    string
 	configPathErr error
 	defaultCfgErr error
+	saved         *config.Config // Copy of the last saved config
 }
 
 func newMockConfigService() *mockConfigService {
@@ -45,6 +55,8 @@ func (m *mockConfigService) Load() (*config.Config, error) {
 }
 
 func (m *mockConfigService) Save(cfg *config.Config) error {
+	saved := *cfg
+	m.saved = &saved
 	return m.saveErr
 }
 
@@ -68,13 +80,41 @@ type mockBackupService struct {
 	singleResult     backup.BackupResult
 	runBackupErr     error
 	backupProjectErr error
+	runCfg           *config.Config
+	progress         []ports.Progress
+
+	pruneResults []backup.PruneResult
+	pruneErr     error
+	pruneProject string
+	pruneDryRun  bool
+
+	effectiveSource *config.Source
+
+	gcResults []backup.GCResult
+	gcErr     error
+	gcProject string
+	gcFix     bool
+
+	migrateResults []backup.MigrateResult
+	migrateErr     error
+
+	annotateEntry   manifest.BackupEntry
+	annotateErr     error
+	annotateProject string
+	annotateVersion string
+	annotation      manifest.Annotation
 }
 
 func newMockBackupService() *mockBackupService {
 	return &mockBackupService{}
 }
 
-func (m *mockBackupService) BackupProject(cfg *config.Config, project string) backup.BackupResult {
+func (m *mockBackupService) BackupProject(ctx context.Context, cfg *config.Config, project string, onProgress backup.ProgressFunc) backup.BackupResult {
+	if onProgress != nil {
+		for _, p := range m.progress {
+			onProgress(project, p)
+		}
+	}
 	if m.backupProjectErr != nil {
 		return backup.BackupResult{Project: project, Error: m.backupProjectErr}
 	}
@@ -82,13 +122,55 @@ func (m *mockBackupService) BackupProject(cfg *config.Config, project string) ba
 	return m.singleResult
 }
 
-func (m *mockBackupService) RunBackup(cfg *config.Config) ([]backup.BackupResult, error) {
+func (m *mockBackupService) RunBackup(ctx context.Context, cfg *config.Config, onResult func(backup.BackupResult), onProgress backup.ProgressFunc) ([]backup.BackupResult, error) {
+	m.runCfg = cfg
 	if m.runBackupErr != nil {
 		return nil, m.runBackupErr
 	}
+	for _, r := range m.backupResults {
+		if onProgress != nil {
+			for _, p := range m.progress {
+				onProgress(r.Project, p)
+			}
+		}
+		if onResult != nil {
+			onResult(r)
+		}
+	}
 	return m.backupResults, nil
 }
 
+func (m *mockBackupService) Prune(cfg *config.Config, project string, dryRun bool) ([]backup.PruneResult, error) {
+	m.pruneProject, m.pruneDryRun = project, dryRun
+	return m.pruneResults, m.pruneErr
+}
+
+func (m *mockBackupService) GC(cfg *config.Config, project string, fix bool) ([]backup.GCResult, error) {
+	m.gcProject, m.gcFix = project, fix
+	return m.gcResults, m.gcErr
+}
+
+func (m *mockBackupService) Migrate(cfg *config.Config) ([]backup.MigrateResult, error) {
+	return m.migrateResults, m.migrateErr
+}
+
+func (m *mockBackupService) Annotate(cfg *config.Config, project, version string, a manifest.Annotation) (manifest.BackupEntry, error) {
+	m.annotateProject, m.annotateVersion, m.annotation = project, version, a
+	if m.annotateErr != nil {
+		return manifest.BackupEntry{}, m.annotateErr
+	}
+	mf := &manifest.Manifest{Backups: []manifest.BackupEntry{m.annotateEntry}}
+	entry, err := mf.Annotate(version, a)
+	if err != nil {
+		return manifest.BackupEntry{}, err
+	}
+	return *entry, nil
+}
+
+func (m *mockBackupService) Effective(cfg *config.Config, project string) config.Effective {
+	return cfg.Effective(project, m.effectiveSource)
+}
+
 // mockRecoveryService implements RecoveryService for testing.
 type mockRecoveryService struct {
 	verifyErr      error
@@ -96,6 +178,32 @@ type mockRecoveryService struct {
 	listVersions   []manifest.BackupEntry
 	listVersionErr error
 	lastRecoverOpts recovery.RecoverOptions
+
+	fileHistory     []recovery.FileVersion
+	fileHistoryPath string
+
+	deepResult recovery.DeepResult
+	deepCalled bool
+
+	verifyAllChecks []recovery.VersionCheck
+	verifyAllOpts   recovery.VerifyAllOptions
+	verifyAllJobs   int
+
+	restoreTestResult recovery.RestoreTestResult
+	restoreTestCalled bool
+	restoreTests      []recovery.RestoreTestStatus
+
+	restoreOpts   recovery.RestoreOptions
+	restoreResult recovery.RestoreResult
+	restoreErr    error
+
+	sensitiveOpts   recovery.SensitiveOptions
+	sensitiveResult recovery.SensitiveResult
+	sensitiveErr    error
+
+	previewOpts   recovery.RecoverOptions
+	previewResult recovery.RecoverPreview
+	previewCalled bool
 }
 
 func newMockRecoveryService() *mockRecoveryService {
@@ -106,11 +214,47 @@ func (m *mockRecoveryService) Verify(cfg *config.Config, project, version string
 	return m.verifyErr
 }
 
-func (m *mockRecoveryService) Recover(cfg *config.Config, opts recovery.RecoverOptions) error {
+func (m *mockRecoveryService) VerifyDeep(ctx context.Context, cfg *config.Config, project, version string) (recovery.DeepResult, error) {
+	m.deepCalled = true
+	return m.deepResult, m.verifyErr
+}
+
+func (m *mockRecoveryService) VerifyAll(ctx context.Context, cfg *config.Config, opts recovery.VerifyAllOptions) ([]recovery.VersionCheck, error) {
+	m.verifyAllOpts = opts
+	m.verifyAllJobs = cfg.Jobs
+	return m.verifyAllChecks, m.verifyErr
+}
+
+func (m *mockRecoveryService) RestoreTest(ctx context.Context, cfg *config.Config, project, version string) (recovery.RestoreTestResult, error) {
+	m.restoreTestCalled = true
+	return m.restoreTestResult, m.verifyErr
+}
+
+func (m *mockRecoveryService) RestoreTests(cfg *config.Config) ([]recovery.RestoreTestStatus, error) {
+	return m.restoreTests, nil
+}
+
+func (m *mockRecoveryService) Recover(ctx context.Context, cfg *config.Config, opts recovery.RecoverOptions) error {
 	m.lastRecoverOpts = opts
 	return m.recoverErr
 }
 
+func (m *mockRecoveryService) Preview(cfg *config.Config, opts recovery.RecoverOptions) (recovery.RecoverPreview, error) {
+	m.previewCalled = true
+	m.previewOpts = opts
+	return m.previewResult, m.recoverErr
+}
+
+func (m *mockRecoveryService) RecoverSensitive(ctx context.Context, cfg *config.Config, opts recovery.SensitiveOptions) (recovery.SensitiveResult, error) {
+	m.sensitiveOpts = opts
+	return m.sensitiveResult, m.sensitiveErr
+}
+
+func (m *mockRecoveryService) Restore(ctx context.Context, cfg *config.Config, opts recovery.RestoreOptions) (recovery.RestoreResult, error) {
+	m.restoreOpts = opts
+	return m.restoreResult, m.restoreErr
+}
+
 func (m *mockRecoveryService) ListVersions(cfg *config.Config, project string) ([]manifest.BackupEntry, error) {
 	if m.listVersionErr != nil {
 		return nil, m.listVersionErr
@@ -118,6 +262,14 @@ func (m *mockRecoveryService) ListVersions(cfg *config.Config, project string) (
 	return m.listVersions, nil
 }
 
+func (m *mockRecoveryService) FileHistory(cfg *config.Config, project, path string) ([]recovery.FileVersion, error) {
+	m.fileHistoryPath = path
+	if m.listVersionErr != nil {
+		return nil, m.listVersionErr
+	}
+	return m.fileHistory, nil
+}
+
 // mockLaunchdService implements LaunchdService for testing.
 type mockLaunchdService struct {
 	installed   bool
@@ -583,6 +735,23 @@ func TestRunBackupRunBackupError(t *testing.T) {
 	}
 }
 
+func TestRunBackupInterrupted(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "run"})
+	mockBackup := newMockBackupService()
+	mockBackup.runBackupErr = context.Canceled
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = mockBackup
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != exitInterrupted {
+		t.Errorf("expected Exit(%d), got %d", exitInterrupted, tc.exitCode)
+	}
+	if errOut := tc.errOut.String(); !strings.Contains(errOut, "Interrupted:") || strings.Contains(errOut, "Error:") {
+		t.Errorf("expected interrupted message, got %q", errOut)
+	}
+}
+
 func TestRunBackupWithErrors(t *testing.T) {
 	tc := newTestCLI([]string{"codebak", "run"})
 	mockCfg := newMockConfigService()
@@ -608,6 +777,120 @@ func TestRunBackupWithErrors(t *testing.T) {
 	}
 }
 
+func TestRunBackupReportsUnreadableFiles(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "run"})
+	mockBackup := newMockBackupService()
+	mockBackup.backupResults = []backup.BackupResult{
+		{Project: "project-a", Size: 1024, FileCount: 2, SkippedFiles: []manifest.SkippedFile{
+			{Path: "secret.key", Reason: "permission denied"},
+		}},
+	}
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = mockBackup
+
+	tc.Run()
+
+	out := tc.out.String()
+	if !strings.Contains(out, "1 file(s) could not be read") || !strings.Contains(out, "secret.key (permission denied)") {
+		t.Errorf("expected unreadable files to be listed, got %q", out)
+	}
+	if !strings.Contains(out, "1 unreadable files") {
+		t.Errorf("expected unreadable count in summary, got %q", out)
+	}
+}
+
+func TestRunBackupWaitFlag(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "run", "--wait=30s", "myproject"})
+	mockCfg := newMockConfigService()
+	mockBackup := newMockBackupService()
+	tc.ConfigSvc = mockCfg
+	tc.BackupSvc = mockBackup
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Errorf("Exit should not have been called")
+	}
+	if mockCfg.config.LockWait != 30*time.Second {
+		t.Errorf("LockWait = %v, expected 30s", mockCfg.config.LockWait)
+	}
+	if !strings.Contains(tc.out.String(), "myproject") {
+		t.Errorf("expected myproject in output, got %q", tc.out.String())
+	}
+}
+
+func TestRunBackupInvalidWait(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "run", "--wait=soon"})
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = newMockBackupService()
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != 1 {
+		t.Errorf("expected Exit(1)")
+	}
+	if !strings.Contains(tc.errOut.String(), "invalid --wait duration") {
+		t.Errorf("expected invalid duration message, got %q", tc.errOut.String())
+	}
+}
+
+func TestRunBackupLockedSuggestsWait(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "run"})
+	mockBackup := newMockBackupService()
+	mockBackup.runBackupErr = fmt.Errorf("locking: %w", ports.ErrLocked)
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = mockBackup
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != 1 {
+		t.Errorf("expected Exit(1)")
+	}
+	if !strings.Contains(tc.errOut.String(), "another codebak run is in progress") {
+		t.Errorf("expected lock error, got %q", tc.errOut.String())
+	}
+	if !strings.Contains(tc.errOut.String(), "--wait") {
+		t.Errorf("expected --wait hint, got %q", tc.errOut.String())
+	}
+}
+
+func TestSplitWaitFlag(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantRest []string
+		wantWait *time.Duration
+		wantErr  bool
+	}{
+		{name: "absent", args: []string{"proj"}, wantRest: []string{"proj"}},
+		{name: "bare waits forever", args: []string{"--wait", "proj"}, wantRest: []string{"proj"}, wantWait: durationPtr(ports.WaitForever)},
+		{name: "duration", args: []string{"proj", "--wait=2m"}, wantRest: []string{"proj"}, wantWait: durationPtr(2 * time.Minute)},
+		{name: "invalid", args: []string{"--wait=later"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rest, wait, err := splitWaitFlag(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if strings.Join(rest, " ") != strings.Join(tt.wantRest, " ") {
+				t.Errorf("rest = %v, expected %v", rest, tt.wantRest)
+			}
+			if (wait == nil) != (tt.wantWait == nil) || (wait != nil && *wait != *tt.wantWait) {
+				t.Errorf("wait = %v, expected %v", wait, tt.wantWait)
+			}
+		})
+	}
+}
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
+
 // ============================================================================
 // InstallLaunchd tests
 // ============================================================================
@@ -757,6 +1040,27 @@ func TestShowStatusSuccess(t *testing.T) {
 	}
 }
 
+func TestShowStatusRestoreTests(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "status"})
+	mockRecovery := newMockRecoveryService()
+	mockRecovery.restoreTests = []recovery.RestoreTestStatus{
+		{Project: "api", Version: "20240101-120000", TestedAt: time.Date(2024, 1, 2, 9, 30, 0, 0, time.Local)},
+		{Project: "web"},
+	}
+	tc.ConfigSvc = newMockConfigService()
+	tc.LaunchdSvc = newMockLaunchdService()
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	output := strings.Join(strings.Fields(tc.out.String()), " ")
+	for _, want := range []string{"Restore tests:", "api 2024-01-02 09:30 (20240101-120000)", "web never"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q, got %q", want, output)
+		}
+	}
+}
+
 func TestShowStatusLaunchdInstalled(t *testing.T) {
 	tc := newTestCLI([]string{"codebak", "status"})
 	mockCfg := newMockConfigService()
@@ -879,15 +1183,11 @@ func TestRunVerifyVerificationFailed(t *testing.T) {
 	}
 }
 
-// ============================================================================
-// RunRecover tests
-// ============================================================================
-
-func TestRunRecoverSuccess(t *testing.T) {
-	tc := newTestCLI([]string{"codebak", "recover", "myproject"})
-	mockCfg := newMockConfigService()
+func TestRunVerifyDeep(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "verify", "myproject", "--deep"})
 	mockRecovery := newMockRecoveryService()
-	tc.ConfigSvc = mockCfg
+	mockRecovery.deepResult = recovery.DeepResult{Version: "20240101-120000", Files: 12}
+	tc.ConfigSvc = newMockConfigService()
 	tc.RecoverySvc = mockRecovery
 
 	tc.Run()
@@ -895,20 +1195,46 @@ func TestRunRecoverSuccess(t *testing.T) {
 	if tc.exitCalled {
 		t.Errorf("Exit should not have been called")
 	}
-	output := tc.out.String()
-	if !strings.Contains(output, "Recovering myproject") {
-		t.Errorf("expected recovering message, got %q", output)
+	if !mockRecovery.deepCalled {
+		t.Error("--deep should run deep verification")
 	}
-	if !strings.Contains(output, "Successfully recovered myproject") {
-		t.Errorf("expected success message, got %q", output)
+	if !strings.Contains(tc.out.String(), "Verified 12 files of myproject 20240101-120000") {
+		t.Errorf("expected success message, got %q", tc.out.String())
 	}
 }
 
-func TestRunRecoverWithWipe(t *testing.T) {
-	tc := newTestCLI([]string{"codebak", "recover", "myproject", "--wipe"})
-	mockCfg := newMockConfigService()
+func TestRunVerifyDeepFailures(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "verify", "myproject", "--deep"})
 	mockRecovery := newMockRecoveryService()
-	tc.ConfigSvc = mockCfg
+	mockRecovery.deepResult = recovery.DeepResult{
+		Version: "20240101-120000",
+		Files:   12,
+		Failures: []recovery.EntryFailure{
+			{Reason: "archive holds 12 files, manifest records 13"},
+			{Path: "src/main.go", Reason: "CRC32 mismatch"},
+		},
+	}
+	tc.ConfigSvc = newMockConfigService()
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != 1 {
+		t.Errorf("expected Exit(1)")
+	}
+	output := tc.out.String()
+	for _, want := range []string{"2 problem(s) in 12 files", "(archive)  archive holds 12 files", "src/main.go  CRC32 mismatch"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q, got %q", want, output)
+		}
+	}
+}
+
+func TestRunVerifyRestoreTest(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "verify", "myproject", "--restore-test"})
+	mockRecovery := newMockRecoveryService()
+	mockRecovery.restoreTestResult = recovery.RestoreTestResult{Version: "20240101-120000", Files: 12, TestedAt: time.Now()}
+	tc.ConfigSvc = newMockConfigService()
 	tc.RecoverySvc = mockRecovery
 
 	tc.Run()
@@ -916,39 +1242,52 @@ func TestRunRecoverWithWipe(t *testing.T) {
 	if tc.exitCalled {
 		t.Errorf("Exit should not have been called")
 	}
-	if !mockRecovery.lastRecoverOpts.Wipe {
-		t.Error("expected Wipe option to be set")
+	if !mockRecovery.restoreTestCalled {
+		t.Error("--restore-test should run a restore test")
 	}
-	if !strings.Contains(tc.out.String(), "wiping current") {
-		t.Errorf("expected wipe message, got %q", tc.out.String())
+	if !strings.Contains(tc.out.String(), "Restored 12 files of myproject 20240101-120000 cleanly") {
+		t.Errorf("expected success message, got %q", tc.out.String())
 	}
 }
 
-func TestRunRecoverWithArchive(t *testing.T) {
-	tc := newTestCLI([]string{"codebak", "recover", "myproject", "--archive"})
-	mockCfg := newMockConfigService()
+func TestRunVerifyRestoreTestFailures(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "verify", "myproject", "--restore-test"})
 	mockRecovery := newMockRecoveryService()
-	tc.ConfigSvc = mockCfg
+	mockRecovery.restoreTestResult = recovery.RestoreTestResult{
+		Version: "20240101-120000",
+		Failures: []recovery.EntryFailure{
+			{Reason: "extracting: symlinks not supported in backups: myproject/link"},
+		},
+	}
+	tc.ConfigSvc = newMockConfigService()
 	tc.RecoverySvc = mockRecovery
 
 	tc.Run()
 
-	if tc.exitCalled {
-		t.Errorf("Exit should not have been called")
+	if !tc.exitCalled || tc.exitCode != 1 {
+		t.Errorf("expected Exit(1)")
 	}
-	if !mockRecovery.lastRecoverOpts.Archive {
-		t.Error("expected Archive option to be set")
+	output := tc.out.String()
+	for _, want := range []string{"restore test found 1 problem(s)", "(archive)  extracting: symlinks not supported"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q, got %q", want, output)
+		}
 	}
-	if !strings.Contains(tc.out.String(), "archiving current") {
-		t.Errorf("expected archive message, got %q", tc.out.String())
+}
+
+// verifyAllChecks returns a passing and a failing version check.
+func verifyAllChecks() []recovery.VersionCheck {
+	return []recovery.VersionCheck{
+		{Project: "alpha", Version: "20240101-120000"},
+		{Project: "beta", Version: "20240102-120000", Err: errors.New("checksum mismatch: expected aaa, got bbb")},
 	}
 }
 
-func TestRunRecoverWithVersion(t *testing.T) {
-	tc := newTestCLI([]string{"codebak", "recover", "myproject", "--version=20240101-120000"})
-	mockCfg := newMockConfigService()
+func TestRunVerifyAll(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "verify", "--all", "--versions=all", "--deep", "--jobs", "4"})
 	mockRecovery := newMockRecoveryService()
-	tc.ConfigSvc = mockCfg
+	mockRecovery.verifyAllChecks = verifyAllChecks()[:1]
+	tc.ConfigSvc = newMockConfigService()
 	tc.RecoverySvc = mockRecovery
 
 	tc.Run()
@@ -956,16 +1295,20 @@ func TestRunRecoverWithVersion(t *testing.T) {
 	if tc.exitCalled {
 		t.Errorf("Exit should not have been called")
 	}
-	if mockRecovery.lastRecoverOpts.Version != "20240101-120000" {
-		t.Errorf("expected version 20240101-120000, got %q", mockRecovery.lastRecoverOpts.Version)
+	if mockRecovery.verifyAllOpts != (recovery.VerifyAllOptions{Versions: recovery.VersionsAll, Deep: true}) || mockRecovery.verifyAllJobs != 4 {
+		t.Errorf("opts = %+v, jobs = %d, expected every version deep-checked 4 at a time", mockRecovery.verifyAllOpts, mockRecovery.verifyAllJobs)
+	}
+	output := tc.out.String()
+	if !strings.Contains(output, "alpha                20240101-120000    ok") || !strings.Contains(output, "1 version(s) verified") {
+		t.Errorf("expected summary table, got %q", output)
 	}
 }
 
-func TestRunRecoverWipeAndArchive(t *testing.T) {
-	tc := newTestCLI([]string{"codebak", "recover", "myproject", "--wipe", "--archive"})
-	mockCfg := newMockConfigService()
+func TestRunVerifyAllFailure(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "verify", "--all"})
 	mockRecovery := newMockRecoveryService()
-	tc.ConfigSvc = mockCfg
+	mockRecovery.verifyAllChecks = verifyAllChecks()
+	tc.ConfigSvc = newMockConfigService()
 	tc.RecoverySvc = mockRecovery
 
 	tc.Run()
@@ -973,69 +1316,96 @@ func TestRunRecoverWipeAndArchive(t *testing.T) {
 	if !tc.exitCalled || tc.exitCode != 1 {
 		t.Errorf("expected Exit(1)")
 	}
-	if !strings.Contains(tc.out.String(), "Cannot use both --wipe and --archive") {
-		t.Errorf("expected error message, got %q", tc.out.String())
+	output := tc.out.String()
+	if !strings.Contains(output, "FAILED checksum mismatch") || !strings.Contains(output, "1 of 2 version(s) failed") {
+		t.Errorf("expected the failure reported, got %q", output)
 	}
 }
 
-func TestRunRecoverConfigLoadError(t *testing.T) {
-	tc := newTestCLI([]string{"codebak", "recover", "myproject"})
-	mockCfg := newMockConfigService()
-	mockCfg.loadErr = errors.New("config error")
-	tc.ConfigSvc = mockCfg
+func TestRunVerifyAllJSON(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "verify", "--all", "--json"})
+	mockRecovery := newMockRecoveryService()
+	mockRecovery.verifyAllChecks = verifyAllChecks()
+	mockRecovery.verifyAllChecks[0].Failures = []recovery.EntryFailure{{Path: "main.go", Reason: "CRC32 mismatch"}}
+	tc.ConfigSvc = newMockConfigService()
+	tc.RecoverySvc = mockRecovery
 
 	tc.Run()
 
 	if !tc.exitCalled || tc.exitCode != 1 {
 		t.Errorf("expected Exit(1)")
 	}
-	if !strings.Contains(tc.errOut.String(), "Error loading config") {
-		t.Errorf("expected error message, got %q", tc.errOut.String())
+	var report struct {
+		OK      bool `json:"ok"`
+		Checked int  `json:"checked"`
+		Failed  int  `json:"failed"`
+		Results []struct {
+			Project  string `json:"project"`
+			OK       bool   `json:"ok"`
+			Error    string `json:"error"`
+			Failures []struct {
+				Path   string `json:"path"`
+				Reason string `json:"reason"`
+			} `json:"failures"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(tc.out.Bytes(), &report); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, tc.out.String())
+	}
+	if report.OK || report.Checked != 2 || report.Failed != 2 {
+		t.Errorf("report = %+v, expected two failed versions", report)
+	}
+	if len(report.Results[0].Failures) != 1 || report.Results[0].Failures[0].Path != "main.go" {
+		t.Errorf("results[0] = %+v, expected the failing file", report.Results[0])
+	}
+	if report.Results[1].Error == "" {
+		t.Errorf("results[1] = %+v, expected the error", report.Results[1])
+	}
+}
+
+func TestRunVerifyAllRejectsProject(t *testing.T) {
+	for _, args := range [][]string{
+		{"codebak", "verify", "--all", "myproject"},
+		{"codebak", "verify", "myproject", "--json"},
+		{"codebak", "verify"},
+		{"codebak", "verify", "--all", "--restore-test"},
+		{"codebak", "verify", "myproject", "--deep", "--restore-test"},
+	} {
+		tc := newTestCLI(args)
+		tc.ConfigSvc = newMockConfigService()
+		tc.RecoverySvc = newMockRecoveryService()
+
+		tc.Run()
+
+		if !tc.exitCalled || tc.exitCode != 1 || !strings.Contains(tc.out.String(), "Usage: codebak verify") {
+			t.Errorf("%v: expected usage and Exit(1), got %q", args[2:], tc.out.String())
+		}
 	}
 }
 
-func TestRunRecoverRecoveryFailed(t *testing.T) {
-	tc := newTestCLI([]string{"codebak", "recover", "myproject"})
-	mockCfg := newMockConfigService()
-	mockRecovery := newMockRecoveryService()
-	mockRecovery.recoverErr = errors.New("project already exists")
-	tc.ConfigSvc = mockCfg
-	tc.RecoverySvc = mockRecovery
+func TestRunVerifyUnknownFlag(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "verify", "myproject", "--deeper"})
+	tc.ConfigSvc = newMockConfigService()
+	tc.RecoverySvc = newMockRecoveryService()
 
 	tc.Run()
 
 	if !tc.exitCalled || tc.exitCode != 1 {
 		t.Errorf("expected Exit(1)")
 	}
-	if !strings.Contains(tc.errOut.String(), "Recovery failed") {
-		t.Errorf("expected error message, got %q", tc.errOut.String())
+	if !strings.Contains(tc.errOut.String(), "Unknown flag: --deeper") {
+		t.Errorf("expected unknown flag error, got %q", tc.errOut.String())
 	}
 }
 
 // ============================================================================
-// ListBackups tests
+// RunRecover tests
 // ============================================================================
 
-func TestListBackupsSuccess(t *testing.T) {
-	tc := newTestCLI([]string{"codebak", "list", "myproject"})
+func TestRunRecoverSuccess(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "recover", "myproject"})
 	mockCfg := newMockConfigService()
 	mockRecovery := newMockRecoveryService()
-	mockRecovery.listVersions = []manifest.BackupEntry{
-		{
-			File:      "20240101-120000.zip",
-			SizeBytes: 1024,
-			FileCount: 10,
-			GitHead:   "abc1234567890",
-			CreatedAt: time.Now(),
-		},
-		{
-			File:      "20240102-120000.zip",
-			SizeBytes: 2048,
-			FileCount: 20,
-			GitHead:   "",
-			CreatedAt: time.Now(),
-		},
-	}
 	tc.ConfigSvc = mockCfg
 	tc.RecoverySvc = mockRecovery
 
@@ -1045,15 +1415,1040 @@ func TestListBackupsSuccess(t *testing.T) {
 		t.Errorf("Exit should not have been called")
 	}
 	output := tc.out.String()
-	if !strings.Contains(output, "Backups for myproject") {
-		t.Errorf("expected header, got %q", output)
+	if !strings.Contains(output, "Recovering myproject") {
+		t.Errorf("expected recovering message, got %q", output)
 	}
-	if !strings.Contains(output, "20240101-120000") {
-		t.Errorf("expected version, got %q", output)
+	if !strings.Contains(output, "Successfully recovered myproject") {
+		t.Errorf("expected success message, got %q", output)
+	}
+}
+
+func TestRunRecoverWaitFlag(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "recover", "myproject", "--wait", "--wipe"})
+	mockCfg := newMockConfigService()
+	mockRecovery := newMockRecoveryService()
+	tc.ConfigSvc = mockCfg
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Errorf("Exit should not have been called")
+	}
+	if mockCfg.config.LockWait != ports.WaitForever {
+		t.Errorf("LockWait = %v, expected WaitForever", mockCfg.config.LockWait)
+	}
+	if mockRecovery.lastRecoverOpts.Project != "myproject" || !mockRecovery.lastRecoverOpts.Wipe {
+		t.Errorf("unexpected recover options: %+v", mockRecovery.lastRecoverOpts)
+	}
+}
+
+func TestMoveBackupsLocked(t *testing.T) {
+	oldPath := t.TempDir()
+	newPath := filepath.Join(t.TempDir(), "moved")
+
+	tc := newTestCLI([]string{"codebak", "move", newPath})
+	mockCfg := newMockConfigService()
+	mockCfg.config.BackupDir = oldPath
+	locker := mocks.NewMockLocker()
+	locker.Held["dir:"+oldPath] = true
+	tc.ConfigSvc = mockCfg
+	tc.Locker = locker
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != 1 {
+		t.Errorf("expected Exit(1)")
+	}
+	if !strings.Contains(tc.errOut.String(), "another codebak run is in progress") {
+		t.Errorf("expected lock error, got %q", tc.errOut.String())
+	}
+	if mockCfg.config.BackupDir != oldPath {
+		t.Errorf("BackupDir changed to %q while locked", mockCfg.config.BackupDir)
+	}
+	if _, err := os.Stat(newPath); err == nil {
+		t.Error("destination should not be created while locked")
+	}
+}
+
+func TestMoveBackupsWaitNotSaved(t *testing.T) {
+	oldPath := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(oldPath, "my-project"), 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	newPath := filepath.Join(t.TempDir(), "moved")
+
+	tc := newTestCLI([]string{"codebak", "move", newPath, "--wait=5m"})
+	mockCfg := newMockConfigService()
+	mockCfg.config.BackupDir = oldPath
+	locker := mocks.NewMockLocker()
+	tc.ConfigSvc = mockCfg
+	tc.Locker = locker
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Fatalf("Exit should not have been called: %s", tc.errOut.String())
+	}
+	if len(locker.Waits) != 1 || locker.Waits[0] != 5*time.Minute {
+		t.Errorf("lock waits = %v, expected 5m", locker.Waits)
+	}
+	if mockCfg.saved == nil || mockCfg.saved.BackupDir != newPath {
+		t.Fatalf("saved config = %+v, expected BackupDir %s", mockCfg.saved, newPath)
+	}
+	data, err := yaml.Marshal(mockCfg.saved)
+	if err != nil {
+		t.Fatalf("Failed to marshal saved config: %v", err)
+	}
+	if strings.Contains(string(data), "lock_wait") {
+		t.Errorf("--wait should not be saved to the config, got:\n%s", data)
+	}
+}
+
+func TestRunRecoverWithWipe(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "recover", "myproject", "--wipe"})
+	mockCfg := newMockConfigService()
+	mockRecovery := newMockRecoveryService()
+	tc.ConfigSvc = mockCfg
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Errorf("Exit should not have been called")
+	}
+	if !mockRecovery.lastRecoverOpts.Wipe {
+		t.Error("expected Wipe option to be set")
+	}
+	if !strings.Contains(tc.out.String(), "wiping current") {
+		t.Errorf("expected wipe message, got %q", tc.out.String())
+	}
+}
+
+func TestRunRecoverWithArchive(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "recover", "myproject", "--archive"})
+	mockCfg := newMockConfigService()
+	mockRecovery := newMockRecoveryService()
+	tc.ConfigSvc = mockCfg
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Errorf("Exit should not have been called")
+	}
+	if !mockRecovery.lastRecoverOpts.Archive {
+		t.Error("expected Archive option to be set")
+	}
+	if !strings.Contains(tc.out.String(), "archiving current") {
+		t.Errorf("expected archive message, got %q", tc.out.String())
+	}
+}
+
+func TestRunRecoverWithVersion(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "recover", "myproject", "--version=20240101-120000"})
+	mockCfg := newMockConfigService()
+	mockRecovery := newMockRecoveryService()
+	tc.ConfigSvc = mockCfg
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Errorf("Exit should not have been called")
+	}
+	if mockRecovery.lastRecoverOpts.Version != "20240101-120000" {
+		t.Errorf("expected version 20240101-120000, got %q", mockRecovery.lastRecoverOpts.Version)
+	}
+}
+
+// setupRealBackup backs up a one-file project with the production services
+// and returns a config service pointing at it and the version created.
+func setupRealBackup(t *testing.T) (*mockConfigService, string) {
+	t.Helper()
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+	if err := os.MkdirAll(filepath.Join(sourceDir, "myproject"), 0755); err != nil {
+		t.Fatalf("Failed to create project: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "myproject", "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	cfgSvc := newMockConfigService()
+	cfgSvc.config = &config.Config{SourceDir: sourceDir, BackupDir: filepath.Join(tempDir, "backups")}
+	result := backup.BackupProject(context.Background(), cfgSvc.config, "myproject")
+	if result.Error != nil {
+		t.Fatalf("BackupProject failed: %v", result.Error)
+	}
+	return cfgSvc, manifest.VersionName(filepath.Base(result.ZipPath))
+}
+
+func TestRunVerifyLatest(t *testing.T) {
+	cfgSvc, version := setupRealBackup(t)
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"latest"}, "Checksum verified for myproject"},
+		{[]string{"--deep", "latest"}, "Verified 1 files of myproject " + version},
+		{[]string{"--restore-test", "latest"}, "Restored 1 files of myproject " + version + " cleanly"},
+	} {
+		tc := newTestCLI(append([]string{"codebak", "verify", "myproject"}, tt.args...))
+		tc.ConfigSvc = cfgSvc
+
+		tc.Run()
+
+		if tc.exitCalled {
+			t.Errorf("verify %v exited %d: %s", tt.args, tc.exitCode, tc.errOut.String())
+		}
+		if !strings.Contains(tc.out.String(), tt.want) {
+			t.Errorf("verify %v: expected %q, got %q", tt.args, tt.want, tc.out.String())
+		}
+	}
+}
+
+func TestRunRecoverLatest(t *testing.T) {
+	cfgSvc, version := setupRealBackup(t)
+	target := filepath.Join(t.TempDir(), "restored")
+
+	tc := newTestCLI([]string{"codebak", "recover", "myproject", "--version=latest", "--to=" + target, "--dry-run"})
+	tc.ConfigSvc = cfgSvc
+	tc.Run()
+	if tc.exitCalled {
+		t.Fatalf("recover --dry-run exited %d: %s", tc.exitCode, tc.errOut.String())
+	}
+	if want := "Recovering myproject " + version + " would change 1 file(s)"; !strings.Contains(tc.out.String(), want) {
+		t.Errorf("expected %q, got %q", want, tc.out.String())
+	}
+
+	tc = newTestCLI([]string{"codebak", "recover", "myproject", "--version=latest", "--to=" + target})
+	tc.ConfigSvc = cfgSvc
+	tc.Run()
+	if tc.exitCalled {
+		t.Fatalf("recover exited %d: %s", tc.exitCode, tc.errOut.String())
+	}
+	if data, _ := os.ReadFile(filepath.Join(target, "main.go")); string(data) != "package main" {
+		t.Errorf("main.go = %q, expected the latest version recovered", data)
+	}
+}
+
+func TestRunRecoverWipeAndArchive(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "recover", "myproject", "--wipe", "--archive"})
+	mockCfg := newMockConfigService()
+	mockRecovery := newMockRecoveryService()
+	tc.ConfigSvc = mockCfg
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != 1 {
+		t.Errorf("expected Exit(1)")
+	}
+	if !strings.Contains(tc.out.String(), "Cannot use both --wipe and --archive") {
+		t.Errorf("expected error message, got %q", tc.out.String())
+	}
+}
+
+func TestRunRecoverToTarget(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "recover", "myproject", "--to=/tmp/compare", "--force"})
+	mockRecovery := newMockRecoveryService()
+	tc.ConfigSvc = newMockConfigService()
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Errorf("Exit should not have been called")
+	}
+	opts := mockRecovery.lastRecoverOpts
+	if opts.Target != "/tmp/compare" || !opts.Force {
+		t.Errorf("opts = %+v, expected Target /tmp/compare with Force", opts)
+	}
+	if !strings.Contains(tc.out.String(), "Successfully recovered myproject to /tmp/compare") {
+		t.Errorf("expected the target in the output, got %q", tc.out.String())
+	}
+}
+
+func TestRunRecoverForceWithoutTarget(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "recover", "myproject", "--force"})
+	mockRecovery := newMockRecoveryService()
+	tc.ConfigSvc = newMockConfigService()
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != 1 {
+		t.Errorf("expected Exit(1)")
+	}
+	if !strings.Contains(tc.out.String(), "--force requires --to=DIR") {
+		t.Errorf("expected error message, got %q", tc.out.String())
+	}
+}
+
+func TestRunRecoverDryRun(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "recover", "myproject", "--version=20240101-120000", "--dry-run"})
+	mockRecovery := newMockRecoveryService()
+	mockRecovery.previewResult = recovery.RecoverPreview{
+		Version:     "20240101-120000",
+		Dir:         "/src/myproject",
+		Added:       []string{"docs/new.md"},
+		Overwritten: []string{"go.mod", "main.go"},
+		Removed:     []string{"scratch.txt"},
+		Unchanged:   []string{"README.md"},
+		Newer:       []string{"main.go"},
+	}
+	tc.ConfigSvc = newMockConfigService()
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Errorf("Exit should not have been called")
+	}
+	if mockRecovery.previewOpts.Version != "20240101-120000" || mockRecovery.lastRecoverOpts.Project != "" {
+		t.Errorf("expected only a preview of 20240101-120000, got preview %+v and recover %+v", mockRecovery.previewOpts, mockRecovery.lastRecoverOpts)
+	}
+	output := tc.out.String()
+	for _, want := range []string{
+		"Recovering myproject 20240101-120000 would change 4 file(s) in /src/myproject",
+		"+ docs/new.md",
+		"~ go.mod\n",
+		"~ main.go (newer locally)",
+		"- scratch.txt",
+		"1 file(s) already match the backup",
+		"1 file(s) were modified locally after the backup",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q, got %q", want, output)
+		}
+	}
+}
+
+func TestRunRecoverSensitive(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "recover", "--sensitive", "ssh", "--snapshot=abc123", "--include=config", "--include=id_ed25519", "--archive"})
+	mockRecovery := newMockRecoveryService()
+	mockRecovery.sensitiveResult = recovery.SensitiveResult{
+		Snapshot: ports.Snapshot{ID: "abc1234567890", Time: time.Date(2026, 1, 2, 15, 4, 0, 0, time.Local)},
+		Dir:      "/home/me/.ssh",
+		Restored: []string{"config", "id_ed25519"},
+	}
+	tc.ConfigSvc = newMockConfigService()
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Errorf("Exit should not have been called")
+	}
+	opts := mockRecovery.sensitiveOpts
+	expected := recovery.SensitiveOptions{Label: "ssh", Snapshot: "abc123", Include: []string{"config", "id_ed25519"}, Archive: true}
+	if !reflect.DeepEqual(opts, expected) {
+		t.Errorf("opts = %+v, expected %+v", opts, expected)
+	}
+	out := tc.out.String()
+	if !strings.Contains(out, "Recovered ssh from snapshot abc12345 (2026-01-02 15:04) to /home/me/.ssh") {
+		t.Errorf("expected the snapshot and directory in the output, got %q", out)
+	}
+	if !strings.Contains(out, "id_ed25519") {
+		t.Errorf("expected the restored paths in the output, got %q", out)
+	}
+	if mockRecovery.lastRecoverOpts.Project != "" {
+		t.Error("project recovery should not run for --sensitive")
+	}
+}
+
+func TestRunRecoverSensitiveWaitFlag(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "recover", "--sensitive", "ssh", "--wait=5m", "--wipe"})
+	mockCfg := newMockConfigService()
+	mockRecovery := newMockRecoveryService()
+	tc.ConfigSvc = mockCfg
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Errorf("Exit should not have been called")
+	}
+	if mockCfg.config.LockWait != 5*time.Minute {
+		t.Errorf("LockWait = %v, expected 5m", mockCfg.config.LockWait)
+	}
+	if mockRecovery.sensitiveOpts.Label != "ssh" || !mockRecovery.sensitiveOpts.Wipe {
+		t.Errorf("unexpected recover options: %+v", mockRecovery.sensitiveOpts)
+	}
+}
+
+func TestRunRecoverSensitiveDryRun(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "recover", "--sensitive", "ssh", "--dry-run"})
+	mockRecovery := newMockRecoveryService()
+	mockRecovery.sensitiveResult = recovery.SensitiveResult{
+		Snapshot: ports.Snapshot{ID: "abc1234567890", Time: time.Date(2026, 1, 2, 15, 4, 0, 0, time.Local)},
+		Dir:      "/home/me/.ssh",
+		Restored: []string{"."},
+		Preview:  &recovery.RecoverPreview{Version: "abc1234567890", Dir: "/home/me/.ssh", Overwritten: []string{"config"}},
+	}
+	tc.ConfigSvc = newMockConfigService()
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Errorf("Exit should not have been called")
+	}
+	if !mockRecovery.sensitiveOpts.DryRun {
+		t.Error("expected DryRun to be passed to RecoverSensitive")
+	}
+	output := tc.out.String()
+	if !strings.Contains(output, "Recovering snapshot abc12345 (2026-01-02 15:04) would change 1 file(s) in /home/me/.ssh") {
+		t.Errorf("expected the preview in the output, got %q", output)
+	}
+	if strings.Contains(output, "Recovered ssh") {
+		t.Errorf("a dry run should not report a recovery, got %q", output)
+	}
+}
+
+func TestRunRecoverSensitiveErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"no label", []string{"--sensitive"}, "Usage: codebak recover --sensitive"},
+		{"unknown flag", []string{"--sensitive", "ssh", "--version=20240101-120000"}, "Usage: codebak recover --sensitive"},
+		{"wipe and archive", []string{"--sensitive", "ssh", "--wipe", "--archive"}, "Cannot use both --wipe and --archive"},
+		{"force without target", []string{"--sensitive", "ssh", "--force"}, "--force requires --to=DIR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := newTestCLI(append([]string{"codebak", "recover"}, tt.args...))
+			tc.ConfigSvc = newMockConfigService()
+			tc.RecoverySvc = newMockRecoveryService()
+
+			tc.Run()
+
+			if !tc.exitCalled || tc.exitCode != 1 {
+				t.Errorf("expected Exit(1)")
+			}
+			if !strings.Contains(tc.out.String(), tt.want) {
+				t.Errorf("expected %q in the output, got %q", tt.want, tc.out.String())
+			}
+		})
+	}
+
+	tc := newTestCLI([]string{"codebak", "recover", "--sensitive", "ssh"})
+	mockRecovery := newMockRecoveryService()
+	mockRecovery.sensitiveErr = errors.New("no sensitive source labelled ssh")
+	tc.ConfigSvc = newMockConfigService()
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if !tc.exitCalled || !strings.Contains(tc.errOut.String(), "Recovery failed: no sensitive source labelled ssh") {
+		t.Errorf("expected the failure to be reported, got %q", tc.errOut.String())
+	}
+}
+
+func TestRunRecoverConfigLoadError(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "recover", "myproject"})
+	mockCfg := newMockConfigService()
+	mockCfg.loadErr = errors.New("config error")
+	tc.ConfigSvc = mockCfg
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != 1 {
+		t.Errorf("expected Exit(1)")
+	}
+	if !strings.Contains(tc.errOut.String(), "Error loading config") {
+		t.Errorf("expected error message, got %q", tc.errOut.String())
+	}
+}
+
+func TestRunRecoverRecoveryFailed(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "recover", "myproject"})
+	mockCfg := newMockConfigService()
+	mockRecovery := newMockRecoveryService()
+	mockRecovery.recoverErr = errors.New("project already exists")
+	tc.ConfigSvc = mockCfg
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != 1 {
+		t.Errorf("expected Exit(1)")
+	}
+	if !strings.Contains(tc.errOut.String(), "Recovery failed") {
+		t.Errorf("expected error message, got %q", tc.errOut.String())
+	}
+}
+
+func TestRunRecoverInterrupted(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "recover", "myproject"})
+	mockRecovery := newMockRecoveryService()
+	mockRecovery.recoverErr = fmt.Errorf("extracting backup: %w", context.Canceled)
+	tc.ConfigSvc = newMockConfigService()
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != exitInterrupted {
+		t.Errorf("expected Exit(%d), got %d", exitInterrupted, tc.exitCode)
+	}
+	if errOut := tc.errOut.String(); !strings.Contains(errOut, "Interrupted:") || strings.Contains(errOut, "Recovery failed") {
+		t.Errorf("expected interrupted message, got %q", errOut)
+	}
+}
+
+// ============================================================================
+// Restore tests
+// ============================================================================
+
+func TestRunRestore(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "restore", "myproject", "src/main.go", "--version=latest", "--to=/tmp/out", "--force"})
+	mockRecovery := newMockRecoveryService()
+	mockRecovery.restoreResult = recovery.RestoreResult{
+		Version:     "20240101-120000",
+		Dir:         "/tmp/out",
+		Restored:    []string{"src/main.go"},
+		Overwritten: []string{"src/main.go"},
+	}
+	tc.ConfigSvc = newMockConfigService()
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Errorf("Exit should not have been called")
+	}
+	want := recovery.RestoreOptions{Project: "myproject", Path: "src/main.go", Version: "latest", Target: "/tmp/out", Overwrite: true}
+	if mockRecovery.restoreOpts != want {
+		t.Errorf("opts = %+v, expected %+v", mockRecovery.restoreOpts, want)
+	}
+	output := tc.out.String()
+	for _, want := range []string{"Restored 1 file(s) from myproject 20240101-120000 to /tmp/out", "src/main.go (local changes overwritten)"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q, got %q", want, output)
+		}
+	}
+}
+
+func TestRunRestoreWouldOverwrite(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "restore", "myproject", "src"})
+	mockRecovery := newMockRecoveryService()
+	mockRecovery.restoreResult = recovery.RestoreResult{Version: "20240101-120000", Overwritten: []string{"src/a.go", "src/b.go"}}
+	mockRecovery.restoreErr = fmt.Errorf("%w: 2 file(s) differ from the backup", recovery.ErrWouldOverwrite)
+	tc.ConfigSvc = newMockConfigService()
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != 1 {
+		t.Errorf("expected Exit(1)")
+	}
+	output := tc.out.String()
+	for _, want := range []string{"2 local file(s) differ from myproject 20240101-120000", "src/a.go", "src/b.go", "--force"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q, got %q", want, output)
+		}
+	}
+}
+
+func TestRunRestoreUpToDate(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "restore", "myproject", "main.go"})
+	mockRecovery := newMockRecoveryService()
+	mockRecovery.restoreResult = recovery.RestoreResult{Version: "20240101-120000", Unchanged: []string{"main.go"}}
+	tc.ConfigSvc = newMockConfigService()
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Errorf("Exit should not have been called")
+	}
+	if !strings.Contains(tc.out.String(), "main.go already matches 20240101-120000") {
+		t.Errorf("expected up-to-date message, got %q", tc.out.String())
+	}
+}
+
+func TestRunRestoreDryRun(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "restore", "myproject", "src", "--dry-run"})
+	mockRecovery := newMockRecoveryService()
+	mockRecovery.restoreResult = recovery.RestoreResult{
+		Version:     "20240101-120000",
+		Dir:         "/src/myproject",
+		Restored:    []string{"src/a.go", "src/b.go", "src/c.go"},
+		Overwritten: []string{"src/a.go", "src/b.go"},
+		Newer:       []string{"src/b.go"},
+	}
+	tc.ConfigSvc = newMockConfigService()
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Errorf("Exit should not have been called")
+	}
+	if !mockRecovery.restoreOpts.DryRun {
+		t.Error("expected DryRun to be passed to Restore")
+	}
+	output := tc.out.String()
+	for _, want := range []string{
+		"Restoring myproject 20240101-120000 would write 3 file(s) in /src/myproject",
+		"~ src/a.go\n",
+		"~ src/b.go (newer locally)",
+		"+ src/c.go",
+		"needs --force",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q, got %q", want, output)
+		}
+	}
+}
+
+func TestRunRestoreUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"codebak", "restore", "myproject"},
+		{"codebak", "restore", "myproject", "main.go", "--overwrite"},
+	} {
+		tc := newTestCLI(args)
+		tc.ConfigSvc = newMockConfigService()
+		tc.RecoverySvc = newMockRecoveryService()
+
+		tc.Run()
+
+		if !tc.exitCalled || tc.exitCode != 1 || !strings.Contains(tc.out.String(), "Usage: codebak restore") {
+			t.Errorf("%v: expected usage and Exit(1), got %q", args[2:], tc.out.String())
+		}
+	}
+}
+
+// ============================================================================
+// Prune tests
+// ============================================================================
+
+func TestRunPruneDryRun(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "prune", "my-project", "--dry-run"})
+	mockCfg := newMockConfigService()
+	mockCfg.config.Retention = config.RetentionConfig{KeepLast: 1, KeepDaily: 7}
+	mockBackup := newMockBackupService()
+	mockBackup.pruneResults = []backup.PruneResult{{
+		Project:   "my-project",
+		Retention: mockCfg.config.Retention,
+		Versions: []backup.PruneVersion{
+			{Entry: manifest.BackupEntry{File: "20260102-120000.zip"}, Keep: true, Reasons: []string{"last", "daily"}},
+			{Entry: manifest.BackupEntry{File: "20260101-120000.zip"}},
+		},
+	}}
+	tc.ConfigSvc = mockCfg
+	tc.BackupSvc = mockBackup
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Fatalf("unexpected exit %d: %s", tc.exitCode, tc.errOut.String())
+	}
+	if mockBackup.pruneProject != "my-project" || !mockBackup.pruneDryRun {
+		t.Errorf("Prune called with %q dryRun=%v", mockBackup.pruneProject, mockBackup.pruneDryRun)
+	}
+	out := tc.out.String()
+	for _, want := range []string{
+		"Retention: keep_last 1, keep_daily 7",
+		"Dry run",
+		"my-project: keep 1 of 2 versions",
+		"20260102-120000    keep    last, daily",
+		"20260101-120000    remove",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output, got %q", want, out)
+		}
+	}
+}
+
+func TestRunPruneRemoves(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "prune"})
+	mockCfg := newMockConfigService()
+	mockCfg.config.Retention = config.RetentionConfig{KeepWithin: 48 * time.Hour}
+	mockBackup := newMockBackupService()
+	mockBackup.pruneResults = []backup.PruneResult{
+		{Project: "a", Retention: mockCfg.config.Retention, Versions: []backup.PruneVersion{{Keep: true}, {}}, Removed: []string{"20260101-120000.zip"}},
+		{Project: "b", Retention: config.RetentionConfig{KeepLast: 5}, Versions: []backup.PruneVersion{{Keep: true}}},
+	}
+	tc.ConfigSvc = mockCfg
+	tc.BackupSvc = mockBackup
+
+	tc.Run()
+
+	out := tc.out.String()
+	if !strings.Contains(out, "keep_within 48h0m0s") {
+		t.Errorf("expected policy summary, got %q", out)
+	}
+	if !strings.Contains(out, "* a: removed 1, kept 1") || !strings.Contains(out, "b (nothing to remove, 1 kept)") {
+		t.Errorf("expected per-project results, got %q", out)
+	}
+	if strings.Contains(out, "a overrides") || !strings.Contains(out, "b overrides retention: keep_last 5") {
+		t.Errorf("expected only b's retention override noted, got %q", out)
+	}
+	if mockBackup.pruneProject != "" || mockBackup.pruneDryRun {
+		t.Errorf("Prune called with %q dryRun=%v, expected all projects", mockBackup.pruneProject, mockBackup.pruneDryRun)
+	}
+}
+
+func TestRunPruneNoRetention(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "prune", "--dry-run"})
+	mockBackup := newMockBackupService()
+	mockBackup.pruneErr = backup.ErrNoRetention
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = mockBackup
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != 1 {
+		t.Errorf("expected Exit(1)")
+	}
+	if !strings.Contains(tc.errOut.String(), "under retention") {
+		t.Errorf("expected a hint to configure retention, got %q", tc.errOut.String())
+	}
+}
+
+func TestRunPruneUnknownFlag(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "prune", "--force"})
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = newMockBackupService()
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != 1 {
+		t.Errorf("expected Exit(1) for unknown flag")
+	}
+}
+
+func TestRunGCReportsIssues(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "fsck"})
+	mockBackup := newMockBackupService()
+	mockBackup.gcResults = []backup.GCResult{
+		{Project: "a"},
+		{Project: "b", Issues: []backup.Issue{
+			{Kind: backup.IssueOrphan, File: "20260101-120000.zip", Detail: "archive not in manifest"},
+			{Kind: backup.IssueTemp, File: "20260102-120000.zip.partial", Detail: "leftover"},
+		}},
+	}
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = mockBackup
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != 1 {
+		t.Errorf("expected Exit(1) while issues remain")
+	}
+	if mockBackup.gcProject != "" || mockBackup.gcFix {
+		t.Errorf("GC called with %q fix=%v, expected all projects without fixing", mockBackup.gcProject, mockBackup.gcFix)
+	}
+	out := tc.out.String()
+	for _, want := range []string{
+		"* a (ok)",
+		"! b: 2 issue(s)",
+		"orphan    20260101-120000.zip  archive not in manifest",
+		"temp      20260102-120000.zip.partial",
+		"codebak fsck --fix",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output, got %q", want, out)
+		}
+	}
+}
+
+func TestRunGCFix(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "gc", "my-project", "--fix"})
+	mockBackup := newMockBackupService()
+	mockBackup.gcResults = []backup.GCResult{{Project: "my-project", Issues: []backup.Issue{
+		{Kind: backup.IssueMissing, File: "20260101-120000.zip", Fixed: true},
+	}}}
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = mockBackup
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Fatalf("unexpected exit %d: %s", tc.exitCode, tc.errOut.String())
+	}
+	if mockBackup.gcProject != "my-project" || !mockBackup.gcFix {
+		t.Errorf("GC called with %q fix=%v", mockBackup.gcProject, mockBackup.gcFix)
+	}
+	if !strings.Contains(tc.out.String(), "(fixed)") {
+		t.Errorf("expected fixed issue, got %q", tc.out.String())
+	}
+}
+
+func TestRunGCUnknownFlag(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "gc", "--force"})
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = newMockBackupService()
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != 1 {
+		t.Errorf("expected Exit(1)")
+	}
+	if !strings.Contains(tc.out.String(), "Usage: codebak gc") {
+		t.Errorf("expected usage, got %q", tc.out.String())
+	}
+}
+
+func TestRunMigrate(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "migrate"})
+	mockBackup := newMockBackupService()
+	mockBackup.migrateResults = []backup.MigrateResult{
+		{Project: "a", From: manifest.SchemaVersion},
+		{Project: "b", From: 0, Backup: "/backups/b/manifest.json.v0.bak"},
+		{Project: "c", From: 99, Error: manifest.ErrNewerSchema},
+	}
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = mockBackup
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != 1 {
+		t.Errorf("expected Exit(1) when a manifest cannot be migrated")
+	}
+	out := tc.out.String()
+	for _, want := range []string{
+		"a (up to date)",
+		fmt.Sprintf("* b: schema 0 -> %d (original kept as manifest.json.v0.bak)", manifest.SchemaVersion),
+		"x c: manifest was written by a newer version of codebak",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output, got %q", want, out)
+		}
+	}
+}
+
+func TestRunConfigShow(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "config", "show", "monorepo"})
+	mockCfg := newMockConfigService()
+	mockCfg.config.Exclude = []string{"node_modules"}
+	mockCfg.config.Retention = config.RetentionConfig{KeepLast: 30}
+	disabled := false
+	mockCfg.config.Overrides = map[string]config.Override{
+		"monorepo": {Retention: &config.RetentionConfig{KeepLast: 5}, Enabled: &disabled},
+	}
+	mockBackup := newMockBackupService()
+	mockBackup.effectiveSource = &config.Source{Path: "~/work", Override: config.Override{
+		Exclude:     []string{"vendor"},
+		Compression: config.CompressionBest,
+	}}
+	tc.ConfigSvc = mockCfg
+	tc.BackupSvc = mockBackup
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Fatalf("unexpected exit %d: %s", tc.exitCode, tc.errOut.String())
+	}
+	out := tc.out.String()
+	for _, want := range []string{
+		"Effective config for monorepo",
+		"Source:      ~/work",
+		"Enabled:     no",
+		"Retention:   keep_last 5",
+		"Compression: best",
+		"Exclude:     node_modules, vendor",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output, got %q", want, out)
+		}
+	}
+}
+
+func TestRunConfigUsage(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "config"})
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = newMockBackupService()
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != 1 {
+		t.Errorf("expected Exit(1) without a subcommand")
+	}
+	if !strings.Contains(tc.out.String(), "Usage: codebak config show <project>") {
+		t.Errorf("expected usage, got %q", tc.out.String())
+	}
+}
+
+func TestRunPinLatest(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "pin", "my-project", "latest"})
+	mockBackup := newMockBackupService()
+	mockBackup.annotateEntry = manifest.BackupEntry{File: "20260102-120000.zip"}
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = mockBackup
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Fatalf("unexpected exit %d: %s", tc.exitCode, tc.errOut.String())
+	}
+	if mockBackup.annotateProject != "my-project" || mockBackup.annotateVersion != "latest" {
+		t.Errorf("Annotate called with %q %q, expected latest", mockBackup.annotateProject, mockBackup.annotateVersion)
+	}
+	if p := mockBackup.annotation.Pinned; p == nil || !*p {
+		t.Errorf("annotation = %+v, expected Pinned true", mockBackup.annotation)
+	}
+	if !strings.Contains(tc.out.String(), "Pinned my-project 20260102-120000") {
+		t.Errorf("expected confirmation, got %q", tc.out.String())
+	}
+}
+
+func TestRunTagAndNote(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "tag", "my-project", "20260102-120000", "old", "--remove"})
+	mockBackup := newMockBackupService()
+	mockBackup.annotateEntry = manifest.BackupEntry{File: "20260102-120000.zip", Labels: []string{"old", "release"}}
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = mockBackup
+
+	tc.Run()
+
+	if len(mockBackup.annotation.RemoveLabels) != 1 || len(mockBackup.annotation.AddLabels) != 0 {
+		t.Errorf("annotation = %+v, expected old to be removed", mockBackup.annotation)
+	}
+	if !strings.Contains(tc.out.String(), "Labels on my-project 20260102-120000: release") {
+		t.Errorf("expected remaining labels, got %q", tc.out.String())
+	}
+
+	tc = newTestCLI([]string{"codebak", "note", "my-project", "20260102-120000", "before", "the", "refactor"})
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = mockBackup
+
+	tc.Run()
+
+	if n := mockBackup.annotation.Note; n == nil || *n != "before the refactor" {
+		t.Errorf("annotation = %+v, expected the joined note", mockBackup.annotation)
+	}
+	if !strings.Contains(tc.out.String(), "Note on my-project 20260102-120000: before the refactor") {
+		t.Errorf("expected note confirmation, got %q", tc.out.String())
+	}
+}
+
+func TestRunAnnotateErrors(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "tag", "my-project", "20260102-120000"})
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = newMockBackupService()
+
+	tc.Run()
+
+	if !tc.exitCalled || !strings.Contains(tc.out.String(), "Usage: codebak tag") {
+		t.Errorf("expected tag usage without a label, got %q", tc.out.String())
+	}
+
+	tc = newTestCLI([]string{"codebak", "unpin", "my-project", "20990101-000000"})
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = newMockBackupService()
+
+	tc.Run()
+
+	if !tc.exitCalled || !strings.Contains(tc.errOut.String(), "version not found") {
+		t.Errorf("expected an error for an unknown version, got %q", tc.errOut.String())
+	}
+}
+
+// ============================================================================
+// ListBackups tests
+// ============================================================================
+
+func TestListBackupsSuccess(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "list", "myproject"})
+	mockCfg := newMockConfigService()
+	mockRecovery := newMockRecoveryService()
+	mockRecovery.listVersions = []manifest.BackupEntry{
+		{
+			File:      "20240101-120000.zip",
+			SizeBytes: 1024,
+			FileCount: 10,
+			GitHead:   "abc1234567890",
+			CreatedAt: time.Now(),
+		},
+		{
+			File:      "20240102-120000.zip",
+			SizeBytes: 2048,
+			FileCount: 20,
+			GitHead:   "",
+			CreatedAt: time.Now(),
+			Pinned:    true,
+			Labels:    []string{"release", "v2"},
+			Note:      "before the big refactor",
+		},
+	}
+	tc.ConfigSvc = mockCfg
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Errorf("Exit should not have been called")
+	}
+	output := tc.out.String()
+	if !strings.Contains(output, "Backups for myproject") {
+		t.Errorf("expected header, got %q", output)
+	}
+	if !strings.Contains(output, "20240101-120000") {
+		t.Errorf("expected version, got %q", output)
 	}
 	if !strings.Contains(output, "abc1234") {
 		t.Errorf("expected truncated git head, got %q", output)
 	}
+	if !strings.Contains(output, "- pinned [release, v2]") || !strings.Contains(output, "before the big refactor") {
+		t.Errorf("expected pin, labels and note, got %q", output)
+	}
+}
+
+func TestListBackupsLong(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "list", "myproject", "--long"})
+	mockRecovery := newMockRecoveryService()
+	mockRecovery.listVersions = []manifest.BackupEntry{
+		{File: "20240101-120000.zip", CreatedAt: time.Now()},
+		{
+			File:      "20240102-120000.zip",
+			CreatedAt: time.Now(),
+			Provenance: &manifest.Provenance{
+				Hostname:       "laptop",
+				User:           "dev",
+				CodebakVersion: "1.4.0",
+				Branch:         "main",
+				Dirty:          true,
+				Duration:       2300 * time.Millisecond,
+				Trigger:        "schedule",
+				ConfigHash:     "3f2a1b9c0d4e5f60718293a4b5c6d7e8",
+			},
+		},
+	}
+	tc.ConfigSvc = newMockConfigService()
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Errorf("Exit should not have been called")
+	}
+	output := tc.out.String()
+	if !strings.Contains(output, "no provenance recorded") {
+		t.Errorf("expected the older version marked, got %q", output)
+	}
+	want := "host laptop  user dev  codebak 1.4.0  branch main (dirty)  trigger schedule  took 2.3s  config 3f2a1b9c0d4e"
+	if !strings.Contains(output, want) {
+		t.Errorf("expected provenance %q, got %q", want, output)
+	}
+}
+
+func TestListBackupsUnknownFlag(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "list", "myproject", "--wide"})
+	tc.ConfigSvc = newMockConfigService()
+	tc.RecoverySvc = newMockRecoveryService()
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != 1 {
+		t.Errorf("expected Exit(1)")
+	}
+	if !strings.Contains(tc.errOut.String(), "Unknown flag: --wide") {
+		t.Errorf("expected unknown flag error, got %q", tc.errOut.String())
+	}
 }
 
 func TestListBackupsEmpty(t *testing.T) {
@@ -1417,6 +2812,51 @@ func TestListBackupsWithExactly7CharGitHead(t *testing.T) {
 	}
 }
 
+func TestFindFile(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "find", "my-project", "src/main.go"})
+	mockRecovery := newMockRecoveryService()
+	mockRecovery.fileHistory = []recovery.FileVersion{
+		{Version: "20260101-120000", Size: 2048, Changed: true},
+		{Version: "20260102-120000", Size: 2048, SHA256: "0123456789abcdef0123"},
+	}
+	tc.ConfigSvc = newMockConfigService()
+	tc.RecoverySvc = mockRecovery
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Fatalf("unexpected exit %d: %s", tc.exitCode, tc.errOut.String())
+	}
+	if mockRecovery.fileHistoryPath != "src/main.go" {
+		t.Errorf("FileHistory called with %q", mockRecovery.fileHistoryPath)
+	}
+	out := tc.out.String()
+	for _, want := range []string{
+		"src/main.go in my-project (2 versions)",
+		"20260101-120000          2.0 KB - changed",
+		"20260102-120000          2.0 KB 0123456789ab",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output, got %q", want, out)
+		}
+	}
+}
+
+func TestFindFileNotFound(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "find", "my-project", "gone.go"})
+	tc.ConfigSvc = newMockConfigService()
+	tc.RecoverySvc = newMockRecoveryService()
+
+	tc.Run()
+
+	if !tc.exitCalled || tc.exitCode != 1 {
+		t.Errorf("expected Exit(1)")
+	}
+	if !strings.Contains(tc.out.String(), "gone.go is not in any backup of my-project") {
+		t.Errorf("expected not-found message, got %q", tc.out.String())
+	}
+}
+
 // ============================================================================
 // Helper function tests
 // ============================================================================
@@ -1567,3 +3007,116 @@ func TestDefaultLaunchdServiceStatus(t *testing.T) {
 	svc := &defaultLaunchdService{}
 	_, _ = svc.Status() // Just ensure it doesn't panic
 }
+
+func TestRunBackupJobsFlag(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "run", "--jobs", "4"})
+	mockBackup := newMockBackupService()
+	mockBackup.backupResults = []backup.BackupResult{
+		{Project: "project-a", Size: 1024, FileCount: 3},
+		{Project: "project-b", Skipped: true, Reason: "no changes"},
+	}
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = mockBackup
+
+	tc.Run()
+
+	if tc.exitCalled {
+		t.Errorf("Exit should not have been called")
+	}
+	if mockBackup.runCfg == nil || mockBackup.runCfg.GetJobs() != 4 {
+		t.Errorf("expected config with 4 jobs, got %+v", mockBackup.runCfg)
+	}
+	out := tc.out.String()
+	if strings.Index(out, "project-a") > strings.Index(out, "project-b") {
+		t.Errorf("results should be printed as they arrive, got %q", out)
+	}
+	if !strings.Contains(out, "1 backed up") || !strings.Contains(out, "1 skipped") {
+		t.Errorf("expected summary in output, got %q", out)
+	}
+}
+
+func TestRunBackupInvalidJobs(t *testing.T) {
+	for _, arg := range []string{"--jobs=0", "--jobs=many", "--jobs"} {
+		tc := newTestCLI([]string{"codebak", "run", arg})
+		tc.ConfigSvc = newMockConfigService()
+		tc.BackupSvc = newMockBackupService()
+
+		tc.Run()
+
+		if !tc.exitCalled || tc.exitCode != 1 {
+			t.Errorf("%s: expected Exit(1)", arg)
+		}
+		if !strings.Contains(tc.errOut.String(), "--jobs") {
+			t.Errorf("%s: expected --jobs error, got %q", arg, tc.errOut.String())
+		}
+	}
+}
+
+func TestSplitJobsFlag(t *testing.T) {
+	rest, jobs, err := splitJobsFlag([]string{"--jobs", "8", "myproject"})
+	if err != nil {
+		t.Fatalf("splitJobsFlag failed: %v", err)
+	}
+	if jobs != 8 || len(rest) != 1 || rest[0] != "myproject" {
+		t.Errorf("got rest=%v jobs=%d, expected [myproject] 8", rest, jobs)
+	}
+
+	rest, jobs, err = splitJobsFlag([]string{"--jobs=2"})
+	if err != nil || jobs != 2 || len(rest) != 0 {
+		t.Errorf("got rest=%v jobs=%d err=%v, expected [] 2", rest, jobs, err)
+	}
+
+	_, jobs, _ = splitJobsFlag(nil)
+	if jobs != 0 {
+		t.Errorf("jobs = %d, expected 0 when flag is absent", jobs)
+	}
+}
+
+func TestRunBackupShowsProgress(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "run"})
+	tc.ShowProgress = true
+	mockBackup := newMockBackupService()
+	mockBackup.backupResults = []backup.BackupResult{
+		{Project: "project-a", Size: 1024, FileCount: 2},
+	}
+	mockBackup.progress = []ports.Progress{
+		{Files: 1, Bytes: 512, Path: "main.go", FilesTotal: 2, BytesTotal: 1024},
+		{Files: 2, Bytes: 1024, Path: "go.mod", FilesTotal: 2, BytesTotal: 1024},
+	}
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = mockBackup
+
+	tc.Run()
+
+	out := tc.out.String()
+	line := strings.Index(out, "~ project-a [##########----------]  50%  1/2 files")
+	if line < 0 {
+		t.Fatalf("expected progress line in output, got %q", out)
+	}
+	if !strings.Contains(out, "main.go") {
+		t.Errorf("progress line should show the current path, got %q", out)
+	}
+	// Updates closer together than progressInterval are dropped
+	if strings.Contains(out, "go.mod") {
+		t.Errorf("expected second update to be throttled, got %q", out)
+	}
+	result := strings.Index(out, "* project-a")
+	if result < line || !strings.Contains(out[line:result], "\r\033[K") {
+		t.Errorf("progress line should be cleared before the result, got %q", out)
+	}
+}
+
+func TestRunBackupNoProgressWhenNotTerminal(t *testing.T) {
+	tc := newTestCLI([]string{"codebak", "run", "project-a"})
+	mockBackup := newMockBackupService()
+	mockBackup.singleResult = backup.BackupResult{Size: 1024, FileCount: 1}
+	mockBackup.progress = []ports.Progress{{Files: 1, Bytes: 1024, FilesTotal: 1, BytesTotal: 1024}}
+	tc.ConfigSvc = newMockConfigService()
+	tc.BackupSvc = mockBackup
+
+	tc.Run()
+
+	if out := tc.out.String(); strings.Contains(out, "\r") || strings.Contains(out, "~ project-a") {
+		t.Errorf("progress should only be drawn on a terminal, got %q", out)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"time"
 
+	"github.com/jmcdonald/codebak/internal/retention"
 	"gopkg.in/yaml.v3"
 )
 
@@ -15,12 +18,37 @@ var ErrNoHomeDir = fmt.Errorf("cannot determine home directory: HOME environment
 type SourceType string
 
 const (
-	// SourceTypeGit backs up using git bundles (default for code directories)
+	// SourceTypeGit backs up the working tree, plus a git bundle with
+	// git_bundle enabled (default for code directories)
 	SourceTypeGit SourceType = "git"
 	// SourceTypeSensitive backs up using restic for encrypted incremental backups
 	SourceTypeSensitive SourceType = "sensitive"
 )
 
+// StorageFormat defines how backup versions of git sources are stored on disk
+type StorageFormat string
+
+const (
+	// StorageZip stores every version as a self-contained zip archive (default)
+	StorageZip StorageFormat = "zip"
+	// StorageChunked stores versions as small indexes over deduplicated content chunks
+	StorageChunked StorageFormat = "chunked"
+)
+
+// Compression selects how hard backup archives are compressed
+type Compression string
+
+const (
+	// CompressionDefault balances size and speed (default)
+	CompressionDefault Compression = "default"
+	// CompressionNone stores files uncompressed, for already-compressed data
+	CompressionNone Compression = "none"
+	// CompressionFast favours speed over size
+	CompressionFast Compression = "fast"
+	// CompressionBest favours size over speed
+	CompressionBest Compression = "best"
+)
+
 // DefaultSensitivePaths returns the default paths to back up with restic encryption.
 // These are common dotfiles and config directories containing sensitive data.
 func DefaultSensitivePaths() []string {
@@ -48,6 +76,30 @@ type Source struct {
 	Label string     `yaml:"label,omitempty"` // Display label (defaults to path basename)
 	Icon  string     `yaml:"icon,omitempty"`  // Emoji icon for TUI display
 	Type  SourceType `yaml:"type,omitempty"`  // Backup type: git (default) or sensitive
+	// Override customises the backups of every project in this source
+	Override `yaml:",inline"`
+}
+
+// Override customises backup settings for the projects of a source, or for a
+// single project under overrides. Unset fields inherit the global settings.
+type Override struct {
+	// Exclude adds patterns to the global list; "!pattern" re-includes a path
+	Exclude []string `yaml:"exclude,omitempty"`
+	// Retention replaces the global retention rules
+	Retention   *RetentionConfig `yaml:"retention,omitempty"`
+	Compression Compression      `yaml:"compression,omitempty"`
+	// Enabled set to false leaves the projects out of backups
+	Enabled *bool `yaml:"enabled,omitempty"`
+}
+
+// Effective is the resolved configuration for backing up one project.
+type Effective struct {
+	Project     string
+	Source      string // Path of the source containing the project, if known
+	Exclude     []string
+	Retention   RetentionConfig
+	Compression Compression
+	Enabled     bool
 }
 
 // ResticConfig holds configuration for restic encrypted backups.
@@ -60,23 +112,126 @@ type ResticConfig struct {
 	PasswordEnvVar string `yaml:"password_env_var,omitempty"`
 }
 
+// RetentionConfig holds the rules deciding which zip and chunked backup
+// versions are kept. Zero values disable a rule; with no rules every version
+// is kept. See the retention package for how the rules combine.
+type RetentionConfig struct {
+	KeepLast    int `yaml:"keep_last"`
+	KeepHourly  int `yaml:"keep_hourly,omitempty"`
+	KeepDaily   int `yaml:"keep_daily,omitempty"`
+	KeepWeekly  int `yaml:"keep_weekly,omitempty"`
+	KeepMonthly int `yaml:"keep_monthly,omitempty"`
+	KeepYearly  int `yaml:"keep_yearly,omitempty"`
+	// KeepWithin keeps every version made within this long of the newest (e.g. "168h")
+	KeepWithin time.Duration `yaml:"keep_within,omitempty"`
+}
+
+// Policy converts the configured rules for the retention package.
+func (r RetentionConfig) Policy() retention.Policy {
+	return retention.Policy{
+		Last:    r.KeepLast,
+		Hourly:  r.KeepHourly,
+		Daily:   r.KeepDaily,
+		Weekly:  r.KeepWeekly,
+		Monthly: r.KeepMonthly,
+		Yearly:  r.KeepYearly,
+		Within:  r.KeepWithin,
+	}
+}
+
 type Config struct {
 	// Deprecated: Use Sources instead. Kept for backwards compatibility.
 	SourceDir string   `yaml:"source_dir,omitempty"`
 	Sources   []Source `yaml:"sources,omitempty"`
 	// Individual projects outside source dirs (a la carte)
-	Projects  []string `yaml:"projects,omitempty"`
-	BackupDir string   `yaml:"backup_dir"`
-	Schedule  string   `yaml:"schedule"`
-	Time      string   `yaml:"time"`
-	Exclude   []string `yaml:"exclude"`
-	Retention struct {
-		KeepLast int `yaml:"keep_last"`
-	} `yaml:"retention"`
+	Projects  []string        `yaml:"projects,omitempty"`
+	BackupDir string          `yaml:"backup_dir"`
+	Schedule  string          `yaml:"schedule"`
+	Time      string          `yaml:"time"`
+	Exclude   []string        `yaml:"exclude"` // gitignore-style patterns
+	Retention RetentionConfig `yaml:"retention"`
+	// GitBundle also stores a git bundle of each repository's full history,
+	// branches and stashes with every backup version
+	GitBundle bool `yaml:"git_bundle,omitempty"`
+	// RespectGitignore also excludes files matched by each project's .gitignore files
+	RespectGitignore bool `yaml:"respect_gitignore,omitempty"`
+	// Storage selects the on-disk format for git source backups: zip (default) or chunked
+	Storage StorageFormat `yaml:"storage,omitempty"`
+	// LockWait is how long to wait for another codebak process to release the
+	// backup directory (e.g. "5m"); 0 fails immediately, negative waits forever
+	LockWait time.Duration `yaml:"lock_wait,omitempty"`
+	// Jobs is how many projects a full run backs up concurrently (default 1)
+	Jobs int `yaml:"jobs,omitempty"`
+	// FailOnSkip fails a project's backup, recording no version, when any of
+	// its files cannot be read
+	FailOnSkip bool `yaml:"fail_on_skip,omitempty"`
+	// Compression selects how hard archives are compressed: default, none, fast or best
+	Compression Compression `yaml:"compression,omitempty"`
+	// Overrides customises the settings of individual projects, by project name
+	Overrides map[string]Override `yaml:"overrides,omitempty"`
 	// Restic configuration for sensitive path backups
 	Restic ResticConfig `yaml:"restic,omitempty"`
 }
 
+// GetStorage returns the configured storage format, defaulting to zip.
+func (c *Config) GetStorage() StorageFormat {
+	if c.Storage == "" {
+		return StorageZip
+	}
+	return c.Storage
+}
+
+// GetCompression returns the configured compression, defaulting to CompressionDefault.
+func (c *Config) GetCompression() Compression {
+	if c.Compression == "" {
+		return CompressionDefault
+	}
+	return c.Compression
+}
+
+// Effective resolves the settings for project, found in source (nil when the
+// project is not in a configured source). The project's entry under
+// overrides takes precedence over the source, which takes precedence over
+// the global settings. Exclude patterns accumulate in that order.
+func (c *Config) Effective(project string, source *Source) Effective {
+	eff := Effective{
+		Project:     project,
+		Exclude:     append([]string(nil), c.Exclude...),
+		Retention:   c.Retention,
+		Compression: c.GetCompression(),
+		Enabled:     true,
+	}
+	var layers []Override
+	if source != nil {
+		eff.Source = source.Path
+		layers = append(layers, source.Override)
+	}
+	if o, ok := c.Overrides[project]; ok {
+		layers = append(layers, o)
+	}
+	for _, o := range layers {
+		eff.Exclude = append(eff.Exclude, o.Exclude...)
+		if o.Retention != nil {
+			eff.Retention = *o.Retention
+		}
+		if o.Compression != "" {
+			eff.Compression = o.Compression
+		}
+		if o.Enabled != nil {
+			eff.Enabled = *o.Enabled
+		}
+	}
+	return eff
+}
+
+// GetJobs returns the number of concurrent backup workers, at least 1.
+func (c *Config) GetJobs() int {
+	if c.Jobs < 1 {
+		return 1
+	}
+	return c.Jobs
+}
+
 // GetSources returns all sources, migrating from SourceDir if needed
 func (c *Config) GetSources() []Source {
 	// If new Sources format is used, return it with defaults applied
@@ -167,6 +322,61 @@ func IsValidSourceType(t SourceType) bool {
 	return t == SourceTypeGit || t == SourceTypeSensitive
 }
 
+// IsValidStorageFormat checks if a storage format is valid
+func IsValidStorageFormat(f StorageFormat) bool {
+	return f == StorageZip || f == StorageChunked
+}
+
+// IsValidCompression checks if a compression setting is valid
+func IsValidCompression(c Compression) bool {
+	return c == CompressionDefault || c == CompressionNone || c == CompressionFast || c == CompressionBest
+}
+
+// CheckStorage returns an error naming the valid formats when f is set to
+// anything else. Unset means zip.
+func CheckStorage(f StorageFormat) error {
+	if f == "" || IsValidStorageFormat(f) {
+		return nil
+	}
+	return fmt.Errorf("invalid storage %q: must be %s or %s", f, StorageZip, StorageChunked)
+}
+
+// CheckCompression returns an error naming the allowed values when c is set
+// to anything else. Unset means the default.
+func CheckCompression(c Compression) error {
+	if c == "" || IsValidCompression(c) {
+		return nil
+	}
+	return fmt.Errorf("invalid compression %q: must be one of %s, %s, %s or %s",
+		c, CompressionDefault, CompressionNone, CompressionFast, CompressionBest)
+}
+
+// validate checks the settings that have no sensible fallback.
+func (c *Config) validate() error {
+	if err := CheckStorage(c.Storage); err != nil {
+		return err
+	}
+	if err := CheckCompression(c.Compression); err != nil {
+		return err
+	}
+	for _, s := range c.Sources {
+		if err := CheckCompression(s.Compression); err != nil {
+			return fmt.Errorf("source %s: %w", s.Path, err)
+		}
+	}
+	names := make([]string, 0, len(c.Overrides))
+	for name := range c.Overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := CheckCompression(c.Overrides[name].Compression); err != nil {
+			return fmt.Errorf("override %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
 func DefaultConfig() (*Config, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -194,9 +404,7 @@ func DefaultConfig() (*Config, error) {
 			"dist",
 			"build",
 		},
-		Retention: struct {
-			KeepLast int `yaml:"keep_last"`
-		}{KeepLast: 30},
+		Retention: RetentionConfig{KeepLast: 30},
 	}, nil
 }
 
@@ -230,6 +438,9 @@ func Load() (*Config, error) {
 	if err := yaml.Unmarshal(data, cfg); err != nil {
 		return nil, err
 	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
 
 	return cfg, nil
 }
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -174,9 +175,7 @@ func TestSaveConfig(t *testing.T) {
 		Schedule:  "hourly",
 		Time:      "00:00",
 		Exclude:   []string{"test_exclude"},
-		Retention: struct {
-			KeepLast int `yaml:"keep_last"`
-		}{KeepLast: 5},
+		Retention: RetentionConfig{KeepLast: 5},
 	}
 
 	if err := cfg.Save(); err != nil {
@@ -1070,3 +1069,238 @@ restic:
 		t.Errorf("Restic.PasswordEnvVar = %q, expected %q", cfg.Restic.PasswordEnvVar, "CUSTOM_PW_VAR")
 	}
 }
+
+func TestGetStorage(t *testing.T) {
+	cfg := &Config{}
+	if got := cfg.GetStorage(); got != StorageZip {
+		t.Errorf("GetStorage() default = %q, expected %q", got, StorageZip)
+	}
+
+	cfg.Storage = StorageChunked
+	if got := cfg.GetStorage(); got != StorageChunked {
+		t.Errorf("GetStorage() = %q, expected %q", got, StorageChunked)
+	}
+}
+
+func TestGetJobs(t *testing.T) {
+	tests := []struct {
+		jobs     int
+		expected int
+	}{
+		{0, 1},
+		{-3, 1},
+		{1, 1},
+		{8, 8},
+	}
+	for _, tt := range tests {
+		cfg := &Config{Jobs: tt.jobs}
+		if got := cfg.GetJobs(); got != tt.expected {
+			t.Errorf("GetJobs() with Jobs=%d = %d, expected %d", tt.jobs, got, tt.expected)
+		}
+	}
+}
+
+func TestIsValidStorageFormat(t *testing.T) {
+	tests := []struct {
+		input    StorageFormat
+		expected bool
+	}{
+		{StorageZip, true},
+		{StorageChunked, true},
+		{"tar", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.input), func(t *testing.T) {
+			if got := IsValidStorageFormat(tt.input); got != tt.expected {
+				t.Errorf("IsValidStorageFormat(%q) = %v, expected %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadLockWait(t *testing.T) {
+	tempDir := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", origHome)
+
+	configDir := filepath.Join(tempDir, ".codebak")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	configContent := "backup_dir: /custom/backup\nlock_wait: 5m\n"
+	if err := os.WriteFile(filepath.Join(configDir, "config.yaml"), []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.LockWait != 5*time.Minute {
+		t.Errorf("LockWait = %v, expected 5m", cfg.LockWait)
+	}
+}
+
+func TestLoadRespectGitignore(t *testing.T) {
+	tempDir := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", origHome)
+
+	configDir := filepath.Join(tempDir, ".codebak")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	configContent := "backup_dir: /custom/backup\nrespect_gitignore: true\nexclude:\n  - docs/build\n"
+	if err := os.WriteFile(filepath.Join(configDir, "config.yaml"), []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !cfg.RespectGitignore {
+		t.Error("RespectGitignore should be true")
+	}
+	if len(cfg.Exclude) != 1 || cfg.Exclude[0] != "docs/build" {
+		t.Errorf("Exclude = %v, expected [docs/build]", cfg.Exclude)
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	tempDir := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", origHome)
+
+	configDir := filepath.Join(tempDir, ".codebak")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	configContent := `backup_dir: /custom/backup
+sources:
+  - path: ~/work
+    exclude: [vendor]
+    compression: best
+    retention:
+      keep_last: 5
+overrides:
+  dotfiles:
+    enabled: false
+`
+	if err := os.WriteFile(filepath.Join(configDir, "config.yaml"), []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	source := cfg.Sources[0]
+	if source.Path != "~/work" || len(source.Exclude) != 1 || source.Compression != CompressionBest {
+		t.Errorf("source = %+v, expected inline overrides", source)
+	}
+	if source.Retention == nil || source.Retention.KeepLast != 5 {
+		t.Errorf("source.Retention = %+v, expected keep_last 5", source.Retention)
+	}
+	if o := cfg.Overrides["dotfiles"]; o.Enabled == nil || *o.Enabled {
+		t.Errorf("overrides[dotfiles] = %+v, expected enabled: false", o)
+	}
+}
+
+func TestEffective(t *testing.T) {
+	disabled := false
+	cfg := &Config{
+		Exclude:   []string{"node_modules"},
+		Retention: RetentionConfig{KeepLast: 30},
+		Overrides: map[string]Override{
+			"monorepo": {Exclude: []string{"!dist"}, Compression: CompressionNone},
+			"scratch":  {Enabled: &disabled},
+		},
+	}
+	source := &Source{Path: "~/work", Override: Override{
+		Exclude:     []string{"dist"},
+		Retention:   &RetentionConfig{KeepDaily: 7},
+		Compression: CompressionFast,
+	}}
+
+	// Global settings only
+	eff := cfg.Effective("other", nil)
+	if eff.Source != "" || !eff.Enabled || eff.Compression != CompressionDefault || eff.Retention.KeepLast != 30 {
+		t.Errorf("global effective = %+v", eff)
+	}
+
+	// Source overrides the global settings, the project overrides the source
+	eff = cfg.Effective("monorepo", source)
+	if got := strings.Join(eff.Exclude, ","); got != "node_modules,dist,!dist" {
+		t.Errorf("Exclude = %s, expected global, source then project patterns", got)
+	}
+	if eff.Retention != (RetentionConfig{KeepDaily: 7}) {
+		t.Errorf("Retention = %+v, expected the source's rules", eff.Retention)
+	}
+	if eff.Compression != CompressionNone || eff.Source != "~/work" || !eff.Enabled {
+		t.Errorf("effective = %+v, expected project compression and source path", eff)
+	}
+
+	if cfg.Effective("scratch", source).Enabled {
+		t.Error("scratch should be disabled by its override")
+	}
+	if len(cfg.Exclude) != 1 {
+		t.Errorf("Effective modified the global excludes: %v", cfg.Exclude)
+	}
+}
+
+func TestIsValidCompression(t *testing.T) {
+	for _, c := range []Compression{CompressionDefault, CompressionNone, CompressionFast, CompressionBest} {
+		if !IsValidCompression(c) {
+			t.Errorf("IsValidCompression(%q) = false", c)
+		}
+	}
+	if IsValidCompression("zstd") {
+		t.Error("IsValidCompression(zstd) = true")
+	}
+}
+
+func TestLoadRejectsInvalidCompression(t *testing.T) {
+	for name, content := range map[string]string{
+		"global":   "compression: zstd\n",
+		"source":   "sources:\n  - path: ~/work\n    compression: zstd\n",
+		"override": "overrides:\n  monorepo:\n    compression: zstd\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := loadYAML(t, content)
+			if err == nil || !strings.Contains(err.Error(), `invalid compression "zstd": must be one of default, none, fast or best`) {
+				t.Errorf("Load err = %v, expected the allowed values to be named", err)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsInvalidStorage(t *testing.T) {
+	err := loadYAML(t, "storage: chunk\n")
+	if err == nil || !strings.Contains(err.Error(), `invalid storage "chunk": must be zip or chunked`) {
+		t.Errorf("Load err = %v, expected the valid formats to be named", err)
+	}
+	if err := loadYAML(t, "storage: chunked\n"); err != nil {
+		t.Errorf("Load failed for a valid storage: %v", err)
+	}
+}
+
+// loadYAML loads content as the config file of a temporary home directory.
+func loadYAML(t *testing.T, content string) error {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".codebak"), 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".codebak", "config.yaml"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	_, err := Load()
+	return err
+}
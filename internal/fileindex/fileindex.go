@@ -0,0 +1,156 @@
+// Package fileindex stores a per-version listing of every backed-up file.
+//
+// Each backup version gets a small JSON index under files/ in the project's
+// backup directory, next to the manifest, recording the path, size, SHA-256,
+// mode and modification time of every file it contains. Diffs, file history
+// lookups and restore checks read the index instead of opening the archive.
+package fileindex
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jmcdonald/codebak/internal/atomicfile"
+)
+
+// DirName is the directory, inside a project's backup directory, that holds
+// the file indexes.
+const DirName = "files"
+
+// formatVersion is bumped when the index layout changes incompatibly.
+const formatVersion = 1
+
+// File describes one file of a backup version.
+type File struct {
+	Path    string      `json:"path"` // Relative to the project, slash-separated
+	Size    int64       `json:"size"`
+	SHA256  string      `json:"sha256"`
+	Mode    os.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mod_time"`
+}
+
+// Index lists the files of one backup version, sorted by path.
+type Index struct {
+	Format  int    `json:"format"`
+	Version string `json:"version"` // YYYYMMDD-HHMMSS
+	Files   []File `json:"files"`
+}
+
+// Path returns where the index of a project's version is stored.
+func Path(backupDir, project, version string) string {
+	return filepath.Join(backupDir, project, DirName, version+".json")
+}
+
+// Write atomically stores the index of a backup version.
+func Write(backupDir, project, version string, files []File) error {
+	sorted := append([]File(nil), files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+	if sorted == nil {
+		sorted = []File{}
+	}
+
+	data, err := json.MarshalIndent(Index{Format: formatVersion, Version: version, Files: sorted}, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := Path(backupDir, project, version)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0644)
+}
+
+// Load reads the index of a project's version. Versions made before indexes
+// existed have none; the error then satisfies os.IsNotExist.
+func Load(backupDir, project, version string) (*Index, error) {
+	path := Path(backupDir, project, version)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("parsing file index %s: %w", filepath.Base(path), err)
+	}
+	if idx.Format > formatVersion {
+		return nil, fmt.Errorf("file index %s uses unsupported format %d", filepath.Base(path), idx.Format)
+	}
+	return &idx, nil
+}
+
+// Remove deletes the index of a project's version, if there is one.
+func Remove(backupDir, project, version string) error {
+	err := os.Remove(Path(backupDir, project, version))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Lookup returns the entry for path, if the version contains it.
+func (idx *Index) Lookup(path string) (File, bool) {
+	i := sort.Search(len(idx.Files), func(i int) bool { return idx.Files[i].Path >= path })
+	if i < len(idx.Files) && idx.Files[i].Path == path {
+		return idx.Files[i], true
+	}
+	return File{}, false
+}
+
+// Mismatch is a file whose restored copy differs from the index.
+type Mismatch struct {
+	Path   string
+	Reason string // "missing", "size" or "checksum"
+}
+
+// Check compares the files under dir, a restored copy of the version, with
+// the index. Files in dir that the index does not list are ignored, so a
+// restore over an existing tree or with git history added still passes.
+func (idx *Index) Check(dir string) ([]Mismatch, error) {
+	var mismatches []Mismatch
+	for _, f := range idx.Files {
+		path := filepath.Join(dir, filepath.FromSlash(f.Path))
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				mismatches = append(mismatches, Mismatch{Path: f.Path, Reason: "missing"})
+				continue
+			}
+			return nil, err
+		}
+		if info.Size() != f.Size {
+			mismatches = append(mismatches, Mismatch{Path: f.Path, Reason: "size"})
+			continue
+		}
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return nil, err
+		}
+		if sum != f.SHA256 {
+			mismatches = append(mismatches, Mismatch{Path: f.Path, Reason: "checksum"})
+		}
+	}
+	return mismatches, nil
+}
+
+// fileSHA256 returns the hex SHA-256 of a file's content.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
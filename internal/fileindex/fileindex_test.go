@@ -0,0 +1,98 @@
+package fileindex
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sum returns the hex SHA-256 of content.
+func sum(content string) string {
+	h := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(h[:])
+}
+
+func TestWriteAndLoad(t *testing.T) {
+	backupDir := t.TempDir()
+	files := []File{
+		{Path: "pkg/util.go", Size: 11, SHA256: sum("package pkg"), Mode: 0644, ModTime: time.Date(2026, time.January, 2, 9, 0, 0, 0, time.UTC)},
+		{Path: "main.go", Size: 12, SHA256: sum("package main"), Mode: 0755},
+	}
+	if err := Write(backupDir, "proj", "20260102-120000", files); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(backupDir, "proj", DirName, "20260102-120000.json")); err != nil {
+		t.Fatalf("index not stored under %s: %v", DirName, err)
+	}
+
+	idx, err := Load(backupDir, "proj", "20260102-120000")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if idx.Version != "20260102-120000" || len(idx.Files) != 2 || idx.Files[0].Path != "main.go" {
+		t.Errorf("index = %+v, expected both files sorted by path", idx)
+	}
+	f, ok := idx.Lookup("pkg/util.go")
+	if !ok || !reflect.DeepEqual(f, files[0]) {
+		t.Errorf("Lookup = %+v, %v, expected %+v", f, ok, files[0])
+	}
+	if _, ok := idx.Lookup("missing.go"); ok {
+		t.Error("Lookup should not find a file the version lacks")
+	}
+
+	if err := Remove(backupDir, "proj", "20260102-120000"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := Load(backupDir, "proj", "20260102-120000"); !os.IsNotExist(err) {
+		t.Errorf("Load after Remove = %v, expected not exist", err)
+	}
+	if err := Remove(backupDir, "proj", "20260102-120000"); err != nil {
+		t.Errorf("removing a missing index should succeed, got %v", err)
+	}
+}
+
+func TestLoadRejectsNewerFormat(t *testing.T) {
+	backupDir := t.TempDir()
+	path := Path(backupDir, "proj", "v1")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"format": 99, "files": []}`), 0644); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+	if _, err := Load(backupDir, "proj", "v1"); err == nil {
+		t.Error("Load should refuse an index from a newer codebak")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	for path, content := range map[string]string{"same.txt": "same", "edited.txt": "edited", "resized.txt": "longer now", "extra.txt": "ignored"} {
+		if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	idx := &Index{Files: []File{
+		{Path: "edited.txt", Size: 6, SHA256: sum("origin")},
+		{Path: "gone.txt", Size: 1, SHA256: sum("x")},
+		{Path: "resized.txt", Size: 5, SHA256: sum("short")},
+		{Path: "same.txt", Size: 4, SHA256: sum("same")},
+	}}
+
+	mismatches, err := idx.Check(dir)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	want := []Mismatch{
+		{Path: "edited.txt", Reason: "checksum"},
+		{Path: "gone.txt", Reason: "missing"},
+		{Path: "resized.txt", Reason: "size"},
+	}
+	if !reflect.DeepEqual(mismatches, want) {
+		t.Errorf("mismatches = %+v, expected %+v", mismatches, want)
+	}
+}
@@ -0,0 +1,321 @@
+// Package ignore decides which project files are left out of backups.
+//
+// Patterns follow .gitignore semantics: a pattern without a slash matches a
+// name at any depth, a leading or middle slash anchors it to the directory the
+// pattern came from, a trailing slash matches directories only, "**" spans
+// directories, "!" re-includes, and the last matching pattern wins. As in git,
+// a file inside an excluded directory cannot be re-included.
+package ignore
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jmcdonald/codebak/internal/ports"
+)
+
+const (
+	// GitignoreName is the per-directory git ignore file.
+	GitignoreName = ".gitignore"
+
+	// CodebakignoreName is the project-root file with codebak-only patterns.
+	// It is read after the config and .gitignore patterns, so it can re-include
+	// files they exclude.
+	CodebakignoreName = ".codebakignore"
+)
+
+// Pattern is one compiled ignore pattern.
+type Pattern struct {
+	// base is the slash-separated directory the pattern is relative to ("" for the root).
+	base     string
+	re       *regexp.Regexp
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// ParsePattern compiles a single ignore line relative to base. It returns false
+// for blank lines and comments.
+func ParsePattern(line, base string) (Pattern, bool) {
+	line = trimTrailingSpace(strings.TrimSuffix(line, "\r"))
+	if line == "" || strings.HasPrefix(line, "#") {
+		return Pattern{}, false
+	}
+
+	p := Pattern{base: strings.Trim(filepath.ToSlash(base), "/")}
+	if p.base == "." {
+		p.base = ""
+	}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		p.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return Pattern{}, false
+	}
+
+	re, err := regexp.Compile(translate(line))
+	if err != nil {
+		return Pattern{}, false
+	}
+	p.re = re
+	return p, true
+}
+
+// Match reports whether the pattern matches relPath, a slash-separated path
+// relative to the project root.
+func (p Pattern) Match(relPath string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if p.base != "" {
+		if !strings.HasPrefix(relPath, p.base+"/") {
+			return false
+		}
+		relPath = relPath[len(p.base)+1:]
+	}
+	if !p.anchored {
+		relPath = path.Base(relPath)
+	}
+	return p.re.MatchString(relPath)
+}
+
+// Negated reports whether the pattern re-includes what it matches.
+func (p Pattern) Negated() bool {
+	return p.negate
+}
+
+// ParseFile compiles the patterns in an ignore file relative to base.
+// A missing or unreadable file yields no patterns.
+func ParseFile(filename, base string) []Pattern {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+	var patterns []Pattern
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if p, ok := ParsePattern(scanner.Text(), base); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// Matcher implements ports.Excluder for one project. It combines, from lowest
+// to highest precedence, the configured exclude patterns, the project's
+// .gitignore files (when enabled) and its .codebakignore.
+// A Matcher caches results and is not safe for concurrent use.
+type Matcher struct {
+	root         string
+	useGitignore bool
+	exclude      []Pattern
+	codebak      []Pattern
+	// gitignores caches the parsed .gitignore of each directory by relative path
+	gitignores map[string][]Pattern
+	// dirs caches the decision for directories already seen
+	dirs map[string]bool
+}
+
+// Option is a functional option for configuring Matcher.
+type Option func(*Matcher)
+
+// WithGitignore makes the matcher honor .gitignore files in the project.
+func WithGitignore(enabled bool) Option {
+	return func(m *Matcher) {
+		m.useGitignore = enabled
+	}
+}
+
+// New creates a matcher for the project at root using the given exclude patterns.
+func New(root string, exclude []string, opts ...Option) *Matcher {
+	m := &Matcher{
+		root:       root,
+		gitignores: make(map[string][]Pattern),
+		dirs:       make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	for _, line := range exclude {
+		if p, ok := ParsePattern(line, ""); ok {
+			m.exclude = append(m.exclude, p)
+		}
+	}
+	m.codebak = ParseFile(filepath.Join(root, CodebakignoreName), "")
+	return m
+}
+
+// Excluded reports whether relPath (relative to the project root) should be
+// left out. The project root itself is never excluded.
+func (m *Matcher) Excluded(relPath string, isDir bool) bool {
+	relPath = path.Clean(filepath.ToSlash(relPath))
+	if relPath == "." || relPath == "" || relPath == "/" {
+		return false
+	}
+	if isDir {
+		if excluded, ok := m.dirs[relPath]; ok {
+			return excluded
+		}
+	}
+
+	excluded := false
+	if parent := path.Dir(relPath); parent != "." && m.Excluded(parent, true) {
+		excluded = true
+	} else {
+		excluded = m.match(relPath, isDir)
+	}
+
+	if isDir {
+		m.dirs[relPath] = excluded
+	}
+	return excluded
+}
+
+// match applies every pattern in precedence order; the last match wins.
+func (m *Matcher) match(relPath string, isDir bool) bool {
+	excluded := false
+	apply := func(patterns []Pattern) {
+		for _, p := range patterns {
+			if p.Match(relPath, isDir) {
+				excluded = !p.negate
+			}
+		}
+	}
+
+	apply(m.exclude)
+	if m.useGitignore {
+		// Deeper .gitignore files override shallower ones
+		apply(m.gitignore(""))
+		dir := path.Dir(relPath)
+		if dir != "." {
+			parts := strings.Split(dir, "/")
+			for i := range parts {
+				apply(m.gitignore(strings.Join(parts[:i+1], "/")))
+			}
+		}
+	}
+	apply(m.codebak)
+	return excluded
+}
+
+// gitignore returns the parsed .gitignore of the directory dir, loading it once.
+func (m *Matcher) gitignore(dir string) []Pattern {
+	if patterns, ok := m.gitignores[dir]; ok {
+		return patterns
+	}
+	patterns := ParseFile(filepath.Join(m.root, filepath.FromSlash(dir), GitignoreName), dir)
+	m.gitignores[dir] = patterns
+	return patterns
+}
+
+// trimTrailingSpace removes trailing spaces unless escaped with a backslash.
+func trimTrailingSpace(s string) string {
+	for strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\\ ") {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// translate converts a glob pattern to an anchored regular expression in
+// which "*" and "?" never match a slash and "**" spans directories.
+func translate(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				atStart := i == 0 || pattern[i-1] == '/'
+				rest := pattern[i+2:]
+				switch {
+				case atStart && rest == "":
+					// "dir/**" matches everything inside dir
+					b.WriteString(".*")
+					i++
+					continue
+				case atStart && strings.HasPrefix(rest, "/"):
+					// "**/" matches zero or more directories
+					b.WriteString("(?:.*/)?")
+					i += 2
+					continue
+				}
+				// Any other "**" behaves like "*"
+				for i+1 < len(pattern) && pattern[i+1] == '*' {
+					i++
+				}
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			class, n := translateClass(pattern[i:])
+			if n == 0 {
+				b.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			b.WriteString(class)
+			i += n - 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// translateClass converts the bracket expression at the start of s. It returns
+// the regular expression and the number of bytes consumed, or 0 if the bracket
+// is not closed.
+func translateClass(s string) (string, int) {
+	var b strings.Builder
+	b.WriteString("[")
+	i := 1
+	if i < len(s) && (s[i] == '!' || s[i] == '^') {
+		b.WriteString("^/")
+		i++
+	}
+	first := true
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == ']' && !first {
+			b.WriteString("]")
+			return b.String(), i + 1
+		}
+		first = false
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			b.WriteString(regexp.QuoteMeta(s[i : i+1]))
+		case c == '-':
+			b.WriteString("-")
+		case c == '[' || c == ']' || c == '^':
+			b.WriteString("\\" + string(c))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return "", 0
+}
+
+// Compile-time check that Matcher implements ports.Excluder.
+var _ ports.Excluder = (*Matcher)(nil)
@@ -0,0 +1,205 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		// Names without a slash match at any depth
+		{"node_modules", "node_modules", true, true},
+		{"node_modules", "src/node_modules", true, true},
+		{"*.pyc", "file.pyc", false, true},
+		{"*.pyc", "dir/file.pyc", false, true},
+		{"*.pyc", "main.go", false, false},
+		{".DS_Store", ".DS_Store", false, true},
+
+		// A slash anchors the pattern to the root
+		{"docs/build", "docs/build", true, true},
+		{"docs/build", "build", true, false},
+		{"docs/build", "src/docs/build", true, false},
+		{"/build", "build", true, true},
+		{"/build", "src/build", true, false},
+
+		// Trailing slash matches directories only
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"build/", "src/build", true, true},
+
+		// Double star spans directories
+		{"**/logs", "logs", true, true},
+		{"**/logs", "a/b/logs", true, true},
+		{"logs/**", "logs/debug.log", false, true},
+		{"logs/**", "logs", true, false},
+		{"a/**/b", "a/b", true, true},
+		{"a/**/b", "a/x/y/b", true, true},
+		{"a/**/b", "x/a/b", true, false},
+
+		// Single-segment wildcards never cross a slash
+		{"src/*.go", "src/main.go", false, true},
+		{"src/*.go", "src/pkg/main.go", false, false},
+		{"file?.txt", "file1.txt", false, true},
+		{"file?.txt", "file10.txt", false, false},
+		{"[abc].txt", "b.txt", false, true},
+		{"[!abc].txt", "b.txt", false, false},
+		{"[!abc].txt", "d.txt", false, true},
+		{"[a-c].txt", "c.txt", false, true},
+
+		// Escapes
+		{`\#notes`, "#notes", false, true},
+		{`\!important`, "!important", false, true},
+		{`\*`, "*", false, true},
+		{`\*`, "x", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"|"+tt.path, func(t *testing.T) {
+			p, ok := ParsePattern(tt.pattern, "")
+			if !ok {
+				t.Fatalf("ParsePattern(%q) returned no pattern", tt.pattern)
+			}
+			if got := p.Match(tt.path, tt.isDir); got != tt.expected {
+				t.Errorf("%q.Match(%q, %v) = %v, expected %v", tt.pattern, tt.path, tt.isDir, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParsePatternSkipsBlankAndComments(t *testing.T) {
+	for _, line := range []string{"", "   ", "# comment", "/", "!"} {
+		if _, ok := ParsePattern(line, ""); ok {
+			t.Errorf("ParsePattern(%q) should yield no pattern", line)
+		}
+	}
+
+	p, ok := ParsePattern("!keep.log  ", "")
+	if !ok || !p.Negated() {
+		t.Fatal("expected a negated pattern")
+	}
+	if !p.Match("keep.log", false) {
+		t.Error("trailing spaces should be trimmed")
+	}
+}
+
+func TestPatternRelativeToBase(t *testing.T) {
+	p, _ := ParsePattern("/out", "web")
+	if !p.Match("web/out", true) {
+		t.Error("anchored pattern should match relative to its directory")
+	}
+	if p.Match("out", true) {
+		t.Error("pattern from web/.gitignore should not match outside web")
+	}
+
+	p, _ = ParsePattern("*.tmp", "web")
+	if !p.Match("web/a/b.tmp", false) {
+		t.Error("unanchored pattern should match below its directory")
+	}
+	if p.Match("b.tmp", false) {
+		t.Error("unanchored pattern should not match outside its directory")
+	}
+}
+
+func TestMatcherConfigPatterns(t *testing.T) {
+	root := t.TempDir()
+	m := New(root, []string{"node_modules", "*.pyc", ".DS_Store", "docs/build"})
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"node_modules", true, true},
+		{"src/node_modules", true, true},
+		{"src/node_modules/dep/index.js", false, true},
+		{"file.pyc", false, true},
+		{"dir/file.pyc", false, true},
+		{"main.go", false, false},
+		{".DS_Store", false, true},
+		{"docs/build", true, true},
+		{"docs/build/index.html", false, true},
+		{"build", true, false},
+		{".", true, false},
+	}
+	for _, tt := range tests {
+		if got := m.Excluded(tt.path, tt.isDir); got != tt.expected {
+			t.Errorf("Excluded(%q) = %v, expected %v", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestMatcherGitignore(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".gitignore"), "*.log\n/dist/\n!important.log\n")
+	writeFile(t, filepath.Join(root, "web", ".gitignore"), "out/\n!debug.log\n")
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"app.log", false, true},
+		{"important.log", false, false},
+		{"dist", true, true},
+		{"web/dist", true, false},
+		{"web/out", true, true},
+		{"out", true, false},
+		{"web/app.log", false, true},
+		{"web/debug.log", false, false},
+	}
+
+	m := New(root, nil, WithGitignore(true))
+	for _, tt := range tests {
+		if got := m.Excluded(tt.path, tt.isDir); got != tt.expected {
+			t.Errorf("Excluded(%q) = %v, expected %v", tt.path, got, tt.expected)
+		}
+	}
+
+	// .gitignore is only honored when enabled
+	m = New(root, nil)
+	if m.Excluded("app.log", false) {
+		t.Error(".gitignore should be ignored unless enabled")
+	}
+}
+
+func TestMatcherCodebakignoreOverrides(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".gitignore"), ".env\n")
+	writeFile(t, filepath.Join(root, CodebakignoreName), "# keep local secrets in backups\n!.env\n!vendor/\nscratch/\n")
+
+	m := New(root, []string{"vendor"}, WithGitignore(true))
+	if m.Excluded(".env", false) {
+		t.Error(".codebakignore should re-include files excluded by .gitignore")
+	}
+	if m.Excluded("vendor", true) {
+		t.Error(".codebakignore should re-include directories excluded by config")
+	}
+	if !m.Excluded("scratch", true) {
+		t.Error(".codebakignore patterns should exclude")
+	}
+}
+
+func TestMatcherCannotReincludeInsideExcludedDir(t *testing.T) {
+	root := t.TempDir()
+	m := New(root, []string{"build/", "!build/keep.txt"})
+
+	if !m.Excluded("build/keep.txt", false) {
+		t.Error("files inside an excluded directory cannot be re-included")
+	}
+}
@@ -4,55 +4,175 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
+
+	"github.com/jmcdonald/codebak/internal/atomicfile"
+	"github.com/jmcdonald/codebak/internal/fileindex"
+	"github.com/jmcdonald/codebak/internal/retention"
 )
 
+// FormatChunked marks a backup stored as a chunk store index rather than a zip.
+const FormatChunked = "chunked"
+
+// BundleExt is the extension of git bundles stored alongside a backup version.
+const BundleExt = ".bundle"
+
 type BackupEntry struct {
 	File      string    `json:"file"`
 	SHA256    string    `json:"sha256"`
 	SizeBytes int64     `json:"size_bytes"`
 	CreatedAt time.Time `json:"created_at"`
 	GitHead   string    `json:"git_head,omitempty"`
+	DirtyHash string    `json:"dirty_hash,omitempty"` // Fingerprint of uncommitted changes, empty when clean
 	FileCount int       `json:"file_count"`
 	Excluded  []string  `json:"excluded"`
+	Format    string    `json:"format,omitempty"` // Empty for zip, FormatChunked for chunk store
+	// Bundle is the git bundle with the repository's history, branches and stashes
+	Bundle       string `json:"bundle,omitempty"`
+	BundleSHA256 string `json:"bundle_sha256,omitempty"`
+	// Skipped lists files that could not be read and are missing from the backup
+	Skipped []SkippedFile `json:"skipped,omitempty"`
+	// Pinned versions are never removed by retention
+	Pinned bool     `json:"pinned,omitempty"`
+	Labels []string `json:"labels,omitempty"`
+	Note   string   `json:"note,omitempty"`
+	// Provenance describes the run that made the version; nil for versions
+	// from older releases or adopted by gc
+	Provenance *Provenance `json:"provenance,omitempty"`
+	// LastRestoreTest is when the version last passed a test restore
+	LastRestoreTest *time.Time `json:"last_restore_test,omitempty"`
+}
+
+// Provenance records where, when and how a backup version was made.
+type Provenance struct {
+	Hostname       string        `json:"hostname,omitempty"`
+	User           string        `json:"user,omitempty"`
+	CodebakVersion string        `json:"codebak_version,omitempty"`
+	Branch         string        `json:"branch,omitempty"` // Empty for a detached HEAD or a project outside git
+	Dirty          bool          `json:"dirty,omitempty"`  // Uncommitted changes were backed up
+	Duration       time.Duration `json:"duration_ns"`
+	Trigger        string        `json:"trigger,omitempty"`     // "schedule", "manual" or "tui"
+	ConfigHash     string        `json:"config_hash,omitempty"` // SHA-256 of the settings the backup used
+}
+
+// SkippedFile is a file left out of a backup because it could not be read.
+type SkippedFile struct {
+	Path   string `json:"path"` // Relative to the project, slash-separated
+	Reason string `json:"reason"`
+}
+
+// Annotation changes the pin, labels or note of a backup version. Nil and
+// empty fields leave the entry unchanged.
+type Annotation struct {
+	Pinned       *bool
+	AddLabels    []string
+	RemoveLabels []string
+	Note         *string // Replaces the note; "" clears it
+}
+
+// HasLabel reports whether the backup carries label.
+func (e BackupEntry) HasLabel(label string) bool {
+	return contains(e.Labels, label)
+}
+
+// VersionName returns the version identifier (YYYYMMDD-HHMMSS) of a backup file name.
+func VersionName(file string) string {
+	return strings.TrimSuffix(file, filepath.Ext(file))
+}
+
+// Version returns the version identifier of the backup.
+func (e BackupEntry) Version() string {
+	return VersionName(e.File)
 }
 
 type Manifest struct {
-	Project string        `json:"project"`
-	Source  string        `json:"source"`
-	Backups []BackupEntry `json:"backups"`
+	SchemaVersion int           `json:"schema_version"` // Layout version; see SchemaVersion
+	Project       string        `json:"project"`
+	Source        string        `json:"source"`
+	Backups       []BackupEntry `json:"backups"`
 }
 
 func ManifestPath(backupDir, project string) string {
 	return filepath.Join(backupDir, project, "manifest.json")
 }
 
-func Load(backupDir, project string) (*Manifest, error) {
-	path := ManifestPath(backupDir, project)
+// BackupPath returns the path of the previous manifest generation, kept so a
+// corrupted manifest.json can be recovered.
+func BackupPath(backupDir, project string) string {
+	return ManifestPath(backupDir, project) + ".bak"
+}
 
-	data, err := os.ReadFile(path)
+// Projects returns the directories in backupDir that hold a manifest,
+// sorted by name. A missing backupDir has no projects.
+func Projects(backupDir string) ([]string, error) {
+	entries, err := os.ReadDir(backupDir)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return &Manifest{
-				Project: project,
-				Backups: []BackupEntry{},
-			}, nil
+			return nil, nil
 		}
 		return nil, err
 	}
 
-	var m Manifest
-	if err := json.Unmarshal(data, &m); err != nil {
-		return nil, err
+	var projects []string
+	for _, entry := range entries {
+		// Skip the lock directory and other codebak internals
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if _, err := os.Stat(ManifestPath(backupDir, entry.Name())); err == nil {
+			projects = append(projects, entry.Name())
+		}
+	}
+	sort.Strings(projects)
+	return projects, nil
+}
+
+// Load reads a project's manifest. If manifest.json is corrupt, the previous
+// generation (manifest.json.bak) is used instead; the next Save repairs it.
+// Manifests from older codebak releases are migrated to SchemaVersion in
+// memory and written in the new layout by the next Save.
+func Load(backupDir, project string) (*Manifest, error) {
+	m, err := readManifest(ManifestPath(backupDir, project))
+	if err == nil {
+		return m, nil
+	}
+	if os.IsNotExist(err) {
+		return &Manifest{
+			SchemaVersion: SchemaVersion,
+			Project:       project,
+			Backups:       []BackupEntry{},
+		}, nil
 	}
 
-	return &m, nil
+	if prev, bakErr := readManifest(BackupPath(backupDir, project)); bakErr == nil {
+		return prev, nil
+	}
+	return nil, err
 }
 
+// readManifest reads and decodes a manifest file, migrating older layouts.
+func readManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
+
+// Save atomically writes the manifest. The current manifest, if valid, is
+// kept as manifest.json.bak first. Manifests written by a newer codebak are
+// refused with ErrNewerSchema rather than losing fields this one does not know.
 func (m *Manifest) Save(backupDir string) error {
+	if m.SchemaVersion > SchemaVersion {
+		return fmt.Errorf("%w (schema %d, this codebak supports %d); upgrade codebak", ErrNewerSchema, m.SchemaVersion, SchemaVersion)
+	}
+	m.SchemaVersion = SchemaVersion
 	path := ManifestPath(backupDir, m.Project)
 
 	// Ensure directory exists
@@ -65,7 +185,14 @@ func (m *Manifest) Save(backupDir string) error {
 		return err
 	}
 
-	return os.WriteFile(path, data, 0644)
+	// Never replace a good backup generation with a corrupt manifest
+	if current, err := os.ReadFile(path); err == nil && json.Valid(current) {
+		if err := atomicfile.WriteFile(BackupPath(backupDir, m.Project), current, 0644); err != nil {
+			return fmt.Errorf("writing manifest backup: %w", err)
+		}
+	}
+
+	return atomicfile.WriteFile(path, data, 0644)
 }
 
 func (m *Manifest) AddBackup(entry BackupEntry) {
@@ -79,19 +206,114 @@ func (m *Manifest) LatestBackup() *BackupEntry {
 	return &m.Backups[len(m.Backups)-1]
 }
 
+// FindBackup returns the backup with the given version, or the latest backup
+// when version is empty or "latest". Returns nil if no matching backup exists.
+func (m *Manifest) FindBackup(version string) *BackupEntry {
+	if version == "" || version == "latest" {
+		return m.LatestBackup()
+	}
+	for i := range m.Backups {
+		if m.Backups[i].Version() == version {
+			return &m.Backups[i]
+		}
+	}
+	return nil
+}
+
+// Annotate applies a to the backup with the given version and returns the
+// updated entry. Labels are added once, in order, and blank labels are ignored.
+func (m *Manifest) Annotate(version string, a Annotation) (*BackupEntry, error) {
+	entry := m.FindBackup(version)
+	if entry == nil {
+		return nil, fmt.Errorf("version not found: %s", version)
+	}
+
+	if a.Pinned != nil {
+		entry.Pinned = *a.Pinned
+	}
+	for _, label := range a.AddLabels {
+		if label = strings.TrimSpace(label); label != "" && !entry.HasLabel(label) {
+			entry.Labels = append(entry.Labels, label)
+		}
+	}
+	if len(a.RemoveLabels) > 0 {
+		var labels []string
+		for _, l := range entry.Labels {
+			if !contains(a.RemoveLabels, l) {
+				labels = append(labels, l)
+			}
+		}
+		entry.Labels = labels
+	}
+	if a.Note != nil {
+		entry.Note = strings.TrimSpace(*a.Note)
+	}
+	return entry, nil
+}
+
+// contains reports whether list includes s.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// CollectFunc removes the chunks in a project directory that no remaining
+// chunked version references. The chunk store provides it; this package
+// cannot import the chunk store.
+type CollectFunc func(projectDir string) error
+
 // Prune removes old backups exceeding keepLast limit
-// Returns list of deleted files and any error
-func (m *Manifest) Prune(backupDir string, keepLast int) ([]string, error) {
-	if keepLast <= 0 || len(m.Backups) <= keepLast {
-		return nil, nil
+// Returns list of deleted files and any error. See ApplyRetention for collect.
+func (m *Manifest) Prune(backupDir string, keepLast int, collect CollectFunc) ([]string, error) {
+	return m.ApplyRetention(backupDir, retention.Policy{Last: keepLast}, collect)
+}
+
+// PlanRetention evaluates policy over the manifest's backups and returns a
+// decision for each, in manifest order. Pinned backups are always kept, with
+// retention.RulePinned among their reasons. Nothing is removed.
+func (m *Manifest) PlanRetention(policy retention.Policy) []retention.Decision {
+	times := make([]time.Time, len(m.Backups))
+	for i, b := range m.Backups {
+		times[i] = b.CreatedAt
+	}
+	decisions := retention.Apply(policy, times)
+	for i, b := range m.Backups {
+		if b.Pinned {
+			decisions[i].Keep = true
+			decisions[i].Reasons = append(decisions[i].Reasons, retention.RulePinned)
+		}
+	}
+	return decisions
+}
+
+// ApplyRetention removes the backups policy does not keep, with their bundles
+// and file indexes, and returns the deleted backup files. When a chunked
+// version is deleted, collect then removes the chunks only it referenced; with
+// a nil collect nothing is deleted and an error is returned instead, so the
+// chunks cannot be left behind.
+func (m *Manifest) ApplyRetention(backupDir string, policy retention.Policy, collect CollectFunc) ([]string, error) {
+	decisions := m.PlanRetention(policy)
+	if collect == nil {
+		for i, entry := range m.Backups {
+			if !decisions[i].Keep && entry.Format == FormatChunked {
+				return nil, fmt.Errorf("removing chunked version %s needs a chunk collector", entry.Version())
+			}
+		}
 	}
 
-	// Backups are ordered oldest to newest
-	toRemove := len(m.Backups) - keepLast
 	var deleted []string
+	var kept []BackupEntry
+	chunked := false
 
-	for i := 0; i < toRemove; i++ {
-		entry := m.Backups[i]
+	for i, entry := range m.Backups {
+		if decisions[i].Keep {
+			kept = append(kept, entry)
+			continue
+		}
 		zipPath := filepath.Join(backupDir, m.Project, entry.File)
 
 		if err := os.Remove(zipPath); err != nil && !os.IsNotExist(err) {
@@ -99,11 +321,27 @@ func (m *Manifest) Prune(backupDir string, keepLast int) ([]string, error) {
 			continue
 		}
 		deleted = append(deleted, entry.File)
+		chunked = chunked || entry.Format == FormatChunked
+		_ = fileindex.Remove(backupDir, m.Project, entry.Version())
+		if entry.Bundle != "" {
+			_ = os.Remove(filepath.Join(backupDir, m.Project, entry.Bundle))
+		}
+	}
+	if len(kept) == len(m.Backups) {
+		return nil, nil
 	}
 
-	// Update manifest to keep only recent backups
-	m.Backups = m.Backups[toRemove:]
+	// Update manifest to keep only retained backups
+	if kept == nil {
+		kept = []BackupEntry{}
+	}
+	m.Backups = kept
 
+	if chunked {
+		if err := collect(filepath.Join(backupDir, m.Project)); err != nil {
+			return deleted, fmt.Errorf("collecting unused chunks: %w", err)
+		}
+	}
 	return deleted, nil
 }
 
@@ -2,10 +2,16 @@ package manifest
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/jmcdonald/codebak/internal/fileindex"
+	"github.com/jmcdonald/codebak/internal/retention"
 )
 
 func TestManifestSerializationRoundTrip(t *testing.T) {
@@ -81,6 +87,37 @@ func TestManifestSerializationRoundTrip(t *testing.T) {
 	}
 }
 
+func TestProjects(t *testing.T) {
+	backupDir := t.TempDir()
+	for _, project := range []string{"zeta", "alpha"} {
+		m := &Manifest{Project: project, Backups: []BackupEntry{}}
+		if err := m.Save(backupDir); err != nil {
+			t.Fatalf("Failed to save manifest: %v", err)
+		}
+	}
+	// Neither a directory without a manifest nor codebak internals are projects
+	for _, dir := range []string{"empty", ".locks"} {
+		if err := os.MkdirAll(filepath.Join(backupDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(backupDir, ".locks", "manifest.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	projects, err := Projects(backupDir)
+	if err != nil {
+		t.Fatalf("Projects failed: %v", err)
+	}
+	if strings.Join(projects, ",") != "alpha,zeta" {
+		t.Errorf("Projects = %v, expected alpha and zeta", projects)
+	}
+
+	if projects, err := Projects(filepath.Join(backupDir, "missing")); err != nil || len(projects) != 0 {
+		t.Errorf("Projects = %v, %v; expected none for a missing directory", projects, err)
+	}
+}
+
 func TestLoadMissingManifest(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "codebak-test-*")
 	if err != nil {
@@ -222,7 +259,7 @@ func TestPrune(t *testing.T) {
 	}
 
 	// Prune to keep only 3
-	deleted, err := m.Prune(tempDir, 3)
+	deleted, err := m.Prune(tempDir, 3, nil)
 	if err != nil {
 		t.Fatalf("Prune failed: %v", err)
 	}
@@ -252,6 +289,221 @@ func TestPrune(t *testing.T) {
 	}
 }
 
+func TestApplyRetentionKeepsDailyVersions(t *testing.T) {
+	tempDir := t.TempDir()
+	projectDir := filepath.Join(tempDir, "test-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+
+	// Two backups a day for three days
+	m := &Manifest{Project: "test-project"}
+	for day := 1; day <= 3; day++ {
+		for _, hour := range []int{9, 17} {
+			created := time.Date(2026, time.January, day, hour, 0, 0, 0, time.Local)
+			file := created.Format("20060102-150405") + ".zip"
+			if err := os.WriteFile(filepath.Join(projectDir, file), []byte("dummy"), 0644); err != nil {
+				t.Fatalf("Failed to create backup file: %v", err)
+			}
+			m.Backups = append(m.Backups, BackupEntry{File: file, CreatedAt: created})
+		}
+	}
+
+	policy := retention.Policy{Last: 1, Daily: 3}
+	if decisions := m.PlanRetention(policy); len(decisions) != 6 || decisions[0].Keep {
+		t.Fatalf("PlanRetention = %+v, expected the first morning backup to go", decisions)
+	}
+
+	deleted, err := m.ApplyRetention(tempDir, policy, nil)
+	if err != nil {
+		t.Fatalf("ApplyRetention failed: %v", err)
+	}
+	want := []string{"20260101-090000.zip", "20260102-090000.zip", "20260103-090000.zip"}
+	if strings.Join(deleted, ",") != strings.Join(want, ",") {
+		t.Errorf("deleted = %v, expected %v", deleted, want)
+	}
+	for _, b := range m.Backups {
+		if !strings.HasSuffix(b.File, "-170000.zip") {
+			t.Errorf("kept %s, expected only the last backup of each day", b.File)
+		}
+		if _, err := os.Stat(filepath.Join(projectDir, b.File)); err != nil {
+			t.Errorf("kept backup %s should still exist", b.File)
+		}
+	}
+}
+
+func TestPruneKeepsPinnedVersions(t *testing.T) {
+	tempDir := t.TempDir()
+	projectDir := filepath.Join(tempDir, "test-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+
+	m := &Manifest{Project: "test-project"}
+	for _, file := range []string{"20260101-120000.zip", "20260102-120000.zip", "20260103-120000.zip"} {
+		if err := os.WriteFile(filepath.Join(projectDir, file), []byte("dummy"), 0644); err != nil {
+			t.Fatalf("Failed to create backup file: %v", err)
+		}
+		m.AddBackup(BackupEntry{File: file})
+	}
+	m.Backups[0].Pinned = true
+
+	decisions := m.PlanRetention(retention.Policy{Last: 1})
+	if !decisions[0].Keep || strings.Join(decisions[0].Reasons, ",") != retention.RulePinned {
+		t.Errorf("decision = %+v, expected the pinned version kept as pinned", decisions[0])
+	}
+
+	deleted, err := m.Prune(tempDir, 1, nil)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0] != "20260102-120000.zip" {
+		t.Errorf("deleted = %v, expected only the unpinned older version", deleted)
+	}
+	if len(m.Backups) != 2 || !m.Backups[0].Pinned {
+		t.Errorf("backups = %+v, expected the pinned and newest versions", m.Backups)
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	m := &Manifest{Project: "test-project"}
+	m.AddBackup(BackupEntry{File: "20260101-120000.zip"})
+	m.AddBackup(BackupEntry{File: "20260102-120000.zip", Labels: []string{"old"}})
+
+	pinned := true
+	note := "  before the big refactor "
+	entry, err := m.Annotate("20260102-120000", Annotation{
+		Pinned:       &pinned,
+		AddLabels:    []string{"refactor", "old", " ", "refactor"},
+		RemoveLabels: []string{"old"},
+		Note:         &note,
+	})
+	if err != nil {
+		t.Fatalf("Annotate failed: %v", err)
+	}
+	if !entry.Pinned || strings.Join(entry.Labels, ",") != "refactor" || entry.Note != "before the big refactor" {
+		t.Errorf("entry = %+v", entry)
+	}
+	if !m.Backups[1].Pinned {
+		t.Error("Annotate should update the manifest entry")
+	}
+
+	// Unset fields are left alone
+	if entry, _ = m.Annotate("20260102-120000", Annotation{}); !entry.Pinned || entry.Note == "" {
+		t.Errorf("empty annotation changed %+v", entry)
+	}
+
+	if _, err := m.Annotate("20250101-000000", Annotation{Pinned: &pinned}); err == nil {
+		t.Error("Annotate should fail for an unknown version")
+	}
+}
+
+func TestPruneRemovesBundles(t *testing.T) {
+	tempDir := t.TempDir()
+	projectDir := filepath.Join(tempDir, "test-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	for _, f := range []string{"20241213-100000.zip", "20241213-100000.bundle", "20241214-100000.zip", "20241214-100000.bundle"} {
+		if err := os.WriteFile(filepath.Join(projectDir, f), []byte("dummy"), 0644); err != nil {
+			t.Fatalf("Failed to create backup file: %v", err)
+		}
+	}
+
+	m := &Manifest{
+		Project: "test-project",
+		Backups: []BackupEntry{
+			{File: "20241213-100000.zip", Bundle: "20241213-100000.bundle"},
+			{File: "20241214-100000.zip", Bundle: "20241214-100000.bundle"},
+		},
+	}
+	deleted, err := m.Prune(tempDir, 1, nil)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0] != "20241213-100000.zip" {
+		t.Errorf("deleted = %v, expected the oldest version", deleted)
+	}
+	if _, err := os.Stat(filepath.Join(projectDir, "20241213-100000.bundle")); !os.IsNotExist(err) {
+		t.Error("bundle of pruned version should be removed")
+	}
+	if _, err := os.Stat(filepath.Join(projectDir, "20241214-100000.bundle")); err != nil {
+		t.Error("bundle of kept version should remain")
+	}
+}
+
+func TestPruneCollectsChunks(t *testing.T) {
+	tempDir := t.TempDir()
+	projectDir := filepath.Join(tempDir, "test-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	files := []string{"20241213-100000.idx", "20241214-100000.idx"}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(projectDir, f), []byte("index"), 0644); err != nil {
+			t.Fatalf("Failed to create backup file: %v", err)
+		}
+	}
+	newManifest := func() *Manifest {
+		return &Manifest{Project: "test-project", Backups: []BackupEntry{
+			{File: files[0], Format: FormatChunked},
+			{File: files[1], Format: FormatChunked},
+		}}
+	}
+
+	// Without a collector the chunks would leak, so nothing is removed
+	m := newManifest()
+	if _, err := m.Prune(tempDir, 1, nil); err == nil || !strings.Contains(err.Error(), "chunk collector") {
+		t.Errorf("err = %v, expected a missing collector to be refused", err)
+	}
+	if _, err := os.Stat(filepath.Join(projectDir, files[0])); err != nil || len(m.Backups) != 2 {
+		t.Error("nothing should be removed without a collector")
+	}
+
+	var collected []string
+	collect := func(dir string) error {
+		collected = append(collected, dir)
+		return nil
+	}
+	deleted, err := m.Prune(tempDir, 1, collect)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if len(deleted) != 1 || !reflect.DeepEqual(collected, []string{projectDir}) {
+		t.Errorf("deleted %v and collected %v, expected one version and one collection of %s", deleted, collected, projectDir)
+	}
+
+	// Collection failures are reported after the version is removed
+	m = newManifest()
+	m.Backups[0].File = files[1]
+	m.Backups[1].File = "20241215-100000.idx"
+	_, err = m.Prune(tempDir, 1, func(string) error { return errors.New("disk full") })
+	if err == nil || !strings.Contains(err.Error(), "collecting unused chunks: disk full") {
+		t.Errorf("err = %v, expected the collection failure", err)
+	}
+}
+
+func TestPruneRemovesFileIndexes(t *testing.T) {
+	tempDir := t.TempDir()
+	m := &Manifest{Project: "test-project"}
+	for _, version := range []string{"20241213-100000", "20241214-100000"} {
+		if err := fileindex.Write(tempDir, "test-project", version, nil); err != nil {
+			t.Fatalf("Failed to write file index: %v", err)
+		}
+		m.Backups = append(m.Backups, BackupEntry{File: version + ".zip"})
+	}
+
+	if _, err := m.Prune(tempDir, 1, nil); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if _, err := fileindex.Load(tempDir, "test-project", "20241213-100000"); !os.IsNotExist(err) {
+		t.Error("file index of pruned version should be removed")
+	}
+	if _, err := fileindex.Load(tempDir, "test-project", "20241214-100000"); err != nil {
+		t.Errorf("file index of kept version should remain: %v", err)
+	}
+}
+
 func TestPruneNoAction(t *testing.T) {
 	m := &Manifest{
 		Project: "test",
@@ -262,7 +514,7 @@ func TestPruneNoAction(t *testing.T) {
 	}
 
 	// Prune with keepLast >= current count
-	deleted, err := m.Prune("/tmp", 5)
+	deleted, err := m.Prune("/tmp", 5, nil)
 	if err != nil {
 		t.Fatalf("Prune failed: %v", err)
 	}
@@ -409,7 +661,7 @@ func TestPruneZeroKeepLast(t *testing.T) {
 	}
 
 	// Prune with keepLast = 0
-	deleted, err := m.Prune("/tmp", 0)
+	deleted, err := m.Prune("/tmp", 0, nil)
 	if err != nil {
 		t.Fatalf("Prune failed: %v", err)
 	}
@@ -432,7 +684,7 @@ func TestPruneNegativeKeepLast(t *testing.T) {
 	}
 
 	// Prune with keepLast = -1
-	deleted, err := m.Prune("/tmp", -1)
+	deleted, err := m.Prune("/tmp", -1, nil)
 	if err != nil {
 		t.Fatalf("Prune failed: %v", err)
 	}
@@ -469,7 +721,7 @@ func TestPruneFileMissing(t *testing.T) {
 	}
 
 	// Prune should handle missing files gracefully
-	_, err = m.Prune(tempDir, 1)
+	_, err = m.Prune(tempDir, 1, nil)
 	if err != nil {
 		t.Fatalf("Prune failed: %v", err)
 	}
@@ -563,7 +815,7 @@ func TestPruneRemoveError(t *testing.T) {
 	}
 
 	// Prune should handle remove errors gracefully
-	deleted, err := m.Prune(tempDir, 1)
+	deleted, err := m.Prune(tempDir, 1, nil)
 	if err != nil {
 		t.Fatalf("Prune should not fail: %v", err)
 	}
@@ -606,3 +858,121 @@ func TestMultipleAddBackups(t *testing.T) {
 		t.Errorf("Latest backup size = %d, expected %d", latest.SizeBytes, 4*1024)
 	}
 }
+
+func TestVersionName(t *testing.T) {
+	tests := map[string]string{
+		"20241215-100000.zip": "20241215-100000",
+		"20241215-100000.idx": "20241215-100000",
+		"20241215-100000":     "20241215-100000",
+	}
+	for file, expected := range tests {
+		if got := VersionName(file); got != expected {
+			t.Errorf("VersionName(%q) = %q, expected %q", file, got, expected)
+		}
+	}
+}
+
+func TestFindBackup(t *testing.T) {
+	m := &Manifest{
+		Project: "test",
+		Backups: []BackupEntry{
+			{File: "20241213-100000.zip"},
+			{File: "20241214-100000.idx", Format: FormatChunked},
+		},
+	}
+
+	if got := m.FindBackup(""); got == nil || got.File != "20241214-100000.idx" {
+		t.Errorf("FindBackup(\"\") = %v, expected latest", got)
+	}
+	if got := m.FindBackup("latest"); got == nil || got.File != "20241214-100000.idx" {
+		t.Errorf("FindBackup(latest) = %v, expected latest", got)
+	}
+	if got := m.FindBackup("20241213-100000"); got == nil || got.File != "20241213-100000.zip" {
+		t.Errorf("FindBackup(zip version) = %v", got)
+	}
+	if got := m.FindBackup("20241214-100000"); got == nil || got.Format != FormatChunked {
+		t.Errorf("FindBackup(chunked version) = %v", got)
+	}
+	if got := m.FindBackup("20990101-000000"); got != nil {
+		t.Errorf("FindBackup(missing) = %v, expected nil", got)
+	}
+}
+
+func TestSaveKeepsPreviousGeneration(t *testing.T) {
+	tempDir := t.TempDir()
+
+	m := &Manifest{Project: "proj", Backups: []BackupEntry{{File: "v1.zip"}}}
+	if err := m.Save(tempDir); err != nil {
+		t.Fatalf("first Save failed: %v", err)
+	}
+	if _, err := os.Stat(BackupPath(tempDir, "proj")); !os.IsNotExist(err) {
+		t.Error("first Save should not create a backup generation")
+	}
+
+	m.AddBackup(BackupEntry{File: "v2.zip"})
+	if err := m.Save(tempDir); err != nil {
+		t.Fatalf("second Save failed: %v", err)
+	}
+
+	data, err := os.ReadFile(BackupPath(tempDir, "proj"))
+	if err != nil {
+		t.Fatalf("backup generation missing: %v", err)
+	}
+	var prev Manifest
+	if err := json.Unmarshal(data, &prev); err != nil {
+		t.Fatalf("backup generation is not valid JSON: %v", err)
+	}
+	if len(prev.Backups) != 1 {
+		t.Errorf("backup generation has %d entries, expected 1", len(prev.Backups))
+	}
+
+	// No temp files are left in the project directory
+	entries, err := os.ReadDir(filepath.Join(tempDir, "proj"))
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".partial") {
+			t.Errorf("leftover temp file %s", e.Name())
+		}
+	}
+}
+
+func TestLoadRecoversFromBackupGeneration(t *testing.T) {
+	tempDir := t.TempDir()
+
+	m := &Manifest{Project: "proj", Backups: []BackupEntry{{File: "v1.zip"}}}
+	if err := m.Save(tempDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	m.AddBackup(BackupEntry{File: "v2.zip"})
+	if err := m.Save(tempDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Simulate a manifest truncated by a crash
+	if err := os.WriteFile(ManifestPath(tempDir, "proj"), []byte(`{"project": "pr`), 0644); err != nil {
+		t.Fatalf("Failed to corrupt manifest: %v", err)
+	}
+
+	loaded, err := Load(tempDir, "proj")
+	if err != nil {
+		t.Fatalf("Load should fall back to the backup generation: %v", err)
+	}
+	if len(loaded.Backups) != 1 || loaded.Backups[0].File != "v1.zip" {
+		t.Errorf("loaded backups = %+v, expected the previous generation", loaded.Backups)
+	}
+
+	// Saving again repairs manifest.json and keeps the good backup generation
+	loaded.AddBackup(BackupEntry{File: "v3.zip"})
+	if err := loaded.Save(tempDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := Load(tempDir, "proj"); err != nil {
+		t.Fatalf("Load after repair failed: %v", err)
+	}
+	data, err := os.ReadFile(BackupPath(tempDir, "proj"))
+	if err != nil || !json.Valid(data) {
+		t.Error("a corrupt manifest must not replace the backup generation")
+	}
+}
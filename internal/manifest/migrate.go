@@ -0,0 +1,150 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jmcdonald/codebak/internal/atomicfile"
+)
+
+// SchemaVersion is the manifest layout this codebak reads and writes.
+// Manifests without a schema_version are version 0.
+const SchemaVersion = 1
+
+// ErrNewerSchema is returned when saving a manifest that a newer codebak
+// wrote; this release would drop the fields it does not know about.
+var ErrNewerSchema = errors.New("manifest was written by a newer version of codebak")
+
+// migration upgrades a decoded manifest by one schema version.
+type migration func(raw map[string]interface{}) error
+
+// migrations[i] upgrades schema version i to i+1. To change the manifest
+// layout, append a migration and bump SchemaVersion.
+var migrations = []migration{
+	migrateV0,
+}
+
+// migrateV0 upgrades manifests from releases before schema versioning:
+// null lists become empty and chunk store versions get their format, which
+// was once inferred from the file extension alone.
+func migrateV0(raw map[string]interface{}) error {
+	backups, _ := raw["backups"].([]interface{})
+	if backups == nil {
+		backups = []interface{}{}
+	}
+	for _, b := range backups {
+		entry, ok := b.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("backup entry is not an object")
+		}
+		if entry["excluded"] == nil {
+			entry["excluded"] = []interface{}{}
+		}
+		file, _ := entry["file"].(string)
+		if format, _ := entry["format"].(string); format == "" && strings.HasSuffix(file, ".idx") {
+			entry["format"] = FormatChunked
+		}
+	}
+	raw["backups"] = backups
+	return nil
+}
+
+// decode parses manifest JSON, applying any migrations it needs. Manifests
+// from a newer codebak are decoded as they are and keep their version, so
+// Save can refuse to overwrite them.
+func decode(data []byte) (*Manifest, error) {
+	raw, version, err := decodeRaw(data)
+	if err != nil {
+		return nil, err
+	}
+	if version < SchemaVersion {
+		if data, err = upgrade(raw, version); err != nil {
+			return nil, err
+		}
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// decodeRaw parses manifest JSON without interpreting it and returns its
+// schema version.
+func decodeRaw(data []byte) (map[string]interface{}, int, error) {
+	raw := make(map[string]interface{})
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, 0, err
+	}
+
+	v, ok := raw["schema_version"]
+	if !ok {
+		return raw, 0, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid schema_version %v", v)
+	}
+	version, err := n.Int64()
+	if err != nil || version < 0 {
+		return nil, 0, fmt.Errorf("invalid schema_version %v", v)
+	}
+	return raw, int(version), nil
+}
+
+// upgrade applies the migrations from version to SchemaVersion and returns
+// the upgraded JSON.
+func upgrade(raw map[string]interface{}, version int) ([]byte, error) {
+	for v := version; v < SchemaVersion; v++ {
+		if err := migrations[v](raw); err != nil {
+			return nil, fmt.Errorf("migrating manifest from schema %d: %w", v, err)
+		}
+	}
+	raw["schema_version"] = SchemaVersion
+	return json.Marshal(raw)
+}
+
+// MigrationBackupPath returns where Migrate keeps a project's manifest as it
+// was before upgrading from schema version from.
+func MigrationBackupPath(backupDir, project string, from int) string {
+	return fmt.Sprintf("%s.v%d.bak", ManifestPath(backupDir, project), from)
+}
+
+// Migrate upgrades a project's manifest on disk to SchemaVersion, keeping
+// the original at MigrationBackupPath. Returns the version it started from;
+// manifests already current are left untouched. Callers must hold the
+// project lock.
+func Migrate(backupDir, project string) (int, error) {
+	path := ManifestPath(backupDir, project)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	_, from, err := decodeRaw(data)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", filepath.Base(path), err)
+	}
+	if from > SchemaVersion {
+		return from, fmt.Errorf("%w (schema %d, this codebak supports %d)", ErrNewerSchema, from, SchemaVersion)
+	}
+	if from == SchemaVersion {
+		return from, nil
+	}
+
+	m, err := decode(data)
+	if err != nil {
+		return from, err
+	}
+	if err := atomicfile.WriteFile(MigrationBackupPath(backupDir, project, from), data, 0644); err != nil {
+		return from, fmt.Errorf("backing up manifest: %w", err)
+	}
+	return from, m.Save(backupDir)
+}
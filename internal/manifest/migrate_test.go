@@ -0,0 +1,117 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// legacyManifest is a manifest as written before schema versioning.
+const legacyManifest = `{
+  "project": "legacy",
+  "source": "/code/legacy",
+  "backups": [
+    {"file": "20250101-120000.zip", "sha256": "abc", "size_bytes": 10, "created_at": "2025-01-01T12:00:00Z", "file_count": 1, "excluded": null},
+    {"file": "20250102-120000.idx", "sha256": "def", "size_bytes": 20, "created_at": "2025-01-02T12:00:00Z", "file_count": 2, "excluded": ["node_modules"]}
+  ]
+}`
+
+// writeManifestFile stores raw manifest JSON for project under backupDir.
+func writeManifestFile(t *testing.T, backupDir, project, content string) {
+	t.Helper()
+	path := ManifestPath(backupDir, project)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+}
+
+func TestMigrationsCoverSchemaVersion(t *testing.T) {
+	if len(migrations) != SchemaVersion {
+		t.Errorf("%d migrations for schema version %d; every version needs one", len(migrations), SchemaVersion)
+	}
+}
+
+func TestLoadMigratesLegacyManifest(t *testing.T) {
+	backupDir := t.TempDir()
+	writeManifestFile(t, backupDir, "legacy", legacyManifest)
+
+	m, err := Load(backupDir, "legacy")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if m.SchemaVersion != SchemaVersion || len(m.Backups) != 2 {
+		t.Fatalf("manifest = %+v, expected both backups at the current schema", m)
+	}
+	if m.Backups[0].Excluded == nil || m.Backups[0].Format != "" {
+		t.Errorf("zip entry = %+v, expected empty excluded list and zip format", m.Backups[0])
+	}
+	if m.Backups[1].Format != FormatChunked || m.Backups[1].SizeBytes != 20 {
+		t.Errorf("index entry = %+v, expected chunked format inferred", m.Backups[1])
+	}
+}
+
+func TestLoadRejectsInvalidSchemaVersion(t *testing.T) {
+	backupDir := t.TempDir()
+	writeManifestFile(t, backupDir, "bad", `{"schema_version": "two", "project": "bad", "backups": []}`)
+	if _, err := Load(backupDir, "bad"); err == nil {
+		t.Error("Load should fail on a non-numeric schema_version")
+	}
+}
+
+func TestSaveRefusesNewerSchema(t *testing.T) {
+	backupDir := t.TempDir()
+	newer := `{"schema_version": 99, "project": "future", "backups": [], "new_field": true}`
+	writeManifestFile(t, backupDir, "future", newer)
+
+	m, err := Load(backupDir, "future")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if m.SchemaVersion != 99 {
+		t.Errorf("SchemaVersion = %d, expected the newer version kept", m.SchemaVersion)
+	}
+	if err := m.Save(backupDir); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("Save err = %v, expected ErrNewerSchema", err)
+	}
+	if data, _ := os.ReadFile(ManifestPath(backupDir, "future")); string(data) != newer {
+		t.Error("manifest from a newer codebak should be left untouched")
+	}
+	if _, err := Migrate(backupDir, "future"); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("Migrate err = %v, expected ErrNewerSchema", err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	backupDir := t.TempDir()
+	writeManifestFile(t, backupDir, "legacy", legacyManifest)
+
+	from, err := Migrate(backupDir, "legacy")
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if from != 0 {
+		t.Errorf("from = %d, expected 0", from)
+	}
+	original, err := os.ReadFile(MigrationBackupPath(backupDir, "legacy", 0))
+	if err != nil || string(original) != legacyManifest {
+		t.Errorf("original manifest not kept: %v", err)
+	}
+	data, err := os.ReadFile(ManifestPath(backupDir, "legacy"))
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	if !strings.Contains(string(data), fmt.Sprintf(`"schema_version": %d`, SchemaVersion)) {
+		t.Errorf("manifest = %s, expected the current schema version on disk", data)
+	}
+
+	// Running again is a no-op
+	if from, err := Migrate(backupDir, "legacy"); err != nil || from != SchemaVersion {
+		t.Errorf("second Migrate = %d, %v, expected nothing to do", from, err)
+	}
+}
@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/jmcdonald/codebak/internal/ports"
 )
 
@@ -10,6 +12,8 @@ type MockArchiver struct {
 	CreateCalls []CreateCall
 	// ExtractCalls records calls to Extract
 	ExtractCalls []ExtractCall
+	// ExtractFilesCalls records calls to ExtractFiles
+	ExtractFilesCalls []ExtractFilesCall
 	// ListResults maps zip paths to file listings
 	ListResults map[string]map[string]ports.FileInfo
 	// ReadResults maps "zipPath:filePath" to content
@@ -18,13 +22,23 @@ type MockArchiver struct {
 	Errors map[string]error
 	// CreateResult is the default file count to return
 	CreateResult int
+	// CreateSkipped lists files Create reports as unreadable
+	CreateSkipped []ports.SkippedFile
+	// CreateProgress lists progress events Create reports before returning
+	CreateProgress []ports.Progress
+	// CreateFiles lists the archived files Create reports
+	CreateFiles []ports.ArchivedFile
+	// CheckResults maps archive paths to the files Check reports
+	CheckResults map[string][]ports.CheckedFile
 }
 
 // CreateCall records parameters of a Create call.
 type CreateCall struct {
-	DestPath  string
-	SourceDir string
-	Exclude   []string
+	DestPath    string
+	SourceDir   string
+	Exclude     ports.Excluder
+	Compression ports.Compression
+	Progress    ports.ProgressFunc
 }
 
 // ExtractCall records parameters of an Extract call.
@@ -33,32 +47,51 @@ type ExtractCall struct {
 	DestDir string
 }
 
+// ExtractFilesCall records parameters of an ExtractFiles call.
+type ExtractFilesCall struct {
+	ArchivePath string
+	DestDir     string
+	Paths       []string
+}
+
 // NewMockArchiver creates a new mock archiver.
 func NewMockArchiver() *MockArchiver {
 	return &MockArchiver{
 		ListResults:  make(map[string]map[string]ports.FileInfo),
 		ReadResults:  make(map[string]string),
 		Errors:       make(map[string]error),
+		CheckResults: make(map[string][]ports.CheckedFile),
 		CreateResult: 1, // Default to 1 file
 	}
 }
 
 // Create creates a zip archive of sourceDir at destPath.
-// Returns the number of files archived.
-func (m *MockArchiver) Create(destPath, sourceDir string, exclude []string) (int, error) {
+// Returns CreateResult files, CreateSkipped and CreateFiles. A cancelled ctx
+// fails the call.
+func (m *MockArchiver) Create(ctx context.Context, destPath, sourceDir string, exclude ports.Excluder, compression ports.Compression, progress ports.ProgressFunc) (ports.ArchiveResult, error) {
 	m.CreateCalls = append(m.CreateCalls, CreateCall{
-		DestPath:  destPath,
-		SourceDir: sourceDir,
-		Exclude:   exclude,
+		DestPath:    destPath,
+		SourceDir:   sourceDir,
+		Exclude:     exclude,
+		Compression: compression,
+		Progress:    progress,
 	})
 	if err, ok := m.Errors["Create"]; ok {
-		return 0, err
+		return ports.ArchiveResult{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return ports.ArchiveResult{}, err
 	}
-	return m.CreateResult, nil
+	if progress != nil {
+		for _, p := range m.CreateProgress {
+			progress(p)
+		}
+	}
+	return ports.ArchiveResult{FileCount: m.CreateResult, Skipped: m.CreateSkipped, Files: m.CreateFiles}, nil
 }
 
-// Extract extracts a zip archive to destDir.
-func (m *MockArchiver) Extract(zipPath, destDir string) error {
+// Extract extracts a zip archive to destDir. A cancelled ctx fails the call.
+func (m *MockArchiver) Extract(ctx context.Context, zipPath, destDir string) error {
 	m.ExtractCalls = append(m.ExtractCalls, ExtractCall{
 		ZipPath: zipPath,
 		DestDir: destDir,
@@ -66,7 +99,21 @@ func (m *MockArchiver) Extract(zipPath, destDir string) error {
 	if err, ok := m.Errors["Extract"]; ok {
 		return err
 	}
-	return nil
+	return ctx.Err()
+}
+
+// ExtractFiles extracts the named files of an archive to destDir.
+// A cancelled ctx fails the call.
+func (m *MockArchiver) ExtractFiles(ctx context.Context, archivePath, destDir string, paths []string) error {
+	m.ExtractFilesCalls = append(m.ExtractFilesCalls, ExtractFilesCall{
+		ArchivePath: archivePath,
+		DestDir:     destDir,
+		Paths:       paths,
+	})
+	if err, ok := m.Errors["ExtractFiles"]; ok {
+		return err
+	}
+	return ctx.Err()
 }
 
 // List returns a map of file paths to their info from the archive.
@@ -92,5 +139,17 @@ func (m *MockArchiver) ReadFile(zipPath, filePath, projectName string) (string,
 	return "", nil
 }
 
+// Check reads back every file in an archive, verifying its checksum.
+// Returns CheckResults for the archive; a cancelled ctx fails the call.
+func (m *MockArchiver) Check(ctx context.Context, archivePath string) ([]ports.CheckedFile, error) {
+	if err, ok := m.Errors["Check"]; ok {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.CheckResults[archivePath], nil
+}
+
 // Compile-time check that MockArchiver implements ports.Archiver.
 var _ ports.Archiver = (*MockArchiver)(nil)
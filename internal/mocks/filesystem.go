@@ -3,6 +3,7 @@ package mocks
 
 import (
 	"errors"
+	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -24,6 +25,8 @@ type MockFileSystem struct {
 	Errors map[string]error
 	// WalkEntries contains entries to return during Walk
 	WalkEntries []WalkEntry
+	// TempDirs records the paths of directories made by MkdirTemp
+	TempDirs []string
 }
 
 // WalkEntry represents a file or directory entry for Walk testing.
@@ -166,6 +169,18 @@ func (m *MockFileSystem) Create(name string) (*os.File, error) {
 	return nil, errors.New("mock Create returns nil *os.File - use WriteFile instead")
 }
 
+// MkdirTemp creates a new directory in dir whose name begins with pattern
+// and returns its path. Errors are looked up by dir.
+func (m *MockFileSystem) MkdirTemp(dir, pattern string) (string, error) {
+	if err, ok := m.Errors[dir]; ok {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s%d", pattern, len(m.TempDirs)+1))
+	m.TempDirs = append(m.TempDirs, path)
+	m.Stats[path] = &mockFileInfo{name: filepath.Base(path), isDir: true}
+	return path, nil
+}
+
 // Walk walks the file tree rooted at root, calling fn for each file or directory.
 func (m *MockFileSystem) Walk(root string, fn ports.WalkFunc) error {
 	for _, entry := range m.WalkEntries {
@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/jmcdonald/codebak/internal/ports"
 )
 
@@ -8,27 +10,51 @@ import (
 type MockGitClient struct {
 	// Heads maps repository paths to HEAD commit hashes
 	Heads map[string]string
+	// Branches maps repository paths to checked-out branch names
+	Branches map[string]string
 	// Repos maps paths to whether they are git repos
 	Repos map[string]bool
+	// Statuses maps repository paths to working-tree changes
+	Statuses map[string][]ports.GitFileStatus
+	// StatusErrors maps repository paths to errors returned by Status
+	StatusErrors map[string]error
+	// Bundles records CreateBundle calls: destination path -> repository path
+	Bundles map[string]string
+	// Restored records RestoreBundle calls: repository path -> bundle path
+	Restored map[string]string
+	// BundleErrors maps repository paths to errors returned by CreateBundle and RestoreBundle
+	BundleErrors map[string]error
 }
 
 // NewMockGitClient creates a new mock git client.
 func NewMockGitClient() *MockGitClient {
 	return &MockGitClient{
-		Heads: make(map[string]string),
-		Repos: make(map[string]bool),
+		Heads:        make(map[string]string),
+		Branches:     make(map[string]string),
+		Repos:        make(map[string]bool),
+		Statuses:     make(map[string][]ports.GitFileStatus),
+		StatusErrors: make(map[string]error),
+		Bundles:      make(map[string]string),
+		Restored:     make(map[string]string),
+		BundleErrors: make(map[string]error),
 	}
 }
 
 // GetHead returns the current HEAD commit hash for the repository.
 // Returns empty string if not a git repo or on error.
-func (m *MockGitClient) GetHead(repoPath string) string {
+func (m *MockGitClient) GetHead(ctx context.Context, repoPath string) string {
 	if head, ok := m.Heads[repoPath]; ok {
 		return head
 	}
 	return ""
 }
 
+// Branch returns the checked-out branch name for the repository.
+// Returns empty string for repositories without a configured branch.
+func (m *MockGitClient) Branch(ctx context.Context, repoPath string) string {
+	return m.Branches[repoPath]
+}
+
 // IsRepo checks if the given path is a git repository.
 func (m *MockGitClient) IsRepo(path string) bool {
 	if isRepo, ok := m.Repos[path]; ok {
@@ -37,5 +63,35 @@ func (m *MockGitClient) IsRepo(path string) bool {
 	return false
 }
 
+// Status returns the uncommitted changes in the working tree.
+// Returns an empty slice for repositories without configured changes.
+func (m *MockGitClient) Status(ctx context.Context, repoPath string) ([]ports.GitFileStatus, error) {
+	if err, ok := m.StatusErrors[repoPath]; ok {
+		return nil, err
+	}
+	if status, ok := m.Statuses[repoPath]; ok {
+		return status, nil
+	}
+	return []ports.GitFileStatus{}, nil
+}
+
+// CreateBundle records the bundle request without writing a file.
+func (m *MockGitClient) CreateBundle(ctx context.Context, repoPath, destPath string) error {
+	if err, ok := m.BundleErrors[repoPath]; ok {
+		return err
+	}
+	m.Bundles[destPath] = repoPath
+	return nil
+}
+
+// RestoreBundle records the restore request.
+func (m *MockGitClient) RestoreBundle(ctx context.Context, bundlePath, repoPath string) error {
+	if err, ok := m.BundleErrors[repoPath]; ok {
+		return err
+	}
+	m.Restored[repoPath] = bundlePath
+	return nil
+}
+
 // Compile-time check that MockGitClient implements ports.GitClient.
 var _ ports.GitClient = (*MockGitClient)(nil)
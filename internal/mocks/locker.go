@@ -0,0 +1,66 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/jmcdonald/codebak/internal/ports"
+)
+
+// MockLocker implements ports.Locker for testing.
+// Locks are tracked in memory; a held lock makes further attempts fail with ports.ErrLocked.
+type MockLocker struct {
+	// Held maps lock keys ("dir:<backupDir>" or "project:<backupDir>/<project>") to whether they are held
+	Held map[string]bool
+	// Calls records the key of every lock attempt
+	Calls []string
+	// Waits records the wait duration of every lock attempt
+	Waits []time.Duration
+	// Errors maps lock keys to errors returned instead of acquiring
+	Errors map[string]error
+}
+
+// NewMockLocker creates a new mock locker.
+func NewMockLocker() *MockLocker {
+	return &MockLocker{
+		Held:   make(map[string]bool),
+		Errors: make(map[string]error),
+	}
+}
+
+// LockBackupDir acquires the lock for the whole backup directory.
+func (m *MockLocker) LockBackupDir(backupDir string, wait time.Duration) (ports.Lock, error) {
+	return m.lock("dir:"+backupDir, wait)
+}
+
+// LockProject acquires the lock for one project's backups.
+func (m *MockLocker) LockProject(backupDir, project string, wait time.Duration) (ports.Lock, error) {
+	return m.lock("project:"+backupDir+"/"+project, wait)
+}
+
+func (m *MockLocker) lock(key string, wait time.Duration) (ports.Lock, error) {
+	m.Calls = append(m.Calls, key)
+	m.Waits = append(m.Waits, wait)
+	if err, ok := m.Errors[key]; ok {
+		return nil, err
+	}
+	if m.Held[key] {
+		return nil, ports.ErrLocked
+	}
+	m.Held[key] = true
+	return &mockLock{locker: m, key: key}, nil
+}
+
+// mockLock is a lock held in a MockLocker.
+type mockLock struct {
+	locker *MockLocker
+	key    string
+}
+
+// Unlock releases the lock.
+func (l *mockLock) Unlock() error {
+	delete(l.locker.Held, l.key)
+	return nil
+}
+
+// Compile-time check that MockLocker implements ports.Locker.
+var _ ports.Locker = (*MockLocker)(nil)
@@ -1,14 +1,17 @@
 package mocks
 
 import (
+	"context"
 	"errors"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/ignore"
 	"github.com/jmcdonald/codebak/internal/ports"
 )
 
@@ -277,6 +280,30 @@ func TestMockFileSystemMkdirAll(t *testing.T) {
 	}
 }
 
+func TestMockFileSystemMkdirTemp(t *testing.T) {
+	m := NewMockFileSystem()
+
+	first, err := m.MkdirTemp("/scratch", ".restore-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	second, _ := m.MkdirTemp("/scratch", ".restore-")
+	if first == second || filepath.Dir(first) != "/scratch" || !strings.HasPrefix(filepath.Base(first), ".restore-") {
+		t.Errorf("MkdirTemp() = %q then %q, expected distinct dirs in /scratch", first, second)
+	}
+	if info, err := m.Stat(first); err != nil || !info.IsDir() {
+		t.Errorf("MkdirTemp() did not create a directory entry")
+	}
+	if len(m.TempDirs) != 2 {
+		t.Errorf("TempDirs = %v, expected both dirs", m.TempDirs)
+	}
+
+	m.Errors["/readonly"] = errors.New("mkdir error")
+	if _, err := m.MkdirTemp("/readonly", ".restore-"); err == nil {
+		t.Error("MkdirTemp() should fail with the error set for its dir")
+	}
+}
+
 func TestMockFileSystemWriteFile(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -871,7 +898,7 @@ func TestMockGitClient(t *testing.T) {
 	git := NewMockGitClient()
 
 	// Test GetHead for non-repo
-	head := git.GetHead("/not-a-repo")
+	head := git.GetHead(context.Background(), "/not-a-repo")
 	if head != "" {
 		t.Errorf("GetHead should return empty for non-repo, got %q", head)
 	}
@@ -884,10 +911,89 @@ func TestMockGitClient(t *testing.T) {
 		t.Error("IsRepo should return true for configured repo")
 	}
 
-	head = git.GetHead("/my-repo")
+	head = git.GetHead(context.Background(), "/my-repo")
 	if head != "abc123def456" {
 		t.Errorf("GetHead = %q, expected %q", head, "abc123def456")
 	}
+
+	if branch := git.Branch(context.Background(), "/my-repo"); branch != "" {
+		t.Errorf("Branch should return empty when not configured, got %q", branch)
+	}
+	git.Branches["/my-repo"] = "main"
+	if branch := git.Branch(context.Background(), "/my-repo"); branch != "main" {
+		t.Errorf("Branch = %q, expected %q", branch, "main")
+	}
+}
+
+func TestMockGitClientStatus(t *testing.T) {
+	git := NewMockGitClient()
+
+	status, err := git.Status(context.Background(), "/my-repo")
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(status) != 0 {
+		t.Errorf("Status should be empty by default, got %v", status)
+	}
+
+	git.Statuses["/my-repo"] = []ports.GitFileStatus{{Path: "main.go", Code: " M"}}
+	status, _ = git.Status(context.Background(), "/my-repo")
+	if len(status) != 1 || status[0].Path != "main.go" {
+		t.Errorf("Status = %v, expected configured entries", status)
+	}
+
+	git.StatusErrors["/my-repo"] = errors.New("status failed")
+	if _, err := git.Status(context.Background(), "/my-repo"); err == nil {
+		t.Error("Status should return configured error")
+	}
+}
+
+func TestMockGitClientBundle(t *testing.T) {
+	git := NewMockGitClient()
+
+	if err := git.CreateBundle(context.Background(), "/my-repo", "/backups/v1.bundle"); err != nil {
+		t.Fatalf("CreateBundle failed: %v", err)
+	}
+	if git.Bundles["/backups/v1.bundle"] != "/my-repo" {
+		t.Errorf("Bundles = %v, expected recorded call", git.Bundles)
+	}
+	if err := git.RestoreBundle(context.Background(), "/backups/v1.bundle", "/restored"); err != nil {
+		t.Fatalf("RestoreBundle failed: %v", err)
+	}
+	if git.Restored["/restored"] != "/backups/v1.bundle" {
+		t.Errorf("Restored = %v, expected recorded call", git.Restored)
+	}
+
+	git.BundleErrors["/my-repo"] = errors.New("bundle failed")
+	if err := git.CreateBundle(context.Background(), "/my-repo", "/backups/v2.bundle"); err == nil {
+		t.Error("CreateBundle should return configured error")
+	}
+}
+
+func TestMockLocker(t *testing.T) {
+	locker := NewMockLocker()
+
+	l, err := locker.LockProject("/backups", "proj", 0)
+	if err != nil {
+		t.Fatalf("LockProject failed: %v", err)
+	}
+	if _, err := locker.LockProject("/backups", "proj", 0); !errors.Is(err, ports.ErrLocked) {
+		t.Errorf("second LockProject err = %v, expected ErrLocked", err)
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if locker.Held["project:/backups/proj"] {
+		t.Error("Unlock should release the lock")
+	}
+
+	locker.Errors["dir:/backups"] = errors.New("disk full")
+	if _, err := locker.LockBackupDir("/backups", time.Second); err == nil || errors.Is(err, ports.ErrLocked) {
+		t.Errorf("LockBackupDir err = %v, expected configured error", err)
+	}
+	if len(locker.Calls) != 3 || locker.Waits[2] != time.Second {
+		t.Errorf("Calls = %v, Waits = %v", locker.Calls, locker.Waits)
+	}
 }
 
 func TestMockGitClientIsRepo(t *testing.T) {
@@ -961,7 +1067,7 @@ func TestMockGitClientGetHead(t *testing.T) {
 			g := NewMockGitClient()
 			tt.setup(g)
 
-			if got := g.GetHead(tt.path); got != tt.want {
+			if got := g.GetHead(context.Background(), tt.path); got != tt.want {
 				t.Errorf("GetHead() = %v, want %v", got, tt.want)
 			}
 		})
@@ -977,19 +1083,19 @@ func TestMockArchiver(t *testing.T) {
 	archiver.CreateResult = 5
 
 	// Test Create
-	count, err := archiver.Create("/backup.zip", "/source", []string{"node_modules"})
+	result, err := archiver.Create(context.Background(), "/backup.zip", "/source", ignore.New("/source", []string{"node_modules"}), ports.CompressDefault, nil)
 	if err != nil {
 		t.Fatalf("Create failed: %v", err)
 	}
-	if count != 5 {
-		t.Errorf("Create returned %d, expected 5", count)
+	if result.FileCount != 5 {
+		t.Errorf("Create returned %d, expected 5", result.FileCount)
 	}
 	if len(archiver.CreateCalls) != 1 {
 		t.Errorf("CreateCalls = %d, expected 1", len(archiver.CreateCalls))
 	}
 
 	// Test Extract
-	err = archiver.Extract("/backup.zip", "/dest")
+	err = archiver.Extract(context.Background(), "/backup.zip", "/dest")
 	if err != nil {
 		t.Fatalf("Extract failed: %v", err)
 	}
@@ -1011,7 +1117,7 @@ func TestMockArchiver(t *testing.T) {
 
 	// Test error injection
 	archiver.Errors["Create"] = errors.New("disk full")
-	_, err = archiver.Create("/another.zip", "/source", nil)
+	_, err = archiver.Create(context.Background(), "/another.zip", "/source", nil, ports.CompressDefault, nil)
 	if err == nil || err.Error() != "disk full" {
 		t.Errorf("Expected 'disk full' error, got: %v", err)
 	}
@@ -1048,7 +1154,7 @@ func TestMockArchiverExtract(t *testing.T) {
 			a := NewMockArchiver()
 			tt.setup(a)
 
-			err := a.Extract(tt.zipPath, tt.destDir)
+			err := a.Extract(context.Background(), tt.zipPath, tt.destDir)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Extract() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -1060,6 +1166,21 @@ func TestMockArchiverExtract(t *testing.T) {
 	}
 }
 
+func TestMockArchiverExtractFiles(t *testing.T) {
+	a := NewMockArchiver()
+	if err := a.ExtractFiles(context.Background(), "/backup.zip", "/dest", []string{"src/main.go"}); err != nil {
+		t.Fatalf("ExtractFiles failed: %v", err)
+	}
+	if len(a.ExtractFilesCalls) != 1 || a.ExtractFilesCalls[0].Paths[0] != "src/main.go" {
+		t.Errorf("ExtractFilesCalls = %+v, expected the call to be recorded", a.ExtractFilesCalls)
+	}
+
+	a.Errors["ExtractFiles"] = errors.New("extract error")
+	if err := a.ExtractFiles(context.Background(), "/backup.zip", "/dest", nil); err == nil {
+		t.Error("ExtractFiles should return the configured error")
+	}
+}
+
 func TestMockArchiverList(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -1515,7 +1636,7 @@ func TestMockTUIServiceRunBackup(t *testing.T) {
 			svc := NewMockTUIService()
 			tt.setup(svc)
 
-			result := svc.RunBackup(nil, tt.project)
+			result := svc.RunBackup(context.Background(), nil, tt.project, nil)
 			if tt.wantErr && result.Error == nil {
 				t.Error("RunBackup() should have returned error")
 			}
@@ -1587,6 +1708,58 @@ func TestMockTUIServiceVerifyBackup(t *testing.T) {
 	}
 }
 
+func TestMockTUIServiceAnnotateVersion(t *testing.T) {
+	svc := NewMockTUIService()
+	pinned := true
+	if err := svc.AnnotateVersion(nil, "project1", "20260101-120000", ports.TUIAnnotation{Pinned: &pinned}); err != nil {
+		t.Errorf("AnnotateVersion() error = %v", err)
+	}
+	if len(svc.AnnotateCalls) != 1 || svc.AnnotateCalls[0].Version != "20260101-120000" || svc.AnnotateCalls[0].Annotation.Pinned != &pinned {
+		t.Errorf("AnnotateCalls = %+v, expected the call to be recorded", svc.AnnotateCalls)
+	}
+
+	svc.AnnotateError = errors.New("locked")
+	if err := svc.AnnotateVersion(nil, "project1", "20260101-120000", ports.TUIAnnotation{}); err == nil {
+		t.Error("AnnotateVersion() should return AnnotateError")
+	}
+}
+
+func TestMockTUIServiceRestorePath(t *testing.T) {
+	svc := NewMockTUIService()
+	svc.RestoreResult = ports.TUIRestoreResult{Restored: 2}
+	result, err := svc.RestorePath(context.Background(), nil, "project1", "20260101-120000", "src", true)
+	if err != nil || result.Restored != 2 {
+		t.Errorf("RestorePath() = %+v, %v, expected RestoreResult", result, err)
+	}
+	want := RestoreCall{Project: "project1", Version: "20260101-120000", Path: "src", Overwrite: true}
+	if len(svc.RestoreCalls) != 1 || svc.RestoreCalls[0] != want {
+		t.Errorf("RestoreCalls = %+v, expected the call to be recorded", svc.RestoreCalls)
+	}
+}
+
+func TestMockTUIServiceRecover(t *testing.T) {
+	svc := NewMockTUIService()
+	svc.RecoverPreview = ports.TUIRecoverPreview{Added: 3}
+	preview, err := svc.PreviewRecover(nil, "project1", "20260101-120000", "/tmp/copy")
+	if err != nil || preview.Added != 3 {
+		t.Errorf("PreviewRecover() = %+v, %v, expected RecoverPreview", preview, err)
+	}
+	want := RecoverCall{Project: "project1", Version: "20260101-120000", Opts: ports.TUIRecoverOptions{Target: "/tmp/copy"}}
+	if len(svc.PreviewRecoverCalls) != 1 || svc.PreviewRecoverCalls[0] != want {
+		t.Errorf("PreviewRecoverCalls = %+v, expected the call to be recorded", svc.PreviewRecoverCalls)
+	}
+
+	svc.RecoverError = errors.New("project already exists")
+	opts := ports.TUIRecoverOptions{Archive: true}
+	if err := svc.RecoverVersion(context.Background(), nil, "project1", "20260101-120000", opts); err != svc.RecoverError {
+		t.Errorf("RecoverVersion() = %v, expected RecoverError", err)
+	}
+	want = RecoverCall{Project: "project1", Version: "20260101-120000", Opts: opts}
+	if len(svc.RecoverCalls) != 1 || svc.RecoverCalls[0] != want {
+		t.Errorf("RecoverCalls = %+v, expected the call to be recorded", svc.RecoverCalls)
+	}
+}
+
 // ============================================================================
 // Interface Compliance Tests
 // ============================================================================
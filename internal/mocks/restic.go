@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -15,6 +16,8 @@ type MockResticClient struct {
 	SnapshotsByRepo map[string][]ports.Snapshot
 	// RestoredSnapshots tracks restore calls: repoPath -> snapshotID -> targetDir
 	RestoredSnapshots map[string]map[string]string
+	// RestorePathsCalls tracks RestorePaths calls in order
+	RestorePathsCalls []RestorePathsCall
 	// ForgetCalls tracks forget calls: repoPath -> keepLast
 	ForgetCalls map[string]int
 	// NextSnapshotID is returned by the next Backup call
@@ -41,7 +44,7 @@ func NewMockResticClient() *MockResticClient {
 }
 
 // Init initializes a new restic repository at the given path.
-func (m *MockResticClient) Init(repoPath, password string) error {
+func (m *MockResticClient) Init(ctx context.Context, repoPath, password string) error {
 	if m.Errors.Init != nil {
 		return m.Errors.Init
 	}
@@ -53,7 +56,7 @@ func (m *MockResticClient) Init(repoPath, password string) error {
 }
 
 // Backup creates a new backup of the given paths to the repository.
-func (m *MockResticClient) Backup(repoPath, password string, paths []string, tags []string) (string, error) {
+func (m *MockResticClient) Backup(ctx context.Context, repoPath, password string, paths []string, tags []string) (string, error) {
 	if m.Errors.Backup != nil {
 		return "", m.Errors.Backup
 	}
@@ -75,7 +78,7 @@ func (m *MockResticClient) Backup(repoPath, password string, paths []string, tag
 }
 
 // Snapshots returns all snapshots in the repository.
-func (m *MockResticClient) Snapshots(repoPath, password string, tags []string) ([]ports.Snapshot, error) {
+func (m *MockResticClient) Snapshots(ctx context.Context, repoPath, password string, tags []string) ([]ports.Snapshot, error) {
 	if m.Errors.Snapshots != nil {
 		return nil, m.Errors.Snapshots
 	}
@@ -100,7 +103,7 @@ func (m *MockResticClient) Snapshots(repoPath, password string, tags []string) (
 }
 
 // Restore restores a snapshot to the given target directory.
-func (m *MockResticClient) Restore(repoPath, password, snapshotID, targetDir string) error {
+func (m *MockResticClient) Restore(ctx context.Context, repoPath, password, snapshotID, targetDir string) error {
 	if m.Errors.Restore != nil {
 		return m.Errors.Restore
 	}
@@ -112,8 +115,35 @@ func (m *MockResticClient) Restore(repoPath, password, snapshotID, targetDir str
 	return nil
 }
 
+// RestorePathsCall records one RestorePaths call.
+type RestorePathsCall struct {
+	RepoPath   string
+	SnapshotID string
+	TargetDir  string
+	Paths      []string
+}
+
+// RestorePaths restores only the given paths of a snapshot.
+// Uses Errors.Restore, like Restore.
+func (m *MockResticClient) RestorePaths(ctx context.Context, repoPath, password, snapshotID, targetDir string, paths []string) error {
+	if m.Errors.Restore != nil {
+		return m.Errors.Restore
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no paths specified for restore")
+	}
+
+	m.RestorePathsCalls = append(m.RestorePathsCalls, RestorePathsCall{
+		RepoPath:   repoPath,
+		SnapshotID: snapshotID,
+		TargetDir:  targetDir,
+		Paths:      paths,
+	})
+	return nil
+}
+
 // Forget removes old snapshots according to the retention policy.
-func (m *MockResticClient) Forget(repoPath, password string, keepLast int, prune bool) error {
+func (m *MockResticClient) Forget(ctx context.Context, repoPath, password string, keepLast int, prune bool) error {
 	if m.Errors.Forget != nil {
 		return m.Errors.Forget
 	}
@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/jmcdonald/codebak/internal/config"
 	"github.com/jmcdonald/codebak/internal/ports"
 )
@@ -29,9 +31,26 @@ type MockTUIService struct {
 
 	// BackupResults maps project names to backup results
 	BackupResults map[string]ports.TUIBackupResult
+	// BackupProgress lists progress events RunBackup reports before returning
+	BackupProgress []ports.Progress
+	// BackupWaitsForCancel makes RunBackup block until its context is cancelled
+	BackupWaitsForCancel bool
 
 	// VerifyErrors maps project names to verify errors
 	VerifyErrors map[string]error
+	// AnnotateError is the error to return from AnnotateVersion
+	AnnotateError error
+	// RestoreResult is the result to return from RestorePath
+	RestoreResult ports.TUIRestoreResult
+	// RestoreError is the error to return from RestorePath
+	RestoreError error
+	// RestoreWaitsForCancel makes RestorePath and RecoverVersion block until
+	// their context is cancelled
+	RestoreWaitsForCancel bool
+	// RecoverPreview is the preview to return from PreviewRecover
+	RecoverPreview ports.TUIRecoverPreview
+	// RecoverError is the error to return from PreviewRecover and RecoverVersion
+	RecoverError error
 
 	// Call tracking
 	LoadConfigCalls     int
@@ -40,6 +59,35 @@ type MockTUIService struct {
 	ListSnapshotsCalls  []string
 	RunBackupCalls      []string
 	VerifyBackupCalls   []string
+
+	AnnotateCalls []AnnotateCall
+	RestoreCalls  []RestoreCall
+
+	PreviewRecoverCalls []RecoverCall
+	RecoverCalls        []RecoverCall
+}
+
+// RecoverCall records parameters of a PreviewRecover or RecoverVersion call.
+// PreviewRecover only sets Opts.Target.
+type RecoverCall struct {
+	Project string
+	Version string
+	Opts    ports.TUIRecoverOptions
+}
+
+// RestoreCall records parameters of a RestorePath call.
+type RestoreCall struct {
+	Project   string
+	Version   string
+	Path      string
+	Overwrite bool
+}
+
+// AnnotateCall records parameters of an AnnotateVersion call.
+type AnnotateCall struct {
+	Project    string
+	Version    string
+	Annotation ports.TUIAnnotation
 }
 
 // NewMockTUIService creates a new mock TUI service.
@@ -83,8 +131,17 @@ func (m *MockTUIService) ListVersions(cfg *config.Config, project string) ([]por
 }
 
 // RunBackup performs a backup of the specified project.
-func (m *MockTUIService) RunBackup(cfg *config.Config, project string) ports.TUIBackupResult {
+func (m *MockTUIService) RunBackup(ctx context.Context, cfg *config.Config, project string, progress ports.ProgressFunc) ports.TUIBackupResult {
 	m.RunBackupCalls = append(m.RunBackupCalls, project)
+	if progress != nil {
+		for _, p := range m.BackupProgress {
+			progress(p)
+		}
+	}
+	if m.BackupWaitsForCancel {
+		<-ctx.Done()
+		return ports.TUIBackupResult{Error: ctx.Err()}
+	}
 	if result, ok := m.BackupResults[project]; ok {
 		return result
 	}
@@ -100,6 +157,38 @@ func (m *MockTUIService) VerifyBackup(cfg *config.Config, project string) error
 	return nil
 }
 
+// AnnotateVersion pins, labels or notes a backup version of a project.
+func (m *MockTUIService) AnnotateVersion(cfg *config.Config, project, version string, a ports.TUIAnnotation) error {
+	m.AnnotateCalls = append(m.AnnotateCalls, AnnotateCall{Project: project, Version: version, Annotation: a})
+	return m.AnnotateError
+}
+
+// RestorePath restores one file or directory of a backup version.
+func (m *MockTUIService) RestorePath(ctx context.Context, cfg *config.Config, project, version, path string, overwrite bool) (ports.TUIRestoreResult, error) {
+	m.RestoreCalls = append(m.RestoreCalls, RestoreCall{Project: project, Version: version, Path: path, Overwrite: overwrite})
+	if m.RestoreWaitsForCancel {
+		<-ctx.Done()
+		return ports.TUIRestoreResult{}, ctx.Err()
+	}
+	return m.RestoreResult, m.RestoreError
+}
+
+// PreviewRecover compares a backup version with the directory recovering it would write to.
+func (m *MockTUIService) PreviewRecover(cfg *config.Config, project, version, target string) (ports.TUIRecoverPreview, error) {
+	m.PreviewRecoverCalls = append(m.PreviewRecoverCalls, RecoverCall{Project: project, Version: version, Opts: ports.TUIRecoverOptions{Target: target}})
+	return m.RecoverPreview, m.RecoverError
+}
+
+// RecoverVersion restores a whole backup version of a project.
+func (m *MockTUIService) RecoverVersion(ctx context.Context, cfg *config.Config, project, version string, opts ports.TUIRecoverOptions) error {
+	m.RecoverCalls = append(m.RecoverCalls, RecoverCall{Project: project, Version: version, Opts: opts})
+	if m.RestoreWaitsForCancel {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return m.RecoverError
+}
+
 // ListSnapshots returns all restic snapshots for sensitive sources.
 func (m *MockTUIService) ListSnapshots(cfg *config.Config, tag string) ([]ports.TUISnapshotInfo, error) {
 	m.ListSnapshotsCalls = append(m.ListSnapshotsCalls, tag)
@@ -1,15 +1,32 @@
 package ports
 
+import (
+	"context"
+	"os"
+	"time"
+)
+
 // Archiver abstracts zip archive operations for testability.
 // Production code uses ZipArchiver adapter; tests use MockArchiver.
 type Archiver interface {
 	// Create creates a zip archive of sourceDir at destPath.
-	// Returns the number of files archived.
-	// exclude is a list of patterns to skip (e.g., "node_modules", "*.pyc").
-	Create(destPath, sourceDir string, exclude []string) (fileCount int, err error)
+	// Returns the number of files archived and the files that could not be read.
+	// exclude decides which files and directories to skip; nil archives everything.
+	// compression selects how hard file contents are compressed.
+	// progress, if non-nil, is called after each file is archived.
+	// Cancelling ctx stops between files and leaves nothing at destPath.
+	Create(ctx context.Context, destPath, sourceDir string, exclude Excluder, compression Compression, progress ProgressFunc) (ArchiveResult, error)
 
 	// Extract extracts a zip archive to destDir.
-	Extract(zipPath, destDir string) error
+	// Cancelling ctx stops between files; files already extracted are left in place.
+	Extract(ctx context.Context, zipPath, destDir string) error
+
+	// ExtractFiles extracts only the named files, given relative to the
+	// project as List returns them, to destDir/<path>; unlike Extract no
+	// project directory is added. Existing files are overwritten. Naming a
+	// file the archive does not hold is an error.
+	// Cancelling ctx stops between files; files already extracted are left in place.
+	ExtractFiles(ctx context.Context, archivePath, destDir string, paths []string) error
 
 	// List returns a map of file paths to their info from the archive.
 	// The path key has the project prefix stripped.
@@ -17,10 +34,95 @@ type Archiver interface {
 
 	// ReadFile reads the contents of a file from inside a zip archive.
 	ReadFile(zipPath, filePath, projectName string) (string, error)
+
+	// Check reads back every file in an archive, verifying the CRC32 stored
+	// with it, and returns one result per file in archive order. An error is
+	// returned only when the archive itself cannot be read.
+	// Cancelling ctx stops before the next file.
+	Check(ctx context.Context, archivePath string) ([]CheckedFile, error)
+}
+
+// CheckedFile is a file read back from an archive by Check.
+type CheckedFile struct {
+	Path   string // Relative to the project, slash-separated
+	Size   int64  // Bytes read
+	SHA256 string // Of the content read
+	Err    error  // Why the content is bad; nil when it matches the archive's checksum
+}
+
+// ArchiveResult describes what Create put in an archive.
+type ArchiveResult struct {
+	FileCount int            // Files archived
+	Skipped   []SkippedFile  // Files left out because they could not be read
+	Files     []ArchivedFile // Every file archived, in walk order
+}
+
+// ArchivedFile describes a file as Create stored it.
+type ArchivedFile struct {
+	Path    string // Relative to the source directory, slash-separated
+	Size    int64
+	SHA256  string // Of the content as archived
+	Mode    os.FileMode
+	ModTime time.Time
+}
+
+// SkippedFile is a file Create could not read and left out of the archive.
+type SkippedFile struct {
+	Path   string // Relative to the source directory, slash-separated
+	Reason string
 }
 
+// Compression selects the trade-off between archive size and speed.
+type Compression int
+
+const (
+	CompressDefault Compression = iota // Balanced size and speed
+	CompressNone                       // Store contents uncompressed
+	CompressFast                       // Favour speed over size
+	CompressBest                       // Favour size over speed
+)
+
+// Excluder decides which paths are left out of an archive.
+type Excluder interface {
+	// Excluded reports whether relPath, relative to the archived directory,
+	// should be skipped. Excluding a directory skips everything inside it.
+	Excluded(relPath string, isDir bool) bool
+}
+
+// Progress reports how far an archive operation has got. Archivers fill in
+// the running counts; callers that know the totals add them and an ETA.
+type Progress struct {
+	Files int    // Files archived so far
+	Bytes int64  // Bytes of file content archived so far
+	Path  string // File just archived, relative to the source directory
+
+	FilesTotal int           // Files to archive, 0 when unknown
+	BytesTotal int64         // Bytes to archive, 0 when unknown
+	ETA        time.Duration // Estimated time remaining, 0 when unknown
+}
+
+// Fraction returns how much of the work is done, from 0 to 1. Bytes are used
+// when the byte total is known, otherwise files; 0 when neither total is known.
+func (p Progress) Fraction() float64 {
+	var f float64
+	switch {
+	case p.BytesTotal > 0:
+		f = float64(p.Bytes) / float64(p.BytesTotal)
+	case p.FilesTotal > 0:
+		f = float64(p.Files) / float64(p.FilesTotal)
+	}
+	if f > 1 {
+		f = 1
+	}
+	return f
+}
+
+// ProgressFunc receives progress updates on the goroutine doing the work.
+type ProgressFunc func(Progress)
+
 // FileInfo contains metadata about a file in an archive.
 type FileInfo struct {
-	Size  int64
-	CRC32 uint32
+	Size    int64
+	CRC32   uint32
+	ModTime time.Time // Zero when the archive does not record it
 }
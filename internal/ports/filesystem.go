@@ -40,6 +40,10 @@ type FileSystem interface {
 	// Create creates or truncates the named file.
 	Create(name string) (*os.File, error)
 
+	// MkdirTemp creates a new directory in dir whose name begins with pattern
+	// and returns its path.
+	MkdirTemp(dir, pattern string) (string, error)
+
 	// Walk walks the file tree rooted at root, calling fn for each file or directory.
 	Walk(root string, fn WalkFunc) error
 }
@@ -1,12 +1,42 @@
 package ports
 
+import "context"
+
+// GitFileStatus describes a single uncommitted change reported by git status.
+type GitFileStatus struct {
+	// Path is the file path relative to the repository root.
+	Path string
+	// Code is the two-character porcelain status (e.g. " M", "A ", "??").
+	Code string
+}
+
 // GitClient abstracts git operations for testability.
 // Production code uses ExecGitClient adapter; tests use MockGitClient.
 type GitClient interface {
 	// GetHead returns the current HEAD commit hash for the repository.
 	// Returns empty string if not a git repo or on error.
-	GetHead(repoPath string) string
+	// Cancelling ctx stops git and returns an empty string.
+	GetHead(ctx context.Context, repoPath string) string
+
+	// Branch returns the name of the checked-out branch.
+	// Returns empty string for a detached HEAD, if not a git repo or on error.
+	Branch(ctx context.Context, repoPath string) string
 
 	// IsRepo checks if the given path is a git repository.
 	IsRepo(path string) bool
+
+	// Status returns the uncommitted changes in the working tree: modified,
+	// staged and untracked files. Files ignored by .gitignore are not reported.
+	// Returns an empty slice for a clean working tree. Cancelling ctx stops git.
+	Status(ctx context.Context, repoPath string) ([]GitFileStatus, error)
+
+	// CreateBundle writes a git bundle of every ref in the repository,
+	// including all stash entries, to destPath. Cancelling ctx stops git and
+	// leaves nothing at destPath.
+	CreateBundle(ctx context.Context, repoPath, destPath string) error
+
+	// RestoreBundle turns repoPath, a directory holding a restored working
+	// tree, into a repository with the branches, tags, HEAD and stashes from
+	// a bundle made by CreateBundle. The working tree files are left as-is.
+	RestoreBundle(ctx context.Context, bundlePath, repoPath string) error
 }
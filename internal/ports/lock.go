@@ -0,0 +1,35 @@
+package ports
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrLocked is returned (possibly wrapped) when another codebak process holds
+// a lock on the backup directory or project.
+var ErrLocked = errors.New("another codebak run is in progress")
+
+// WaitForever makes Locker wait indefinitely for a lock to be released.
+const WaitForever time.Duration = -1
+
+// Lock is a held advisory lock.
+type Lock interface {
+	// Unlock releases the lock.
+	Unlock() error
+}
+
+// Locker abstracts advisory cross-process locking of backup storage.
+// Production code uses FileLocker adapter; tests use MockLocker.
+//
+// The backup directory lock excludes every project lock, so whole-directory
+// operations (full runs, moves) never overlap with single-project ones.
+//
+// wait controls how long to wait for a busy lock: 0 fails immediately with
+// ErrLocked, a negative value (WaitForever) waits indefinitely.
+type Locker interface {
+	// LockBackupDir acquires the lock for the whole backup directory.
+	LockBackupDir(backupDir string, wait time.Duration) (Lock, error)
+
+	// LockProject acquires the lock for one project's backups.
+	LockProject(backupDir, project string, wait time.Duration) (Lock, error)
+}
@@ -1,6 +1,9 @@
 package ports
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // Snapshot represents a restic backup snapshot.
 type Snapshot struct {
@@ -13,28 +16,35 @@ type Snapshot struct {
 
 // ResticClient abstracts restic operations for testability.
 // Production code uses ExecResticClient adapter; tests use MockResticClient.
+// Cancelling the context stops the running restic process.
 type ResticClient interface {
 	// Init initializes a new restic repository at the given path.
 	// Returns an error if the repository already exists or cannot be created.
-	Init(repoPath, password string) error
+	Init(ctx context.Context, repoPath, password string) error
 
 	// Backup creates a new backup of the given paths to the repository.
 	// Tags can be used to identify/filter snapshots later.
 	// Returns the snapshot ID on success.
-	Backup(repoPath, password string, paths []string, tags []string) (string, error)
+	Backup(ctx context.Context, repoPath, password string, paths []string, tags []string) (string, error)
 
 	// Snapshots returns all snapshots in the repository.
 	// Can optionally filter by tags.
-	Snapshots(repoPath, password string, tags []string) ([]Snapshot, error)
+	Snapshots(ctx context.Context, repoPath, password string, tags []string) ([]Snapshot, error)
 
 	// Restore restores a snapshot to the given target directory.
 	// Use "latest" as snapshotID to restore the most recent snapshot.
-	Restore(repoPath, password, snapshotID, targetDir string) error
+	Restore(ctx context.Context, repoPath, password, snapshotID, targetDir string) error
+
+	// RestorePaths restores only the given paths of a snapshot, and everything
+	// under those that are directories, to the target directory. Paths are
+	// absolute, as the snapshot records them; restic keeps that layout under
+	// targetDir.
+	RestorePaths(ctx context.Context, repoPath, password, snapshotID, targetDir string, paths []string) error
 
 	// Forget removes old snapshots according to the retention policy.
 	// keepLast specifies how many recent snapshots to keep.
 	// If prune is true, also removes unreferenced data from the repository.
-	Forget(repoPath, password string, keepLast int, prune bool) error
+	Forget(ctx context.Context, repoPath, password string, keepLast int, prune bool) error
 
 	// IsInitialized checks if a restic repository exists at the given path.
 	IsInitialized(repoPath string) bool
@@ -1,6 +1,7 @@
 package ports
 
 import (
+	"context"
 	"time"
 
 	"github.com/jmcdonald/codebak/internal/config"
@@ -25,6 +26,33 @@ type TUIVersionInfo struct {
 	FileCount int
 	GitHead   string
 	CreatedAt time.Time
+	Skipped   []SkippedFile // Files that could not be read and are missing
+	Pinned    bool          // Kept regardless of retention
+	Labels    []string
+	Note      string
+	// Provenance is nil for versions made before provenance was recorded
+	Provenance *TUIProvenance
+}
+
+// TUIProvenance describes the run that made a backup version.
+type TUIProvenance struct {
+	Hostname       string
+	User           string
+	CodebakVersion string
+	Branch         string
+	Dirty          bool // Uncommitted changes were backed up
+	Duration       time.Duration
+	Trigger        string // "schedule", "manual" or "tui"
+	ConfigHash     string
+}
+
+// TUIAnnotation changes the pin, labels or note of a backup version.
+// Nil and empty fields are left unchanged.
+type TUIAnnotation struct {
+	Pinned       *bool
+	AddLabels    []string
+	RemoveLabels []string
+	Note         *string
 }
 
 // TUIBackupResult contains the result of a backup operation.
@@ -33,6 +61,35 @@ type TUIBackupResult struct {
 	Error   error
 	Skipped bool
 	Reason  string
+
+	// SkippedFiles lists files that could not be read and were left out
+	SkippedFiles []SkippedFile
+}
+
+// TUIRestoreResult describes a restore of one file or directory.
+type TUIRestoreResult struct {
+	Dir         string   // Directory the files were restored under
+	Restored    int      // Files written, including overwritten ones
+	Overwritten int      // Local copies replaced
+	Conflicts   []string // Local files that differ from the backup; nothing was written
+}
+
+// TUIRecoverOptions selects what happens to the current copy of a project
+// when a whole version is recovered.
+type TUIRecoverOptions struct {
+	Wipe    bool   // Delete the current copy first
+	Archive bool   // Move the current copy aside first
+	Target  string // Restore into this empty or new directory instead
+}
+
+// TUIRecoverPreview counts the files recovering a version would change.
+type TUIRecoverPreview struct {
+	Dir         string // Directory the version would be restored into
+	Added       int
+	Overwritten int
+	Removed     int // Local files the version does not hold
+	Unchanged   int
+	Newer       int // Overwritten files modified locally after the backup
 }
 
 // TUISnapshotInfo contains restic snapshot metadata for display.
@@ -60,9 +117,26 @@ type TUIService interface {
 	ListSnapshots(cfg *config.Config, tag string) ([]TUISnapshotInfo, error)
 
 	// RunBackup performs a backup of the specified project.
-	RunBackup(cfg *config.Config, project string) TUIBackupResult
+	// progress, if non-nil, is called as files are archived.
+	// Cancelling ctx stops the backup and removes its partial files.
+	RunBackup(ctx context.Context, cfg *config.Config, project string, progress ProgressFunc) TUIBackupResult
 
 	// VerifyBackup verifies the latest backup of a project.
 	// Returns nil if verified successfully, error otherwise.
 	VerifyBackup(cfg *config.Config, project string) error
+
+	// AnnotateVersion pins, labels or notes a backup version of a project.
+	AnnotateVersion(cfg *config.Config, project, version string, a TUIAnnotation) error
+
+	// RestorePath restores one file or directory of a backup version into
+	// the project's directory. Unless overwrite is set, nothing is written
+	// when local files differ from the backup; they are listed in Conflicts.
+	RestorePath(ctx context.Context, cfg *config.Config, project, version, path string, overwrite bool) (TUIRestoreResult, error)
+
+	// PreviewRecover compares a backup version with the directory recovering
+	// it would write to: target, or the project's own when target is "".
+	PreviewRecover(cfg *config.Config, project, version, target string) (TUIRecoverPreview, error)
+
+	// RecoverVersion restores a whole backup version of a project.
+	RecoverVersion(ctx context.Context, cfg *config.Config, project, version string, opts TUIRecoverOptions) error
 }
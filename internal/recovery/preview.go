@@ -0,0 +1,148 @@
+package recovery
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/ports"
+)
+
+// RecoverPreview compares a backup version with the directory Recover would
+// restore it into. Paths are relative to Dir and slash-separated.
+type RecoverPreview struct {
+	Version     string
+	Dir         string
+	Added       []string // In the backup only
+	Overwritten []string // In both, with different contents
+	Removed     []string // In Dir only; a wipe deletes them and an archive moves them aside
+	Unchanged   []string
+	// Newer lists the overwritten files modified locally after the time the
+	// backup records for them, which recovering would roll back
+	Newer []string
+}
+
+// Changes returns how many files recovering would add, overwrite or remove.
+func (p RecoverPreview) Changes() int {
+	return len(p.Added) + len(p.Overwritten) + len(p.Removed)
+}
+
+// Preview compares the version opts selects with the directory Recover
+// would restore it into, without changing anything. Only opts.Project,
+// opts.Version and opts.Target are used. A directory that does not exist yet
+// has nothing to overwrite or remove.
+func (s *Service) Preview(cfg *config.Config, opts RecoverOptions) (RecoverPreview, error) {
+	backupDir, err := config.ExpandPath(cfg.BackupDir)
+	if err != nil {
+		return RecoverPreview{}, err
+	}
+
+	dir := opts.Target
+	if dir == "" {
+		if dir, err = s.locateProject(cfg, opts.Project); err != nil {
+			return RecoverPreview{}, err
+		}
+	} else if dir, err = config.ExpandPath(dir); err != nil {
+		return RecoverPreview{}, err
+	}
+
+	unlock, err := s.lockProject(cfg, backupDir, opts.Project)
+	if err != nil {
+		return RecoverPreview{}, err
+	}
+	defer unlock()
+
+	entry, err := findEntry(backupDir, opts.Project, opts.Version)
+	if err != nil {
+		return RecoverPreview{}, err
+	}
+	preview := RecoverPreview{Version: entry.Version(), Dir: dir}
+
+	listing, err := s.archiver.List(filepath.Join(backupDir, opts.Project, entry.File))
+	if err != nil {
+		return preview, fmt.Errorf("listing backup: %w", err)
+	}
+	if err := s.compareDir(&preview, []string{"."}, listing); err != nil {
+		return preview, err
+	}
+	return preview, nil
+}
+
+// compareDir fills in the file lists of preview by comparing the files
+// under each of roots in preview.Dir with listing. Roots are relative to
+// preview.Dir; "." compares all of it. Listing paths are slash-separated.
+func (s *Service) compareDir(preview *RecoverPreview, roots []string, listing map[string]ports.FileInfo) error {
+	dir := preview.Dir
+	local := make(map[string]os.FileInfo)
+	for _, root := range roots {
+		start := filepath.Join(dir, root)
+		err := s.fs.Walk(start, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if path == start && os.IsNotExist(err) {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			local[filepath.ToSlash(rel)] = info
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", start, err)
+		}
+	}
+
+	for p, archived := range listing {
+		info, ok := local[p]
+		if !ok {
+			preview.Added = append(preview.Added, p)
+			continue
+		}
+		same, _, err := s.matchesLocal(filepath.Join(dir, filepath.FromSlash(p)), archived)
+		if err != nil {
+			return fmt.Errorf("reading local %s: %w", p, err)
+		}
+		if same {
+			preview.Unchanged = append(preview.Unchanged, p)
+			continue
+		}
+		preview.Overwritten = append(preview.Overwritten, p)
+		if newerLocally(info, archived) {
+			preview.Newer = append(preview.Newer, p)
+		}
+	}
+	for p := range local {
+		if _, ok := listing[p]; !ok {
+			preview.Removed = append(preview.Removed, p)
+		}
+	}
+
+	sort.Strings(preview.Added)
+	sort.Strings(preview.Overwritten)
+	sort.Strings(preview.Removed)
+	sort.Strings(preview.Unchanged)
+	sort.Strings(preview.Newer)
+	return nil
+}
+
+// newerLocally reports whether a local file was modified after the time
+// the backup records for it. Archives keep whole seconds, so the local time
+// is truncated to match.
+func newerLocally(local os.FileInfo, archived ports.FileInfo) bool {
+	return !archived.ModTime.IsZero() && local.ModTime().Truncate(time.Second).After(archived.ModTime)
+}
+
+// Preview compares a backup version with the directory Recover would restore it into.
+// Uses the default production dependencies.
+func Preview(cfg *config.Config, opts RecoverOptions) (RecoverPreview, error) {
+	return defaultService.Preview(cfg, opts)
+}
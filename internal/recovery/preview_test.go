@@ -0,0 +1,66 @@
+package recovery
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jmcdonald/codebak/internal/adapters/osfs"
+	"github.com/jmcdonald/codebak/internal/adapters/ziparchiver"
+)
+
+func TestPreview(t *testing.T) {
+	cfg := setupRestoreTest(t, map[string]string{
+		"main.go":    "package main",
+		"go.mod":     "module test",
+		"src/new.go": "package src",
+		"old.txt":    "from the backup",
+	})
+	projectDir := filepath.Join(cfg.SourceDir, "test-project")
+	for name, content := range map[string]string{"main.go": "package main", "go.mod": "module changed", "old.txt": "stale", "notes.txt": "local only"} {
+		if err := os.WriteFile(filepath.Join(projectDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write local file: %v", err)
+		}
+	}
+	// go.mod was edited after the backup; old.txt has not been touched since before it
+	newer, older := testBackupTime.Add(time.Hour), testBackupTime.Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(projectDir, "go.mod"), newer, newer); err != nil {
+		t.Fatalf("Failed to set modification time: %v", err)
+	}
+	if err := os.Chtimes(filepath.Join(projectDir, "old.txt"), older, older); err != nil {
+		t.Fatalf("Failed to set modification time: %v", err)
+	}
+	svc := NewService(osfs.New(), ziparchiver.New())
+
+	preview, err := svc.Preview(cfg, RecoverOptions{Project: "test-project"})
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+	expected := RecoverPreview{
+		Version:     "20260101-120000",
+		Dir:         projectDir,
+		Added:       []string{"src/new.go"},
+		Overwritten: []string{"go.mod", "old.txt"},
+		Removed:     []string{"notes.txt"},
+		Unchanged:   []string{"main.go"},
+		Newer:       []string{"go.mod"},
+	}
+	if !reflect.DeepEqual(preview, expected) {
+		t.Errorf("preview = %+v, expected %+v", preview, expected)
+	}
+	if preview.Changes() != 4 {
+		t.Errorf("Changes() = %d, expected 4", preview.Changes())
+	}
+
+	// A target that does not exist yet only gains files
+	target := filepath.Join(t.TempDir(), "copy")
+	preview, err = svc.Preview(cfg, RecoverOptions{Project: "test-project", Target: target})
+	if err != nil {
+		t.Fatalf("Preview of a missing target failed: %v", err)
+	}
+	if len(preview.Added) != 4 || preview.Changes() != 4 || preview.Dir != target {
+		t.Errorf("preview = %+v, expected all 4 files added to %s", preview, target)
+	}
+}
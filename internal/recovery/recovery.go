@@ -1,13 +1,21 @@
 package recovery
 
 import (
+	"context"
 	"fmt"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/jmcdonald/codebak/internal/adapters/execgit"
+	"github.com/jmcdonald/codebak/internal/adapters/execrestic"
+	"github.com/jmcdonald/codebak/internal/adapters/filelock"
+	"github.com/jmcdonald/codebak/internal/adapters/multiarchiver"
 	"github.com/jmcdonald/codebak/internal/adapters/osfs"
-	"github.com/jmcdonald/codebak/internal/adapters/ziparchiver"
 	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/fileindex"
 	"github.com/jmcdonald/codebak/internal/manifest"
 	"github.com/jmcdonald/codebak/internal/ports"
 )
@@ -18,30 +26,96 @@ type RecoverOptions struct {
 	Version string // YYYYMMDD-HHMMSS format, empty for latest
 	Wipe    bool   // Delete current before restore
 	Archive bool   // Archive current before restore
+	Target  string // Directory to restore into instead of the project's source; empty for the default
+	Force   bool   // Restore into a non-empty Target
 }
 
 // Service provides recovery operations with injected dependencies.
 type Service struct {
 	fs       ports.FileSystem
 	archiver ports.Archiver
+	locker   ports.Locker       // nil disables cross-process locking
+	git      ports.GitClient    // nil skips restoring git history from bundles
+	restic   ports.ResticClient // nil disables recovering sensitive sources
+}
+
+// Option is a functional option for configuring Service.
+type Option func(*Service)
+
+// WithLocker sets the locker used to serialize recovery with other codebak processes.
+func WithLocker(locker ports.Locker) Option {
+	return func(s *Service) {
+		s.locker = locker
+	}
+}
+
+// WithGit sets the git client used to rebuild repositories from git bundles.
+func WithGit(git ports.GitClient) Option {
+	return func(s *Service) {
+		s.git = git
+	}
+}
+
+// WithRestic sets the restic client used to recover sensitive sources.
+func WithRestic(restic ports.ResticClient) Option {
+	return func(s *Service) {
+		s.restic = restic
+	}
 }
 
 // NewService creates a new recovery service with the given dependencies.
-func NewService(fs ports.FileSystem, archiver ports.Archiver) *Service {
-	return &Service{
+func NewService(fs ports.FileSystem, archiver ports.Archiver, opts ...Option) *Service {
+	s := &Service{
 		fs:       fs,
 		archiver: archiver,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // NewDefaultService creates a recovery service with real production dependencies.
 func NewDefaultService() *Service {
 	return NewService(
 		osfs.New(),
-		ziparchiver.New(),
+		multiarchiver.New(),
+		WithLocker(filelock.New()),
+		WithGit(execgit.New()),
+		WithRestic(execrestic.New()),
 	)
 }
 
+// lockProject takes the cross-process lock for a project's backups and
+// returns the function that releases it.
+func (s *Service) lockProject(cfg *config.Config, backupDir, project string) (func(), error) {
+	if s.locker == nil {
+		return func() {}, nil
+	}
+	l, err := s.locker.LockProject(backupDir, project, cfg.LockWait)
+	if err != nil {
+		return nil, err
+	}
+	return func() { _ = l.Unlock() }, nil
+}
+
+// lockBackupDir takes the cross-process lock for the whole backup directory
+// and returns the function that releases it.
+func (s *Service) lockBackupDir(cfg *config.Config) (func(), error) {
+	if s.locker == nil {
+		return func() {}, nil
+	}
+	backupDir, err := config.ExpandPath(cfg.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+	l, err := s.locker.LockBackupDir(backupDir, cfg.LockWait)
+	if err != nil {
+		return nil, err
+	}
+	return func() { _ = l.Unlock() }, nil
+}
+
 // Verify checks the integrity of a backup by comparing checksums.
 func (s *Service) Verify(cfg *config.Config, project, version string) error {
 	backupDir, err := config.ExpandPath(cfg.BackupDir)
@@ -49,34 +123,47 @@ func (s *Service) Verify(cfg *config.Config, project, version string) error {
 		return err
 	}
 
-	m, err := manifest.Load(backupDir, project)
+	// Keep a concurrent prune from removing the backup mid-check
+	unlock, err := s.lockProject(cfg, backupDir, project)
 	if err != nil {
-		return fmt.Errorf("loading manifest: %w", err)
+		return err
 	}
+	defer unlock()
 
-	// Find the backup entry
-	var entry *manifest.BackupEntry
-	if version == "" {
-		entry = m.LatestBackup()
-	} else {
-		for i := range m.Backups {
-			if m.Backups[i].File == version+".zip" {
-				entry = &m.Backups[i]
-				break
-			}
-		}
+	return s.verify(backupDir, project, version)
+}
+
+// verify checks a backup's checksum. The caller must hold the project lock.
+func (s *Service) verify(backupDir, project, version string) error {
+	entry, err := findEntry(backupDir, project, version)
+	if err != nil {
+		return err
+	}
+	return verifyChecksums(filepath.Join(backupDir, project), entry)
+}
+
+// findEntry returns the manifest entry of a project's version, or of its
+// latest version when version is "".
+func findEntry(backupDir, project, version string) (*manifest.BackupEntry, error) {
+	m, err := manifest.Load(backupDir, project)
+	if err != nil {
+		return nil, fmt.Errorf("loading manifest: %w", err)
 	}
 
+	entry := m.FindBackup(version)
 	if entry == nil {
-		if version == "" {
-			return fmt.Errorf("no backups found for project: %s", project)
+		if len(m.Backups) == 0 {
+			return nil, fmt.Errorf("no backups found for project: %s", project)
 		}
-		return fmt.Errorf("backup not found: %s", version)
+		return nil, fmt.Errorf("backup not found: %s", version)
 	}
+	return entry, nil
+}
 
-	// Verify checksum
-	zipPath := filepath.Join(backupDir, project, entry.File)
-	actualChecksum, err := manifest.ComputeSHA256(zipPath)
+// verifyChecksums compares the SHA-256 of a version's archive, and of its git
+// bundle if it has one, with the manifest.
+func verifyChecksums(projectDir string, entry *manifest.BackupEntry) error {
+	actualChecksum, err := manifest.ComputeSHA256(filepath.Join(projectDir, entry.File))
 	if err != nil {
 		return fmt.Errorf("computing checksum: %w", err)
 	}
@@ -85,43 +172,49 @@ func (s *Service) Verify(cfg *config.Config, project, version string) error {
 		return fmt.Errorf("checksum mismatch: expected %s, got %s", entry.SHA256, actualChecksum)
 	}
 
+	if entry.Bundle != "" {
+		bundleChecksum, err := manifest.ComputeSHA256(filepath.Join(projectDir, entry.Bundle))
+		if err != nil {
+			return fmt.Errorf("computing bundle checksum: %w", err)
+		}
+		if bundleChecksum != entry.BundleSHA256 {
+			return fmt.Errorf("bundle checksum mismatch: expected %s, got %s", entry.BundleSHA256, bundleChecksum)
+		}
+	}
+
 	return nil
 }
 
 // Recover restores a project from backup.
-func (s *Service) Recover(cfg *config.Config, opts RecoverOptions) error {
+// With opts.Target the project is restored into that directory instead, for
+// example next to the live checkout. A missing or empty target needs no wipe
+// or archive; a non-empty one is refused unless opts.Force is set, in which
+// case it is wiped or archived as asked, or restored over.
+// Cancelling ctx before the existing project is touched leaves it as it was;
+// cancelling later removes the partially restored project.
+func (s *Service) Recover(ctx context.Context, cfg *config.Config, opts RecoverOptions) error {
 	backupDir, err := config.ExpandPath(cfg.BackupDir)
 	if err != nil {
 		return err
 	}
 
-	// Search for project in all sources, or use first source as default
 	var projectPath string
-	sources := cfg.GetSources()
-	for _, source := range sources {
-		sourceDir, err := config.ExpandPath(source.Path)
-		if err != nil {
-			continue
-		}
-		candidatePath := filepath.Join(sourceDir, opts.Project)
-		if _, err := s.fs.Stat(candidatePath); err == nil {
-			projectPath = candidatePath
-			break
-		}
-	}
-	// If project not found in any source, restore to first source (for deleted projects)
-	if projectPath == "" && len(sources) > 0 {
-		sourceDir, err := config.ExpandPath(sources[0].Path)
-		if err != nil {
-			return err
-		}
-		projectPath = filepath.Join(sourceDir, opts.Project)
+	if opts.Target != "" {
+		projectPath, err = config.ExpandPath(opts.Target)
+	} else {
+		projectPath, err = s.locateProject(cfg, opts.Project)
 	}
-	if projectPath == "" {
-		return fmt.Errorf("no source directories configured")
+	if err != nil {
+		return err
 	}
 	sourceDir := filepath.Dir(projectPath)
 
+	unlock, err := s.lockProject(cfg, backupDir, opts.Project)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Load manifest
 	m, err := manifest.Load(backupDir, opts.Project)
 	if err != nil {
@@ -129,20 +222,10 @@ func (s *Service) Recover(cfg *config.Config, opts RecoverOptions) error {
 	}
 
 	// Find the backup entry
-	var entry *manifest.BackupEntry
-	if opts.Version == "" {
-		entry = m.LatestBackup()
-	} else {
-		for i := range m.Backups {
-			if m.Backups[i].File == opts.Version+".zip" {
-				entry = &m.Backups[i]
-				break
-			}
-		}
-	}
+	entry := m.FindBackup(opts.Version)
 
 	if entry == nil {
-		if opts.Version == "" {
+		if len(m.Backups) == 0 {
 			return fmt.Errorf("no backups found for project: %s", opts.Project)
 		}
 		return fmt.Errorf("backup version not found: %s", opts.Version)
@@ -150,12 +233,32 @@ func (s *Service) Recover(cfg *config.Config, opts RecoverOptions) error {
 
 	// Verify checksum before recovery
 	zipPath := filepath.Join(backupDir, opts.Project, entry.File)
-	if err := s.Verify(cfg, opts.Project, opts.Version); err != nil {
+	if err := s.verify(backupDir, opts.Project, opts.Version); err != nil {
 		return fmt.Errorf("verification failed: %w", err)
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Handle existing project directory
-	if _, err := s.fs.Stat(projectPath); err == nil {
+	_, err = s.fs.Stat(projectPath)
+	exists, overlay := err == nil, false
+	if exists && opts.Target != "" {
+		entries, err := s.fs.ReadDir(projectPath)
+		if err != nil {
+			return fmt.Errorf("reading target: %w", err)
+		}
+		switch {
+		case len(entries) == 0:
+			exists = false
+		case !opts.Force:
+			return fmt.Errorf("target is not empty: %s (use --force)", projectPath)
+		case !opts.Wipe && !opts.Archive:
+			exists, overlay = false, true
+		}
+	}
+	if exists {
 		if opts.Wipe {
 			// Delete current
 			if err := s.fs.RemoveAll(projectPath); err != nil {
@@ -163,7 +266,7 @@ func (s *Service) Recover(cfg *config.Config, opts RecoverOptions) error {
 			}
 		} else if opts.Archive {
 			// Archive current first
-			archiveName := fmt.Sprintf("%s-archived-%s", opts.Project, time.Now().Format("20060102-150405"))
+			archiveName := fmt.Sprintf("%s-archived-%s", filepath.Base(projectPath), time.Now().Format("20060102-150405"))
 			archivePath := filepath.Join(sourceDir, archiveName)
 			if err := s.fs.Rename(projectPath, archivePath); err != nil {
 				return fmt.Errorf("archiving current project: %w", err)
@@ -173,14 +276,106 @@ func (s *Service) Recover(cfg *config.Config, opts RecoverOptions) error {
 		}
 	}
 
-	// Extract zip using archiver
-	if err := s.archiver.Extract(zipPath, sourceDir); err != nil {
+	// Files restored over a forced target are mixed with the files it
+	// already held, so they are left in place even when cancelled
+	discard := func() {
+		if !overlay {
+			s.discardCancelled(ctx, projectPath)
+		}
+	}
+
+	if err := s.extractProject(ctx, zipPath, projectPath, opts.Target != ""); err != nil {
+		discard()
 		return fmt.Errorf("extracting backup: %w", err)
 	}
 
+	// Check the restored files against the version's file index; versions
+	// made before indexes existed rely on the archive checksum alone
+	if idx, err := fileindex.Load(backupDir, opts.Project, entry.Version()); err == nil {
+		mismatches, err := idx.Check(projectPath)
+		if err != nil {
+			return fmt.Errorf("checking restored files: %w", err)
+		}
+		if len(mismatches) > 0 {
+			return fmt.Errorf("restored files do not match the backup: %s", describeMismatches(mismatches))
+		}
+	}
+
+	// Rebuild the repository around the restored working tree, unless the
+	// archive already brought its own .git directory
+	if entry.Bundle != "" && s.git != nil && !s.git.IsRepo(projectPath) {
+		bundlePath := filepath.Join(backupDir, opts.Project, entry.Bundle)
+		if err := s.git.RestoreBundle(ctx, bundlePath, projectPath); err != nil {
+			discard()
+			return fmt.Errorf("restoring git history: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// extractProject extracts a backup to projectPath. Archives hold the project
+// under its own name, so a target with another name is filled file by file.
+func (s *Service) extractProject(ctx context.Context, archivePath, projectPath string, target bool) error {
+	if !target {
+		return s.archiver.Extract(ctx, archivePath, filepath.Dir(projectPath))
+	}
+	listing, err := s.archiver.List(archivePath)
+	if err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(listing))
+	for p := range listing {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	if err := s.fs.MkdirAll(projectPath, 0755); err != nil {
+		return err
+	}
+	return s.archiver.ExtractFiles(ctx, archivePath, projectPath, paths)
+}
+
+// locateProject returns the project's directory in the first source that
+// has it, or in the first source for deleted projects.
+func (s *Service) locateProject(cfg *config.Config, project string) (string, error) {
+	sources := cfg.GetSources()
+	for _, source := range sources {
+		sourceDir, err := config.ExpandPath(source.Path)
+		if err != nil {
+			continue
+		}
+		candidatePath := filepath.Join(sourceDir, project)
+		if _, err := s.fs.Stat(candidatePath); err == nil {
+			return candidatePath, nil
+		}
+	}
+	if len(sources) == 0 {
+		return "", fmt.Errorf("no source directories configured")
+	}
+	sourceDir, err := config.ExpandPath(sources[0].Path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(sourceDir, project), nil
+}
+
+// describeMismatches summarizes restored files that differ from the index.
+func describeMismatches(mismatches []fileindex.Mismatch) string {
+	first := mismatches[0]
+	if len(mismatches) == 1 {
+		return fmt.Sprintf("%s (%s)", first.Path, first.Reason)
+	}
+	return fmt.Sprintf("%s (%s) and %d more", first.Path, first.Reason, len(mismatches)-1)
+}
+
+// discardCancelled removes a partially restored project when ctx was cancelled.
+// Failures for other reasons leave the files in place for inspection.
+func (s *Service) discardCancelled(ctx context.Context, projectPath string) {
+	if ctx.Err() != nil {
+		_ = s.fs.RemoveAll(projectPath)
+	}
+}
+
 // ListVersions returns all backup versions for a project.
 func (s *Service) ListVersions(cfg *config.Config, project string) ([]manifest.BackupEntry, error) {
 	backupDir, err := config.ExpandPath(cfg.BackupDir)
@@ -196,6 +391,87 @@ func (s *Service) ListVersions(cfg *config.Config, project string) ([]manifest.B
 	return m.Backups, nil
 }
 
+// FileVersion is one backup version's copy of a file.
+type FileVersion struct {
+	Version   string
+	CreatedAt time.Time
+	Size      int64
+	SHA256    string // Empty for versions without a file index
+	Changed   bool   // Differs from the previous version, or absent from it
+
+	crc32 uint32 // From the archive, for versions without a file index
+}
+
+// FileHistory returns the versions of project that contain path, oldest
+// first. Versions with a file index are looked up without opening their
+// archive; older versions fall back to listing it.
+func (s *Service) FileHistory(cfg *config.Config, project, filePath string) ([]FileVersion, error) {
+	backupDir, err := config.ExpandPath(cfg.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := manifest.Load(backupDir, project)
+	if err != nil {
+		return nil, fmt.Errorf("loading manifest: %w", err)
+	}
+	if len(m.Backups) == 0 {
+		return nil, fmt.Errorf("no backups found for project: %s", project)
+	}
+
+	filePath = strings.TrimPrefix(path.Clean(filepath.ToSlash(filePath)), "/")
+	var history []FileVersion
+	var prev *FileVersion
+	for _, entry := range m.Backups {
+		v, found, err := s.findFile(backupDir, project, entry, filePath)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", entry.Version(), err)
+		}
+		if !found {
+			prev = nil
+			continue
+		}
+		v.Changed = prev == nil || fileChanged(*prev, v)
+		history = append(history, v)
+		prev = &history[len(history)-1]
+	}
+	return history, nil
+}
+
+// findFile looks filePath up in one backup version.
+func (s *Service) findFile(backupDir, project string, entry manifest.BackupEntry, filePath string) (FileVersion, bool, error) {
+	v := FileVersion{Version: entry.Version(), CreatedAt: entry.CreatedAt}
+
+	if idx, err := fileindex.Load(backupDir, project, entry.Version()); err == nil {
+		f, ok := idx.Lookup(filePath)
+		v.Size, v.SHA256 = f.Size, f.SHA256
+		return v, ok, nil
+	}
+
+	files, err := s.archiver.List(filepath.Join(backupDir, project, entry.File))
+	if err != nil {
+		return v, false, err
+	}
+	info, ok := files[filePath]
+	v.Size, v.crc32 = info.Size, info.CRC32
+	return v, ok, nil
+}
+
+// fileChanged reports whether two copies of a file differ. Copies from an
+// indexed and an unindexed version can only be compared by size.
+func fileChanged(a, b FileVersion) bool {
+	switch {
+	case a.Size != b.Size:
+		return true
+	case a.SHA256 != "" && b.SHA256 != "":
+		return a.SHA256 != b.SHA256
+	case a.SHA256 == "" && b.SHA256 == "":
+		return a.crc32 != b.crc32
+	default:
+		return false
+	}
+}
+
 // ============================================================================
 // Backward-compatible package-level functions using default service
 // ============================================================================
@@ -210,8 +486,8 @@ func Verify(cfg *config.Config, project, version string) error {
 
 // Recover restores a project from backup.
 // Uses the default production dependencies.
-func Recover(cfg *config.Config, opts RecoverOptions) error {
-	return defaultService.Recover(cfg, opts)
+func Recover(ctx context.Context, cfg *config.Config, opts RecoverOptions) error {
+	return defaultService.Recover(ctx, cfg, opts)
 }
 
 // ListVersions returns all backup versions for a project.
@@ -219,3 +495,9 @@ func Recover(cfg *config.Config, opts RecoverOptions) error {
 func ListVersions(cfg *config.Config, project string) ([]manifest.BackupEntry, error) {
 	return defaultService.ListVersions(cfg, project)
 }
+
+// FileHistory returns the versions of project that contain path.
+// Uses the default production dependencies.
+func FileHistory(cfg *config.Config, project, filePath string) ([]FileVersion, error) {
+	return defaultService.FileHistory(cfg, project, filePath)
+}
@@ -2,7 +2,9 @@ package recovery
 
 import (
 	"archive/zip"
+	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -10,9 +12,14 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/jmcdonald/codebak/internal/adapters/osfs"
 	"github.com/jmcdonald/codebak/internal/adapters/ziparchiver"
 	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/fileindex"
+	"github.com/jmcdonald/codebak/internal/manifest"
+	"github.com/jmcdonald/codebak/internal/mocks"
 	"github.com/jmcdonald/codebak/internal/ports"
 )
 
@@ -138,7 +145,7 @@ func TestExtractZipZipSlipPrevention(t *testing.T) {
 	}
 
 	archiver := ziparchiver.New()
-	err = archiver.Extract(maliciousZipPath, destDir)
+	err = archiver.Extract(context.Background(), maliciousZipPath, destDir)
 	if err == nil {
 		t.Error("Extract should have rejected malicious zip with path traversal")
 	}
@@ -168,7 +175,7 @@ func TestExtractZipValidArchive(t *testing.T) {
 	}
 
 	archiver := ziparchiver.New()
-	err = archiver.Extract(zipPath, destDir)
+	err = archiver.Extract(context.Background(), zipPath, destDir)
 	if err != nil {
 		t.Fatalf("Extract failed for valid archive: %v", err)
 	}
@@ -347,7 +354,7 @@ func TestRecoverWithWipe(t *testing.T) {
 		Wipe:    true,
 	}
 
-	err = Recover(cfg, opts)
+	err = Recover(context.Background(), cfg, opts)
 	if err != nil {
 		t.Fatalf("Recover failed: %v", err)
 	}
@@ -395,7 +402,7 @@ func TestRecoverWithArchive(t *testing.T) {
 		Archive: true,
 	}
 
-	err = Recover(cfg, opts)
+	err = Recover(context.Background(), cfg, opts)
 	if err != nil {
 		t.Fatalf("Recover failed: %v", err)
 	}
@@ -442,7 +449,7 @@ func TestRecoverExistingNoOption(t *testing.T) {
 		// No Wipe or Archive
 	}
 
-	err = Recover(cfg, opts)
+	err = Recover(context.Background(), cfg, opts)
 	if err == nil {
 		t.Error("Recover should fail when project exists without --wipe or --archive")
 	}
@@ -473,7 +480,7 @@ func TestRecoverToNewLocation(t *testing.T) {
 		Project: "test-project",
 	}
 
-	err = Recover(cfg, opts)
+	err = Recover(context.Background(), cfg, opts)
 	if err != nil {
 		t.Fatalf("Recover failed: %v", err)
 	}
@@ -490,6 +497,88 @@ func TestRecoverToNewLocation(t *testing.T) {
 	}
 }
 
+func TestRecoverToTarget(t *testing.T) {
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+	sourceDir := filepath.Join(tempDir, "source")
+	livePath := filepath.Join(sourceDir, "test-project")
+	if err := os.MkdirAll(livePath, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(livePath, "file.txt"), []byte("live"), 0644); err != nil {
+		t.Fatalf("Failed to create live file: %v", err)
+	}
+	cfg := &config.Config{SourceDir: sourceDir, BackupDir: filepath.Join(tempDir, "backups")}
+
+	// An empty target needs neither --wipe nor --archive
+	target := filepath.Join(tempDir, "compare")
+	if err := os.MkdirAll(target, 0755); err != nil {
+		t.Fatalf("Failed to create target: %v", err)
+	}
+	if err := Recover(context.Background(), cfg, RecoverOptions{Project: "test-project", Target: target}); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(target, "file.txt")); string(content) != "test content" {
+		t.Errorf("target file.txt = %q, expected the backup's content", content)
+	}
+	if content, _ := os.ReadFile(filepath.Join(livePath, "file.txt")); string(content) != "live" {
+		t.Error("recovering to a target should leave the live project alone")
+	}
+
+	// A missing target is created
+	missing := filepath.Join(tempDir, "new", "copy")
+	if err := Recover(context.Background(), cfg, RecoverOptions{Project: "test-project", Target: missing}); err != nil {
+		t.Fatalf("Recover to a missing target failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(missing, "file.txt")); err != nil {
+		t.Errorf("file.txt was not restored to the missing target: %v", err)
+	}
+}
+
+func TestRecoverToNonEmptyTarget(t *testing.T) {
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+	cfg := &config.Config{SourceDir: filepath.Join(tempDir, "source"), BackupDir: filepath.Join(tempDir, "backups")}
+	target := filepath.Join(tempDir, "compare")
+	if err := os.MkdirAll(target, 0755); err != nil {
+		t.Fatalf("Failed to create target: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(target, "notes.txt"), []byte("notes"), 0644); err != nil {
+		t.Fatalf("Failed to create target file: %v", err)
+	}
+
+	err := Recover(context.Background(), cfg, RecoverOptions{Project: "test-project", Target: target})
+	if err == nil || !strings.Contains(err.Error(), "target is not empty") {
+		t.Fatalf("err = %v, expected a non-empty target to be refused", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "file.txt")); err == nil {
+		t.Error("a refused target should not be written to")
+	}
+
+	// Forced without --wipe or --archive, the backup is restored over it
+	if err := Recover(context.Background(), cfg, RecoverOptions{Project: "test-project", Target: target, Force: true}); err != nil {
+		t.Fatalf("forced Recover failed: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(target, "file.txt")); string(content) != "test content" {
+		t.Errorf("target file.txt = %q, expected the backup's content", content)
+	}
+	if _, err := os.Stat(filepath.Join(target, "notes.txt")); err != nil {
+		t.Error("restoring over a target should keep its other files")
+	}
+
+	// Forced with --archive, the target is moved aside first
+	if err := Recover(context.Background(), cfg, RecoverOptions{Project: "test-project", Target: target, Force: true, Archive: true}); err != nil {
+		t.Fatalf("forced Recover with Archive failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "notes.txt")); err == nil {
+		t.Error("an archived target should not keep its old files")
+	}
+	archived, _ := filepath.Glob(filepath.Join(tempDir, "compare-archived-*", "notes.txt"))
+	if len(archived) != 1 {
+		t.Errorf("archived targets = %v, expected the old target archived next to it", archived)
+	}
+}
+
 // setupTestBackup creates a test backup with manifest
 func setupTestBackup(t *testing.T, tempDir string) {
 	t.Helper()
@@ -666,7 +755,7 @@ func TestRecoverSpecificVersion(t *testing.T) {
 		Version: "20260101-120000", // Specific version
 	}
 
-	err = Recover(cfg, opts)
+	err = Recover(context.Background(), cfg, opts)
 	if err != nil {
 		t.Fatalf("Recover with specific version failed: %v", err)
 	}
@@ -698,7 +787,7 @@ func TestRecoverVersionNotFound(t *testing.T) {
 		Version: "19990101-000000", // Non-existent version
 	}
 
-	err = Recover(cfg, opts)
+	err = Recover(context.Background(), cfg, opts)
 	if err == nil {
 		t.Error("Recover should fail for non-existent version")
 	}
@@ -723,7 +812,7 @@ func TestRecoverNoBackupsForProject(t *testing.T) {
 		Project: "nonexistent-project",
 	}
 
-	err = Recover(cfg, opts)
+	err = Recover(context.Background(), cfg, opts)
 	if err == nil {
 		t.Error("Recover should fail for project with no backups")
 	}
@@ -744,6 +833,12 @@ func TestServiceNewServiceAndNewDefaultService(t *testing.T) {
 	if svc.archiver == nil {
 		t.Error("NewDefaultService should set archiver")
 	}
+	if svc.locker == nil {
+		t.Error("NewDefaultService should set locker")
+	}
+	if svc.git == nil {
+		t.Error("NewDefaultService should set git client")
+	}
 
 	// Test NewService with mocks
 	mockFS := &mockTestFS{}
@@ -761,6 +856,232 @@ func TestServiceNewServiceAndNewDefaultService(t *testing.T) {
 	}
 }
 
+func TestRecoverHoldsProjectLock(t *testing.T) {
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+
+	backupDir := filepath.Join(tempDir, "backups")
+	cfg := &config.Config{
+		SourceDir: filepath.Join(tempDir, "source"),
+		BackupDir: backupDir,
+		LockWait:  5 * time.Second,
+	}
+	locker := mocks.NewMockLocker()
+	svc := NewService(osfs.New(), ziparchiver.New(), WithLocker(locker))
+
+	if err := svc.Recover(context.Background(), cfg, RecoverOptions{Project: "test-project"}); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+
+	// The internal checksum verification must not take the lock a second time
+	key := "project:" + backupDir + "/test-project"
+	if len(locker.Calls) != 1 || locker.Calls[0] != key {
+		t.Errorf("lock calls = %v, expected one call for %s", locker.Calls, key)
+	}
+	if locker.Waits[0] != 5*time.Second {
+		t.Errorf("lock wait = %v, expected cfg.LockWait", locker.Waits[0])
+	}
+	if locker.Held[key] {
+		t.Error("project lock should be released after recovery")
+	}
+}
+
+func TestRecoverAndVerifyFailWhileLocked(t *testing.T) {
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+
+	backupDir := filepath.Join(tempDir, "backups")
+	cfg := &config.Config{
+		SourceDir: filepath.Join(tempDir, "source"),
+		BackupDir: backupDir,
+	}
+	locker := mocks.NewMockLocker()
+	locker.Held["project:"+backupDir+"/test-project"] = true
+	svc := NewService(osfs.New(), ziparchiver.New(), WithLocker(locker))
+
+	if err := svc.Recover(context.Background(), cfg, RecoverOptions{Project: "test-project"}); !errors.Is(err, ports.ErrLocked) {
+		t.Errorf("Recover err = %v, expected ErrLocked", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "source", "test-project")); err == nil {
+		t.Error("Recover should not extract while the project is locked")
+	}
+	if err := svc.Verify(cfg, "test-project", ""); !errors.Is(err, ports.ErrLocked) {
+		t.Errorf("Verify err = %v, expected ErrLocked", err)
+	}
+}
+
+// addTestBundle attaches a git bundle to the backup created by setupTestBackup.
+func addTestBundle(t *testing.T, tempDir string) string {
+	t.Helper()
+	backupDir := filepath.Join(tempDir, "backups")
+	bundlePath := filepath.Join(backupDir, "test-project", "20260101-120000"+manifest.BundleExt)
+	if err := os.WriteFile(bundlePath, []byte("# v2 git bundle\n"), 0644); err != nil {
+		t.Fatalf("Failed to write bundle: %v", err)
+	}
+
+	m, err := manifest.Load(backupDir, "test-project")
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+	m.Backups[0].Bundle = filepath.Base(bundlePath)
+	m.Backups[0].BundleSHA256 = computeTestChecksum(t, bundlePath)
+	if err := m.Save(backupDir); err != nil {
+		t.Fatalf("Failed to save manifest: %v", err)
+	}
+	return bundlePath
+}
+
+func TestRecoverRestoresGitBundle(t *testing.T) {
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+	bundlePath := addTestBundle(t, tempDir)
+
+	cfg := &config.Config{
+		SourceDir: filepath.Join(tempDir, "source"),
+		BackupDir: filepath.Join(tempDir, "backups"),
+	}
+	git := mocks.NewMockGitClient()
+	svc := NewService(osfs.New(), ziparchiver.New(), WithGit(git))
+
+	if err := svc.Recover(context.Background(), cfg, RecoverOptions{Project: "test-project"}); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+
+	projectPath := filepath.Join(tempDir, "source", "test-project")
+	if got := git.Restored[projectPath]; got != bundlePath {
+		t.Errorf("RestoreBundle called with %q, expected %q", got, bundlePath)
+	}
+	if _, err := os.Stat(filepath.Join(projectPath, "file.txt")); err != nil {
+		t.Errorf("working tree not restored: %v", err)
+	}
+}
+
+func TestRecoverSkipsBundleWhenArchiveHasRepo(t *testing.T) {
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+	addTestBundle(t, tempDir)
+
+	cfg := &config.Config{
+		SourceDir: filepath.Join(tempDir, "source"),
+		BackupDir: filepath.Join(tempDir, "backups"),
+	}
+	projectPath := filepath.Join(tempDir, "source", "test-project")
+	git := mocks.NewMockGitClient()
+	git.Repos[projectPath] = true // .git was archived with the working tree
+	svc := NewService(osfs.New(), ziparchiver.New(), WithGit(git))
+
+	if err := svc.Recover(context.Background(), cfg, RecoverOptions{Project: "test-project"}); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if len(git.Restored) != 0 {
+		t.Errorf("RestoreBundle should not run over an existing repository, got %v", git.Restored)
+	}
+}
+
+func TestRecoverGitBundleError(t *testing.T) {
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+	addTestBundle(t, tempDir)
+
+	cfg := &config.Config{
+		SourceDir: filepath.Join(tempDir, "source"),
+		BackupDir: filepath.Join(tempDir, "backups"),
+	}
+	git := mocks.NewMockGitClient()
+	git.BundleErrors[filepath.Join(tempDir, "source", "test-project")] = errors.New("bad bundle")
+	svc := NewService(osfs.New(), ziparchiver.New(), WithGit(git))
+
+	err := svc.Recover(context.Background(), cfg, RecoverOptions{Project: "test-project"})
+	if err == nil || !strings.Contains(err.Error(), "restoring git history") {
+		t.Errorf("err = %v, expected git history error", err)
+	}
+}
+
+// cancellingGit cancels the recovery while the git history is being restored.
+type cancellingGit struct {
+	*mocks.MockGitClient
+	cancel context.CancelFunc
+}
+
+func (g *cancellingGit) RestoreBundle(ctx context.Context, bundlePath, repoPath string) error {
+	g.cancel()
+	return ctx.Err()
+}
+
+func TestRecoverCancelledRemovesPartialProject(t *testing.T) {
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+	addTestBundle(t, tempDir)
+
+	cfg := &config.Config{
+		SourceDir: filepath.Join(tempDir, "source"),
+		BackupDir: filepath.Join(tempDir, "backups"),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	git := &cancellingGit{MockGitClient: mocks.NewMockGitClient(), cancel: cancel}
+	svc := NewService(osfs.New(), ziparchiver.New(), WithGit(git))
+
+	err := svc.Recover(ctx, cfg, RecoverOptions{Project: "test-project"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, expected context.Canceled", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "source", "test-project")); !os.IsNotExist(err) {
+		t.Error("cancelled recovery should remove the partially restored project")
+	}
+}
+
+func TestRecoverCancelledLeavesExistingProject(t *testing.T) {
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+
+	projectPath := filepath.Join(tempDir, "source", "test-project")
+	if err := os.MkdirAll(projectPath, 0755); err != nil {
+		t.Fatalf("Failed to create project: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectPath, "current.txt"), []byte("current"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	cfg := &config.Config{
+		SourceDir: filepath.Join(tempDir, "source"),
+		BackupDir: filepath.Join(tempDir, "backups"),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := NewService(osfs.New(), ziparchiver.New()).Recover(ctx, cfg, RecoverOptions{Project: "test-project", Wipe: true})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, expected context.Canceled", err)
+	}
+	if _, err := os.Stat(filepath.Join(projectPath, "current.txt")); err != nil {
+		t.Errorf("cancelled recovery should not touch the existing project: %v", err)
+	}
+}
+
+func TestVerifyBundleChecksumMismatch(t *testing.T) {
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+	bundlePath := addTestBundle(t, tempDir)
+
+	cfg := &config.Config{
+		SourceDir: filepath.Join(tempDir, "source"),
+		BackupDir: filepath.Join(tempDir, "backups"),
+	}
+	svc := NewService(osfs.New(), ziparchiver.New())
+	if err := svc.Verify(cfg, "test-project", ""); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	if err := os.WriteFile(bundlePath, []byte("tampered"), 0644); err != nil {
+		t.Fatalf("Failed to modify bundle: %v", err)
+	}
+	err := svc.Verify(cfg, "test-project", "")
+	if err == nil || !strings.Contains(err.Error(), "bundle checksum mismatch") {
+		t.Errorf("err = %v, expected bundle checksum mismatch", err)
+	}
+}
+
 // mockTestFS is a minimal mock for testing
 type mockTestFS struct{}
 
@@ -774,21 +1095,28 @@ func (m *mockTestFS) RemoveAll(path string) error
 func (m *mockTestFS) Rename(oldpath, newpath string) error                       { return nil }
 func (m *mockTestFS) Open(name string) (fs.File, error)                          { return nil, nil }
 func (m *mockTestFS) Create(name string) (*os.File, error)                       { return nil, nil }
+func (m *mockTestFS) MkdirTemp(dir, pattern string) (string, error)              { return "", nil }
 func (m *mockTestFS) Walk(root string, fn ports.WalkFunc) error                  { return nil }
 
 // mockTestArchiver is a minimal mock for testing
 type mockTestArchiver struct{}
 
-func (m *mockTestArchiver) Create(destPath, sourceDir string, exclude []string) (int, error) {
-	return 0, nil
+func (m *mockTestArchiver) Create(ctx context.Context, destPath, sourceDir string, exclude ports.Excluder, compression ports.Compression, progress ports.ProgressFunc) (ports.ArchiveResult, error) {
+	return ports.ArchiveResult{}, nil
+}
+func (m *mockTestArchiver) Extract(ctx context.Context, zipPath, destDir string) error { return nil }
+func (m *mockTestArchiver) ExtractFiles(ctx context.Context, archivePath, destDir string, paths []string) error {
+	return nil
 }
-func (m *mockTestArchiver) Extract(zipPath, destDir string) error { return nil }
 func (m *mockTestArchiver) List(zipPath string) (map[string]ports.FileInfo, error) {
 	return nil, nil
 }
 func (m *mockTestArchiver) ReadFile(zipPath, filePath, projectName string) (string, error) {
 	return "", nil
 }
+func (m *mockTestArchiver) Check(ctx context.Context, archivePath string) ([]ports.CheckedFile, error) {
+	return nil, nil
+}
 
 func TestListVersionsWithLoadError(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "codebak-test-*")
@@ -925,7 +1253,7 @@ func TestRecoverManifestLoadError(t *testing.T) {
 		Project: "bad-project",
 	}
 
-	err = Recover(cfg, opts)
+	err = Recover(context.Background(), cfg, opts)
 	if err == nil {
 		t.Error("Recover should fail for malformed manifest")
 	}
@@ -982,7 +1310,7 @@ func TestRecoverVerificationFails(t *testing.T) {
 		Project: "test-project",
 	}
 
-	err = Recover(cfg, opts)
+	err = Recover(context.Background(), cfg, opts)
 	if err == nil {
 		t.Error("Recover should fail when verification fails")
 	}
@@ -1044,7 +1372,7 @@ func TestRecoverExtractError(t *testing.T) {
 		Project: "test-project",
 	}
 
-	err = Recover(cfg, opts)
+	err = Recover(context.Background(), cfg, opts)
 	if err == nil {
 		t.Error("Recover should fail when extracting corrupt zip")
 	}
@@ -1090,3 +1418,86 @@ func TestExtractFile(t *testing.T) {
 		t.Errorf("Content = %q, expected %q", string(content), "test content")
 	}
 }
+
+func TestRecoverChecksFileIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"matching", "test content", false},
+		{"mismatched", "other content", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			setupTestBackup(t, tempDir)
+			backupDir := filepath.Join(tempDir, "backups")
+			sum := sha256.Sum256([]byte(tt.content))
+			files := []fileindex.File{{Path: "file.txt", Size: 12, SHA256: fmt.Sprintf("%x", sum)}}
+			if err := fileindex.Write(backupDir, "test-project", "20260101-120000", files); err != nil {
+				t.Fatalf("Failed to write file index: %v", err)
+			}
+			cfg := &config.Config{SourceDir: filepath.Join(tempDir, "source"), BackupDir: backupDir}
+
+			err := Recover(context.Background(), cfg, RecoverOptions{Project: "test-project"})
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "file.txt (checksum)") {
+					t.Errorf("err = %v, expected a checksum mismatch for file.txt", err)
+				}
+			} else if err != nil {
+				t.Errorf("Recover failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestFileHistory(t *testing.T) {
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+	backupDir := filepath.Join(tempDir, "backups")
+
+	// The first version predates file indexes and is listed from its zip
+	m, err := manifest.Load(backupDir, "test-project")
+	if err != nil {
+		t.Fatalf("manifest.Load failed: %v", err)
+	}
+	indexed := map[string][]fileindex.File{
+		"20260102-120000": {{Path: "file.txt", Size: 20, SHA256: "aaaa"}},
+		"20260103-120000": {{Path: "other.txt", Size: 1, SHA256: "bbbb"}},
+		"20260104-120000": {{Path: "file.txt", Size: 20, SHA256: "aaaa"}},
+		"20260105-120000": {{Path: "file.txt", Size: 20, SHA256: "aaaa"}},
+	}
+	for _, version := range []string{"20260102-120000", "20260103-120000", "20260104-120000", "20260105-120000"} {
+		m.AddBackup(manifest.BackupEntry{File: version + ".zip"})
+		if err := fileindex.Write(backupDir, "test-project", version, indexed[version]); err != nil {
+			t.Fatalf("Failed to write file index: %v", err)
+		}
+	}
+	if err := m.Save(backupDir); err != nil {
+		t.Fatalf("Failed to save manifest: %v", err)
+	}
+	cfg := &config.Config{BackupDir: backupDir}
+
+	history, err := FileHistory(cfg, "test-project", "./file.txt")
+	if err != nil {
+		t.Fatalf("FileHistory failed: %v", err)
+	}
+	var got []string
+	for _, v := range history {
+		got = append(got, fmt.Sprintf("%s %d %v", v.Version, v.Size, v.Changed))
+	}
+	want := []string{
+		"20260101-120000 12 true",
+		"20260102-120000 20 true",
+		"20260104-120000 20 true", // Back after being deleted
+		"20260105-120000 20 false",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("history = %q, expected %q", got, want)
+	}
+
+	if _, err := FileHistory(cfg, "missing", "file.txt"); err == nil {
+		t.Error("FileHistory should fail for a project without backups")
+	}
+}
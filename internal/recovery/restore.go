@@ -0,0 +1,188 @@
+package recovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/ports"
+)
+
+// ErrWouldOverwrite is returned (possibly wrapped) when a restore would
+// replace local files that differ from the backup and overwriting was not
+// allowed.
+var ErrWouldOverwrite = errors.New("restore would overwrite local changes")
+
+// ErrLocalDirectory is returned (possibly wrapped) when the backup holds a
+// file where the project has a directory. Overwriting cannot replace a
+// directory, so the local copy has to be moved aside first.
+var ErrLocalDirectory = errors.New("local directory where the backup has a file")
+
+// RestoreOptions configures a restore of part of a project.
+type RestoreOptions struct {
+	Project   string
+	Path      string // File or directory, relative to the project
+	Version   string // YYYYMMDD-HHMMSS format, empty for latest
+	Target    string // Directory to restore into; empty for the project's own directory
+	Overwrite bool   // Replace local files that differ from the backup
+	DryRun    bool   // Report what would be restored without writing anything
+}
+
+// RestoreResult describes the files a restore wrote or would write.
+type RestoreResult struct {
+	Version     string
+	Dir         string   // Directory the paths are relative to
+	Restored    []string // Written, including the overwritten files
+	Overwritten []string // Local copies that differ from the backup
+	Unchanged   []string // Local copies that already match the backup
+	Newer       []string // Overwritten copies modified after the time the backup records
+}
+
+// Restore extracts one file, or every file under one directory, from a
+// backup without touching the rest of the project. Files are written to
+// the project's directory, or under opts.Target, at their path in the
+// project. Local copies that already match the backup are left alone;
+// copies that differ are only replaced with opts.Overwrite, and otherwise
+// nothing is written and the result lists them with ErrWouldOverwrite.
+// Local files the backup does not hold are never removed, and a local
+// directory in place of a backed up file fails with ErrLocalDirectory. With
+// opts.DryRun the result describes the restore and nothing is written.
+func (s *Service) Restore(ctx context.Context, cfg *config.Config, opts RestoreOptions) (RestoreResult, error) {
+	backupDir, err := config.ExpandPath(cfg.BackupDir)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+
+	want := strings.TrimPrefix(path.Clean(filepath.ToSlash(opts.Path)), "/")
+	if want == ".." || strings.HasPrefix(want, "../") {
+		return RestoreResult{}, fmt.Errorf("path is outside the project: %s", opts.Path)
+	}
+
+	dir := opts.Target
+	if dir == "" {
+		if dir, err = s.locateProject(cfg, opts.Project); err != nil {
+			return RestoreResult{}, err
+		}
+	} else if dir, err = config.ExpandPath(dir); err != nil {
+		return RestoreResult{}, err
+	}
+
+	unlock, err := s.lockProject(cfg, backupDir, opts.Project)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	defer unlock()
+
+	entry, err := findEntry(backupDir, opts.Project, opts.Version)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	result := RestoreResult{Version: entry.Version(), Dir: dir}
+
+	projectDir := filepath.Join(backupDir, opts.Project)
+	if err := verifyChecksums(projectDir, entry); err != nil {
+		return result, fmt.Errorf("verification failed: %w", err)
+	}
+	archivePath := filepath.Join(projectDir, entry.File)
+	listing, err := s.archiver.List(archivePath)
+	if err != nil {
+		return result, fmt.Errorf("listing backup: %w", err)
+	}
+
+	var matches []string
+	for p := range listing {
+		if want == "." || p == want || strings.HasPrefix(p, want+"/") {
+			matches = append(matches, p)
+		}
+	}
+	if len(matches) == 0 {
+		return result, fmt.Errorf("%s is not in backup %s", want, result.Version)
+	}
+	sort.Strings(matches)
+
+	var dirs []string
+	for _, p := range matches {
+		same, exists, err := s.matchesLocal(filepath.Join(dir, filepath.FromSlash(p)), listing[p])
+		if errors.Is(err, ErrLocalDirectory) {
+			dirs = append(dirs, p)
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("reading local %s: %w", p, err)
+		}
+		switch {
+		case same:
+			result.Unchanged = append(result.Unchanged, p)
+			continue
+		case exists:
+			result.Overwritten = append(result.Overwritten, p)
+			if info, err := s.fs.Stat(filepath.Join(dir, filepath.FromSlash(p))); err == nil && newerLocally(info, listing[p]) {
+				result.Newer = append(result.Newer, p)
+			}
+		}
+		result.Restored = append(result.Restored, p)
+	}
+	if len(dirs) > 0 {
+		result.Restored = nil
+		return result, fmt.Errorf("%w: %s", ErrLocalDirectory, strings.Join(dirs, ", "))
+	}
+
+	if opts.DryRun {
+		return result, nil
+	}
+	if len(result.Overwritten) > 0 && !opts.Overwrite {
+		result.Restored = nil
+		return result, fmt.Errorf("%w: %d file(s) differ from the backup", ErrWouldOverwrite, len(result.Overwritten))
+	}
+	if len(result.Restored) == 0 {
+		return result, nil
+	}
+	if err := s.archiver.ExtractFiles(ctx, archivePath, dir, result.Restored); err != nil {
+		return result, fmt.Errorf("extracting backup: %w", err)
+	}
+	return result, nil
+}
+
+// matchesLocal reports whether the file at localPath exists and whether
+// it has the size and CRC32 the archive lists. It fails with
+// ErrLocalDirectory if localPath is a directory.
+func (s *Service) matchesLocal(localPath string, archived ports.FileInfo) (same, exists bool, err error) {
+	info, err := s.fs.Stat(localPath)
+	if os.IsNotExist(err) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	if info.IsDir() {
+		return false, true, ErrLocalDirectory
+	}
+	if info.Size() != archived.Size {
+		return false, true, nil
+	}
+
+	f, err := s.fs.Open(localPath)
+	if err != nil {
+		return false, true, err
+	}
+	defer func() { _ = f.Close() }()
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, f); err != nil {
+		return false, true, err
+	}
+	return h.Sum32() == archived.CRC32, true, nil
+}
+
+// Restore extracts one file or directory from a backup.
+// Uses the default production dependencies.
+func Restore(ctx context.Context, cfg *config.Config, opts RestoreOptions) (RestoreResult, error) {
+	return defaultService.Restore(ctx, cfg, opts)
+}
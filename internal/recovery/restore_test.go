@@ -0,0 +1,212 @@
+package recovery
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmcdonald/codebak/internal/adapters/osfs"
+	"github.com/jmcdonald/codebak/internal/adapters/ziparchiver"
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/manifest"
+)
+
+// testBackupTime is the modification time setupRestoreTest records for
+// every file in the backup.
+var testBackupTime = time.Date(2026, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// setupRestoreTest creates the test backup with its archive replaced by
+// files, and returns a config whose source dir holds test-project.
+func setupRestoreTest(t *testing.T, files map[string]string) *config.Config {
+	t.Helper()
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+	backupDir := filepath.Join(tempDir, "backups")
+	zipPath := filepath.Join(backupDir, "test-project", "20260101-120000.zip")
+
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatalf("Failed to create zip: %v", err)
+	}
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: "test-project/" + name, Modified: testBackupTime})
+		if err != nil {
+			t.Fatalf("Failed to create zip entry: %v", err)
+		}
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write zip entry: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close zip writer: %v", err)
+	}
+	_ = f.Close()
+
+	m, _ := manifest.Load(backupDir, "test-project")
+	m.Backups[0].SHA256 = computeTestChecksum(t, zipPath)
+	m.Backups[0].FileCount = len(files)
+	if err := m.Save(backupDir); err != nil {
+		t.Fatalf("Failed to save manifest: %v", err)
+	}
+
+	sourceDir := filepath.Join(tempDir, "source")
+	if err := os.MkdirAll(filepath.Join(sourceDir, "test-project"), 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	return &config.Config{SourceDir: sourceDir, BackupDir: backupDir}
+}
+
+// readRestored returns the content of a file under dir, or "" if missing.
+func readRestored(dir, path string) string {
+	data, _ := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+	return string(data)
+}
+
+func TestRestoreDirectory(t *testing.T) {
+	cfg := setupRestoreTest(t, map[string]string{
+		"src/a.go":   "package a",
+		"src/b.go":   "package b",
+		"srcmap.txt": "not under src/",
+		"README.md":  "readme",
+	})
+	projectDir := filepath.Join(cfg.SourceDir, "test-project")
+	svc := NewService(osfs.New(), ziparchiver.New())
+
+	result, err := svc.Restore(context.Background(), cfg, RestoreOptions{Project: "test-project", Path: "src/"})
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Restored, []string{"src/a.go", "src/b.go"}) || result.Dir != projectDir {
+		t.Errorf("result = %+v, expected src/a.go and src/b.go restored to %s", result, projectDir)
+	}
+	if readRestored(projectDir, "src/a.go") != "package a" {
+		t.Error("src/a.go was not restored")
+	}
+	if readRestored(projectDir, "srcmap.txt") != "" || readRestored(projectDir, "README.md") != "" {
+		t.Error("files outside the restored path were extracted")
+	}
+
+	// Copies that already match are left alone
+	result, err = svc.Restore(context.Background(), cfg, RestoreOptions{Project: "test-project", Path: "src"})
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if len(result.Restored) != 0 || len(result.Unchanged) != 2 {
+		t.Errorf("result = %+v, expected both files unchanged", result)
+	}
+}
+
+func TestRestoreLocalChanges(t *testing.T) {
+	cfg := setupRestoreTest(t, map[string]string{"main.go": "package main"})
+	projectDir := filepath.Join(cfg.SourceDir, "test-project")
+	if err := os.WriteFile(filepath.Join(projectDir, "main.go"), []byte("clobbered"), 0644); err != nil {
+		t.Fatalf("Failed to write local file: %v", err)
+	}
+	svc := NewService(osfs.New(), ziparchiver.New())
+	opts := RestoreOptions{Project: "test-project", Path: "main.go"}
+
+	result, err := svc.Restore(context.Background(), cfg, opts)
+	if !errors.Is(err, ErrWouldOverwrite) {
+		t.Fatalf("err = %v, expected ErrWouldOverwrite", err)
+	}
+	if !reflect.DeepEqual(result.Overwritten, []string{"main.go"}) || len(result.Restored) != 0 {
+		t.Errorf("result = %+v, expected main.go reported and nothing restored", result)
+	}
+	if readRestored(projectDir, "main.go") != "clobbered" {
+		t.Error("the local copy was overwritten without Overwrite")
+	}
+
+	opts.Overwrite = true
+	result, err = svc.Restore(context.Background(), cfg, opts)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Restored, []string{"main.go"}) || !reflect.DeepEqual(result.Overwritten, []string{"main.go"}) {
+		t.Errorf("result = %+v, expected main.go overwritten", result)
+	}
+	if readRestored(projectDir, "main.go") != "package main" {
+		t.Error("main.go was not restored")
+	}
+}
+
+func TestRestoreLocalDirectoryInTheWay(t *testing.T) {
+	cfg := setupRestoreTest(t, map[string]string{"src/a.go": "package a", "src/config": "debug: false"})
+	projectDir := filepath.Join(cfg.SourceDir, "test-project")
+	if err := os.MkdirAll(filepath.Join(projectDir, "src", "config"), 0755); err != nil {
+		t.Fatalf("Failed to create local directory: %v", err)
+	}
+	svc := NewService(osfs.New(), ziparchiver.New())
+
+	for _, opts := range []RestoreOptions{
+		{Project: "test-project", Path: "src", Overwrite: true},
+		{Project: "test-project", Path: "src", DryRun: true},
+	} {
+		result, err := svc.Restore(context.Background(), cfg, opts)
+		if !errors.Is(err, ErrLocalDirectory) || !strings.Contains(err.Error(), "src/config") {
+			t.Fatalf("err = %v, expected ErrLocalDirectory naming src/config", err)
+		}
+		if len(result.Restored) != 0 || len(result.Overwritten) != 0 {
+			t.Errorf("result = %+v, expected nothing restored or overwritten", result)
+		}
+	}
+	if readRestored(projectDir, "src/a.go") != "" {
+		t.Error("nothing should be extracted when a directory is in the way")
+	}
+}
+
+func TestRestoreDryRun(t *testing.T) {
+	cfg := setupRestoreTest(t, map[string]string{"main.go": "package main", "util.go": "package util"})
+	localPath := filepath.Join(cfg.SourceDir, "test-project", "main.go")
+	if err := os.WriteFile(localPath, []byte("edited"), 0644); err != nil {
+		t.Fatalf("Failed to write local file: %v", err)
+	}
+	edited := testBackupTime.Add(time.Hour)
+	if err := os.Chtimes(localPath, edited, edited); err != nil {
+		t.Fatalf("Failed to set modification time: %v", err)
+	}
+
+	result, err := NewService(osfs.New(), ziparchiver.New()).Restore(context.Background(), cfg, RestoreOptions{Project: "test-project", Path: ".", DryRun: true})
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Restored, []string{"main.go", "util.go"}) || !reflect.DeepEqual(result.Newer, []string{"main.go"}) {
+		t.Errorf("result = %+v, expected both files with main.go newer locally", result)
+	}
+	if readRestored(filepath.Dir(localPath), "main.go") != "edited" || readRestored(filepath.Dir(localPath), "util.go") != "" {
+		t.Error("a dry run should not write anything")
+	}
+}
+
+func TestRestoreToTarget(t *testing.T) {
+	cfg := setupRestoreTest(t, map[string]string{"docs/guide.md": "guide"})
+	target := filepath.Join(t.TempDir(), "scratch")
+	svc := NewService(osfs.New(), ziparchiver.New())
+
+	if _, err := svc.Restore(context.Background(), cfg, RestoreOptions{Project: "test-project", Path: "docs/guide.md", Target: target}); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if readRestored(target, "docs/guide.md") != "guide" {
+		t.Error("docs/guide.md was not restored under the target")
+	}
+	if readRestored(filepath.Join(cfg.SourceDir, "test-project"), "docs/guide.md") != "" {
+		t.Error("restoring to a target should leave the project alone")
+	}
+}
+
+func TestRestoreRejectsBadPaths(t *testing.T) {
+	cfg := setupRestoreTest(t, map[string]string{"main.go": "package main"})
+	svc := NewService(osfs.New(), ziparchiver.New())
+
+	for _, p := range []string{"missing.go", "../escape", "main"} {
+		if _, err := svc.Restore(context.Background(), cfg, RestoreOptions{Project: "test-project", Path: p}); err == nil {
+			t.Errorf("Restore(%q) should fail", p)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/fileindex"
+	"github.com/jmcdonald/codebak/internal/manifest"
+)
+
+// RestoreTestResult is the outcome of a test restore of one backup version.
+type RestoreTestResult struct {
+	Version  string
+	Files    int // Files found in the scratch copy
+	Failures []EntryFailure
+	TestedAt time.Time // Set when the test passed and was recorded
+}
+
+// OK reports whether the version restored cleanly.
+func (r RestoreTestResult) OK() bool {
+	return len(r.Failures) == 0
+}
+
+// indexReasons describes file index mismatches in restore test failures.
+var indexReasons = map[string]string{
+	"missing":  "in file index but not restored",
+	"size":     "size does not match file index",
+	"checksum": "SHA-256 does not match file index",
+}
+
+// RestoreTest extracts a backup into a scratch directory inside the backup
+// directory and checks the restored tree: every file in the archive listing
+// must come back with its listed size, nothing else may appear, nothing may
+// land outside the project directory or come back as a symlink, and every
+// file must keep its owner permissions and stay readable. When the version
+// has a file index, the restored files are also checked against it. The
+// scratch directory is always removed. A passing test is recorded on the
+// manifest entry. The error is reserved for backups that cannot be tested
+// at all; extraction failures are reported as failures.
+func (s *Service) RestoreTest(ctx context.Context, cfg *config.Config, project, version string) (RestoreTestResult, error) {
+	backupDir, err := config.ExpandPath(cfg.BackupDir)
+	if err != nil {
+		return RestoreTestResult{}, err
+	}
+
+	unlock, err := s.lockProject(cfg, backupDir, project)
+	if err != nil {
+		return RestoreTestResult{}, err
+	}
+	defer unlock()
+
+	m, err := manifest.Load(backupDir, project)
+	if err != nil {
+		return RestoreTestResult{}, fmt.Errorf("loading manifest: %w", err)
+	}
+	entry := m.FindBackup(version)
+	if entry == nil {
+		if version == "" {
+			return RestoreTestResult{}, fmt.Errorf("no backups found for project: %s", project)
+		}
+		return RestoreTestResult{}, fmt.Errorf("backup not found: %s", version)
+	}
+
+	result, err := s.restoreTest(ctx, backupDir, project, entry)
+	if err != nil || !result.OK() {
+		return result, err
+	}
+
+	testedAt := time.Now()
+	entry.LastRestoreTest = &testedAt
+	if err := m.Save(backupDir); err != nil {
+		return result, fmt.Errorf("recording restore test: %w", err)
+	}
+	result.TestedAt = testedAt
+	return result, nil
+}
+
+// restoreTest extracts entry into a scratch directory and checks the result.
+// The caller must hold the project lock.
+func (s *Service) restoreTest(ctx context.Context, backupDir, project string, entry *manifest.BackupEntry) (RestoreTestResult, error) {
+	result := RestoreTestResult{Version: entry.Version()}
+	failed := make(map[string]bool)
+	fail := func(path, reason string) {
+		failed[path] = true
+		result.Failures = append(result.Failures, EntryFailure{Path: path, Reason: reason})
+	}
+
+	zipPath := filepath.Join(backupDir, project, entry.File)
+	listing, err := s.archiver.List(zipPath)
+	if err != nil {
+		return result, fmt.Errorf("listing archive: %w", err)
+	}
+	idx, err := fileindex.Load(backupDir, project, entry.Version())
+	if err != nil && !os.IsNotExist(err) {
+		fail("", err.Error())
+	}
+
+	// Refuse the whole archive before extracting if any entry would land
+	// outside the project directory, whatever the archiver checks itself
+	escapes := false
+	for name := range listing {
+		if clean := path.Clean(name); clean == ".." || strings.HasPrefix(clean, "../") || path.IsAbs(clean) {
+			fail(name, "entry escapes the project directory")
+			escapes = true
+		}
+	}
+	if escapes {
+		return result, nil
+	}
+
+	// The dot prefix keeps project scans from mistaking it for a project
+	scratch, err := os.MkdirTemp(backupDir, ".restore-test-")
+	if err != nil {
+		return result, fmt.Errorf("creating scratch directory: %w", err)
+	}
+	defer func() { _ = s.fs.RemoveAll(scratch) }()
+
+	if err := s.archiver.Extract(ctx, zipPath, scratch); err != nil {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		fail("", fmt.Sprintf("extracting: %v", err))
+		return result, nil
+	}
+
+	root := filepath.Join(scratch, project)
+	restored := make(map[string]bool, len(listing))
+	err = s.fs.Walk(scratch, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == scratch || path == root {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(rel, "../") {
+			fail(rel, "restored outside the project directory")
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			fail(rel, "restored as a symlink")
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		restored[rel] = true
+		result.Files++
+		listed, ok := listing[rel]
+		switch {
+		case !ok:
+			fail(rel, "not in archive listing")
+			return nil
+		case info.Size() != listed.Size:
+			fail(rel, fmt.Sprintf("restored %d bytes, archive lists %d", info.Size(), listed.Size))
+			return nil
+		case info.Mode().Perm()&0400 == 0:
+			fail(rel, "not readable by its owner")
+			return nil
+		}
+		// Group and other bits depend on the umask; the owner's do not
+		if idx != nil {
+			if f, ok := idx.Lookup(rel); ok && info.Mode().Perm()&0700 != f.Mode.Perm()&0700 {
+				fail(rel, fmt.Sprintf("restored with mode %v, file index records %v", info.Mode().Perm(), f.Mode.Perm()))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return result, fmt.Errorf("reading restored files: %w", err)
+	}
+
+	for path := range listing {
+		if !restored[path] {
+			fail(path, "in archive listing but not restored")
+		}
+	}
+
+	if idx != nil {
+		mismatches, err := idx.Check(root)
+		if err != nil {
+			return result, fmt.Errorf("checking restored files: %w", err)
+		}
+		for _, m := range mismatches {
+			if !failed[m.Path] {
+				fail(m.Path, indexReasons[m.Reason])
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// RestoreTestStatus is the most recent passing restore test of a project.
+type RestoreTestStatus struct {
+	Project  string
+	Version  string    // "" when no version has passed a restore test
+	TestedAt time.Time // Zero when no version has passed a restore test
+}
+
+// RestoreTests returns the most recent passing restore test of every project
+// with a manifest in the backup directory, ordered by project.
+func (s *Service) RestoreTests(cfg *config.Config) ([]RestoreTestStatus, error) {
+	backupDir, err := config.ExpandPath(cfg.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+	projects, err := manifest.Projects(backupDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []RestoreTestStatus
+	for _, project := range projects {
+		m, err := manifest.Load(backupDir, project)
+		if err != nil {
+			return nil, fmt.Errorf("loading manifest for %s: %w", project, err)
+		}
+		status := RestoreTestStatus{Project: project}
+		for _, entry := range m.Backups {
+			if entry.LastRestoreTest != nil && entry.LastRestoreTest.After(status.TestedAt) {
+				status.Version, status.TestedAt = entry.Version(), *entry.LastRestoreTest
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// RestoreTest extracts a backup into a scratch directory and checks it.
+// Uses the default production dependencies.
+func RestoreTest(ctx context.Context, cfg *config.Config, project, version string) (RestoreTestResult, error) {
+	return defaultService.RestoreTest(ctx, cfg, project, version)
+}
+
+// RestoreTests returns each project's most recent passing restore test.
+// Uses the default production dependencies.
+func RestoreTests(cfg *config.Config) ([]RestoreTestStatus, error) {
+	return defaultService.RestoreTests(cfg)
+}
@@ -0,0 +1,184 @@
+package recovery
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jmcdonald/codebak/internal/adapters/osfs"
+	"github.com/jmcdonald/codebak/internal/adapters/ziparchiver"
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/fileindex"
+	"github.com/jmcdonald/codebak/internal/manifest"
+)
+
+// restoreTestFailures maps each failed path to its reason.
+func restoreTestFailures(r RestoreTestResult) map[string]string {
+	reasons := make(map[string]string)
+	for _, f := range r.Failures {
+		reasons[f.Path] = f.Reason
+	}
+	return reasons
+}
+
+// writeTestIndex records file.txt in the test backup's file index with mode.
+func writeTestIndex(t *testing.T, backupDir string, mode os.FileMode, extra ...fileindex.File) {
+	t.Helper()
+	sum := sha256.Sum256([]byte("test content"))
+	files := append([]fileindex.File{{Path: "file.txt", Size: 12, SHA256: fmt.Sprintf("%x", sum), Mode: mode}}, extra...)
+	if err := fileindex.Write(backupDir, "test-project", "20260101-120000", files); err != nil {
+		t.Fatalf("Failed to write file index: %v", err)
+	}
+}
+
+// assertScratchRemoved fails if a restore test left its scratch directory.
+func assertScratchRemoved(t *testing.T, backupDir string) {
+	t.Helper()
+	entries, _ := os.ReadDir(backupDir)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".restore-test-") {
+			t.Errorf("scratch directory %s was not removed", e.Name())
+		}
+	}
+}
+
+func TestRestoreTest(t *testing.T) {
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+	backupDir := filepath.Join(tempDir, "backups")
+	writeTestIndex(t, backupDir, 0644)
+	cfg := &config.Config{BackupDir: backupDir}
+	svc := NewService(osfs.New(), ziparchiver.New())
+
+	result, err := svc.RestoreTest(context.Background(), cfg, "test-project", "")
+	if err != nil {
+		t.Fatalf("RestoreTest failed: %v", err)
+	}
+	if !result.OK() || result.Files != 1 || result.TestedAt.IsZero() {
+		t.Fatalf("result = %+v, expected one restored file and a recorded test", result)
+	}
+	assertScratchRemoved(t, backupDir)
+
+	m, _ := manifest.Load(backupDir, "test-project")
+	if tested := m.Backups[0].LastRestoreTest; tested == nil || !tested.Equal(result.TestedAt) {
+		t.Errorf("LastRestoreTest = %v, expected %v", tested, result.TestedAt)
+	}
+
+	statuses, err := svc.RestoreTests(cfg)
+	if err != nil {
+		t.Fatalf("RestoreTests failed: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].Version != "20260101-120000" || !statuses[0].TestedAt.Equal(result.TestedAt) {
+		t.Errorf("statuses = %+v, expected the recorded test", statuses)
+	}
+}
+
+func TestRestoreTestMismatches(t *testing.T) {
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+	backupDir := filepath.Join(tempDir, "backups")
+	writeTestIndex(t, backupDir, 0755, fileindex.File{Path: "lost.txt", Size: 4, SHA256: "1111", Mode: 0644})
+	cfg := &config.Config{BackupDir: backupDir}
+
+	result, err := NewService(osfs.New(), ziparchiver.New()).RestoreTest(context.Background(), cfg, "test-project", "")
+	if err != nil {
+		t.Fatalf("RestoreTest failed: %v", err)
+	}
+	reasons := restoreTestFailures(result)
+	if len(reasons) != 2 {
+		t.Errorf("failures = %+v, expected 2", result.Failures)
+	}
+	// The restored group and other bits depend on the umask
+	if !strings.HasSuffix(reasons["file.txt"], "file index records -rwxr-xr-x") {
+		t.Errorf("failure for file.txt = %q, expected a mode mismatch", reasons["file.txt"])
+	}
+	if reasons["lost.txt"] != "in file index but not restored" {
+		t.Errorf("failure for lost.txt = %q, expected it to be missing", reasons["lost.txt"])
+	}
+	assertScratchRemoved(t, backupDir)
+
+	m, _ := manifest.Load(backupDir, "test-project")
+	if m.Backups[0].LastRestoreTest != nil {
+		t.Error("a failed restore test should not be recorded")
+	}
+}
+
+func TestRestoreTestUnsafeEntries(t *testing.T) {
+	// Escaping entries are refused before extracting and reported by name;
+	// the rest are refused by the archiver while extracting
+	tests := []struct {
+		name   string
+		header *zip.FileHeader
+		path   string
+		reason string
+	}{
+		{"zip slip", &zip.FileHeader{Name: "test-project/../../escaped.txt"}, "../../escaped.txt", "escapes the project directory"},
+		{"nested zip slip", &zip.FileHeader{Name: "test-project/src/../../../escaped.txt"}, "src/../../../escaped.txt", "escapes the project directory"},
+		{"symlink", func() *zip.FileHeader {
+			h := &zip.FileHeader{Name: "test-project/link"}
+			h.SetMode(os.ModeSymlink | 0777)
+			return h
+		}(), "", "symlinks not supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			setupTestBackup(t, tempDir)
+			backupDir := filepath.Join(tempDir, "backups")
+			zipPath := filepath.Join(backupDir, "test-project", "20260101-120000.zip")
+
+			f, err := os.Create(zipPath)
+			if err != nil {
+				t.Fatalf("Failed to create zip: %v", err)
+			}
+			w := zip.NewWriter(f)
+			fw, err := w.CreateHeader(tt.header)
+			if err != nil {
+				t.Fatalf("Failed to create zip entry: %v", err)
+			}
+			if _, err := fw.Write([]byte("file.txt")); err != nil {
+				t.Fatalf("Failed to write zip entry: %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Failed to close zip writer: %v", err)
+			}
+			_ = f.Close()
+			cfg := &config.Config{BackupDir: backupDir}
+
+			result, err := NewService(osfs.New(), ziparchiver.New()).RestoreTest(context.Background(), cfg, "test-project", "")
+			if err != nil {
+				t.Fatalf("RestoreTest failed: %v", err)
+			}
+			if reason := restoreTestFailures(result)[tt.path]; !strings.Contains(reason, tt.reason) || len(result.Failures) != 1 {
+				t.Errorf("failures = %+v, expected %q to be refused (%s)", result.Failures, tt.path, tt.reason)
+			}
+			if _, err := os.Stat(filepath.Join(backupDir, "escaped.txt")); err == nil {
+				t.Error("entry was extracted outside the scratch directory")
+			}
+			assertScratchRemoved(t, backupDir)
+		})
+	}
+}
+
+func TestRestoreTestsNeverTested(t *testing.T) {
+	backupDir := setupVerifyAll(t)
+
+	statuses, err := NewService(osfs.New(), ziparchiver.New()).RestoreTests(&config.Config{BackupDir: backupDir})
+	if err != nil {
+		t.Fatalf("RestoreTests failed: %v", err)
+	}
+	if len(statuses) != 2 || statuses[0].Project != "broken" || statuses[1].Project != "test-project" {
+		t.Fatalf("statuses = %+v, expected both projects", statuses)
+	}
+	for _, s := range statuses {
+		if s.Version != "" || !s.TestedAt.IsZero() {
+			t.Errorf("status = %+v, expected no restore test", s)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/ports"
+)
+
+// SensitiveOptions configures recovery of a sensitive source from restic.
+type SensitiveOptions struct {
+	Label    string   // Source label, or the base name of its path
+	Snapshot string   // Snapshot ID or a prefix of it; empty or "latest" for the most recent
+	Include  []string // Paths to restore, relative to the source; empty for all of it
+	Wipe     bool     // Delete current before restore
+	Archive  bool     // Archive current before restore
+	Target   string   // Directory to restore into instead of the source path; empty for the default
+	Force    bool     // Restore into a non-empty Target
+	DryRun   bool     // Compare the snapshot with the destination without changing it
+}
+
+// SensitiveResult describes a recovered sensitive source.
+type SensitiveResult struct {
+	Snapshot ports.Snapshot
+	Dir      string          // Directory the source was restored into
+	Restored []string        // The restored paths relative to Dir; "." for all of it
+	Preview  *RecoverPreview // Set instead of restoring by a dry run
+}
+
+// RecoverSensitive restores a sensitive source from a restic snapshot,
+// either all of it or only opts.Include. Each restored path follows the
+// rules of Recover: an existing copy must be wiped or archived, and with
+// opts.Target a missing or empty target needs neither while a non-empty one
+// needs opts.Force, after which it is wiped, archived or restored over.
+// The snapshot is restored into a scratch directory next to the destination
+// first, so a failed or cancelled restic run leaves the current copy alone.
+// A dry run restores into a temporary directory instead and only compares
+// it with the destination, whatever is there.
+func (s *Service) RecoverSensitive(ctx context.Context, cfg *config.Config, opts SensitiveOptions) (SensitiveResult, error) {
+	if s.restic == nil {
+		return SensitiveResult{}, fmt.Errorf("restic is not available")
+	}
+
+	sourcePath, err := sensitiveSource(cfg, opts.Label)
+	if err != nil {
+		return SensitiveResult{}, err
+	}
+	dir := sourcePath
+	if opts.Target != "" {
+		if dir, err = config.ExpandPath(opts.Target); err != nil {
+			return SensitiveResult{}, err
+		}
+	}
+	result := SensitiveResult{Dir: dir, Restored: []string{"."}}
+	if len(opts.Include) > 0 {
+		if result.Restored, err = includePaths(sourcePath, opts.Include); err != nil {
+			return result, err
+		}
+	}
+
+	repoPath, err := cfg.GetResticRepoPath()
+	if err != nil {
+		return result, fmt.Errorf("getting restic repo path: %w", err)
+	}
+	password, err := cfg.GetResticPassword()
+	if err != nil {
+		return result, err
+	}
+	if !s.restic.IsInitialized(repoPath) {
+		return result, fmt.Errorf("no restic repository at %s", repoPath)
+	}
+
+	// Sensitive backups and their restic forget run under the backup
+	// directory lock, so holding it keeps the snapshot from changing mid-restore
+	unlock, err := s.lockBackupDir(cfg)
+	if err != nil {
+		return result, err
+	}
+	defer unlock()
+
+	if result.Snapshot, err = s.findSnapshot(ctx, repoPath, password, sourcePath, opts.Snapshot); err != nil {
+		return result, err
+	}
+
+	// Check every destination before restoring anything
+	var replace map[string]bool
+	// Next to the destination, so the restored files can be renamed into place
+	// and secrets stay on its file system rather than in the system temp dir
+	scratchParent := filepath.Dir(dir)
+	if opts.DryRun {
+		// A dry run creates no directories, so it uses the closest existing one
+		scratchParent = s.existingParent(dir)
+	} else {
+		if replace, err = s.sensitiveConflicts(dir, result.Restored, opts); err != nil {
+			return result, err
+		}
+		if err := s.fs.MkdirAll(scratchParent, 0755); err != nil {
+			return result, fmt.Errorf("creating target directory: %w", err)
+		}
+	}
+	// The dot prefix keeps it out of the way if it is ever left behind
+	scratch, err := s.fs.MkdirTemp(scratchParent, ".codebak-restore-")
+	if err != nil {
+		return result, fmt.Errorf("creating scratch directory: %w", err)
+	}
+	defer func() { _ = s.fs.RemoveAll(scratch) }()
+
+	if len(opts.Include) > 0 {
+		paths := make([]string, len(result.Restored))
+		for i, rel := range result.Restored {
+			paths[i] = filepath.Join(sourcePath, rel)
+		}
+		err = s.restic.RestorePaths(ctx, repoPath, password, result.Snapshot.ID, scratch, paths)
+	} else {
+		err = s.restic.Restore(ctx, repoPath, password, result.Snapshot.ID, scratch)
+	}
+	if err != nil {
+		return result, fmt.Errorf("restoring snapshot: %w", err)
+	}
+
+	// restic recreates the snapshot's absolute paths under the scratch directory
+	restoredRoot := filepath.Join(scratch, sourcePath)
+	for _, rel := range result.Restored {
+		if _, err := s.fs.Stat(filepath.Join(restoredRoot, rel)); err != nil {
+			return result, fmt.Errorf("%s is not in snapshot %s", filepath.Join(sourcePath, rel), result.Snapshot.ID)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	if opts.DryRun {
+		listing, err := s.restoredListing(restoredRoot, result.Restored)
+		if err != nil {
+			return result, fmt.Errorf("reading restored files: %w", err)
+		}
+		preview := RecoverPreview{Version: result.Snapshot.ID, Dir: dir}
+		if err := s.compareDir(&preview, result.Restored, listing); err != nil {
+			return result, err
+		}
+		result.Preview = &preview
+		return result, nil
+	}
+
+	for _, rel := range result.Restored {
+		dest := filepath.Join(dir, rel)
+		if replace[dest] {
+			if err := s.moveAside(dest, opts.Wipe); err != nil {
+				return result, err
+			}
+		}
+		if err := s.moveInto(filepath.Join(restoredRoot, rel), dest); err != nil {
+			return result, fmt.Errorf("moving restored files into place: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// existingParent returns the closest ancestor of path that exists.
+func (s *Service) existingParent(path string) string {
+	dir := filepath.Dir(path)
+	for {
+		if _, err := s.fs.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			return dir
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// sensitiveConflicts checks the destination of each restored path under dir
+// and returns those that must be wiped or archived first.
+func (s *Service) sensitiveConflicts(dir string, rels []string, opts SensitiveOptions) (map[string]bool, error) {
+	replace := make(map[string]bool)
+	for _, rel := range rels {
+		dest := filepath.Join(dir, rel)
+		occupied, err := s.occupied(dest, rel == "." && opts.Target != "")
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case !occupied:
+		case opts.Target != "" && !opts.Force:
+			if rel == "." {
+				return nil, fmt.Errorf("target is not empty: %s (use --force)", dest)
+			}
+			return nil, fmt.Errorf("%s already exists (use --force)", dest)
+		case opts.Wipe || opts.Archive:
+			replace[dest] = true
+		case opts.Target == "":
+			return nil, fmt.Errorf("%s already exists (use --wipe or --archive)", dest)
+		}
+	}
+	return replace, nil
+}
+
+// restoredListing describes the files restored under each of rels in root
+// the way an archive listing would, keyed by slash-separated path relative
+// to root.
+func (s *Service) restoredListing(root string, rels []string) (map[string]ports.FileInfo, error) {
+	listing := make(map[string]ports.FileInfo)
+	for _, rel := range rels {
+		err := s.fs.Walk(filepath.Join(root, rel), func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			p, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			file := ports.FileInfo{Size: info.Size(), ModTime: info.ModTime()}
+			if info.Mode().IsRegular() {
+				if file.CRC32, err = s.fileCRC32(path); err != nil {
+					return err
+				}
+			}
+			listing[filepath.ToSlash(p)] = file
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return listing, nil
+}
+
+// fileCRC32 returns the CRC32 of the file at path, as archives record it.
+func (s *Service) fileCRC32(path string) (uint32, error) {
+	f, err := s.fs.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, f); err != nil {
+		return 0, err
+	}
+	return h.Sum32(), nil
+}
+
+// sensitiveSource returns the expanded path of the sensitive source with
+// the given label, or whose path has it as its base name.
+func sensitiveSource(cfg *config.Config, label string) (string, error) {
+	for _, source := range cfg.GetSourcesByType(config.SourceTypeSensitive) {
+		sourcePath, err := config.ExpandPath(source.Path)
+		if err != nil {
+			return "", err
+		}
+		if source.Label == label || filepath.Base(sourcePath) == label {
+			return sourcePath, nil
+		}
+	}
+	return "", fmt.Errorf("no sensitive source labelled %s", label)
+}
+
+// includePaths cleans paths to restore, given relative to the source or as
+// absolute paths inside it, into paths relative to the source.
+func includePaths(sourcePath string, include []string) ([]string, error) {
+	rels := make([]string, 0, len(include))
+	for _, p := range include {
+		expanded, err := config.ExpandPath(p)
+		if err != nil {
+			return nil, err
+		}
+		rel := filepath.Clean(expanded)
+		if filepath.IsAbs(rel) {
+			if rel, err = filepath.Rel(sourcePath, rel); err != nil {
+				return nil, err
+			}
+		}
+		if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("path is outside the source: %s", p)
+		}
+		rels = append(rels, rel)
+	}
+	return rels, nil
+}
+
+// findSnapshot returns the snapshot of sourcePath with the given ID, or its
+// most recent snapshot when id is "" or "latest".
+func (s *Service) findSnapshot(ctx context.Context, repoPath, password, sourcePath, id string) (ports.Snapshot, error) {
+	// Backups tag each snapshot with the base name of its source
+	snapshots, err := s.restic.Snapshots(ctx, repoPath, password, []string{filepath.Base(sourcePath)})
+	if err != nil {
+		return ports.Snapshot{}, fmt.Errorf("listing snapshots: %w", err)
+	}
+
+	var found []ports.Snapshot
+	for _, snap := range snapshots {
+		if !containsPath(snap.Paths, sourcePath) {
+			continue
+		}
+		switch {
+		case id == "" || id == "latest":
+			if len(found) == 0 || snap.Time.After(found[0].Time) {
+				found = []ports.Snapshot{snap}
+			}
+		case strings.HasPrefix(snap.ID, id):
+			found = append(found, snap)
+		}
+	}
+	switch {
+	case len(found) == 1:
+		return found[0], nil
+	case len(found) > 1:
+		return ports.Snapshot{}, fmt.Errorf("snapshot ID %s is ambiguous", id)
+	case id == "" || id == "latest":
+		return ports.Snapshot{}, fmt.Errorf("no snapshots found for %s", sourcePath)
+	}
+	return ports.Snapshot{}, fmt.Errorf("snapshot not found for %s: %s", sourcePath, id)
+}
+
+// containsPath reports whether paths holds p.
+func containsPath(paths []string, p string) bool {
+	for _, candidate := range paths {
+		if filepath.Clean(candidate) == p {
+			return true
+		}
+	}
+	return false
+}
+
+// occupied reports whether something exists at path. With emptyIsFree an
+// empty directory there does not count.
+func (s *Service) occupied(path string, emptyIsFree bool) (bool, error) {
+	info, err := s.fs.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !emptyIsFree || !info.IsDir() {
+		return true, nil
+	}
+	entries, err := s.fs.ReadDir(path)
+	if err != nil {
+		return false, fmt.Errorf("reading target: %w", err)
+	}
+	return len(entries) > 0, nil
+}
+
+// moveAside deletes the current copy at path, or archives it next to
+// itself as "<name>-archived-<timestamp>".
+func (s *Service) moveAside(path string, wipe bool) error {
+	if wipe {
+		if err := s.fs.RemoveAll(path); err != nil {
+			return fmt.Errorf("removing current copy: %w", err)
+		}
+		return nil
+	}
+	archiveName := fmt.Sprintf("%s-archived-%s", filepath.Base(path), time.Now().Format("20060102-150405"))
+	if err := s.fs.Rename(path, filepath.Join(filepath.Dir(path), archiveName)); err != nil {
+		return fmt.Errorf("archiving current copy: %w", err)
+	}
+	return nil
+}
+
+// moveInto moves src to dest. When dest is an existing directory, the
+// files under src are moved into it one by one, replacing files of the same
+// name and keeping the rest. Missing parents of dest get the mode of the
+// restored directory holding src, so secrets are not exposed on the way.
+func (s *Service) moveInto(src, dest string) error {
+	info, err := s.fs.Stat(dest)
+	if os.IsNotExist(err) {
+		mode := os.FileMode(0700)
+		if parent, err := s.fs.Stat(filepath.Dir(src)); err == nil {
+			mode = parent.Mode().Perm()
+		}
+		if err := s.fs.MkdirAll(filepath.Dir(dest), mode); err != nil {
+			return err
+		}
+		return s.fs.Rename(src, dest)
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return s.fs.Rename(src, dest)
+	}
+
+	return s.fs.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if info.IsDir() {
+			return s.fs.MkdirAll(target, info.Mode().Perm())
+		}
+		return s.fs.Rename(path, target)
+	})
+}
+
+// RecoverSensitive restores a sensitive source from a restic snapshot.
+// Uses the default production dependencies.
+func RecoverSensitive(ctx context.Context, cfg *config.Config, opts SensitiveOptions) (SensitiveResult, error) {
+	return defaultService.RecoverSensitive(ctx, cfg, opts)
+}
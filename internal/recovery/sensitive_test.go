@@ -0,0 +1,330 @@
+package recovery
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmcdonald/codebak/internal/adapters/osfs"
+	"github.com/jmcdonald/codebak/internal/adapters/ziparchiver"
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/mocks"
+	"github.com/jmcdonald/codebak/internal/ports"
+)
+
+// fakeRestic is a mock restic client whose restores write files, laid out
+// the way restic lays out a snapshot's absolute paths under the target.
+// Directories are private, as they are in a typical sensitive source.
+type fakeRestic struct {
+	*mocks.MockResticClient
+	files map[string]string // Absolute path -> content
+}
+
+func (f *fakeRestic) Restore(ctx context.Context, repoPath, password, snapshotID, targetDir string) error {
+	if err := f.MockResticClient.Restore(ctx, repoPath, password, snapshotID, targetDir); err != nil {
+		return err
+	}
+	return f.write(targetDir, nil)
+}
+
+func (f *fakeRestic) RestorePaths(ctx context.Context, repoPath, password, snapshotID, targetDir string, paths []string) error {
+	if err := f.MockResticClient.RestorePaths(ctx, repoPath, password, snapshotID, targetDir, paths); err != nil {
+		return err
+	}
+	return f.write(targetDir, paths)
+}
+
+// write creates the files under any of paths, or all of them when paths is nil.
+func (f *fakeRestic) write(targetDir string, paths []string) error {
+	for name, content := range f.files {
+		included := paths == nil
+		for _, p := range paths {
+			included = included || name == p || strings.HasPrefix(name, p+"/")
+		}
+		if !included {
+			continue
+		}
+		dest := filepath.Join(targetDir, name)
+		if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dest, []byte(content), 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setupSensitive creates a sensitive source holding a local config file and
+// a restic mock with two snapshots of it, the newer holding files. It
+// returns the config, the source path and the restic mock.
+func setupSensitive(t *testing.T, files map[string]string) (*config.Config, string, *fakeRestic) {
+	t.Helper()
+	tempDir := t.TempDir()
+	sourcePath := filepath.Join(tempDir, "home", ".ssh")
+	if err := os.MkdirAll(sourcePath, 0700); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourcePath, "config"), []byte("local"), 0600); err != nil {
+		t.Fatalf("Failed to create local file: %v", err)
+	}
+	t.Setenv("CODEBAK_TEST_RESTIC_PASSWORD", "secret")
+
+	repoPath := filepath.Join(tempDir, "restic-repo")
+	restic := &fakeRestic{MockResticClient: mocks.NewMockResticClient(), files: make(map[string]string)}
+	restic.InitializedRepos[repoPath] = true
+	now := time.Now()
+	restic.SnapshotsByRepo[repoPath] = []ports.Snapshot{
+		{ID: "new22222", Time: now, Paths: []string{sourcePath}, Tags: []string{".ssh"}},
+		{ID: "old11111", Time: now.Add(-time.Hour), Paths: []string{sourcePath}, Tags: []string{".ssh"}},
+	}
+	for name, content := range files {
+		restic.files[filepath.Join(sourcePath, name)] = content
+	}
+
+	cfg := &config.Config{
+		Sources: []config.Source{{Path: sourcePath, Label: "SSH Keys", Type: config.SourceTypeSensitive}},
+		Restic:  config.ResticConfig{RepoPath: repoPath, PasswordEnvVar: "CODEBAK_TEST_RESTIC_PASSWORD"},
+	}
+	return cfg, sourcePath, restic
+}
+
+// readFile returns the content of path, or "" if it is missing.
+func readFile(path string) string {
+	data, _ := os.ReadFile(path)
+	return string(data)
+}
+
+func TestRecoverSensitive(t *testing.T) {
+	cfg, sourcePath, restic := setupSensitive(t, map[string]string{"config": "restored", "id_ed25519": "key"})
+	svc := NewService(osfs.New(), ziparchiver.New(), WithRestic(restic))
+
+	_, err := svc.RecoverSensitive(context.Background(), cfg, SensitiveOptions{Label: "SSH Keys"})
+	if err == nil || !strings.Contains(err.Error(), "use --wipe or --archive") {
+		t.Fatalf("err = %v, expected an existing source to be refused", err)
+	}
+	if len(restic.RestoredSnapshots) != 0 {
+		t.Error("nothing should be restored when the source is refused")
+	}
+
+	result, err := svc.RecoverSensitive(context.Background(), cfg, SensitiveOptions{Label: ".ssh", Archive: true})
+	if err != nil {
+		t.Fatalf("RecoverSensitive failed: %v", err)
+	}
+	if result.Snapshot.ID != "new22222" || result.Dir != sourcePath {
+		t.Errorf("result = %+v, expected the latest snapshot restored to %s", result, sourcePath)
+	}
+	if readFile(filepath.Join(sourcePath, "config")) != "restored" || readFile(filepath.Join(sourcePath, "id_ed25519")) != "key" {
+		t.Error("the snapshot was not restored into the source")
+	}
+	archived, _ := filepath.Glob(filepath.Join(filepath.Dir(sourcePath), ".ssh-archived-*", "config"))
+	if len(archived) != 1 || readFile(archived[0]) != "local" {
+		t.Errorf("archived copies = %v, expected the local source archived next to it", archived)
+	}
+	if scratch, _ := filepath.Glob(filepath.Join(filepath.Dir(sourcePath), ".codebak-restore-*")); len(scratch) != 0 {
+		t.Errorf("scratch directories %v were not removed", scratch)
+	}
+}
+
+func TestRecoverSensitiveInclude(t *testing.T) {
+	cfg, sourcePath, restic := setupSensitive(t, map[string]string{"config": "restored", "id_ed25519": "key"})
+	if err := os.WriteFile(filepath.Join(sourcePath, "known_hosts"), []byte("hosts"), 0600); err != nil {
+		t.Fatalf("Failed to create local file: %v", err)
+	}
+	svc := NewService(osfs.New(), ziparchiver.New(), WithRestic(restic))
+
+	opts := SensitiveOptions{Label: "SSH Keys", Snapshot: "old", Include: []string{"config", filepath.Join(sourcePath, "id_ed25519")}, Wipe: true}
+	result, err := svc.RecoverSensitive(context.Background(), cfg, opts)
+	if err != nil {
+		t.Fatalf("RecoverSensitive failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Restored, []string{"config", "id_ed25519"}) || result.Snapshot.ID != "old11111" {
+		t.Errorf("result = %+v, expected config and id_ed25519 from old11111", result)
+	}
+	calls := restic.RestorePathsCalls
+	expected := []string{filepath.Join(sourcePath, "config"), filepath.Join(sourcePath, "id_ed25519")}
+	if len(calls) != 1 || calls[0].SnapshotID != "old11111" || !reflect.DeepEqual(calls[0].Paths, expected) {
+		t.Errorf("RestorePaths calls = %+v, expected %v from old11111", calls, expected)
+	}
+	if readFile(filepath.Join(sourcePath, "config")) != "restored" || readFile(filepath.Join(sourcePath, "id_ed25519")) != "key" {
+		t.Error("the included files were not restored")
+	}
+	if readFile(filepath.Join(sourcePath, "known_hosts")) != "hosts" {
+		t.Error("files that were not included should be left alone")
+	}
+
+	// Included paths the snapshot lacks fail before anything is touched
+	opts = SensitiveOptions{Label: "SSH Keys", Include: []string{"config", "missing"}, Wipe: true}
+	if _, err := svc.RecoverSensitive(context.Background(), cfg, opts); err == nil || !strings.Contains(err.Error(), "is not in snapshot") {
+		t.Errorf("err = %v, expected the missing path to be reported", err)
+	}
+	if _, err := os.Stat(filepath.Join(sourcePath, "config")); err != nil {
+		t.Error("config should not be wiped when the restore fails")
+	}
+
+	opts = SensitiveOptions{Label: "SSH Keys", Include: []string{"../escape"}}
+	if _, err := svc.RecoverSensitive(context.Background(), cfg, opts); err == nil {
+		t.Error("paths outside the source should be rejected")
+	}
+}
+
+func TestRecoverSensitiveKeepsDirectoryModes(t *testing.T) {
+	cfg, sourcePath, restic := setupSensitive(t, map[string]string{"keys/work/id_ed25519": "key"})
+	svc := NewService(osfs.New(), ziparchiver.New(), WithRestic(restic))
+
+	// keys/work is missing locally, so its parents are created on the way
+	opts := SensitiveOptions{Label: "SSH Keys", Include: []string{"keys/work/id_ed25519"}}
+	if _, err := svc.RecoverSensitive(context.Background(), cfg, opts); err != nil {
+		t.Fatalf("RecoverSensitive failed: %v", err)
+	}
+	if readFile(filepath.Join(sourcePath, "keys", "work", "id_ed25519")) != "key" {
+		t.Fatal("the included file was not restored")
+	}
+	for _, dir := range []string{"keys", filepath.Join("keys", "work")} {
+		info, err := os.Stat(filepath.Join(sourcePath, dir))
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", dir, err)
+		}
+		if info.Mode().Perm() != 0700 {
+			t.Errorf("%s mode = %v, expected 0700 as in the snapshot", dir, info.Mode().Perm())
+		}
+	}
+}
+
+func TestRecoverSensitiveToTarget(t *testing.T) {
+	cfg, sourcePath, restic := setupSensitive(t, map[string]string{"config": "restored"})
+	target := filepath.Join(t.TempDir(), "ssh-old")
+	if err := os.MkdirAll(target, 0700); err != nil {
+		t.Fatalf("Failed to create target: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(target, "notes"), []byte("notes"), 0600); err != nil {
+		t.Fatalf("Failed to create target file: %v", err)
+	}
+	svc := NewService(osfs.New(), ziparchiver.New(), WithRestic(restic))
+
+	opts := SensitiveOptions{Label: "SSH Keys", Target: target}
+	if _, err := svc.RecoverSensitive(context.Background(), cfg, opts); err == nil || !strings.Contains(err.Error(), "target is not empty") {
+		t.Fatalf("err = %v, expected a non-empty target to be refused", err)
+	}
+
+	opts.Force = true
+	if _, err := svc.RecoverSensitive(context.Background(), cfg, opts); err != nil {
+		t.Fatalf("RecoverSensitive failed: %v", err)
+	}
+	if readFile(filepath.Join(target, "config")) != "restored" || readFile(filepath.Join(target, "notes")) != "notes" {
+		t.Error("the snapshot should be restored over the target, keeping its other files")
+	}
+	if readFile(filepath.Join(sourcePath, "config")) != "local" {
+		t.Error("recovering to a target should leave the source alone")
+	}
+}
+
+func TestRecoverSensitiveDryRun(t *testing.T) {
+	cfg, sourcePath, restic := setupSensitive(t, map[string]string{"config": "restored", "id_ed25519": "key"})
+	if err := os.WriteFile(filepath.Join(sourcePath, "known_hosts"), []byte("hosts"), 0600); err != nil {
+		t.Fatalf("Failed to create local file: %v", err)
+	}
+	svc := NewService(osfs.New(), ziparchiver.New(), WithRestic(restic))
+
+	// No --wipe or --archive is needed to look
+	result, err := svc.RecoverSensitive(context.Background(), cfg, SensitiveOptions{Label: "SSH Keys", DryRun: true})
+	if err != nil {
+		t.Fatalf("RecoverSensitive failed: %v", err)
+	}
+	expected := &RecoverPreview{
+		Version:     "new22222",
+		Dir:         sourcePath,
+		Added:       []string{"id_ed25519"},
+		Overwritten: []string{"config"},
+		Removed:     []string{"known_hosts"},
+	}
+	if !reflect.DeepEqual(result.Preview, expected) {
+		t.Errorf("preview = %+v, expected %+v", result.Preview, expected)
+	}
+	if readFile(filepath.Join(sourcePath, "config")) != "local" || readFile(filepath.Join(sourcePath, "id_ed25519")) != "" {
+		t.Error("a dry run should not change the source")
+	}
+	// The snapshot is still restored, next to the source rather than in the system temp dir
+	scratch := restic.RestoredSnapshots[cfg.Restic.RepoPath]["new22222"]
+	if filepath.Dir(scratch) != filepath.Dir(sourcePath) || !strings.HasPrefix(filepath.Base(scratch), ".codebak-restore-") {
+		t.Errorf("restored into %s, expected a scratch directory next to %s", scratch, sourcePath)
+	}
+	if _, err := os.Stat(scratch); !os.IsNotExist(err) {
+		t.Errorf("scratch directory %s was not removed", scratch)
+	}
+
+	// A target that does not exist yet is not created
+	target := filepath.Join(t.TempDir(), "restore", "ssh-old")
+	if _, err := svc.RecoverSensitive(context.Background(), cfg, SensitiveOptions{Label: "SSH Keys", Target: target, DryRun: true}); err != nil {
+		t.Fatalf("RecoverSensitive failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(target)); !os.IsNotExist(err) {
+		t.Error("a dry run should not create the target's parents")
+	}
+
+	// Only the included paths are compared
+	result, err = svc.RecoverSensitive(context.Background(), cfg, SensitiveOptions{Label: "SSH Keys", Include: []string{"config"}, DryRun: true})
+	if err != nil {
+		t.Fatalf("RecoverSensitive failed: %v", err)
+	}
+	if result.Preview == nil || !reflect.DeepEqual(result.Preview.Overwritten, []string{"config"}) || result.Preview.Changes() != 1 {
+		t.Errorf("preview = %+v, expected only config overwritten", result.Preview)
+	}
+}
+
+func TestRecoverSensitiveLocksBackupDir(t *testing.T) {
+	cfg, sourcePath, restic := setupSensitive(t, map[string]string{"config": "restored"})
+	cfg.BackupDir = filepath.Join(t.TempDir(), "backups")
+	cfg.LockWait = 5 * time.Second
+	locker := mocks.NewMockLocker()
+	svc := NewService(osfs.New(), ziparchiver.New(), WithRestic(restic), WithLocker(locker))
+
+	// A sensitive backup or restic forget holds the backup directory lock
+	key := "dir:" + cfg.BackupDir
+	locker.Held[key] = true
+	if _, err := svc.RecoverSensitive(context.Background(), cfg, SensitiveOptions{Label: "SSH Keys", Wipe: true}); !errors.Is(err, ports.ErrLocked) {
+		t.Fatalf("err = %v, expected ErrLocked", err)
+	}
+	if len(restic.RestoredSnapshots) != 0 || readFile(filepath.Join(sourcePath, "config")) != "local" {
+		t.Error("nothing should be restored while the backup directory is locked")
+	}
+
+	delete(locker.Held, key)
+	if _, err := svc.RecoverSensitive(context.Background(), cfg, SensitiveOptions{Label: "SSH Keys", Wipe: true}); err != nil {
+		t.Fatalf("RecoverSensitive failed: %v", err)
+	}
+	if locker.Calls[1] != key || locker.Waits[1] != 5*time.Second {
+		t.Errorf("lock call = %s waiting %v, expected %s waiting 5s", locker.Calls[1], locker.Waits[1], key)
+	}
+	if locker.Held[key] {
+		t.Error("the backup directory lock should be released")
+	}
+}
+
+func TestRecoverSensitiveErrors(t *testing.T) {
+	cfg, _, restic := setupSensitive(t, nil)
+
+	tests := []struct {
+		name string
+		svc  *Service
+		opts SensitiveOptions
+		want string
+	}{
+		{"no restic", NewService(osfs.New(), ziparchiver.New()), SensitiveOptions{Label: "SSH Keys"}, "restic is not available"},
+		{"unknown label", NewService(osfs.New(), ziparchiver.New(), WithRestic(restic)), SensitiveOptions{Label: "aws"}, "no sensitive source labelled aws"},
+		{"unknown snapshot", NewService(osfs.New(), ziparchiver.New(), WithRestic(restic)), SensitiveOptions{Label: "SSH Keys", Snapshot: "ffff"}, "snapshot not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.svc.RecoverSensitive(context.Background(), cfg, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, expected %q", err, tt.want)
+			}
+		})
+	}
+}
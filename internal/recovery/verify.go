@@ -0,0 +1,241 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/fileindex"
+	"github.com/jmcdonald/codebak/internal/manifest"
+)
+
+// EntryFailure is a problem deep verification found with one file of a
+// backup, or with the backup as a whole when Path is "".
+type EntryFailure struct {
+	Path   string `json:"path"` // Relative to the project, slash-separated
+	Reason string `json:"reason"`
+}
+
+// DeepResult is the outcome of deep verification of one backup version.
+type DeepResult struct {
+	Version  string
+	Files    int // Files read back from the archive
+	Failures []EntryFailure
+}
+
+// OK reports whether the backup passed every check.
+func (r DeepResult) OK() bool {
+	return len(r.Failures) == 0
+}
+
+// VerifyDeep checks a backup like Verify, then reads back every file in its
+// archive. Each file is checked against the CRC32 stored with it and, when
+// the version has a file index, the SHA-256 the index records; the number of
+// files is compared with the manifest. Problems are returned as failures so
+// every bad file is reported; the error is reserved for backups that cannot
+// be checked at all. Cancelling ctx stops before the next file.
+func (s *Service) VerifyDeep(ctx context.Context, cfg *config.Config, project, version string) (DeepResult, error) {
+	backupDir, err := config.ExpandPath(cfg.BackupDir)
+	if err != nil {
+		return DeepResult{}, err
+	}
+
+	unlock, err := s.lockProject(cfg, backupDir, project)
+	if err != nil {
+		return DeepResult{}, err
+	}
+	defer unlock()
+
+	return s.verifyDeep(ctx, backupDir, project, version)
+}
+
+// verifyDeep reads back a backup. The caller must hold the project lock.
+func (s *Service) verifyDeep(ctx context.Context, backupDir, project, version string) (DeepResult, error) {
+	entry, err := findEntry(backupDir, project, version)
+	if err != nil {
+		return DeepResult{}, err
+	}
+	result := DeepResult{Version: entry.Version()}
+	fail := func(path, reason string) {
+		result.Failures = append(result.Failures, EntryFailure{Path: path, Reason: reason})
+	}
+
+	projectDir := filepath.Join(backupDir, project)
+	if err := verifyChecksums(projectDir, entry); err != nil {
+		fail("", err.Error())
+	}
+
+	checked, err := s.archiver.Check(ctx, filepath.Join(projectDir, entry.File))
+	if err != nil {
+		return result, fmt.Errorf("reading archive: %w", err)
+	}
+	result.Files = len(checked)
+	if len(checked) != entry.FileCount {
+		fail("", fmt.Sprintf("archive holds %d files, manifest records %d", len(checked), entry.FileCount))
+	}
+
+	idx, err := fileindex.Load(backupDir, project, entry.Version())
+	if err != nil && !os.IsNotExist(err) {
+		fail("", err.Error())
+	}
+
+	inArchive := make(map[string]bool, len(checked))
+	for _, f := range checked {
+		inArchive[f.Path] = true
+		if f.Err != nil {
+			fail(f.Path, f.Err.Error())
+			continue
+		}
+		if idx == nil {
+			continue
+		}
+		indexed, ok := idx.Lookup(f.Path)
+		switch {
+		case !ok:
+			fail(f.Path, "not in file index")
+		case indexed.SHA256 != f.SHA256:
+			fail(f.Path, "SHA-256 does not match file index")
+		}
+	}
+	if idx != nil {
+		for _, f := range idx.Files {
+			if !inArchive[f.Path] {
+				fail(f.Path, "in file index but missing from archive")
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// VersionScope selects the versions VerifyAll checks.
+type VersionScope string
+
+const (
+	// VersionsLatest checks each project's latest version (default)
+	VersionsLatest VersionScope = "latest"
+	// VersionsAll checks every version
+	VersionsAll VersionScope = "all"
+)
+
+// VerifyAllOptions configures VerifyAll.
+type VerifyAllOptions struct {
+	Versions VersionScope
+	Deep     bool // Read back every file, as VerifyDeep does
+}
+
+// VersionCheck is the outcome of verifying one backup version.
+type VersionCheck struct {
+	Project  string
+	Version  string         // "" when the project could not be checked at all
+	Files    int            // Files read back; deep verification only
+	Failures []EntryFailure // Deep verification only
+	Err      error
+}
+
+// OK reports whether the version passed every check.
+func (c VersionCheck) OK() bool {
+	return c.Err == nil && len(c.Failures) == 0
+}
+
+// VerifyAll verifies every project with a manifest in the backup directory,
+// checking up to cfg.GetJobs() projects at once. Each project is locked while
+// its versions are checked. Results are ordered by project, then by version
+// as listed in the manifest. Cancelling ctx stops before the next project and
+// returns the results so far with the context's error.
+func (s *Service) VerifyAll(ctx context.Context, cfg *config.Config, opts VerifyAllOptions) ([]VersionCheck, error) {
+	backupDir, err := config.ExpandPath(cfg.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+	projects, err := manifest.Projects(backupDir)
+	if err != nil {
+		return nil, err
+	}
+
+	perProject := make([][]VersionCheck, len(projects))
+	jobs := cfg.GetJobs()
+	if jobs > len(projects) {
+		jobs = len(projects)
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				perProject[i] = s.verifyProject(ctx, cfg, backupDir, projects[i], opts)
+			}
+		}()
+	}
+dispatch:
+	for i := range projects {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	var checks []VersionCheck
+	for _, c := range perProject {
+		checks = append(checks, c...)
+	}
+	return checks, ctx.Err()
+}
+
+// verifyProject checks the versions of one project selected by opts.
+func (s *Service) verifyProject(ctx context.Context, cfg *config.Config, backupDir, project string, opts VerifyAllOptions) []VersionCheck {
+	unlock, err := s.lockProject(cfg, backupDir, project)
+	if err != nil {
+		return []VersionCheck{{Project: project, Err: err}}
+	}
+	defer unlock()
+
+	m, err := manifest.Load(backupDir, project)
+	if err != nil {
+		return []VersionCheck{{Project: project, Err: fmt.Errorf("loading manifest: %w", err)}}
+	}
+	entries := m.Backups
+	if opts.Versions != VersionsAll {
+		entries = nil
+		if latest := m.LatestBackup(); latest != nil {
+			entries = []manifest.BackupEntry{*latest}
+		}
+	}
+
+	var checks []VersionCheck
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		check := VersionCheck{Project: project, Version: entry.Version()}
+		if opts.Deep {
+			var result DeepResult
+			result, check.Err = s.verifyDeep(ctx, backupDir, project, check.Version)
+			check.Files, check.Failures = result.Files, result.Failures
+		} else {
+			check.Err = s.verify(backupDir, project, check.Version)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// VerifyDeep reads back every file of a backup and reports the bad ones.
+// Uses the default production dependencies.
+func VerifyDeep(ctx context.Context, cfg *config.Config, project, version string) (DeepResult, error) {
+	return defaultService.VerifyDeep(ctx, cfg, project, version)
+}
+
+// VerifyAll verifies every project in the backup directory.
+// Uses the default production dependencies.
+func VerifyAll(ctx context.Context, cfg *config.Config, opts VerifyAllOptions) ([]VersionCheck, error) {
+	return defaultService.VerifyAll(ctx, cfg, opts)
+}
@@ -0,0 +1,239 @@
+package recovery
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmcdonald/codebak/internal/adapters/osfs"
+	"github.com/jmcdonald/codebak/internal/adapters/ziparchiver"
+	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/fileindex"
+	"github.com/jmcdonald/codebak/internal/manifest"
+)
+
+// failureReasons maps each failed path to its reason.
+func failureReasons(r DeepResult) map[string]string {
+	reasons := make(map[string]string)
+	for _, f := range r.Failures {
+		reasons[f.Path] = f.Reason
+	}
+	return reasons
+}
+
+func TestVerifyDeep(t *testing.T) {
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+	backupDir := filepath.Join(tempDir, "backups")
+	sum := sha256.Sum256([]byte("test content"))
+	files := []fileindex.File{{Path: "file.txt", Size: 12, SHA256: fmt.Sprintf("%x", sum)}}
+	if err := fileindex.Write(backupDir, "test-project", "20260101-120000", files); err != nil {
+		t.Fatalf("Failed to write file index: %v", err)
+	}
+	cfg := &config.Config{BackupDir: backupDir}
+
+	result, err := NewService(osfs.New(), ziparchiver.New()).VerifyDeep(context.Background(), cfg, "test-project", "")
+	if err != nil {
+		t.Fatalf("VerifyDeep failed: %v", err)
+	}
+	if !result.OK() || result.Files != 1 || result.Version != "20260101-120000" {
+		t.Errorf("result = %+v, expected one good file", result)
+	}
+}
+
+func TestVerifyDeepBadEntry(t *testing.T) {
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+	backupDir := filepath.Join(tempDir, "backups")
+	zipPath := filepath.Join(backupDir, "test-project", "20260101-120000.zip")
+
+	// Rewrite the archive with an entry whose stored CRC32 is wrong, and
+	// record the bad archive's checksum the way a faulty backup would
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatalf("Failed to create zip: %v", err)
+	}
+	w := zip.NewWriter(f)
+	content := []byte("test content")
+	fw, err := w.CreateRaw(&zip.FileHeader{
+		Name:               "test-project/file.txt",
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(content) + 1,
+		CompressedSize64:   uint64(len(content)),
+		UncompressedSize64: uint64(len(content)),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create zip entry: %v", err)
+	}
+	if _, err := fw.Write(content); err != nil {
+		t.Fatalf("Failed to write zip entry: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close zip writer: %v", err)
+	}
+	_ = f.Close()
+
+	m, _ := manifest.Load(backupDir, "test-project")
+	m.Backups[0].SHA256 = computeTestChecksum(t, zipPath)
+	if err := m.Save(backupDir); err != nil {
+		t.Fatalf("Failed to save manifest: %v", err)
+	}
+	cfg := &config.Config{BackupDir: backupDir}
+
+	// The whole-file checksum matches, so a plain verify passes
+	svc := NewService(osfs.New(), ziparchiver.New())
+	if err := svc.Verify(cfg, "test-project", ""); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	result, err := svc.VerifyDeep(context.Background(), cfg, "test-project", "")
+	if err != nil {
+		t.Fatalf("VerifyDeep failed: %v", err)
+	}
+	if reasons := failureReasons(result); len(reasons) != 1 || reasons["file.txt"] != "CRC32 mismatch" {
+		t.Errorf("failures = %+v, expected a CRC32 mismatch for file.txt", result.Failures)
+	}
+}
+
+func TestVerifyDeepFileCountAndIndex(t *testing.T) {
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+	backupDir := filepath.Join(tempDir, "backups")
+
+	m, _ := manifest.Load(backupDir, "test-project")
+	m.Backups[0].FileCount = 2
+	if err := m.Save(backupDir); err != nil {
+		t.Fatalf("Failed to save manifest: %v", err)
+	}
+	files := []fileindex.File{
+		{Path: "file.txt", Size: 12, SHA256: "0000"},
+		{Path: "lost.txt", Size: 4, SHA256: "1111"},
+	}
+	if err := fileindex.Write(backupDir, "test-project", "20260101-120000", files); err != nil {
+		t.Fatalf("Failed to write file index: %v", err)
+	}
+	cfg := &config.Config{BackupDir: backupDir}
+
+	result, err := NewService(osfs.New(), ziparchiver.New()).VerifyDeep(context.Background(), cfg, "test-project", "")
+	if err != nil {
+		t.Fatalf("VerifyDeep failed: %v", err)
+	}
+	reasons := failureReasons(result)
+	want := map[string]string{
+		"":         "archive holds 1 files, manifest records 2",
+		"file.txt": "SHA-256 does not match file index",
+		"lost.txt": "in file index but missing from archive",
+	}
+	if len(reasons) != len(want) {
+		t.Errorf("failures = %+v, expected %d", result.Failures, len(want))
+	}
+	for path, reason := range want {
+		if reasons[path] != reason {
+			t.Errorf("failure for %q = %q, expected %q", path, reasons[path], reason)
+		}
+	}
+}
+
+func TestVerifyDeepUnreadableArchive(t *testing.T) {
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+	backupDir := filepath.Join(tempDir, "backups")
+	zipPath := filepath.Join(backupDir, "test-project", "20260101-120000.zip")
+	if err := os.WriteFile(zipPath, []byte("not a zip"), 0644); err != nil {
+		t.Fatalf("Failed to corrupt zip: %v", err)
+	}
+	cfg := &config.Config{BackupDir: backupDir}
+
+	if _, err := NewService(osfs.New(), ziparchiver.New()).VerifyDeep(context.Background(), cfg, "test-project", ""); err == nil {
+		t.Error("VerifyDeep should fail when the archive cannot be opened")
+	}
+}
+
+// setupVerifyAll creates test-project with two good versions and broken,
+// whose only archive has been overwritten, plus directories VerifyAll skips.
+// Returns the backup dir.
+func setupVerifyAll(t *testing.T) string {
+	t.Helper()
+	tempDir := t.TempDir()
+	setupTestBackup(t, tempDir)
+	backupDir := filepath.Join(tempDir, "backups")
+	good := filepath.Join(backupDir, "test-project")
+
+	data, err := os.ReadFile(filepath.Join(good, "20260101-120000.zip"))
+	if err != nil {
+		t.Fatalf("Failed to read zip: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(good, "20260102-120000.zip"), data, 0644); err != nil {
+		t.Fatalf("Failed to copy zip: %v", err)
+	}
+	m, _ := manifest.Load(backupDir, "test-project")
+	second := m.Backups[0]
+	second.File = "20260102-120000.zip"
+	m.AddBackup(second)
+	if err := m.Save(backupDir); err != nil {
+		t.Fatalf("Failed to save manifest: %v", err)
+	}
+
+	broken := filepath.Join(backupDir, "broken")
+	for _, dir := range []string{broken, filepath.Join(backupDir, ".locks"), filepath.Join(backupDir, "no-manifest")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+	}
+	m.Project = "broken"
+	m.Backups = m.Backups[:1]
+	if err := m.Save(backupDir); err != nil {
+		t.Fatalf("Failed to save manifest: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(broken, "20260101-120000.zip"), []byte("tampered"), 0644); err != nil {
+		t.Fatalf("Failed to write zip: %v", err)
+	}
+	return backupDir
+}
+
+func TestVerifyAll(t *testing.T) {
+	backupDir := setupVerifyAll(t)
+	cfg := &config.Config{BackupDir: backupDir, Jobs: 2}
+	svc := NewService(osfs.New(), ziparchiver.New())
+
+	checks, err := svc.VerifyAll(context.Background(), cfg, VerifyAllOptions{})
+	if err != nil {
+		t.Fatalf("VerifyAll failed: %v", err)
+	}
+	if len(checks) != 2 {
+		t.Fatalf("checks = %+v, expected the latest version of both projects", checks)
+	}
+	if checks[0].Project != "broken" || checks[0].OK() {
+		t.Errorf("checks[0] = %+v, expected broken to fail", checks[0])
+	}
+	if checks[1].Project != "test-project" || checks[1].Version != "20260102-120000" || !checks[1].OK() {
+		t.Errorf("checks[1] = %+v, expected the latest test-project version to pass", checks[1])
+	}
+
+	checks, err = svc.VerifyAll(context.Background(), cfg, VerifyAllOptions{Versions: VersionsAll, Deep: true})
+	if err != nil {
+		t.Fatalf("VerifyAll failed: %v", err)
+	}
+	if len(checks) != 3 {
+		t.Fatalf("checks = %+v, expected every version", checks)
+	}
+	if checks[0].OK() || !checks[1].OK() || !checks[2].OK() || checks[2].Files != 1 {
+		t.Errorf("checks = %+v, expected only broken to fail", checks)
+	}
+}
+
+func TestVerifyAllCancelled(t *testing.T) {
+	backupDir := setupVerifyAll(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewService(osfs.New(), ziparchiver.New()).VerifyAll(ctx, &config.Config{BackupDir: backupDir}, VerifyAllOptions{})
+	if err != context.Canceled {
+		t.Errorf("err = %v, expected context.Canceled", err)
+	}
+}
@@ -0,0 +1,136 @@
+// Package retention decides which backup versions a retention policy keeps.
+//
+// Rules follow the grandfather-father-son scheme: keep the newest N versions,
+// the newest version in each of the last N hours, days, weeks, months and
+// years that have backups, and every version made within a duration of the
+// newest one. A version is kept if any rule keeps it.
+package retention
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Rule names reported in Decision.Reasons.
+const (
+	RuleLast    = "last"
+	RuleHourly  = "hourly"
+	RuleDaily   = "daily"
+	RuleWeekly  = "weekly"
+	RuleMonthly = "monthly"
+	RuleYearly  = "yearly"
+	RuleWithin  = "within"
+	// RulePinned is reported for versions kept because they are pinned;
+	// callers apply it, since Apply only sees creation times
+	RulePinned = "pinned"
+)
+
+// Policy is a set of retention rules. Zero values disable a rule.
+type Policy struct {
+	Last    int           // Newest versions to keep
+	Hourly  int           // Hours to keep the newest version of
+	Daily   int           // Days to keep the newest version of
+	Weekly  int           // ISO weeks to keep the newest version of
+	Monthly int           // Months to keep the newest version of
+	Yearly  int           // Years to keep the newest version of
+	Within  time.Duration // Keep every version this close to the newest
+}
+
+// Empty reports whether the policy has no rules, in which case every
+// version is kept.
+func (p Policy) Empty() bool {
+	return p.Last <= 0 && p.Hourly <= 0 && p.Daily <= 0 && p.Weekly <= 0 &&
+		p.Monthly <= 0 && p.Yearly <= 0 && p.Within <= 0
+}
+
+// Decision is the outcome of a policy for one version.
+type Decision struct {
+	Keep    bool
+	Reasons []string // Rules that keep the version, in rule order
+}
+
+// bucketRule keeps the newest version in each of the last count periods.
+type bucketRule struct {
+	name   string
+	count  int
+	bucket func(t time.Time) string
+}
+
+// Apply evaluates p over versions created at times and returns a decision
+// for each, in the same order. Versions are ranked newest first by time;
+// equal times rank later entries as newer. keep_within is measured from the
+// newest version rather than the current time, so a policy never removes
+// everything just because backups have stopped. An empty policy keeps all.
+func Apply(p Policy, times []time.Time) []Decision {
+	decisions := make([]Decision, len(times))
+	if p.Empty() {
+		for i := range decisions {
+			decisions[i].Keep = true
+		}
+		return decisions
+	}
+
+	order := make([]int, len(times))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ta, tb := times[order[a]], times[order[b]]
+		if ta.Equal(tb) {
+			return order[a] > order[b]
+		}
+		return ta.After(tb)
+	})
+
+	keep := func(i int, rule string) {
+		decisions[i].Keep = true
+		decisions[i].Reasons = append(decisions[i].Reasons, rule)
+	}
+
+	for rank, i := range order {
+		if rank < p.Last {
+			keep(i, RuleLast)
+		}
+	}
+
+	rules := []bucketRule{
+		{RuleHourly, p.Hourly, func(t time.Time) string { return t.Format("2006-01-02 15") }},
+		{RuleDaily, p.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{RuleWeekly, p.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{RuleMonthly, p.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+		{RuleYearly, p.Yearly, func(t time.Time) string { return t.Format("2006") }},
+	}
+	for _, rule := range rules {
+		if rule.count <= 0 {
+			continue
+		}
+		kept := 0
+		last := ""
+		for _, i := range order {
+			if kept == rule.count {
+				break
+			}
+			// The first version seen in a period is its newest
+			if b := rule.bucket(times[i].Local()); b != last {
+				last = b
+				keep(i, rule.name)
+				kept++
+			}
+		}
+	}
+
+	if p.Within > 0 && len(order) > 0 {
+		newest := times[order[0]]
+		for _, i := range order {
+			if newest.Sub(times[i]) <= p.Within {
+				keep(i, RuleWithin)
+			}
+		}
+	}
+
+	return decisions
+}
@@ -0,0 +1,98 @@
+package retention
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// at returns a local time on the given day and hour of January 2026.
+func at(day, hour int) time.Time {
+	return time.Date(2026, time.January, day, hour, 0, 0, 0, time.Local)
+}
+
+// kept returns the indexes of the versions a policy keeps.
+func kept(decisions []Decision) []int {
+	var idx []int
+	for i, d := range decisions {
+		if d.Keep {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+func TestEmptyPolicyKeepsEverything(t *testing.T) {
+	times := []time.Time{at(1, 9), at(2, 9), at(3, 9)}
+	if got := kept(Apply(Policy{}, times)); !reflect.DeepEqual(got, []int{0, 1, 2}) {
+		t.Errorf("kept = %v, expected all versions", got)
+	}
+	if !(Policy{Last: -1}).Empty() {
+		t.Error("negative counts should not enable a rule")
+	}
+}
+
+func TestApplyRules(t *testing.T) {
+	// Oldest to newest, as stored in a manifest: several backups a day
+	times := []time.Time{
+		at(1, 9), at(1, 17), // Thu
+		at(5, 9), at(5, 12), at(5, 17), // Mon, week 2
+		at(6, 9), at(6, 17), // Tue
+		at(7, 9), at(7, 10), at(7, 17), // Wed
+	}
+
+	tests := []struct {
+		name   string
+		policy Policy
+		kept   []int
+	}{
+		{"last", Policy{Last: 3}, []int{7, 8, 9}},
+		{"hourly", Policy{Hourly: 2}, []int{8, 9}},
+		{"daily", Policy{Daily: 3}, []int{4, 6, 9}},
+		{"weekly", Policy{Weekly: 2}, []int{1, 9}},
+		{"monthly", Policy{Monthly: 5}, []int{9}},
+		{"yearly", Policy{Yearly: 1}, []int{9}},
+		{"within", Policy{Within: 32 * time.Hour}, []int{5, 6, 7, 8, 9}},
+		{"combined", Policy{Last: 2, Daily: 2, Weekly: 2}, []int{1, 6, 8, 9}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := kept(Apply(tt.policy, times)); !reflect.DeepEqual(got, tt.kept) {
+				t.Errorf("kept = %v, expected %v", got, tt.kept)
+			}
+		})
+	}
+}
+
+func TestApplyReportsReasons(t *testing.T) {
+	times := []time.Time{at(1, 9), at(2, 9), at(2, 17)}
+	decisions := Apply(Policy{Last: 1, Daily: 2}, times)
+
+	want := []Decision{
+		{Keep: true, Reasons: []string{RuleDaily}},
+		{Keep: false},
+		{Keep: true, Reasons: []string{RuleLast, RuleDaily}},
+	}
+	if !reflect.DeepEqual(decisions, want) {
+		t.Errorf("decisions = %+v, expected %+v", decisions, want)
+	}
+}
+
+func TestApplyRanksByTime(t *testing.T) {
+	// Manifest order does not matter, and later entries win ties
+	times := []time.Time{at(3, 9), at(1, 9), at(3, 9)}
+	if got := kept(Apply(Policy{Last: 1}, times)); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("kept = %v, expected the later of the two newest", got)
+	}
+	if got := kept(Apply(Policy{Daily: 1}, times)); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("kept = %v, expected the newest version of the day", got)
+	}
+}
+
+func TestWithinIsRelativeToNewest(t *testing.T) {
+	// Backups stopped long ago; keep_within must not remove them all
+	times := []time.Time{at(1, 9), at(2, 9), at(3, 9)}
+	if got := kept(Apply(Policy{Within: 36 * time.Hour}, times)); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("kept = %v, expected the last 36h of backups", got)
+	}
+}
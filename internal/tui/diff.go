@@ -5,12 +5,16 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"unicode/utf8"
 
+	"github.com/jmcdonald/codebak/internal/adapters/chunkstore"
 	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/fileindex"
+	"github.com/jmcdonald/codebak/internal/manifest"
 	"github.com/sergi/go-diff/diffmatchpatch"
 )
 
@@ -32,29 +36,31 @@ type DiffResult struct {
 	Deleted  int
 }
 
-// ComputeDiff compares two backup versions and returns the differences
+// ComputeDiff compares two backup versions and returns the differences.
+// The versions' file indexes are used when both have one; otherwise the
+// archives are read.
 func ComputeDiff(cfg *config.Config, project, version1, version2 string) (*DiffResult, error) {
 	backupDir, err := config.ExpandPath(cfg.BackupDir)
 	if err != nil {
 		return nil, err
 	}
 
-	zip1Path := filepath.Join(backupDir, project, version1)
-	zip2Path := filepath.Join(backupDir, project, version2)
-
-	files1, err := listZipFiles(zip1Path)
-	if err != nil {
-		return nil, fmt.Errorf("reading %s: %w", version1, err)
-	}
+	files1, files2 := indexedVersionFiles(backupDir, project, version1, version2)
+	if files1 == nil {
+		files1, err = listVersionFiles(filepath.Join(backupDir, project, version1))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", version1, err)
+		}
 
-	files2, err := listZipFiles(zip2Path)
-	if err != nil {
-		return nil, fmt.Errorf("reading %s: %w", version2, err)
+		files2, err = listVersionFiles(filepath.Join(backupDir, project, version2))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", version2, err)
+		}
 	}
 
 	result := &DiffResult{
-		Version1: strings.TrimSuffix(version1, ".zip"),
-		Version2: strings.TrimSuffix(version2, ".zip"),
+		Version1: manifest.VersionName(version1),
+		Version2: manifest.VersionName(version2),
 	}
 
 	// Find all unique paths
@@ -85,7 +91,7 @@ func ComputeDiff(cfg *config.Config, project, version1, version2 string) (*DiffR
 			change.Status = 'A'
 			change.Size2 = info2.size
 			result.Added++
-		case info1.crc32 != info2.crc32 || info1.size != info2.size:
+		case info1.crc32 != info2.crc32 || info1.sha256 != info2.sha256 || info1.size != info2.size:
 			// File was modified
 			change.Status = 'M'
 			change.Size1 = info1.size
@@ -112,8 +118,71 @@ func ComputeDiff(cfg *config.Config, project, version1, version2 string) (*DiffR
 }
 
 type fileInfo struct {
-	size  int64
-	crc32 uint32
+	size   int64
+	crc32  uint32 // From an archive listing
+	sha256 string // From a file index
+}
+
+// indexedVersionFiles lists two versions from their file indexes. Returns nil
+// maps unless both versions have an index, since archive CRCs and index
+// checksums cannot be compared with each other.
+func indexedVersionFiles(backupDir, project, version1, version2 string) (map[string]fileInfo, map[string]fileInfo) {
+	idx1, err := fileindex.Load(backupDir, project, manifest.VersionName(version1))
+	if err != nil {
+		return nil, nil
+	}
+	idx2, err := fileindex.Load(backupDir, project, manifest.VersionName(version2))
+	if err != nil {
+		return nil, nil
+	}
+	return indexFiles(idx1), indexFiles(idx2)
+}
+
+// indexFiles converts a file index for diffing.
+func indexFiles(idx *fileindex.Index) map[string]fileInfo {
+	files := make(map[string]fileInfo, len(idx.Files))
+	for _, f := range idx.Files {
+		files[f.Path] = fileInfo{size: f.Size, sha256: f.SHA256}
+	}
+	return files
+}
+
+// versionStore reads chunked backup versions for diffing.
+var versionStore = chunkstore.New()
+
+// listVersionFiles lists the files of a backup version in either storage format.
+func listVersionFiles(path string) (map[string]fileInfo, error) {
+	if !chunkstore.IsIndex(path) {
+		return listZipFiles(path)
+	}
+
+	listing, err := versionStore.List(path)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]fileInfo, len(listing))
+	for name, info := range listing {
+		files[name] = fileInfo{size: info.Size, crc32: info.CRC32}
+	}
+	return files, nil
+}
+
+// readVersionFile reads a file from a backup version in either storage format.
+func readVersionFile(path, filePath, projectName string) (string, error) {
+	if chunkstore.IsIndex(path) {
+		return versionStore.ReadFile(path, filePath, projectName)
+	}
+	return ReadZipFile(path, filePath, projectName)
+}
+
+// versionPath returns the archive path for a version, preferring a chunk
+// store index when one exists and falling back to the zip archive.
+func versionPath(backupDir, project, version string) string {
+	indexPath := filepath.Join(backupDir, project, version+chunkstore.IndexExt)
+	if _, err := os.Stat(indexPath); err == nil {
+		return indexPath
+	}
+	return filepath.Join(backupDir, project, version+".zip")
 }
 
 func listZipFiles(zipPath string) (map[string]fileInfo, error) {
@@ -228,8 +297,8 @@ func ComputeFileDiff(cfg *config.Config, project, version1, version2, filePath s
 		return nil, err
 	}
 
-	zip1Path := filepath.Join(backupDir, project, version1+".zip")
-	zip2Path := filepath.Join(backupDir, project, version2+".zip")
+	zip1Path := versionPath(backupDir, project, version1)
+	zip2Path := versionPath(backupDir, project, version2)
 
 	result := &FileDiffResult{
 		Path:     filePath,
@@ -243,25 +312,25 @@ func ComputeFileDiff(cfg *config.Config, project, version1, version2, filePath s
 	switch status {
 	case 'A': // Added - only exists in v2
 		content1 = ""
-		content2, err = ReadZipFile(zip2Path, filePath, project)
+		content2, err = readVersionFile(zip2Path, filePath, project)
 		if err != nil {
 			result.Error = fmt.Sprintf("Error reading file: %v", err)
 			return result, nil
 		}
 	case 'D': // Deleted - only exists in v1
-		content1, err = ReadZipFile(zip1Path, filePath, project)
+		content1, err = readVersionFile(zip1Path, filePath, project)
 		if err != nil {
 			result.Error = fmt.Sprintf("Error reading file: %v", err)
 			return result, nil
 		}
 		content2 = ""
 	case 'M': // Modified - exists in both
-		content1, err = ReadZipFile(zip1Path, filePath, project)
+		content1, err = readVersionFile(zip1Path, filePath, project)
 		if err != nil {
 			result.Error = fmt.Sprintf("Error reading v1: %v", err)
 			return result, nil
 		}
-		content2, err = ReadZipFile(zip2Path, filePath, project)
+		content2, err = readVersionFile(zip2Path, filePath, project)
 		if err != nil {
 			result.Error = fmt.Sprintf("Error reading v2: %v", err)
 			return result, nil
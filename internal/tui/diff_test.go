@@ -2,11 +2,15 @@ package tui
 
 import (
 	"archive/zip"
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/jmcdonald/codebak/internal/adapters/chunkstore"
 	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/fileindex"
+	"github.com/jmcdonald/codebak/internal/ports"
 )
 
 func TestIsBinaryContent(t *testing.T) {
@@ -304,6 +308,92 @@ func TestComputeDiff(t *testing.T) {
 	}
 }
 
+func TestComputeDiffChunkedVersions(t *testing.T) {
+	tempDir := t.TempDir()
+	backupDir := filepath.Join(tempDir, "backups")
+	projectDir := filepath.Join(backupDir, "testproj")
+	sourceDir := filepath.Join(tempDir, "testproj")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatalf("Failed to create source dir: %v", err)
+	}
+
+	store := chunkstore.New()
+	writeSource := func(content string) {
+		if err := os.WriteFile(filepath.Join(sourceDir, "file1.txt"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write source file: %v", err)
+		}
+	}
+	writeSource("line one\n")
+	if _, err := store.Create(context.Background(), filepath.Join(projectDir, "v1.idx"), sourceDir, nil, ports.CompressDefault, nil); err != nil {
+		t.Fatalf("Create v1 failed: %v", err)
+	}
+	writeSource("line one\nline two\n")
+	if _, err := store.Create(context.Background(), filepath.Join(projectDir, "v2.idx"), sourceDir, nil, ports.CompressDefault, nil); err != nil {
+		t.Fatalf("Create v2 failed: %v", err)
+	}
+
+	cfg := &config.Config{BackupDir: backupDir}
+
+	result, err := ComputeDiff(cfg, "testproj", "v1.idx", "v2.idx")
+	if err != nil {
+		t.Fatalf("ComputeDiff failed: %v", err)
+	}
+	if result.Modified != 1 || result.Version1 != "v1" || result.Version2 != "v2" {
+		t.Errorf("ComputeDiff = %+v, expected one modified file between v1 and v2", result)
+	}
+
+	fileDiff, err := ComputeFileDiff(cfg, "testproj", "v1", "v2", "file1.txt", 'M')
+	if err != nil {
+		t.Fatalf("ComputeFileDiff failed: %v", err)
+	}
+	if fileDiff.Error != "" {
+		t.Fatalf("ComputeFileDiff error: %s", fileDiff.Error)
+	}
+	added := 0
+	for _, line := range fileDiff.Lines {
+		if line.Type == '+' {
+			added++
+		}
+	}
+	if added != 1 {
+		t.Errorf("added lines = %d, expected 1", added)
+	}
+}
+
+func TestComputeDiffUsesFileIndexes(t *testing.T) {
+	backupDir := t.TempDir()
+	// No archives on disk: the diff must come from the indexes alone
+	if err := fileindex.Write(backupDir, "testproj", "v1", []fileindex.File{
+		{Path: "same.txt", Size: 4, SHA256: "aaaa"},
+		{Path: "edited.txt", Size: 4, SHA256: "bbbb"},
+		{Path: "removed.txt", Size: 4, SHA256: "cccc"},
+	}); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+	if err := fileindex.Write(backupDir, "testproj", "v2", []fileindex.File{
+		{Path: "same.txt", Size: 4, SHA256: "aaaa"},
+		{Path: "edited.txt", Size: 4, SHA256: "dddd"},
+		{Path: "added.txt", Size: 4, SHA256: "eeee"},
+	}); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+	cfg := &config.Config{BackupDir: backupDir}
+
+	result, err := ComputeDiff(cfg, "testproj", "v1.zip", "v2.zip")
+	if err != nil {
+		t.Fatalf("ComputeDiff failed: %v", err)
+	}
+	if result.Added != 1 || result.Modified != 1 || result.Deleted != 1 {
+		t.Errorf("ComputeDiff = %+v, expected one added, modified and deleted file", result)
+	}
+	if result.Changes[0].Path != "edited.txt" {
+		t.Errorf("changes = %+v, expected same-size content change detected by checksum", result.Changes)
+	}
+}
+
 // Helper to create test zips
 func createTestZip(t *testing.T, path string, files map[string]string) {
 	t.Helper()
@@ -1,6 +1,8 @@
 package tui
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,6 +16,7 @@ import (
 	"github.com/jmcdonald/codebak/internal/adapters/tuisvc"
 	"github.com/jmcdonald/codebak/internal/backup"
 	"github.com/jmcdonald/codebak/internal/config"
+	"github.com/jmcdonald/codebak/internal/manifest"
 	"github.com/jmcdonald/codebak/internal/ports"
 )
 
@@ -61,8 +64,23 @@ type VersionItem struct {
 	FileCount int
 	GitHead   string
 	CreatedAt time.Time
+	Skipped   []ports.SkippedFile // Files that could not be read
+	Pinned    bool                // Kept regardless of retention
+	Labels    []string
+	Note      string
+	// Provenance is nil for versions made before provenance was recorded
+	Provenance *ports.TUIProvenance
 }
 
+// annotateField is the version metadata being typed in the versions view.
+type annotateField int
+
+const (
+	annotateNone annotateField = iota
+	annotateLabels
+	annotateNote
+)
+
 // Model is the main TUI model
 type Model struct {
 	config   *config.Config
@@ -113,9 +131,27 @@ type Model struct {
 	pathInput          textinput.Model
 	pendingMovePath    string // Path selected, awaiting confirmation
 
+	// Label or note being typed for the selected version
+	annotating    annotateField
+	annotateInput textinput.Model
+
+	// Restore waiting for the user to confirm overwriting local changes
+	pendingRestore *restoreRequest
+
+	// Recovery of the selected project being set up in the versions view
+	recovering *recoverFlow
+
 	// Status message
 	statusMsg string
 	statusErr bool
+
+	// Running backup, if any
+	backupCancel context.CancelFunc // Stops the running backup
+	backupDone   chan struct{}      // Closed once the backup has returned
+
+	// Running restore or recovery, if any
+	restoreCancel context.CancelFunc // Stops the running restore
+	restoreDone   chan struct{}      // Closed once the restore has returned
 }
 
 // Key bindings
@@ -132,6 +168,11 @@ type keyMap struct {
 	Swap    key.Binding
 	Quit     key.Binding
 	Settings key.Binding
+
+	// Version annotations
+	Pin  key.Binding
+	Tag  key.Binding
+	Note key.Binding
 }
 
 var keys = keyMap{
@@ -183,11 +224,23 @@ var keys = keyMap{
 		key.WithKeys("?"),
 		key.WithHelp("?", "settings"),
 	),
+	Pin: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "pin"),
+	),
+	Tag: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "labels"),
+	),
+	Note: key.NewBinding(
+		key.WithKeys("n"),
+		key.WithHelp("n", "note"),
+	),
 }
 
 // NewModel creates a new TUI model with default service.
 func NewModel(version string) (*Model, error) {
-	return NewModelWithService(version, tuisvc.New())
+	return NewModelWithService(version, tuisvc.New(version))
 }
 
 // NewModelWithService creates a new TUI model with a custom service.
@@ -314,6 +367,12 @@ func (m *Model) loadVersions() error {
 			FileCount: v.FileCount,
 			GitHead:   v.GitHead,
 			CreatedAt: v.CreatedAt,
+			Skipped:   v.Skipped,
+			Pinned:    v.Pinned,
+			Labels:    v.Labels,
+			Note:      v.Note,
+
+			Provenance: v.Provenance,
 		})
 	}
 
@@ -393,6 +452,33 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
+	// Esc stops a running restore or recovery
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && keyMsg.Type == tea.KeyEsc && m.restoreDone != nil {
+		m.cancelRestore()
+		return m, nil
+	}
+
+	// Typing a label or note takes every key until saved or cancelled
+	if m.annotating != annotateNone {
+		if keyMsg, ok := msg.(tea.KeyMsg); ok {
+			return m.handleAnnotateInput(keyMsg)
+		}
+	}
+
+	// A restore that would overwrite local changes waits for y or n
+	if m.pendingRestore != nil {
+		if keyMsg, ok := msg.(tea.KeyMsg); ok {
+			return m.handleRestoreConfirm(keyMsg)
+		}
+	}
+
+	// Setting up a recovery takes every key until it starts or is cancelled
+	if m.recovering != nil {
+		if keyMsg, ok := msg.(tea.KeyMsg); ok {
+			return m.handleRecoverInput(keyMsg)
+		}
+	}
+
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
@@ -402,6 +488,8 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case statusMsg:
 		m.statusMsg = msg.msg
 		m.statusErr = msg.err
+		m.clearFinishedBackup()
+		m.clearFinishedRestore()
 		// Reload data to reflect changes
 		_ = m.loadProjects()
 		if m.view == VersionsView {
@@ -409,6 +497,12 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case backupProgressMsg:
+		m.statusMsg = fmt.Sprintf("Backing up %s %s  %s", msg.project,
+			backup.FormatProgress(msg.progress, 20), truncatePath(msg.progress.Path, 30))
+		m.statusErr = false
+		return m, waitForBackup(msg.events)
+
 	case diffMsg:
 		if msg.err != nil {
 			m.statusMsg = fmt.Sprintf("Diff failed: %v", msg.err)
@@ -423,6 +517,14 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case restoreMsg:
+		m.clearFinishedRestore()
+		m.showRestoreResult(msg)
+		return m, nil
+
+	case recoverPreviewMsg:
+		return m, m.showRecoverPreview(msg)
+
 	case fileDiffMsg:
 		if msg.err != nil {
 			m.statusMsg = fmt.Sprintf("File diff failed: %v", msg.err)
@@ -443,6 +545,8 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch {
 		case key.Matches(msg, keys.Quit):
 			m.quitting = true
+			m.cancelBackup()
+			m.cancelRestore()
 			return m, tea.Quit
 
 		case key.Matches(msg, keys.Up):
@@ -567,6 +671,16 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case key.Matches(msg, keys.Verify):
 			return m, m.runVerify()
 
+		case key.Matches(msg, keys.Recover):
+			if m.view == DiffResultView || m.view == FileDiffView {
+				if req, ok := m.selectedRestore(); ok {
+					return m, m.restorePath(req, false)
+				}
+			}
+			if m.view == VersionsView && len(m.versions) > 0 {
+				m.recovering = &recoverFlow{version: manifest.VersionName(m.versions[m.versionCursor].File)}
+			}
+
 		case key.Matches(msg, keys.Diff):
 			if m.view == VersionsView && len(m.versions) >= 2 {
 				m.view = DiffSelectView
@@ -574,6 +688,21 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.statusMsg = "Select 2 versions to compare (space to select)"
 			}
 
+		case key.Matches(msg, keys.Pin):
+			if m.view == VersionsView && len(m.versions) > 0 {
+				return m, m.togglePin()
+			}
+
+		case key.Matches(msg, keys.Tag):
+			if m.view == VersionsView && len(m.versions) > 0 {
+				return m, m.startAnnotate(annotateLabels)
+			}
+
+		case key.Matches(msg, keys.Note):
+			if m.view == VersionsView && len(m.versions) > 0 {
+				return m, m.startAnnotate(annotateNote)
+			}
+
 		case key.Matches(msg, keys.Select):
 			if m.view == DiffSelectView {
 				return m, m.toggleDiffSelection()
@@ -811,6 +940,370 @@ func (m *Model) handlePathInput(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// togglePin pins the selected version, or unpins it if already pinned.
+func (m *Model) togglePin() tea.Cmd {
+	v := m.versions[m.versionCursor]
+	pinned := !v.Pinned
+	version := manifest.VersionName(v.File)
+	done := fmt.Sprintf("★ Pinned %s", version)
+	if !pinned {
+		done = fmt.Sprintf("Unpinned %s", version)
+	}
+	return m.annotateVersion(version, ports.TUIAnnotation{Pinned: &pinned}, done)
+}
+
+// startAnnotate opens the input for the selected version's labels or note,
+// filled in with the current value.
+func (m *Model) startAnnotate(field annotateField) tea.Cmd {
+	v := m.versions[m.versionCursor]
+	m.annotating = field
+	m.annotateInput = textinput.New()
+	m.annotateInput.CharLimit = 256
+	m.annotateInput.Width = 50
+	if field == annotateLabels {
+		m.annotateInput.Placeholder = "release, before-refactor"
+		m.annotateInput.SetValue(strings.Join(v.Labels, ", "))
+	} else {
+		m.annotateInput.Placeholder = "what this version is"
+		m.annotateInput.SetValue(v.Note)
+	}
+	return m.annotateInput.Focus()
+}
+
+// handleAnnotateInput handles keys while a label or note is being typed.
+func (m *Model) handleAnnotateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.annotating = annotateNone
+		m.annotateInput.Blur()
+		return m, nil
+	case tea.KeyEnter:
+		field := m.annotating
+		m.annotating = annotateNone
+		m.annotateInput.Blur()
+
+		v := m.versions[m.versionCursor]
+		version := manifest.VersionName(v.File)
+		value := strings.TrimSpace(m.annotateInput.Value())
+		if field == annotateNote {
+			return m, m.annotateVersion(version, ports.TUIAnnotation{Note: &value}, fmt.Sprintf("✓ Saved note on %s", version))
+		}
+		return m, m.annotateVersion(version, labelChanges(v.Labels, value), fmt.Sprintf("✓ Saved labels on %s", version))
+	}
+
+	var cmd tea.Cmd
+	m.annotateInput, cmd = m.annotateInput.Update(msg)
+	return m, cmd
+}
+
+// labelChanges turns an edited comma-separated label list into the labels
+// to add and remove.
+func labelChanges(current []string, edited string) ports.TUIAnnotation {
+	var a ports.TUIAnnotation
+	want := make(map[string]bool)
+	for _, label := range strings.Split(edited, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			want[label] = true
+			a.AddLabels = append(a.AddLabels, label)
+		}
+	}
+	for _, label := range current {
+		if !want[label] {
+			a.RemoveLabels = append(a.RemoveLabels, label)
+		}
+	}
+	return a
+}
+
+// annotateVersion saves an annotation of the selected project's version,
+// reporting done on success.
+func (m *Model) annotateVersion(version string, a ports.TUIAnnotation, done string) tea.Cmd {
+	cfg, service, project := m.config, m.service, m.selectedProject
+	return func() tea.Msg {
+		if err := service.AnnotateVersion(cfg, project, version, a); err != nil {
+			return statusMsg{err: true, msg: fmt.Sprintf("✗ %v", err)}
+		}
+		return statusMsg{msg: done}
+	}
+}
+
+// restoreRequest is a file or directory to restore from a backup version.
+type restoreRequest struct {
+	version string
+	path    string
+}
+
+// restoreMsg reports the outcome of a restore.
+type restoreMsg struct {
+	req    restoreRequest
+	result ports.TUIRestoreResult
+	err    error
+}
+
+// selectedRestore returns the file selected in the diff views, restored
+// from the second version compared, or the first for deleted files.
+func (m *Model) selectedRestore() (restoreRequest, bool) {
+	if m.diffResult == nil {
+		return restoreRequest{}, false
+	}
+	var change *FileChange
+	if m.view == FileDiffView && m.fileDiffResult != nil {
+		for i := range m.diffResult.Changes {
+			if m.diffResult.Changes[i].Path == m.fileDiffResult.Path {
+				change = &m.diffResult.Changes[i]
+				break
+			}
+		}
+	} else if m.view == DiffResultView && m.diffCursor < len(m.diffResult.Changes) {
+		change = &m.diffResult.Changes[m.diffCursor]
+	}
+	if change == nil {
+		return restoreRequest{}, false
+	}
+	version := m.diffResult.Version2
+	if change.Status == 'D' {
+		version = m.diffResult.Version1
+	}
+	return restoreRequest{version: version, path: change.Path}, true
+}
+
+// restorePath restores req into the selected project's directory.
+func (m *Model) restorePath(req restoreRequest, overwrite bool) tea.Cmd {
+	cfg, service, project := m.config, m.service, m.selectedProject
+	return m.startRestore(func(ctx context.Context) tea.Msg {
+		result, err := service.RestorePath(ctx, cfg, project, req.version, req.path, overwrite)
+		return restoreMsg{req: req, result: result, err: err}
+	})
+}
+
+// startRestore runs restore in the background until it finishes or
+// cancelRestore stops it, and delivers the message it returns.
+func (m *Model) startRestore(restore func(ctx context.Context) tea.Msg) tea.Cmd {
+	m.clearFinishedRestore()
+	if m.restoreDone != nil {
+		return func() tea.Msg {
+			return statusMsg{err: true, msg: "A restore is already running"}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	m.restoreCancel, m.restoreDone = cancel, done
+	result := make(chan tea.Msg, 1)
+	go func() {
+		msg := restore(ctx)
+		close(done)
+		result <- msg
+	}()
+	return func() tea.Msg {
+		return <-result
+	}
+}
+
+// cancelRestore stops a running restore and waits for it to return, so no
+// file is left half written.
+func (m *Model) cancelRestore() {
+	if m.restoreCancel == nil {
+		return
+	}
+	m.restoreCancel()
+	<-m.restoreDone
+	m.restoreCancel, m.restoreDone = nil, nil
+}
+
+// clearFinishedRestore forgets the running restore once it has returned.
+func (m *Model) clearFinishedRestore() {
+	if m.restoreDone == nil {
+		return
+	}
+	select {
+	case <-m.restoreDone:
+		m.restoreCancel()
+		m.restoreCancel, m.restoreDone = nil, nil
+	default:
+	}
+}
+
+// showRestoreResult reports a finished restore, or asks before
+// overwriting local changes.
+func (m *Model) showRestoreResult(msg restoreMsg) {
+	switch {
+	case errors.Is(msg.err, context.Canceled):
+		m.statusMsg = "Restore cancelled"
+		m.statusErr = false
+	case msg.err != nil:
+		m.statusMsg = fmt.Sprintf("✗ Restore failed: %v", msg.err)
+		m.statusErr = true
+	case len(msg.result.Conflicts) > 0:
+		req := msg.req
+		m.pendingRestore = &req
+		m.statusMsg = fmt.Sprintf("%s: %d local file(s) differ from %s. Overwrite? (y/n)",
+			req.path, len(msg.result.Conflicts), req.version)
+		m.statusErr = true
+	case msg.result.Restored == 0:
+		m.statusMsg = fmt.Sprintf("%s already matches %s", msg.req.path, msg.req.version)
+		m.statusErr = false
+	default:
+		m.statusMsg = fmt.Sprintf("✓ Restored %s from %s (%d file(s), %d overwritten)",
+			msg.req.path, msg.req.version, msg.result.Restored, msg.result.Overwritten)
+		m.statusErr = false
+	}
+}
+
+// handleRestoreConfirm overwrites local changes on y and cancels otherwise.
+func (m *Model) handleRestoreConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	req := *m.pendingRestore
+	m.pendingRestore = nil
+	if msg.String() == "y" || msg.String() == "Y" {
+		m.statusMsg = fmt.Sprintf("Restoring %s...", req.path)
+		m.statusErr = false
+		return m, m.restorePath(req, true)
+	}
+	m.statusMsg = "Restore cancelled"
+	m.statusErr = false
+	return m, nil
+}
+
+// recoverStep is the stage reached by the recover flow in the versions view.
+type recoverStep int
+
+const (
+	recoverChoose     recoverStep = iota // Choosing wipe, archive or another directory
+	recoverTyping                        // Typing the other directory
+	recoverPreviewing                    // Comparing the version with the directory
+	recoverConfirm                       // Showing the preview, waiting for y or n
+)
+
+// recoverFlow is a recovery of the selected project's version being set up.
+type recoverFlow struct {
+	version string
+	step    recoverStep
+	opts    ports.TUIRecoverOptions
+	input   textinput.Model // Other directory, while typing it
+	preview ports.TUIRecoverPreview
+}
+
+// recoverPreviewMsg reports what recovering a version would change.
+type recoverPreviewMsg struct {
+	version string
+	preview ports.TUIRecoverPreview
+	err     error
+}
+
+// handleRecoverInput handles keys while a recovery is being set up.
+func (m *Model) handleRecoverInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	flow := m.recovering
+	switch flow.step {
+	case recoverChoose:
+		switch msg.String() {
+		case "w":
+			flow.opts.Wipe = true
+			return m, m.previewRecover()
+		case "a":
+			flow.opts.Archive = true
+			return m, m.previewRecover()
+		case "o":
+			flow.step = recoverTyping
+			flow.input = newPathInput()
+			return m, flow.input.Focus()
+		case "esc", "n", "q":
+			m.cancelRecover()
+		}
+	case recoverTyping:
+		switch msg.Type {
+		case tea.KeyEsc:
+			flow.step = recoverChoose
+			return m, nil
+		case tea.KeyEnter:
+			target := strings.TrimSpace(flow.input.Value())
+			if target == "" {
+				return m, nil
+			}
+			flow.opts.Target = target
+			flow.input.Blur()
+			return m, m.previewRecover()
+		}
+		var cmd tea.Cmd
+		flow.input, cmd = flow.input.Update(msg)
+		return m, cmd
+	case recoverPreviewing:
+		if msg.Type == tea.KeyEsc {
+			m.cancelRecover()
+		}
+	case recoverConfirm:
+		if msg.String() == "y" || msg.String() == "Y" {
+			return m, m.runRecover()
+		}
+		m.cancelRecover()
+	}
+	return m, nil
+}
+
+// cancelRecover abandons the recovery being set up.
+func (m *Model) cancelRecover() {
+	m.recovering = nil
+	m.statusMsg = "Recovery cancelled"
+	m.statusErr = false
+}
+
+// previewRecover compares the version being recovered with the directory
+// it would be restored into.
+func (m *Model) previewRecover() tea.Cmd {
+	m.recovering.step = recoverPreviewing
+	cfg, service, project := m.config, m.service, m.selectedProject
+	version, target := m.recovering.version, m.recovering.opts.Target
+	return func() tea.Msg {
+		preview, err := service.PreviewRecover(cfg, project, version, target)
+		return recoverPreviewMsg{version: version, preview: preview, err: err}
+	}
+}
+
+// showRecoverPreview shows what the recovery would change and asks to go
+// ahead. It ends the flow if the comparison failed, and asks for another
+// directory if the one typed is not empty.
+func (m *Model) showRecoverPreview(msg recoverPreviewMsg) tea.Cmd {
+	flow := m.recovering
+	// Ignore previews of a recovery that was cancelled meanwhile
+	if flow == nil || flow.step != recoverPreviewing || flow.version != msg.version {
+		return nil
+	}
+	if msg.err != nil {
+		m.recovering = nil
+		m.statusMsg = fmt.Sprintf("✗ Preview failed: %v", msg.err)
+		m.statusErr = true
+		return nil
+	}
+	p := msg.preview
+	if flow.opts.Target != "" && p.Overwritten+p.Removed+p.Unchanged > 0 {
+		flow.step = recoverTyping
+		m.statusMsg = fmt.Sprintf("✗ %s is not empty; choose an empty or new directory", p.Dir)
+		m.statusErr = true
+		return flow.input.Focus()
+	}
+	flow.preview = p
+	flow.step = recoverConfirm
+	return nil
+}
+
+// runRecover starts recovering the version, ending the flow.
+func (m *Model) runRecover() tea.Cmd {
+	flow := *m.recovering
+	m.recovering = nil
+	cfg, service, project := m.config, m.service, m.selectedProject
+	m.statusMsg = fmt.Sprintf("Recovering %s from %s...", project, flow.version)
+	m.statusErr = false
+	return m.startRestore(func(ctx context.Context) tea.Msg {
+		err := service.RecoverVersion(ctx, cfg, project, flow.version, flow.opts)
+		switch {
+		case errors.Is(err, context.Canceled):
+			return statusMsg{msg: "Recovery cancelled"}
+		case err != nil:
+			return statusMsg{err: true, msg: fmt.Sprintf("✗ Recover failed: %v", err)}
+		}
+		return statusMsg{msg: fmt.Sprintf("✓ Recovered %s from %s to %s", project, flow.version, flow.preview.Dir)}
+	})
+}
+
 // handleMoveConfirm handles the confirmation dialog
 func (m *Model) handleMoveConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
@@ -895,27 +1388,89 @@ func (m *Model) executeMoveBackups(newPath string) tea.Cmd {
 }
 
 func (m *Model) runBackup() tea.Cmd {
-	return func() tea.Msg {
-		var project string
-		if m.view == ProjectsView && len(m.projects) > 0 {
-			project = m.projects[m.projectCursor].Name
-		} else if m.view == VersionsView {
-			project = m.selectedProject
-		}
+	var project string
+	if m.view == ProjectsView && len(m.projects) > 0 {
+		project = m.projects[m.projectCursor].Name
+	} else if m.view == VersionsView {
+		project = m.selectedProject
+	}
 
-		if project == "" {
+	if project == "" {
+		return func() tea.Msg {
 			return statusMsg{err: true, msg: "No project selected"}
 		}
+	}
+	if m.backupDone != nil {
+		return func() tea.Msg {
+			return statusMsg{err: true, msg: "A backup is already running"}
+		}
+	}
+
+	// The backup runs until it finishes or cancelBackup stops it. Progress
+	// updates and the final status share one channel, which waitForBackup
+	// drains one message per Update.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	m.backupCancel, m.backupDone = cancel, done
+	events := make(chan tea.Msg, 1)
+	cfg, service := m.config, m.service
+	go func() {
+		result := service.RunBackup(ctx, cfg, project, func(p ports.Progress) {
+			// Drop updates the UI has not caught up with rather than stall the backup
+			select {
+			case events <- backupProgressMsg{project: project, progress: p, events: events}:
+			default:
+			}
+		})
+		close(done)
+		events <- backupStatus(project, result)
+	}()
+	return waitForBackup(events)
+}
 
-		result := m.service.RunBackup(m.config, project)
-		if result.Error != nil {
-			return statusMsg{err: true, msg: fmt.Sprintf("Backup failed: %v", result.Error)}
-		}
-		if result.Skipped {
-			return statusMsg{msg: fmt.Sprintf("%s: %s", project, result.Reason)}
-		}
-		return statusMsg{msg: fmt.Sprintf("✓ Backed up %s (%s)", project, backup.FormatSize(result.Size))}
+// cancelBackup stops a running backup and waits for it to remove its
+// partial files.
+func (m *Model) cancelBackup() {
+	if m.backupCancel == nil {
+		return
 	}
+	m.backupCancel()
+	<-m.backupDone
+	m.backupCancel, m.backupDone = nil, nil
+}
+
+// clearFinishedBackup forgets the running backup once it has returned.
+func (m *Model) clearFinishedBackup() {
+	if m.backupDone == nil {
+		return
+	}
+	select {
+	case <-m.backupDone:
+		m.backupCancel()
+		m.backupCancel, m.backupDone = nil, nil
+	default:
+	}
+}
+
+// waitForBackup delivers the next message from a running backup.
+func waitForBackup(events <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-events
+	}
+}
+
+// backupStatus converts a finished backup into the status line to show.
+func backupStatus(project string, result ports.TUIBackupResult) statusMsg {
+	if result.Error != nil {
+		return statusMsg{err: true, msg: fmt.Sprintf("Backup failed: %v", result.Error)}
+	}
+	if result.Skipped {
+		return statusMsg{msg: fmt.Sprintf("%s: %s", project, result.Reason)}
+	}
+	if len(result.SkippedFiles) > 0 {
+		return statusMsg{msg: fmt.Sprintf("✓ Backed up %s (%s), %d file(s) could not be read", project, backup.FormatSize(result.Size), len(result.SkippedFiles))}
+	}
+	return statusMsg{msg: fmt.Sprintf("✓ Backed up %s (%s)", project, backup.FormatSize(result.Size))}
 }
 
 func (m *Model) runVerify() tea.Cmd {
@@ -944,6 +1499,13 @@ type statusMsg struct {
 	err bool
 }
 
+// backupProgressMsg reports archive progress of a running backup.
+type backupProgressMsg struct {
+	project  string
+	progress ports.Progress
+	events   chan tea.Msg
+}
+
 type diffMsg struct {
 	result *DiffResult
 	err    error
@@ -1164,7 +1726,7 @@ func (m *Model) renderVersionsView() string {
 				style = selectedStyle
 			}
 
-			version := strings.TrimSuffix(v.File, ".zip")
+			version := manifest.VersionName(v.File)
 			gitHead := v.GitHead
 			if len(gitHead) > 7 {
 				gitHead = gitHead[:7]
@@ -1176,12 +1738,25 @@ func (m *Model) renderVersionsView() string {
 			line := fmt.Sprintf("%s%-18s %10s %8d %10s",
 				cursor, version, backup.FormatSize(v.Size), v.FileCount, gitHead)
 			b.WriteString(style.Render(line))
+			if v.Pinned {
+				b.WriteString(warningStyle.Render("  ★"))
+			}
+			if len(v.Labels) > 0 {
+				b.WriteString(dimStyle.Render("  [" + strings.Join(v.Labels, ", ") + "]"))
+			}
+			if len(v.Skipped) > 0 {
+				b.WriteString(warningStyle.Render(fmt.Sprintf("  ! %d unreadable", len(v.Skipped))))
+			}
 			b.WriteString("\n")
 		}
 	}
 
+	// Recovery, note, label input, provenance and files missing from the selected version
+	details := m.renderRecover() + m.renderAnnotation() + m.renderProvenance() + m.renderSkippedFiles()
+	b.WriteString(details)
+
 	// Pad to fixed height
-	visibleHeight := m.height - 10
+	visibleHeight := m.height - 10 - strings.Count(details, "\n")
 	for i := len(m.versions); i < visibleHeight; i++ {
 		b.WriteString("\n")
 	}
@@ -1198,12 +1773,138 @@ func (m *Model) renderVersionsView() string {
 	b.WriteString("\n")
 
 	// Help
-	help := "[↑/↓] navigate  [d] diff  [esc] back  [r] backup  [v] verify  [q] quit"
+	help := "[↑/↓] navigate  [d] diff  [p] pin  [t] labels  [n] note  [esc] back  [r] backup  [v] verify  [R] recover  [q] quit"
+	if m.annotating != annotateNone {
+		help = "[enter] save  [esc] cancel"
+	}
+	if m.recovering != nil {
+		help = recoverHelp[m.recovering.step]
+	}
 	b.WriteString(renderSplitFooter(help, m.width))
 
 	return b.String()
 }
 
+// recoverHelp is the versions view help for each step of the recover flow.
+var recoverHelp = map[recoverStep]string{
+	recoverChoose:     "[w] wipe current  [a] archive current  [o] other directory  [esc] cancel",
+	recoverTyping:     "[enter] preview  [esc] back",
+	recoverPreviewing: "[esc] cancel",
+	recoverConfirm:    "[y] recover  [n] cancel",
+}
+
+// renderRecover shows the recovery being set up, or "" when there is none.
+func (m *Model) renderRecover() string {
+	flow := m.recovering
+	if flow == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("\n")
+	b.WriteString(warningStyle.Render(fmt.Sprintf("  Recover %s from %s", m.selectedProject, flow.version)))
+	b.WriteString("\n")
+	switch flow.step {
+	case recoverChoose:
+		b.WriteString("  Wipe the current copy, archive it, or restore into another directory?\n")
+	case recoverTyping:
+		b.WriteString("  Restore into (empty or new directory): " + flow.input.View() + "\n")
+	case recoverPreviewing:
+		b.WriteString(dimStyle.Render("  Comparing with the current files..."))
+		b.WriteString("\n")
+	case recoverConfirm:
+		p := flow.preview
+		removed := "removed"
+		if flow.opts.Archive {
+			removed = "archived with the current copy"
+		}
+		b.WriteString(fmt.Sprintf("  %d file(s) will change in %s: %d added, %d overwritten, %d %s\n",
+			p.Added+p.Overwritten+p.Removed, p.Dir, p.Added, p.Overwritten, p.Removed, removed))
+		if p.Newer > 0 {
+			b.WriteString(warningStyle.Render(fmt.Sprintf("  ! %d overwritten file(s) are newer locally than the backup", p.Newer)))
+			b.WriteString("\n")
+		}
+		b.WriteString(warningStyle.Render("  Recover? (y/n)"))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// renderAnnotation shows the input while a label or note is being typed,
+// otherwise the selected version's note, or "" when it has none.
+func (m *Model) renderAnnotation() string {
+	switch {
+	case m.annotating == annotateLabels:
+		return "\n  Labels (comma-separated): " + m.annotateInput.View() + "\n"
+	case m.annotating == annotateNote:
+		return "\n  Note: " + m.annotateInput.View() + "\n"
+	case m.versionCursor < len(m.versions) && m.versions[m.versionCursor].Note != "":
+		return "\n" + dimStyle.Render("  ✎ "+m.versions[m.versionCursor].Note) + "\n"
+	}
+	return ""
+}
+
+// renderProvenance describes how the selected version was made, or returns
+// "" for versions that predate provenance records.
+func (m *Model) renderProvenance() string {
+	if m.versionCursor >= len(m.versions) {
+		return ""
+	}
+	p := m.versions[m.versionCursor].Provenance
+	if p == nil {
+		return ""
+	}
+
+	var parts []string
+	if p.User != "" || p.Hostname != "" {
+		parts = append(parts, p.User+"@"+p.Hostname)
+	}
+	if p.CodebakVersion != "" {
+		parts = append(parts, "codebak "+p.CodebakVersion)
+	}
+	if p.Branch != "" {
+		parts = append(parts, "branch "+p.Branch)
+	}
+	if p.Dirty {
+		parts = append(parts, "uncommitted changes")
+	}
+	parts = append(parts, p.Trigger, "took "+p.Duration.Round(time.Millisecond).String())
+	if len(p.ConfigHash) > 12 {
+		parts = append(parts, "config "+p.ConfigHash[:12])
+	}
+	return "\n" + dimStyle.Render("  "+strings.Join(parts, " · ")) + "\n"
+}
+
+// maxSkippedShown limits how many unreadable files the versions view lists.
+const maxSkippedShown = 3
+
+// renderSkippedFiles lists the files that could not be read for the selected
+// version, or returns "" when it is complete.
+func (m *Model) renderSkippedFiles() string {
+	if m.versionCursor >= len(m.versions) {
+		return ""
+	}
+	skipped := m.versions[m.versionCursor].Skipped
+	if len(skipped) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("\n")
+	b.WriteString(warningStyle.Render(fmt.Sprintf("  ! %d file(s) could not be read and are missing from this version:", len(skipped))))
+	b.WriteString("\n")
+	for i, f := range skipped {
+		if i == maxSkippedShown {
+			b.WriteString(dimStyle.Render(fmt.Sprintf("    ... and %d more", len(skipped)-maxSkippedShown)))
+			b.WriteString("\n")
+			break
+		}
+		b.WriteString(dimStyle.Render(fmt.Sprintf("    %s (%s)", f.Path, f.Reason)))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
 func (m *Model) renderSnapshotsView() string {
 	var b strings.Builder
 
@@ -1571,7 +2272,7 @@ func (m *Model) renderDiffSelectView() string {
 			checkbox = "[✓]"
 		}
 
-		version := strings.TrimSuffix(v.File, ".zip")
+		version := manifest.VersionName(v.File)
 		gitHead := v.GitHead
 		if len(gitHead) > 7 {
 			gitHead = gitHead[:7]
@@ -1687,7 +2388,7 @@ func (m *Model) renderDiffResultView() string {
 	b.WriteString("\n")
 
 	// Help
-	help := "[↑/↓] navigate  [enter] view diff  [esc] back  [q] quit"
+	help := "[↑/↓] navigate  [enter] view diff  [R] restore file  [esc] back  [q] quit"
 	b.WriteString(renderSplitFooter(help, m.width))
 
 	return b.String()
@@ -1814,7 +2515,7 @@ func (m *Model) renderFileDiffView() string {
 	b.WriteString("\n")
 
 	// Help
-	help := "[↑/↓] scroll  [s] swap sides  [esc] back  [q] quit"
+	help := "[↑/↓] scroll  [s] swap sides  [R] restore file  [esc] back  [q] quit"
 	b.WriteString(renderSplitFooter(help, m.width))
 
 	return b.String()
@@ -1981,6 +2682,9 @@ func Run(version string) error {
 
 	p := tea.NewProgram(m, tea.WithAltScreen())
 	_, err = p.Run()
+	// Never exit with a backup or restore still writing
+	m.cancelBackup()
+	m.cancelRestore()
 	return err
 }
 
@@ -1,7 +1,9 @@
 package tui
 
 import (
+	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -643,6 +645,44 @@ func TestRunBackupNoProject(t *testing.T) {
 	}
 }
 
+func TestRunBackupReportsProgress(t *testing.T) {
+	svc := mocks.NewMockTUIService()
+	svc.BackupResults = map[string]ports.TUIBackupResult{
+		"my-project": {Size: 2048},
+	}
+	svc.BackupProgress = []ports.Progress{
+		{Files: 1, FilesTotal: 2, Bytes: 1024, BytesTotal: 2048, Path: "main.go"},
+		{Files: 2, FilesTotal: 2, Bytes: 2048, BytesTotal: 2048, Path: "go.mod"},
+	}
+	m := NewModelWithConfig(&config.Config{}, svc)
+	m.projects = []ProjectItem{{Name: "my-project"}}
+	m.view = ProjectsView
+
+	progress, ok := m.runBackup()().(backupProgressMsg)
+	if !ok {
+		t.Fatal("expected first message to be a progress update")
+	}
+	_, cmd := m.Update(progress)
+	if !contains(m.statusMsg, "Backing up my-project [") || !contains(m.statusMsg, "main.go") {
+		t.Errorf("statusMsg = %q, expected a progress bar for my-project", m.statusMsg)
+	}
+	if cmd == nil {
+		t.Fatal("expected a command waiting for the next backup message")
+	}
+
+	// Remaining updates are followed by the final status
+	for {
+		msg := cmd()
+		if status, ok := msg.(statusMsg); ok {
+			if status.err || !contains(status.msg, "Backed up my-project") {
+				t.Errorf("final status = %+v, expected success", status)
+			}
+			break
+		}
+		_, cmd = m.Update(msg)
+	}
+}
+
 func TestRunBackupError(t *testing.T) {
 	svc := mocks.NewMockTUIService()
 	svc.BackupResults = map[string]ports.TUIBackupResult{
@@ -683,6 +723,43 @@ func TestRunBackupSkipped(t *testing.T) {
 	}
 }
 
+func TestRunBackupUnreadableFiles(t *testing.T) {
+	svc := mocks.NewMockTUIService()
+	svc.BackupResults = map[string]ports.TUIBackupResult{
+		"my-project": {Size: 2048, SkippedFiles: []ports.SkippedFile{{Path: "secret.key", Reason: "permission denied"}}},
+	}
+	m := NewModelWithConfig(&config.Config{}, svc)
+	m.projects = []ProjectItem{{Name: "my-project"}}
+	m.view = ProjectsView
+
+	msg := m.runBackup()().(statusMsg)
+	if msg.err || !contains(msg.msg, "1 file(s) could not be read") {
+		t.Errorf("msg = %+v, expected success mentioning unreadable files", msg)
+	}
+}
+
+func TestQuitCancelsRunningBackup(t *testing.T) {
+	svc := mocks.NewMockTUIService()
+	svc.BackupWaitsForCancel = true
+	m := NewModelWithConfig(&config.Config{}, svc)
+	m.projects = []ProjectItem{{Name: "my-project"}}
+	m.view = ProjectsView
+
+	wait := m.runBackup()
+	if msg := m.runBackup()().(statusMsg); !msg.err || !contains(msg.msg, "already running") {
+		t.Errorf("second backup status = %+v, expected already running", msg)
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'q'}})
+	if m.backupDone != nil {
+		t.Error("quitting should wait for the backup to stop")
+	}
+	msg, ok := wait().(statusMsg)
+	if !ok || !msg.err || !contains(msg.msg, "context canceled") {
+		t.Errorf("final status = %+v, expected cancelled backup", msg)
+	}
+}
+
 func TestRunVerifySuccess(t *testing.T) {
 	svc := mocks.NewMockTUIService()
 	m := NewModelWithConfig(&config.Config{}, svc)
@@ -1144,6 +1221,457 @@ func TestRenderVersionsView(t *testing.T) {
 	}
 }
 
+func TestRenderVersionsViewUnreadableFiles(t *testing.T) {
+	svc := mocks.NewMockTUIService()
+	svc.Versions = map[string][]ports.TUIVersionInfo{
+		"my-project": {
+			{File: "20240115-120000.zip", Size: 2048, FileCount: 50, Skipped: []ports.SkippedFile{
+				{Path: "a.key", Reason: "permission denied"},
+				{Path: "b.key", Reason: "permission denied"},
+				{Path: "c.key", Reason: "permission denied"},
+				{Path: "d.key", Reason: "permission denied"},
+			}},
+			{File: "20240114-120000.zip", Size: 1024, FileCount: 45},
+		},
+	}
+	m := NewModelWithConfig(&config.Config{}, svc)
+	m.selectedProject = "my-project"
+	if err := m.loadVersions(); err != nil {
+		t.Fatalf("loadVersions failed: %v", err)
+	}
+	m.width = 80
+	m.height = 24
+	m.view = VersionsView
+
+	view := m.View()
+	if !contains(view, "! 4 unreadable") {
+		t.Error("View should flag the version with unreadable files")
+	}
+	if !contains(view, "a.key (permission denied)") || !contains(view, "... and 1 more") {
+		t.Errorf("View should list the selected version's unreadable files, got %q", view)
+	}
+
+	// A complete version shows no details
+	m.versionCursor = 1
+	if view := m.View(); contains(view, "could not be read") {
+		t.Error("View should not list unreadable files for a complete version")
+	}
+}
+
+func TestRenderVersionsViewProvenance(t *testing.T) {
+	svc := mocks.NewMockTUIService()
+	svc.Versions = map[string][]ports.TUIVersionInfo{
+		"my-project": {
+			{File: "20240115-120000.zip", Provenance: &ports.TUIProvenance{
+				Hostname:       "laptop",
+				User:           "dev",
+				CodebakVersion: "1.4.0",
+				Branch:         "main",
+				Dirty:          true,
+				Duration:       1500 * time.Millisecond,
+				Trigger:        "schedule",
+				ConfigHash:     "3f2a1b9c0d4e5f60718293a4b5c6d7e8",
+			}},
+			{File: "20240114-120000.zip"},
+		},
+	}
+	m := NewModelWithConfig(&config.Config{}, svc)
+	m.selectedProject = "my-project"
+	if err := m.loadVersions(); err != nil {
+		t.Fatalf("loadVersions failed: %v", err)
+	}
+	m.width = 100
+	m.height = 24
+	m.view = VersionsView
+
+	view := m.View()
+	for _, want := range []string{"dev@laptop", "codebak 1.4.0", "branch main", "uncommitted changes", "schedule", "took 1.5s", "config 3f2a1b9c0d4e"} {
+		if !contains(view, want) {
+			t.Errorf("View should show %q for the selected version, got %q", want, view)
+		}
+	}
+
+	// Versions from older releases have no provenance to show
+	m.versionCursor = 1
+	if view := m.View(); contains(view, "dev@laptop") {
+		t.Error("View should not show provenance for a version without it")
+	}
+}
+
+// newAnnotateModel returns a model showing two versions of my-project.
+func newAnnotateModel(svc *mocks.MockTUIService) *Model {
+	svc.Versions = map[string][]ports.TUIVersionInfo{
+		"my-project": {
+			{File: "20240115-120000.zip", Labels: []string{"old", "keep-me"}},
+			{File: "20240114-120000.zip", Pinned: true, Note: "before the big refactor"},
+		},
+	}
+	m := NewModelWithConfig(&config.Config{}, svc)
+	m.selectedProject = "my-project"
+	_ = m.loadVersions()
+	m.width = 80
+	m.height = 24
+	m.view = VersionsView
+	return m
+}
+
+func TestPinVersion(t *testing.T) {
+	svc := mocks.NewMockTUIService()
+	m := newAnnotateModel(svc)
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'p'}})
+	if cmd == nil {
+		t.Fatal("p should return a command")
+	}
+	if msg := cmd().(statusMsg); msg.err || !contains(msg.msg, "Pinned 20240115-120000") {
+		t.Errorf("status = %+v, expected pin confirmation", msg)
+	}
+	if len(svc.AnnotateCalls) != 1 {
+		t.Fatalf("AnnotateCalls = %d, expected 1", len(svc.AnnotateCalls))
+	}
+	call := svc.AnnotateCalls[0]
+	if call.Project != "my-project" || call.Version != "20240115-120000" || call.Annotation.Pinned == nil || !*call.Annotation.Pinned {
+		t.Errorf("call = %+v, expected to pin the selected version", call)
+	}
+
+	// A pinned version is unpinned
+	m.versionCursor = 1
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'p'}})
+	cmd()
+	if p := svc.AnnotateCalls[1].Annotation.Pinned; p == nil || *p {
+		t.Errorf("annotation = %+v, expected to unpin", svc.AnnotateCalls[1].Annotation)
+	}
+}
+
+func TestEditLabels(t *testing.T) {
+	svc := mocks.NewMockTUIService()
+	m := newAnnotateModel(svc)
+
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}})
+	if m.annotating != annotateLabels || m.annotateInput.Value() != "old, keep-me" {
+		t.Fatalf("annotating = %d, input %q, expected the current labels", m.annotating, m.annotateInput.Value())
+	}
+	if view := m.View(); !contains(view, "Labels (comma-separated)") || !contains(view, "[enter] save") {
+		t.Errorf("View should show the label input, got %q", view)
+	}
+
+	// Keys go to the input, not the key bindings
+	m.annotateInput.SetValue("keep-me, release")
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'q'}})
+	if m.quitting {
+		t.Fatal("typing q should not quit")
+	}
+	m.annotateInput.SetValue("keep-me, release")
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.annotating != annotateNone || cmd == nil {
+		t.Fatal("enter should save and close the input")
+	}
+	cmd()
+	a := svc.AnnotateCalls[0].Annotation
+	if strings.Join(a.AddLabels, ",") != "keep-me,release" || strings.Join(a.RemoveLabels, ",") != "old" {
+		t.Errorf("annotation = %+v, expected release added and old removed", a)
+	}
+}
+
+func TestEditNote(t *testing.T) {
+	svc := mocks.NewMockTUIService()
+	m := newAnnotateModel(svc)
+	m.versionCursor = 1
+
+	if view := m.View(); !contains(view, "★") || !contains(view, "before the big refactor") || !contains(view, "[old, keep-me]") {
+		t.Errorf("View should show the pin, labels and selected note, got %q", view)
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	if m.annotating != annotateNote || m.annotateInput.Value() != "before the big refactor" {
+		t.Fatalf("annotating = %d, input %q, expected the current note", m.annotating, m.annotateInput.Value())
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.annotating != annotateNone || len(svc.AnnotateCalls) != 0 {
+		t.Error("esc should cancel without saving")
+	}
+
+	svc.AnnotateError = errors.New("project is locked")
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	m.annotateInput.SetValue("")
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if msg := cmd().(statusMsg); !msg.err || !contains(msg.msg, "locked") {
+		t.Errorf("status = %+v, expected the error", msg)
+	}
+	if n := svc.AnnotateCalls[0].Annotation.Note; n == nil || *n != "" {
+		t.Errorf("annotation = %+v, expected an empty note to clear it", svc.AnnotateCalls[0].Annotation)
+	}
+}
+
+// newRestoreModel returns a model showing a diff of my-project with a
+// modified and a deleted file.
+func newRestoreModel(svc *mocks.MockTUIService) *Model {
+	m := NewModelWithConfig(&config.Config{}, svc)
+	m.selectedProject = "my-project"
+	m.width = 80
+	m.height = 24
+	m.view = DiffResultView
+	m.diffResult = &DiffResult{
+		Version1: "20240114-120000",
+		Version2: "20240115-120000",
+		Changes: []FileChange{
+			{Path: "main.go", Status: 'M'},
+			{Path: "old.go", Status: 'D'},
+		},
+	}
+	return m
+}
+
+func TestRestoreFromDiff(t *testing.T) {
+	svc := mocks.NewMockTUIService()
+	svc.RestoreResult = ports.TUIRestoreResult{Restored: 1}
+	m := newRestoreModel(svc)
+
+	if view := m.View(); !contains(view, "[R] restore file") {
+		t.Errorf("View should offer restore, got %q", view)
+	}
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'R'}})
+	if cmd == nil {
+		t.Fatal("R should return a command")
+	}
+	m.Update(cmd())
+	if m.statusErr || !contains(m.statusMsg, "Restored main.go from 20240115-120000") {
+		t.Errorf("status = %q, expected restore confirmation", m.statusMsg)
+	}
+
+	// Deleted files come from the version that still has them
+	m.diffCursor = 1
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'R'}})
+	cmd()
+	want := mocks.RestoreCall{Project: "my-project", Version: "20240114-120000", Path: "old.go"}
+	if len(svc.RestoreCalls) != 2 || svc.RestoreCalls[1] != want {
+		t.Errorf("RestoreCalls = %+v, expected %+v last", svc.RestoreCalls, want)
+	}
+}
+
+func TestRestoreFromFileDiffConfirmsOverwrite(t *testing.T) {
+	svc := mocks.NewMockTUIService()
+	svc.RestoreResult = ports.TUIRestoreResult{Conflicts: []string{"main.go"}}
+	m := newRestoreModel(svc)
+	m.view = FileDiffView
+	m.fileDiffResult = &FileDiffResult{Path: "main.go", Version1: "20240114-120000", Version2: "20240115-120000"}
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'R'}})
+	m.Update(cmd())
+	if m.pendingRestore == nil || !contains(m.statusMsg, "Overwrite? (y/n)") {
+		t.Fatalf("status = %q, expected an overwrite prompt", m.statusMsg)
+	}
+
+	// n cancels without restoring
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	if m.pendingRestore != nil || len(svc.RestoreCalls) != 1 {
+		t.Errorf("n should cancel, got %d calls", len(svc.RestoreCalls))
+	}
+
+	// y overwrites
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'R'}})
+	m.Update(cmd())
+	svc.RestoreResult = ports.TUIRestoreResult{Restored: 1, Overwritten: 1}
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+	if cmd == nil {
+		t.Fatal("y should return a command")
+	}
+	m.Update(cmd())
+	if last := svc.RestoreCalls[len(svc.RestoreCalls)-1]; !last.Overwrite || last.Path != "main.go" {
+		t.Errorf("call = %+v, expected main.go to be overwritten", last)
+	}
+	if !contains(m.statusMsg, "1 overwritten") {
+		t.Errorf("status = %q, expected restore confirmation", m.statusMsg)
+	}
+}
+
+func TestEscCancelsRunningRestore(t *testing.T) {
+	svc := mocks.NewMockTUIService()
+	svc.RestoreWaitsForCancel = true
+	m := newRestoreModel(svc)
+
+	_, wait := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'R'}})
+	if wait == nil {
+		t.Fatal("R should return a command")
+	}
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'R'}})
+	if msg := cmd().(statusMsg); !msg.err || !contains(msg.msg, "already running") {
+		t.Errorf("second restore status = %+v, expected already running", msg)
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.restoreDone != nil {
+		t.Error("esc should wait for the restore to stop")
+	}
+	if m.view != DiffResultView {
+		t.Errorf("view = %v, esc should only stop the restore", m.view)
+	}
+	m.Update(wait())
+	if m.statusErr || m.statusMsg != "Restore cancelled" {
+		t.Errorf("status = %q, expected the restore to be cancelled", m.statusMsg)
+	}
+}
+
+func TestQuitCancelsRunningRestore(t *testing.T) {
+	svc := mocks.NewMockTUIService()
+	svc.RestoreWaitsForCancel = true
+	m := newRestoreModel(svc)
+
+	_, wait := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'R'}})
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+	if m.restoreDone != nil || !m.quitting {
+		t.Error("quitting should wait for the restore to stop")
+	}
+	if msg, ok := wait().(restoreMsg); !ok || !errors.Is(msg.err, context.Canceled) {
+		t.Errorf("final message = %+v, expected a cancelled restore", msg)
+	}
+}
+
+// newRecoverModel returns a model showing the versions of my-project.
+func newRecoverModel(svc *mocks.MockTUIService) *Model {
+	m := NewModelWithConfig(&config.Config{}, svc)
+	m.selectedProject = "my-project"
+	m.width = 80
+	m.height = 24
+	m.view = VersionsView
+	m.versions = []VersionItem{{File: "20240115-120000.zip"}, {File: "20240114-120000.zip"}}
+	m.versionCursor = 1
+	return m
+}
+
+// typeKeys sends each rune of s to the model as a key press.
+func typeKeys(m *Model, s string) {
+	for _, r := range s {
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+}
+
+func TestRecoverFromVersionsView(t *testing.T) {
+	svc := mocks.NewMockTUIService()
+	svc.RecoverPreview = ports.TUIRecoverPreview{Dir: "/code/my-project", Added: 2, Overwritten: 3, Removed: 1, Unchanged: 10, Newer: 1}
+	m := newRecoverModel(svc)
+
+	if view := m.View(); !contains(view, "[R] recover") {
+		t.Errorf("View should offer recovery, got %q", view)
+	}
+
+	typeKeys(m, "R")
+	if m.recovering == nil || m.recovering.version != "20240114-120000" {
+		t.Fatalf("recovering = %+v, expected the selected version", m.recovering)
+	}
+	if view := m.View(); !contains(view, "Recover my-project from 20240114-120000") || !contains(view, "[a] archive current") {
+		t.Errorf("View should ask how to recover, got %q", view)
+	}
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	if cmd == nil {
+		t.Fatal("a should return a command")
+	}
+	m.Update(cmd())
+	if view := m.View(); !contains(view, "6 file(s) will change in /code/my-project: 2 added, 3 overwritten, 1 archived") {
+		t.Errorf("View should preview the changes, got %q", view)
+	}
+	if view := m.View(); !contains(view, "1 overwritten file(s) are newer locally") {
+		t.Errorf("View should warn about newer local files, got %q", view)
+	}
+	if len(svc.RecoverCalls) != 0 {
+		t.Fatal("nothing should be recovered before confirming")
+	}
+
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+	if cmd == nil {
+		t.Fatal("y should return a command")
+	}
+	m.Update(cmd())
+	want := mocks.RecoverCall{Project: "my-project", Version: "20240114-120000", Opts: ports.TUIRecoverOptions{Archive: true}}
+	if len(svc.RecoverCalls) != 1 || svc.RecoverCalls[0] != want {
+		t.Errorf("RecoverCalls = %+v, expected %+v", svc.RecoverCalls, want)
+	}
+	if m.recovering != nil || m.statusErr || !contains(m.statusMsg, "Recovered my-project from 20240114-120000") {
+		t.Errorf("status = %q, expected recovery confirmation", m.statusMsg)
+	}
+}
+
+func TestRecoverToOtherDirectory(t *testing.T) {
+	svc := mocks.NewMockTUIService()
+	svc.RecoverPreview = ports.TUIRecoverPreview{Dir: "/tmp/old", Unchanged: 1}
+	m := newRecoverModel(svc)
+
+	typeKeys(m, "Ro/tmp/old")
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m.Update(cmd())
+	if len(svc.PreviewRecoverCalls) != 1 || svc.PreviewRecoverCalls[0].Opts.Target != "/tmp/old" {
+		t.Fatalf("PreviewRecoverCalls = %+v, expected /tmp/old", svc.PreviewRecoverCalls)
+	}
+	// A directory that already holds files is refused before confirming
+	if m.recovering == nil || m.recovering.step != recoverTyping || !contains(m.statusMsg, "is not empty") {
+		t.Fatalf("status = %q, expected another directory to be asked for", m.statusMsg)
+	}
+
+	svc.RecoverPreview = ports.TUIRecoverPreview{Dir: "/tmp/new", Added: 4}
+	m.recovering.input.SetValue("/tmp/new")
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m.Update(cmd())
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+	m.Update(cmd())
+	if len(svc.RecoverCalls) != 1 || svc.RecoverCalls[0].Opts != (ports.TUIRecoverOptions{Target: "/tmp/new"}) {
+		t.Errorf("RecoverCalls = %+v, expected a recovery into /tmp/new", svc.RecoverCalls)
+	}
+}
+
+func TestRecoverCancelAndFailure(t *testing.T) {
+	svc := mocks.NewMockTUIService()
+	m := newRecoverModel(svc)
+
+	// n at the preview cancels without recovering
+	typeKeys(m, "Rw")
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'w'}})
+	if cmd != nil {
+		t.Error("keys during the preview should be ignored")
+	}
+	m.Update(recoverPreviewMsg{version: "20240114-120000"})
+	typeKeys(m, "n")
+	if m.recovering != nil || len(svc.RecoverCalls) != 0 || m.statusMsg != "Recovery cancelled" {
+		t.Errorf("n should cancel, got status %q and %d calls", m.statusMsg, len(svc.RecoverCalls))
+	}
+
+	// A failed preview ends the flow
+	svc.RecoverError = errors.New("project already exists")
+	typeKeys(m, "R")
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'w'}})
+	m.Update(cmd())
+	if m.recovering != nil || !m.statusErr || !contains(m.statusMsg, "Preview failed: project already exists") {
+		t.Errorf("status = %q, expected the failure to be reported", m.statusMsg)
+	}
+}
+
+func TestEscCancelsRunningRecovery(t *testing.T) {
+	svc := mocks.NewMockTUIService()
+	svc.RestoreWaitsForCancel = true
+	m := newRecoverModel(svc)
+
+	typeKeys(m, "Rw")
+	m.Update(recoverPreviewMsg{version: "20240114-120000", preview: ports.TUIRecoverPreview{Dir: "/code/my-project"}})
+	_, wait := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+	if wait == nil || m.restoreDone == nil {
+		t.Fatal("y should start the recovery")
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.restoreDone != nil {
+		t.Error("esc should wait for the recovery to stop")
+	}
+	if m.view != VersionsView {
+		t.Errorf("view = %v, esc should only stop the recovery", m.view)
+	}
+	m.Update(wait())
+	if m.statusErr || m.statusMsg != "Recovery cancelled" {
+		t.Errorf("status = %q, expected the recovery to be cancelled", m.statusMsg)
+	}
+}
+
 func TestRenderVersionsViewEmpty(t *testing.T) {
 	svc := mocks.NewMockTUIService()
 	m := NewModelWithConfig(&config.Config{}, svc)
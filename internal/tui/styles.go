@@ -12,6 +12,7 @@ var (
 	secondaryColor = lipgloss.Color("#10B981") // Green
 	mutedColor     = lipgloss.Color("#6B7280") // Gray
 	errorColor     = lipgloss.Color("#EF4444") // Red
+	warningColor   = lipgloss.Color("#F59E0B") // Amber
 )
 
 // Styles
@@ -51,6 +52,9 @@ var (
 			Foreground(errorColor).
 			Bold(true)
 
+	warningStyle = lipgloss.NewStyle().
+			Foreground(warningColor)
+
 	// Diff view styles
 	addedStyle = lipgloss.NewStyle().
 			Foreground(secondaryColor) // Green for added lines